@@ -0,0 +1,109 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxAuditUsers caps the number of distinct user labels of the admission metrics. Requests of further users are
+	// counted as otherAuditUser.
+	maxAuditUsers  = 100
+	otherAuditUser = "other"
+)
+
+// AuditUser returns the label of the user of an admission request in the admission metrics. Service accounts and
+// other users keep their name, and nodes are counted together as "system:nodes" since each node would otherwise
+// be its own label.
+func AuditUser(user AdmissionUserInfo) string {
+	switch {
+	case user.Username == "":
+		return "system:anonymous"
+	case strings.HasPrefix(user.Username, "system:node:"):
+		return "system:nodes"
+	default:
+		return user.Username
+	}
+}
+
+// ObserveAdmission records an admission request and its decision, labeled with the bucketed user of the request,
+// so that operators see which clients cause the most churn of their custom resources
+func (m *Metrics) ObserveAdmission(request *AdmissionRequest, response *AdmissionResponse) {
+	if m == nil || request == nil || response == nil {
+		return
+	}
+	m.admissionRequests.WithLabelValues(request.Resource.Resource, request.Operation, m.auditUser(request.UserInfo),
+		strconv.FormatBool(response.Allowed)).Inc()
+}
+
+// auditUser returns the label of the user, or otherAuditUser once the labels of maxAuditUsers users are recorded
+func (m *Metrics) auditUser(user AdmissionUserInfo) string {
+	label := AuditUser(user)
+	m.auditUsersLock.Lock()
+	defer m.auditUsersLock.Unlock()
+	if !m.auditUsers[label] {
+		if len(m.auditUsers) >= maxAuditUsers {
+			return otherAuditUser
+		}
+		m.auditUsers[label] = true
+	}
+	return label
+}
+
+// InstrumentAdmission wraps the handler of an admission webhook, such as one created by
+// NewMutatingWebhookHandler, to record each request in the admission metrics
+func (m *Metrics) InstrumentAdmission(handler http.Handler) http.Handler {
+	if m == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read the request", http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+
+		request := &AdmissionReview{}
+		response := &AdmissionReview{}
+		if json.Unmarshal(body, request) != nil || json.Unmarshal(recorder.body.Bytes(), response) != nil {
+			return
+		}
+		m.ObserveAdmission(request.Request, response.Response)
+	})
+}
+
+// responseRecorder keeps a copy of the body written to the response
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
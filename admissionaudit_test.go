@@ -0,0 +1,94 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditUser(t *testing.T) {
+	assert.Equal(t, "system:anonymous", AuditUser(AdmissionUserInfo{}))
+	assert.Equal(t, "system:nodes", AuditUser(AdmissionUserInfo{Username: "system:node:worker-1"}))
+	assert.Equal(t, "system:serviceaccount:ci:deployer", AuditUser(AdmissionUserInfo{Username: "system:serviceaccount:ci:deployer"}))
+	assert.Equal(t, "alice", AuditUser(AdmissionUserInfo{Username: "alice"}))
+}
+
+// admissionCounts returns the admission request counts of the metrics by their labels
+func admissionCounts(t *testing.T, m *Metrics) map[string]float64 {
+	families, err := m.Registry().Gather()
+	assert.NoError(t, err)
+	counts := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "test_admission_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			key := fmt.Sprintf("%s/%s/%s/%s", labels["resource"], labels["operation"], labels["user"], labels["allowed"])
+			counts[key] = metric.GetCounter().GetValue()
+		}
+	}
+	return counts
+}
+
+func TestInstrumentAdmission(t *testing.T) {
+	m := NewMetrics("test")
+	handler := m.InstrumentAdmission(NewMutatingWebhookHandler(func(request *AdmissionRequest) ([]JSONPatchOperation, error) {
+		if request.Name == "denied" {
+			return nil, errors.New("not allowed")
+		}
+		return nil, nil
+	}))
+
+	send := func(name, user string) {
+		body := []byte(`{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1","name":"` + name +
+			`","operation":"UPDATE","resource":{"group":"example.com","version":"v1","resource":"samples"},"userInfo":{"username":"` + user + `"}}}`)
+		r := httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	send("a", "system:serviceaccount:ci:deployer")
+	send("b", "system:serviceaccount:ci:deployer")
+	send("denied", "system:node:worker-1")
+
+	counts := admissionCounts(t, m)
+	assert.Equal(t, 2.0, counts["samples/UPDATE/system:serviceaccount:ci:deployer/true"])
+	assert.Equal(t, 1.0, counts["samples/UPDATE/system:nodes/false"])
+
+	// users beyond the cap are counted together
+	for i := 0; i < maxAuditUsers; i++ {
+		send("a", fmt.Sprintf("user-%d", i))
+	}
+	counts = admissionCounts(t, m)
+	assert.Equal(t, 2.0, counts["samples/UPDATE/other/true"])
+	assert.Equal(t, 0.0, counts["samples/UPDATE/user-99/true"])
+
+	// a nil metrics leaves the handler as it is
+	var nilMetrics *Metrics
+	assert.NotNil(t, nilMetrics.InstrumentAdmission(http.NotFoundHandler()))
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// ValidationRule is a CEL expression that must be true for a custom resource to be admitted, such as
+// "object.spec.replicas <= 5". The expression can read object, oldObject, and request like in a
+// ValidatingAdmissionPolicy.
+type ValidationRule struct {
+	Expression string
+
+	// Message returned when the expression is false. Defaults to a message with the expression.
+	Message string
+
+	// Reason of the rejection, such as "Invalid" or "Forbidden". Defaults to "Invalid".
+	Reason string
+}
+
+// AdmissionPolicyOptions configure the admission policies generated for custom resources
+type AdmissionPolicyOptions struct {
+	// Operations validated by the policies. Defaults to CREATE and UPDATE.
+	Operations []string
+
+	// FailurePolicy applied when an expression cannot be evaluated. Defaults to FailurePolicyFail.
+	FailurePolicy FailurePolicy
+
+	// ValidationActions of the bindings, such as "Deny", "Warn", or "Audit". Defaults to Deny.
+	ValidationActions []string
+}
+
+// admissionPolicyJSON is the subset of a ValidatingAdmissionPolicy or its binding the kit writes. The typed clientset
+// predates both, so they are sent as raw JSON like the webhook configurations.
+type admissionPolicyJSON struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   metav1.ObjectMeta `json:"metadata"`
+	Spec       interface{}       `json:"spec"`
+}
+
+type admissionPolicySpec struct {
+	FailurePolicy    FailurePolicy          `json:"failurePolicy"`
+	MatchConstraints admissionPolicyMatch   `json:"matchConstraints"`
+	Validations      []admissionPolicyCheck `json:"validations"`
+}
+
+type admissionPolicyMatch struct {
+	ResourceRules []webhookRule `json:"resourceRules"`
+}
+
+type admissionPolicyCheck struct {
+	Expression string `json:"expression"`
+	Message    string `json:"message"`
+	Reason     string `json:"reason"`
+}
+
+type admissionPolicyBindingSpec struct {
+	PolicyName        string   `json:"policyName"`
+	ValidationActions []string `json:"validationActions"`
+}
+
+// admissionPolicyVersions are the versions of ValidatingAdmissionPolicy by preference
+var admissionPolicyVersions = []string{"v1", "v1beta1", "v1alpha1"}
+
+// CreateAdmissionPolicies creates or updates a ValidatingAdmissionPolicy and its binding enforcing the validation
+// rules of each resource that has any. The policies are named like the CRDs of the resources. It fails when the
+// cluster does not serve admission policies, which requires Kubernetes 1.26 with the alpha API enabled, 1.28 with
+// the beta API enabled, or 1.30.
+func CreateAdmissionPolicies(context Context, resources []CustomResource, opts AdmissionPolicyOptions) error {
+	version, err := admissionPolicyVersion(context)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, resource := range resources {
+		if len(resource.ValidationRules) == 0 {
+			continue
+		}
+		policy, binding := newAdmissionPolicy(version, resource, opts)
+		for _, obj := range []*admissionPolicyJSON{policy, binding} {
+			outcome, err := createAdmissionPolicyObject(context, version, obj)
+			if err != nil {
+				context.logger().Error(err, "failed to create admission policy", "resource", resource.Name, "kind", obj.Kind)
+				errs = append(errs, err)
+				continue
+			}
+			context.logger().Info("created admission policy", "resource", resource.Name, "kind", obj.Kind, "outcome", outcome)
+		}
+	}
+	return errorsUtil.NewAggregate(errs)
+}
+
+// admissionPolicyVersion returns the newest version of admissionregistration.k8s.io serving admission policies
+func admissionPolicyVersion(context Context) (string, error) {
+	for _, version := range admissionPolicyVersions {
+		list, err := context.Clientset.Discovery().ServerResourcesForGroupVersion(admissionRegistrationGroup + "/" + version)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", fmt.Errorf("failed to discover %s/%s. %+v", admissionRegistrationGroup, version, err)
+		}
+		if list == nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if resource.Name == "validatingadmissionpolicies" {
+				return version, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("the cluster does not serve validating admission policies. use a validating webhook instead")
+}
+
+func newAdmissionPolicy(version string, resource CustomResource, opts AdmissionPolicyOptions) (*admissionPolicyJSON, *admissionPolicyJSON) {
+	operations := opts.Operations
+	if len(operations) == 0 {
+		operations = []string{"CREATE", "UPDATE"}
+	}
+	failurePolicy := opts.FailurePolicy
+	if failurePolicy == "" {
+		failurePolicy = FailurePolicyFail
+	}
+	actions := opts.ValidationActions
+	if len(actions) == 0 {
+		actions = []string{"Deny"}
+	}
+
+	var validations []admissionPolicyCheck
+	for _, rule := range resource.ValidationRules {
+		check := admissionPolicyCheck{Expression: rule.Expression, Message: rule.Message, Reason: rule.Reason}
+		if check.Message == "" {
+			check.Message = fmt.Sprintf("failed rule: %s", rule.Expression)
+		}
+		if check.Reason == "" {
+			check.Reason = "Invalid"
+		}
+		validations = append(validations, check)
+	}
+
+	name := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
+	apiVersion := fmt.Sprintf("%s/%s", admissionRegistrationGroup, version)
+	policy := &admissionPolicyJSON{
+		APIVersion: apiVersion,
+		Kind:       "ValidatingAdmissionPolicy",
+		Metadata:   metav1.ObjectMeta{Name: name},
+		Spec: admissionPolicySpec{
+			FailurePolicy: failurePolicy,
+			MatchConstraints: admissionPolicyMatch{ResourceRules: []webhookRule{{
+				Operations:  operations,
+				APIGroups:   []string{resource.Group},
+				APIVersions: []string{resource.Version},
+				Resources:   []string{resource.Plural},
+			}}},
+			Validations: validations,
+		},
+	}
+	binding := &admissionPolicyJSON{
+		APIVersion: apiVersion,
+		Kind:       "ValidatingAdmissionPolicyBinding",
+		Metadata:   metav1.ObjectMeta{Name: name},
+		Spec:       admissionPolicyBindingSpec{PolicyName: name, ValidationActions: actions},
+	}
+	return policy, binding
+}
+
+// createAdmissionPolicyObject creates the policy or binding, or replaces the existing one
+func createAdmissionPolicyObject(context Context, version string, obj *admissionPolicyJSON) (InstallOutcome, error) {
+	path := fmt.Sprintf("/apis/%s/%s/%s", admissionRegistrationGroup, version, admissionPolicyPlural(obj.Kind))
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to serialize %s %s. %+v", obj.Kind, obj.Metadata.Name, err)
+	}
+
+	restcli := context.Clientset.Discovery().RESTClient()
+	_, err = restcli.Post().AbsPath(path).SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	if err == nil {
+		return OutcomeCreated, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return OutcomeFailed, fmt.Errorf("failed to create %s %s. %+v", obj.Kind, obj.Metadata.Name, err)
+	}
+
+	raw, err := restcli.Get().AbsPath(path, obj.Metadata.Name).DoRaw()
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to get %s %s. %+v", obj.Kind, obj.Metadata.Name, err)
+	}
+	existing := &admissionPolicyJSON{}
+	if err := json.Unmarshal(raw, existing); err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to parse %s %s. %+v", obj.Kind, obj.Metadata.Name, err)
+	}
+	obj.Metadata.ResourceVersion = existing.Metadata.ResourceVersion
+	if body, err = json.Marshal(obj); err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to serialize %s %s. %+v", obj.Kind, obj.Metadata.Name, err)
+	}
+	_, err = restcli.Put().AbsPath(path, obj.Metadata.Name).SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to update %s %s. %+v", obj.Kind, obj.Metadata.Name, err)
+	}
+	return OutcomeUpdated, nil
+}
+
+func admissionPolicyPlural(kind string) string {
+	if kind == "ValidatingAdmissionPolicyBinding" {
+		return "validatingadmissionpolicybindings"
+	}
+	return "validatingadmissionpolicies"
+}
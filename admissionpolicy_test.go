@@ -0,0 +1,85 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewAdmissionPolicy(t *testing.T) {
+	resource := CustomResource{Name: "sample", Plural: "samples", Group: "example.com", Version: "v1alpha1", Kind: "Sample",
+		ValidationRules: []ValidationRule{
+			{Expression: "object.spec.replicas <= 5"},
+			{Expression: "object.spec.size == oldObject.spec.size", Message: "size is immutable", Reason: "Forbidden"},
+		}}
+
+	policy, binding := newAdmissionPolicy("v1beta1", resource, AdmissionPolicyOptions{ValidationActions: []string{"Warn"}})
+	raw, err := json.Marshal(policy)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"apiVersion": "admissionregistration.k8s.io/v1beta1",
+		"kind": "ValidatingAdmissionPolicy",
+		"metadata": {"name": "samples.example.com", "creationTimestamp": null},
+		"spec": {
+			"failurePolicy": "Fail",
+			"matchConstraints": {"resourceRules": [{"operations": ["CREATE", "UPDATE"], "apiGroups": ["example.com"],
+				"apiVersions": ["v1alpha1"], "resources": ["samples"]}]},
+			"validations": [
+				{"expression": "object.spec.replicas <= 5", "message": "failed rule: object.spec.replicas <= 5", "reason": "Invalid"},
+				{"expression": "object.spec.size == oldObject.spec.size", "message": "size is immutable", "reason": "Forbidden"}
+			]
+		}
+	}`, string(raw))
+
+	raw, err = json.Marshal(binding)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"apiVersion": "admissionregistration.k8s.io/v1beta1",
+		"kind": "ValidatingAdmissionPolicyBinding",
+		"metadata": {"name": "samples.example.com", "creationTimestamp": null},
+		"spec": {"policyName": "samples.example.com", "validationActions": ["Warn"]}
+	}`, string(raw))
+	assert.Equal(t, "validatingadmissionpolicybindings", admissionPolicyPlural(binding.Kind))
+}
+
+func TestAdmissionPolicyVersion(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	context := Context{Clientset: clientset}
+	discovery := clientset.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "admissionregistration.k8s.io/v1",
+		APIResources: []metav1.APIResource{{Name: "validatingwebhookconfigurations"}},
+	}}
+	_, err := admissionPolicyVersion(context)
+	assert.Error(t, err)
+
+	discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{
+		GroupVersion: "admissionregistration.k8s.io/v1beta1",
+		APIResources: []metav1.APIResource{{Name: "validatingadmissionpolicies"}},
+	})
+	version, err := admissionPolicyVersion(context)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1beta1", version)
+
+	// resources without rules get no policy
+	assert.NoError(t, CreateAdmissionPolicies(context, []CustomResource{exampleResource}, AdmissionPolicyOptions{}))
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/ghodss/yaml"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	defaultSLOWindow     = 5 * time.Minute
+	defaultAlertFor      = 15 * time.Minute
+	defaultAlertSeverity = "warning"
+)
+
+// SLOKind is the indicator measured by an SLO
+type SLOKind string
+
+const (
+	// SLOReconcileSuccess is the ratio of reconciles of the resource that succeed. The objective is the ratio, such
+	// as 0.99.
+	SLOReconcileSuccess SLOKind = "ReconcileSuccess"
+
+	// SLOReconcileLatency is the duration of reconciles of the resource. The objective is the quantile, such as 0.95,
+	// that must take less than the threshold in seconds.
+	SLOReconcileLatency SLOKind = "ReconcileLatency"
+
+	// SLOQueueDepth is the number of keys waiting in the work queue of the resource, which must stay below the
+	// threshold
+	SLOQueueDepth SLOKind = "QueueDepth"
+)
+
+// SLO declares an objective of the operator that is alerted on when it is not met. The indicators are the metrics
+// recorded by Metrics.
+type SLO struct {
+	// Name of the alert, in CamelCase
+	Name        string
+	Description string
+	Kind        SLOKind
+
+	// Resource is the name of the custom resource, as in the resource label of the metrics
+	Resource  string
+	Objective float64
+	Threshold float64
+
+	// Window is the range the rates are computed over. Defaults to 5m.
+	Window time.Duration
+
+	// For is how long the objective is missed before the alert fires. Defaults to 15m.
+	For time.Duration
+
+	// Severity labels the alert. Defaults to "warning".
+	Severity string
+}
+
+// AlertRule is an alerting rule in the format of Prometheus rule files and of the PrometheusRule resource
+type AlertRule struct {
+	Alert       string            `json:"alert"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// AlertRuleGroup is a named group of alerting rules
+type AlertRuleGroup struct {
+	Name  string      `json:"name"`
+	Rules []AlertRule `json:"rules"`
+}
+
+// alertNameRE matches the CamelCase alert names
+var alertNameRE = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// Validate returns an aggregate of the problems of the SLO, or nil if there are none
+func (s SLO) Validate() error {
+	var errs []error
+	if !alertNameRE.MatchString(s.Name) {
+		errs = append(errs, fmt.Errorf("SLO name %q is not CamelCase", s.Name))
+	}
+	if s.Resource == "" {
+		errs = append(errs, fmt.Errorf("SLO %s has no resource", s.Name))
+	}
+	switch s.Kind {
+	case SLOReconcileSuccess:
+		if s.Objective <= 0 || s.Objective >= 1 {
+			errs = append(errs, fmt.Errorf("SLO %s objective %v is not a ratio between 0 and 1", s.Name, s.Objective))
+		}
+	case SLOReconcileLatency:
+		if s.Objective <= 0 || s.Objective >= 1 {
+			errs = append(errs, fmt.Errorf("SLO %s objective %v is not a quantile between 0 and 1", s.Name, s.Objective))
+		}
+		if s.Threshold <= 0 {
+			errs = append(errs, fmt.Errorf("SLO %s has no latency threshold", s.Name))
+		}
+	case SLOQueueDepth:
+		if s.Threshold <= 0 {
+			errs = append(errs, fmt.Errorf("SLO %s has no queue depth threshold", s.Name))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("SLO %s has unknown kind %q", s.Name, s.Kind))
+	}
+	return errorsUtil.NewAggregate(errs)
+}
+
+// AlertRules generates the alerting rules of the SLOs and of the condition types of the catalog that declare an
+// alert status. The metrics namespace must be the one given to NewMetrics. The catalog is optional.
+func AlertRules(group, metricsNamespace string, slos []SLO, catalog *ConditionCatalog) (AlertRuleGroup, error) {
+	rules := AlertRuleGroup{Name: group, Rules: []AlertRule{}}
+	var errs []error
+	for _, slo := range slos {
+		if err := slo.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rules.Rules = append(rules.Rules, sloAlertRule(metricsNamespace, slo))
+	}
+	if catalog != nil {
+		for _, t := range catalog.Types() {
+			if t.AlertStatus != "" {
+				rules.Rules = append(rules.Rules, conditionAlertRule(metricsNamespace, t))
+			}
+		}
+	}
+	return rules, errorsUtil.NewAggregate(errs)
+}
+
+func sloAlertRule(metricsNamespace string, slo SLO) AlertRule {
+	window := promDuration(durationOrDefault(slo.Window, defaultSLOWindow))
+	selector := fmt.Sprintf(`{resource=%q}`, slo.Resource)
+
+	rule := AlertRule{
+		Alert:  slo.Name,
+		For:    promDuration(durationOrDefault(slo.For, defaultAlertFor)),
+		Labels: map[string]string{"severity": stringOrDefault(slo.Severity, defaultAlertSeverity), "resource": slo.Resource},
+	}
+	switch slo.Kind {
+	case SLOReconcileSuccess:
+		total := metricName(metricsNamespace, "reconcile_total")
+		rule.Expr = fmt.Sprintf(`sum(rate(%s{resource=%q,result=%q}[%s])) / sum(rate(%s%s[%s])) > (1 - %v)`,
+			total, slo.Resource, resultError, window, total, selector, window, slo.Objective)
+		rule.Annotations = map[string]string{
+			"summary": fmt.Sprintf("Less than %v%% of the reconciles of %s succeed", slo.Objective*100, slo.Resource),
+		}
+	case SLOReconcileLatency:
+		rule.Expr = fmt.Sprintf(`histogram_quantile(%v, sum(rate(%s%s[%s])) by (le)) > %v`,
+			slo.Objective, metricName(metricsNamespace, "reconcile_duration_seconds_bucket"), selector, window, slo.Threshold)
+		rule.Annotations = map[string]string{
+			"summary": fmt.Sprintf("The %vth percentile of the reconciles of %s takes more than %vs", slo.Objective*100, slo.Resource, slo.Threshold),
+		}
+	case SLOQueueDepth:
+		rule.Expr = fmt.Sprintf(`max(%s%s) > %v`, metricName(metricsNamespace, "workqueue_depth"), selector, slo.Threshold)
+		rule.Annotations = map[string]string{
+			"summary": fmt.Sprintf("More than %v keys of %s are waiting to be reconciled", slo.Threshold, slo.Resource),
+		}
+	}
+	if slo.Description != "" {
+		rule.Annotations["description"] = slo.Description
+	}
+	return rule
+}
+
+func conditionAlertRule(metricsNamespace string, t ConditionType) AlertRule {
+	return AlertRule{
+		Alert: fmt.Sprintf("Condition%s%s", t.Type, t.AlertStatus),
+		Expr: fmt.Sprintf(`%s{type=%q,status=%q} == 1`,
+			metricName(metricsNamespace, "resource_condition"), t.Type, string(t.AlertStatus)),
+		For:    promDuration(durationOrDefault(t.AlertFor, defaultAlertFor)),
+		Labels: map[string]string{"severity": stringOrDefault(t.AlertSeverity, defaultAlertSeverity)},
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("{{ $labels.resource }} {{ $labels.namespace }}/{{ $labels.name }} has condition %s=%s",
+				t.Type, t.AlertStatus),
+			"description": t.Description,
+		},
+	}
+}
+
+// ExportAlertRules writes the groups as a Prometheus rule file
+func ExportAlertRules(out io.Writer, groups ...AlertRuleGroup) error {
+	data, err := yaml.Marshal(map[string]interface{}{"groups": groups})
+	if err != nil {
+		return fmt.Errorf("failed to serialize alert rules. %+v", err)
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// ExportPrometheusRule writes the groups as a PrometheusRule resource of the Prometheus operator, so the alerts are
+// installed with the operator
+func ExportPrometheusRule(name, namespace string, out io.Writer, groups ...AlertRuleGroup) error {
+	rule := map[string]interface{}{
+		"apiVersion": "monitoring.coreos.com/v1",
+		"kind":       "PrometheusRule",
+		"metadata":   map[string]string{"name": name, "namespace": namespace},
+		"spec":       map[string]interface{}{"groups": groups},
+	}
+	data, err := yaml.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("failed to serialize prometheus rule %s. %+v", name, err)
+	}
+	_, err = fmt.Fprintf(out, "---\n%s", data)
+	return err
+}
+
+// metricName returns the full name of a metric created by NewMetrics with the namespace
+func metricName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "_" + name
+}
+
+// promDuration formats the duration in the largest unit of the Prometheus duration format that is exact
+func promDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
+
+func stringOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAlertRules(t *testing.T) {
+	catalog := newTestConditionCatalog()
+	catalog.Register(ConditionType{Type: "Available", Description: "The cluster is available", AlertStatus: v1.ConditionFalse, AlertFor: 5 * time.Minute, AlertSeverity: "critical"})
+	slos := []SLO{
+		{Name: "SampleReconcileErrors", Kind: SLOReconcileSuccess, Resource: "sample", Objective: 0.99},
+		{Name: "SampleReconcileSlow", Kind: SLOReconcileLatency, Resource: "sample", Objective: 0.95, Threshold: 30, Window: 10 * time.Minute},
+		{Name: "SampleBacklog", Kind: SLOQueueDepth, Resource: "sample", Threshold: 100, For: time.Hour, Description: "Reconciles are falling behind"},
+	}
+
+	group, err := AlertRules("sample-operator", "sample", slos, catalog)
+	assert.NoError(t, err)
+	assert.Equal(t, "sample-operator", group.Name)
+	assert.Equal(t, 4, len(group.Rules))
+
+	assert.Equal(t, `sum(rate(sample_reconcile_total{resource="sample",result="error"}[5m])) / sum(rate(sample_reconcile_total{resource="sample"}[5m])) > (1 - 0.99)`, group.Rules[0].Expr)
+	assert.Equal(t, "15m", group.Rules[0].For)
+	assert.Equal(t, "warning", group.Rules[0].Labels["severity"])
+
+	assert.Equal(t, `histogram_quantile(0.95, sum(rate(sample_reconcile_duration_seconds_bucket{resource="sample"}[10m])) by (le)) > 30`, group.Rules[1].Expr)
+
+	assert.Equal(t, `max(sample_workqueue_depth{resource="sample"}) > 100`, group.Rules[2].Expr)
+	assert.Equal(t, "1h", group.Rules[2].For)
+	assert.Equal(t, "Reconciles are falling behind", group.Rules[2].Annotations["description"])
+
+	// only the condition types with an alert status are alerted on
+	assert.Equal(t, "ConditionAvailableFalse", group.Rules[3].Alert)
+	assert.Equal(t, `sample_resource_condition{type="Available",status="False"} == 1`, group.Rules[3].Expr)
+	assert.Equal(t, "5m", group.Rules[3].For)
+	assert.Equal(t, "critical", group.Rules[3].Labels["severity"])
+}
+
+func TestAlertRulesInvalidSLO(t *testing.T) {
+	slos := []SLO{
+		{Name: "sample-errors", Kind: SLOReconcileSuccess, Resource: "sample", Objective: 99},
+		{Name: "SampleSlow", Kind: SLOReconcileLatency, Resource: "sample", Objective: 0.95},
+		{Name: "SampleBacklog", Kind: SLOQueueDepth, Resource: "sample", Threshold: 10},
+	}
+	group, err := AlertRules("sample-operator", "", slos, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not CamelCase")
+	assert.Contains(t, err.Error(), "SampleSlow has no latency threshold")
+
+	// the valid SLOs still get their rules
+	assert.Equal(t, 1, len(group.Rules))
+	assert.Equal(t, `max(workqueue_depth{resource="sample"}) > 10`, group.Rules[0].Expr)
+}
+
+func TestExportPrometheusRule(t *testing.T) {
+	group, err := AlertRules("sample-operator", "sample", []SLO{{Name: "SampleBacklog", Kind: SLOQueueDepth, Resource: "sample", Threshold: 100}}, nil)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, ExportPrometheusRule("sample-alerts", "monitoring", &out, group))
+	rule := struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Groups []AlertRuleGroup `json:"groups"`
+		} `json:"spec"`
+	}{}
+	assert.NoError(t, yaml.Unmarshal(bytes.TrimPrefix(out.Bytes(), []byte("---\n")), &rule))
+	assert.Equal(t, "PrometheusRule", rule.Kind)
+	assert.Equal(t, "sample-alerts", rule.Metadata.Name)
+	assert.Equal(t, "monitoring", rule.Metadata.Namespace)
+	assert.Equal(t, []AlertRuleGroup{group}, rule.Spec.Groups)
+
+	out.Reset()
+	assert.NoError(t, ExportAlertRules(&out, group))
+	file := struct {
+		Groups []AlertRuleGroup `json:"groups"`
+	}{}
+	assert.NoError(t, yaml.Unmarshal(out.Bytes(), &file))
+	assert.Equal(t, []AlertRuleGroup{group}, file.Groups)
+}
+
+func TestSetConditionsMetric(t *testing.T) {
+	m := NewMetrics("test")
+	series := func() map[string]float64 {
+		families, err := m.Registry().Gather()
+		assert.NoError(t, err)
+		values := map[string]float64{}
+		for _, family := range families {
+			if family.GetName() != "test_resource_condition" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := map[string]string{}
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				values[labels["namespace"]+"/"+labels["name"]+" "+labels["type"]+"="+labels["status"]] = metric.GetGauge().GetValue()
+			}
+		}
+		return values
+	}
+
+	m.SetConditions("sample", "default/a", []Condition{{Type: "Ready", Status: v1.ConditionFalse}, {Type: "Degraded", Status: v1.ConditionTrue}})
+	m.SetConditions("sample", "default/b", []Condition{{Type: "Ready", Status: v1.ConditionFalse}})
+	assert.Equal(t, map[string]float64{"default/a Ready=False": 1, "default/a Degraded=True": 1, "default/b Ready=False": 1}, series())
+
+	// the previous status and the types the resource no longer has are deleted
+	m.SetConditions("sample", "default/a", []Condition{{Type: "Ready", Status: v1.ConditionTrue}})
+	assert.Equal(t, map[string]float64{"default/a Ready=True": 1, "default/b Ready=False": 1}, series())
+
+	// the series of a deleted resource are deleted
+	m.DeleteConditions("sample", "default/b")
+	assert.Equal(t, map[string]float64{"default/a Ready=True": 1}, series())
+	m.DeleteConditions("sample", "default/unknown")
+
+	// controllers delete the series of their resources on delete events
+	m.SetConditions("example", "default/c", []Condition{{Type: "Ready", Status: v1.ConditionTrue}})
+	c := newController(Context{Metrics: m}, exampleResource, nil, nil, ControllerOptions{})
+	c.eventHandlers().DeleteFunc(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "c"}})
+	assert.Equal(t, map[string]float64{"default/a Ready=True": 1}, series())
+
+	// nil metrics are ignored
+	var nilMetrics *Metrics
+	nilMetrics.SetConditions("sample", "default/a", nil)
+	nilMetrics.DeleteConditions("sample", "default/a")
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ErrRangeExhausted is returned by an allocator when all values of its ranges are allocated or reserved
+var ErrRangeExhausted = errors.New("all values of the range are allocated")
+
+// AllocationRange is an inclusive range of values, such as ports 30000-32767
+type AllocationRange struct {
+	Start int64
+	End   int64
+}
+
+// Allocator hands out unique values, such as IPs, ports, or IDs, to owners like custom resources. The allocations
+// are kept in a state store so that they are unique across the replicas of the operator and survive restarts.
+type Allocator struct {
+	store    StateStore
+	ranges   []AllocationRange
+	reserved map[int64]bool
+}
+
+// NewAllocator creates an allocator of the values in the ranges, except the reserved ones
+func NewAllocator(store StateStore, ranges []AllocationRange, reserved ...int64) *Allocator {
+	a := &Allocator{store: store, ranges: ranges, reserved: map[int64]bool{}}
+	for _, value := range reserved {
+		a.reserved[value] = true
+	}
+	return a
+}
+
+// Allocate returns the value allocated to the owner, allocating the lowest free value if it has none
+func (a *Allocator) Allocate(owner string) (int64, error) {
+	var allocated int64
+	err := UpdateState(a.store, func(data map[string]string) error {
+		if value, ok := lookupAllocation(data, owner); ok {
+			allocated = value
+			return nil
+		}
+		for _, r := range a.ranges {
+			for value := r.Start; value <= r.End; value++ {
+				key := strconv.FormatInt(value, 10)
+				if _, taken := data[key]; taken || a.reserved[value] {
+					continue
+				}
+				data[key] = owner
+				allocated = value
+				return nil
+			}
+		}
+		return ErrRangeExhausted
+	})
+	return allocated, err
+}
+
+// Release frees the values allocated to the owner
+func (a *Allocator) Release(owner string) error {
+	return UpdateState(a.store, func(data map[string]string) error {
+		for key, o := range data {
+			if o == owner {
+				delete(data, key)
+			}
+		}
+		return nil
+	})
+}
+
+// Lookup returns the value allocated to the owner, if any
+func (a *Allocator) Lookup(owner string) (int64, bool, error) {
+	data, _, err := a.store.Get()
+	if err != nil {
+		return 0, false, err
+	}
+	value, ok := lookupAllocation(data, owner)
+	return value, ok, nil
+}
+
+// ReleaseOnDeletion returns a finalize func for HandleDeletion that releases the values allocated to the deleted
+// custom resource, whose owner is its namespace/name key
+func (a *Allocator) ReleaseOnDeletion() FinalizeFunc {
+	return func(obj runtime.Object, namespaceTerminating bool) error {
+		owner, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return err
+		}
+		return a.Release(owner)
+	}
+}
+
+func lookupAllocation(data map[string]string, owner string) (int64, bool) {
+	for key, o := range data {
+		if o != owner {
+			continue
+		}
+		value, err := strconv.ParseInt(key, 10, 64)
+		if err == nil {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// IPv4Range returns the range of the host addresses of an IPv4 CIDR, such as "10.0.0.0/24", without the network and
+// broadcast addresses. Convert the allocated values back with IPv4FromValue.
+func IPv4Range(cidr string) (AllocationRange, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return AllocationRange{}, fmt.Errorf("failed to parse CIDR %s. %+v", cidr, err)
+	}
+	ip := network.IP.To4()
+	if ip == nil {
+		return AllocationRange{}, fmt.Errorf("CIDR %s is not IPv4", cidr)
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones < 2 {
+		return AllocationRange{}, fmt.Errorf("CIDR %s has no host addresses", cidr)
+	}
+
+	start := int64(binary.BigEndian.Uint32(ip))
+	size := int64(1) << uint(bits-ones)
+	return AllocationRange{Start: start + 1, End: start + size - 2}, nil
+}
+
+// IPv4FromValue returns the IPv4 address of a value allocated from an IPv4Range
+func IPv4FromValue(value int64) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, uint32(value))
+	return ip
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocator(t *testing.T) {
+	allocator := NewAllocator(NewMemoryStateStore(), []AllocationRange{{Start: 1, End: 3}}, 2)
+
+	value, err := allocator.Allocate("ns/a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	// allocating again returns the same value
+	value, err = allocator.Allocate("ns/a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	// the reserved value is skipped
+	value, err = allocator.Allocate("ns/b")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), value)
+
+	_, err = allocator.Allocate("ns/c")
+	assert.Equal(t, ErrRangeExhausted, err)
+
+	assert.NoError(t, allocator.Release("ns/a"))
+	_, found, err := allocator.Lookup("ns/a")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	value, err = allocator.Allocate("ns/c")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+}
+
+func TestIPv4Range(t *testing.T) {
+	r, err := IPv4Range("10.0.0.0/30")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", IPv4FromValue(r.Start).String())
+	assert.Equal(t, "10.0.0.2", IPv4FromValue(r.End).String())
+
+	_, err = IPv4Range("10.0.0.0/32")
+	assert.Error(t, err)
+	_, err = IPv4Range("fd00::/64")
+	assert.Error(t, err)
+}
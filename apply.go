@@ -0,0 +1,151 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// DefaultFieldManager is the field manager used for server-side apply when none is configured
+	DefaultFieldManager = "operator-kit"
+
+	applyPatchType types.PatchType = "application/apply-patch+yaml"
+)
+
+// PatchStrategy selects how Apply updates a child object that already exists
+type PatchStrategy int
+
+const (
+	// ServerSideApply sends the desired object as an apply patch owned by the field manager.
+	// The apiserver merges the fields with those owned by other managers.
+	ServerSideApply PatchStrategy = iota
+
+	// StrategicMergePatch sends the desired object as a strategic merge patch. Only supported for built-in types.
+	StrategicMergePatch
+
+	// MergePatch sends the desired object as a JSON merge patch. Lists are replaced as a whole.
+	MergePatch
+
+	// ThreeWayMergePatch records the desired object in the ThreeWayLastAppliedAnnotation and sends a JSON merge patch
+	// computed from the last applied, desired, and live objects. Fields removed from the desired object are deleted,
+	// while fields set by admission controllers or users are kept. Lists are replaced as a whole.
+	ThreeWayMergePatch
+)
+
+// ApplyOptions configures how Apply creates or updates a child object
+type ApplyOptions struct {
+	// Strategy used to patch an existing object
+	Strategy PatchStrategy
+
+	// FieldManager that owns the applied fields. Defaults to DefaultFieldManager.
+	FieldManager string
+
+	// Force takes ownership of fields owned by other managers when server-side apply detects a conflict
+	Force bool
+
+	// Policies the object is checked against before it is created or patched. Violations are returned without
+	// calling the apiserver, see IsPolicyViolation.
+	Policies []Policy
+}
+
+// Apply creates the child object if it is missing and patches it to the desired state when it is present. With
+// server-side apply a single apply patch does both. The client must be the REST client for the group and version of the object and resource is its plural name,
+// for example clientset.CoreV1().RESTClient() and "configmaps". For server-side apply the apiVersion and kind of
+// the object must be set. On success obj is updated with the object returned by the apiserver.
+func Apply(client rest.Interface, resource string, obj runtime.Object, opts ApplyOptions) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return fmt.Errorf("failed to access metadata of %s. %+v", resource, err)
+	}
+	namespace := accessor.GetNamespace()
+	name := accessor.GetName()
+	if err := CheckPolicies(obj, opts.Policies...); err != nil {
+		return err
+	}
+	if opts.Strategy == ThreeWayMergePatch {
+		if err := SetLastApplied(obj); err != nil {
+			return fmt.Errorf("failed to record the last applied %s %s. %+v", resource, name, err)
+		}
+	}
+
+	// an apply patch creates a missing object itself, with the fields owned by the field manager, so only the other
+	// strategies create the object first
+	if opts.Strategy != ServerSideApply {
+		err = client.Post().Namespace(namespace).Resource(resource).Body(obj).Do().Into(obj)
+		if err == nil {
+			return nil
+		}
+		if !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create %s %s. %+v", resource, name, err)
+		}
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s %s. %+v", resource, name, err)
+	}
+	if opts.Strategy == ThreeWayMergePatch {
+		live, err := client.Get().Namespace(namespace).Resource(resource).Name(name).Do().Raw()
+		if err != nil {
+			return fmt.Errorf("failed to get %s %s. %+v", resource, name, err)
+		}
+		if data, err = threeWayPatch(data, live); err != nil {
+			return fmt.Errorf("failed to compute the patch of %s %s. %+v", resource, name, err)
+		}
+		if string(data) == "{}" {
+			// the live object already matches, so it is returned without an update
+			return json.Unmarshal(live, obj)
+		}
+	}
+
+	request := client.Patch(opts.patchType()).Namespace(namespace).Resource(resource).Name(name)
+	if opts.Strategy == ServerSideApply {
+		request = request.Param("fieldManager", opts.fieldManager()).Param("force", strconv.FormatBool(opts.Force))
+	}
+	if err := request.Body(data).Do().Into(obj); err != nil {
+		return fmt.Errorf("failed to patch %s %s. %+v", resource, name, err)
+	}
+	return nil
+}
+
+func (o ApplyOptions) patchType() types.PatchType {
+	switch o.Strategy {
+	case StrategicMergePatch:
+		return types.StrategicMergePatchType
+	case MergePatch, ThreeWayMergePatch:
+		return types.MergePatchType
+	default:
+		return applyPatchType
+	}
+}
+
+func (o ApplyOptions) fieldManager() string {
+	if o.FieldManager == "" {
+		return DefaultFieldManager
+	}
+	return o.FieldManager
+}
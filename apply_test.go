@@ -0,0 +1,139 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const alreadyExistsStatus = `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"AlreadyExists","code":409}`
+
+func desiredConfigMap() *v1.ConfigMap {
+	return &v1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"},
+		Data:       map[string]string{"k": "v"},
+	}
+}
+
+func TestApplyCreate(t *testing.T) {
+	var requests []*http.Request
+	client := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+		requests = append(requests, req)
+		body, _ := ioutil.ReadAll(req.Body)
+		recorder.WriteHeader(http.StatusCreated)
+		recorder.Write(body)
+	})
+
+	cm := desiredConfigMap()
+	assert.NoError(t, Apply(client, "configmaps", cm, ApplyOptions{Strategy: MergePatch}))
+	assert.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Equal(t, "/api/v1/namespaces/ns/configmaps", requests[0].URL.Path)
+	assert.Equal(t, "", requests[0].URL.Query().Get("fieldManager"))
+	assert.Equal(t, "v", cm.Data["k"])
+
+	// the apply patch creates the missing object, with the fields owned by the field manager
+	requests = nil
+	cm = desiredConfigMap()
+	assert.NoError(t, Apply(client, "configmaps", cm, ApplyOptions{Force: true}))
+	assert.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPatch, requests[0].Method)
+	assert.Equal(t, "/api/v1/namespaces/ns/configmaps/a", requests[0].URL.Path)
+	assert.Equal(t, DefaultFieldManager, requests[0].URL.Query().Get("fieldManager"))
+	assert.Equal(t, "true", requests[0].URL.Query().Get("force"))
+	assert.Equal(t, "v", cm.Data["k"])
+}
+
+func TestApplyPatchExisting(t *testing.T) {
+	tests := []struct {
+		strategy    PatchStrategy
+		contentType string
+		params      map[string]string
+	}{
+		{ServerSideApply, "application/apply-patch+yaml", map[string]string{"fieldManager": "test", "force": "false"}},
+		{StrategicMergePatch, "application/strategic-merge-patch+json", map[string]string{"fieldManager": "", "force": ""}},
+		{MergePatch, "application/merge-patch+json", map[string]string{"fieldManager": "", "force": ""}},
+	}
+	for _, test := range tests {
+		var patch *http.Request
+		var body string
+		client := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+			switch req.Method {
+			case http.MethodPost:
+				recorder.WriteHeader(http.StatusConflict)
+				recorder.WriteString(alreadyExistsStatus)
+			case http.MethodPatch:
+				patch = req
+				data, _ := ioutil.ReadAll(req.Body)
+				body = string(data)
+				recorder.WriteString(`{"metadata":{"name":"a","namespace":"ns","resourceVersion":"2"},"data":{"k":"v","x":"y"}}`)
+			default:
+				t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+			}
+		})
+
+		cm := desiredConfigMap()
+		assert.NoError(t, Apply(client, "configmaps", cm, ApplyOptions{Strategy: test.strategy, FieldManager: "test"}))
+		if assert.NotNil(t, patch, test.contentType) {
+			assert.Equal(t, "/api/v1/namespaces/ns/configmaps/a", patch.URL.Path)
+			assert.Equal(t, test.contentType, patch.Header.Get("Content-Type"))
+			for param, value := range test.params {
+				assert.Equal(t, value, patch.URL.Query().Get(param), test.contentType)
+			}
+		}
+		assert.Contains(t, body, `"data":{"k":"v"}`)
+		assert.Equal(t, "2", cm.ResourceVersion)
+		assert.Equal(t, "y", cm.Data["x"])
+	}
+}
+
+func TestApplyServerSideConflict(t *testing.T) {
+	var force string
+	client := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+		switch req.Method {
+		case http.MethodPost:
+			recorder.WriteHeader(http.StatusConflict)
+			recorder.WriteString(alreadyExistsStatus)
+		case http.MethodPatch:
+			force = req.URL.Query().Get("force")
+			if force != "true" {
+				recorder.WriteHeader(http.StatusConflict)
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Conflict","code":409,` +
+					`"message":"Apply failed with 1 conflict: conflict with \"kubectl\": .data.k"}`)
+				return
+			}
+			recorder.WriteString(`{"metadata":{"name":"a","namespace":"ns"},"data":{"k":"v"}}`)
+		}
+	})
+
+	err := Apply(client, "configmaps", desiredConfigMap(), ApplyOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to patch configmaps a")
+	assert.Contains(t, err.Error(), `conflict with "kubectl"`)
+	assert.Equal(t, "false", force)
+
+	// forcing the apply takes ownership of the conflicting fields
+	assert.NoError(t, Apply(client, "configmaps", desiredConfigMap(), ApplyOptions{Force: true}))
+	assert.Equal(t, "true", force)
+}
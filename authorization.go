@@ -0,0 +1,192 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// CreatorAnnotation is added by the creator mutator to custom resources when they are created. The value is the
+// AdmissionUserInfo of the user creating the resource, as JSON.
+const CreatorAnnotation = "operatorkit.io/creator"
+
+// EventReasonUnauthorized is the reason of the event emitted when a custom resource is not reconciled because the
+// authorize func of the controller denied it
+const EventReasonUnauthorized = "Unauthorized"
+
+// AuthorizeFunc returns an error if the operator must not act on the custom resource. The controller does not
+// reconcile the resource when it fails with a TerminalError, and retries later on any other error.
+type AuthorizeFunc func(obj runtime.Object) error
+
+// CreatorForbiddenError is returned when the creator of a custom resource is not allowed to request its operand
+type CreatorForbiddenError struct {
+	Creator string
+	Missing []authorizationv1.ResourceAttributes
+}
+
+func (e *CreatorForbiddenError) Error() string {
+	if len(e.Missing) == 0 {
+		return "the creator of the resource is unknown"
+	}
+	missing := make([]string, 0, len(e.Missing))
+	for _, attributes := range e.Missing {
+		missing = append(missing, describeAttributes(attributes))
+	}
+	return fmt.Sprintf("creator %s is not allowed to %s", e.Creator, strings.Join(missing, "; "))
+}
+
+// CreatorMutator returns a mutate func for the webhook of a custom resource that records the creator in the
+// CreatorAnnotation of created resources and rejects updates changing it, so that the annotation can be trusted by
+// CreatorAuthorizer. The next func is optional and its patch is applied before the annotation is added.
+func CreatorMutator(next MutateFunc) MutateFunc {
+	return func(request *AdmissionRequest) ([]JSONPatchOperation, error) {
+		var patch []JSONPatchOperation
+		if next != nil {
+			var err error
+			if patch, err = next(request); err != nil {
+				return nil, err
+			}
+		}
+
+		switch request.Operation {
+		case "CREATE":
+			creator, err := json.Marshal(request.UserInfo)
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize the creator. %+v", err)
+			}
+			annotation, err := annotationPatch(request.Object, map[string]string{CreatorAnnotation: string(creator)})
+			if err != nil {
+				return nil, err
+			}
+			return append(patch, annotation...), nil
+		case "UPDATE":
+			creator, err := rawAnnotation(request.Object, CreatorAnnotation)
+			if err != nil {
+				return nil, err
+			}
+			oldCreator, err := rawAnnotation(request.OldObject, CreatorAnnotation)
+			if err != nil {
+				return nil, err
+			}
+			if creator != oldCreator {
+				return nil, fmt.Errorf("the %s annotation cannot be changed", CreatorAnnotation)
+			}
+		}
+		return patch, nil
+	}
+}
+
+// CreatorAuthorizer returns an authorize func checking with a SubjectAccessReview that the creator recorded by
+// CreatorMutator is still allowed to perform each of the operations returned by attributes for the resource, such as
+// creating the pods of the operand in its namespace. This keeps a privileged operator from acting for users who could
+// not act themselves. Resources without a creator are denied.
+func CreatorAuthorizer(context Context, attributes func(obj metav1.Object) []authorizationv1.ResourceAttributes) AuthorizeFunc {
+	return func(obj runtime.Object) error {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		creator := AdmissionUserInfo{}
+		value, ok := accessor.GetAnnotations()[CreatorAnnotation]
+		if !ok {
+			return NewTerminalError(&CreatorForbiddenError{})
+		}
+		if err := json.Unmarshal([]byte(value), &creator); err != nil || creator.Username == "" {
+			return NewTerminalError(&CreatorForbiddenError{})
+		}
+
+		var missing []authorizationv1.ResourceAttributes
+		for _, attr := range attributes(accessor) {
+			allowed, err := subjectAccessAllowed(context, creator, attr)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				missing = append(missing, attr)
+			}
+		}
+		if len(missing) > 0 {
+			return NewTerminalError(&CreatorForbiddenError{Creator: creator.Username, Missing: missing})
+		}
+		return nil
+	}
+}
+
+func subjectAccessAllowed(context Context, user AdmissionUserInfo, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+		},
+	}
+	result, err := context.Clientset.AuthorizationV1().SubjectAccessReviews().Create(review)
+	if err != nil {
+		return false, fmt.Errorf("failed to review access of %s to %s. %+v", user.Username, describeAttributes(attributes), err)
+	}
+	return result.Status.Allowed, nil
+}
+
+// authorize calls the authorize func of the controller with the custom resource of the key. Resources that are
+// gone or being deleted are always authorized so their cleanup is not blocked.
+func (c *Controller) authorize(key string) error {
+	if c.options.Authorize == nil {
+		return nil
+	}
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil || !exists {
+		return nil
+	}
+	object, ok := obj.(runtime.Object)
+	if !ok {
+		return nil
+	}
+	if accessor, err := meta.Accessor(object); err == nil && accessor.GetDeletionTimestamp() != nil {
+		return nil
+	}
+	if err := c.options.Authorize(object); err != nil {
+		c.recordEvent(key, v1.EventTypeWarning, EventReasonUnauthorized, err.Error())
+		return err
+	}
+	return nil
+}
+
+// rawAnnotation returns the annotation of the raw object, or "" if it is not set
+func rawAnnotation(raw json.RawMessage, name string) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	obj := struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return "", fmt.Errorf("failed to parse the object. %+v", err)
+	}
+	return obj.Metadata.Annotations[name], nil
+}
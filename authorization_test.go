@@ -0,0 +1,119 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestCreatorMutator(t *testing.T) {
+	mutate := CreatorMutator(nil)
+	user := AdmissionUserInfo{Username: "alice", Groups: []string{"team-a"}}
+	creator, _ := json.Marshal(user)
+
+	patch, err := mutate(&AdmissionRequest{Operation: "CREATE", UserInfo: user, Object: []byte(`{"metadata":{"name":"a"}}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{{Op: "add", Path: "/metadata/annotations", Value: map[string]string{CreatorAnnotation: string(creator)}}}, patch)
+
+	// a creator set by the user is overwritten
+	patch, err = mutate(&AdmissionRequest{Operation: "CREATE", UserInfo: user,
+		Object: []byte(`{"metadata":{"name":"a","annotations":{"operatorkit.io/creator":"{\"username\":\"admin\"}"}}}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{{Op: "add", Path: "/metadata/annotations/operatorkit.io~1creator", Value: string(creator)}}, patch)
+
+	// the creator cannot be changed
+	_, err = mutate(&AdmissionRequest{Operation: "UPDATE", UserInfo: user,
+		Object:    []byte(`{"metadata":{"annotations":{"operatorkit.io/creator":"{\"username\":\"admin\"}"}}}`),
+		OldObject: []byte(`{"metadata":{"annotations":{"operatorkit.io/creator":"{\"username\":\"alice\"}"}}}`)})
+	assert.Error(t, err)
+	_, err = mutate(&AdmissionRequest{Operation: "UPDATE", UserInfo: user,
+		Object:    []byte(`{"metadata":{"annotations":{"operatorkit.io/creator":"{\"username\":\"alice\"}","other":"x"}}}`),
+		OldObject: []byte(`{"metadata":{"annotations":{"operatorkit.io/creator":"{\"username\":\"alice\"}"}}}`)})
+	assert.NoError(t, err)
+}
+
+func TestCreatorAuthorizer(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	var reviewed []authorizationv1.SubjectAccessReviewSpec
+	clientset.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviewed = append(reviewed, review.Spec)
+		review.Status.Allowed = review.Spec.User == "alice"
+		return true, review, nil
+	})
+	authorize := CreatorAuthorizer(Context{Clientset: clientset}, func(obj metav1.Object) []authorizationv1.ResourceAttributes {
+		return []authorizationv1.ResourceAttributes{{Namespace: obj.GetNamespace(), Verb: "create", Resource: "pods"}}
+	})
+	cm := func(creator string) *v1.ConfigMap {
+		obj := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}}
+		if creator != "" {
+			obj.Annotations = map[string]string{CreatorAnnotation: creator}
+		}
+		return obj
+	}
+
+	assert.NoError(t, authorize(cm(`{"username":"alice","groups":["team-a"]}`)))
+	assert.Equal(t, []authorizationv1.SubjectAccessReviewSpec{{
+		User:               "alice",
+		Groups:             []string{"team-a"},
+		ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: "ns", Verb: "create", Resource: "pods"},
+	}}, reviewed)
+
+	err := authorize(cm(`{"username":"bob"}`))
+	assert.True(t, IsTerminalError(err))
+	assert.EqualError(t, err, "creator bob is not allowed to create pods in namespace ns")
+
+	err = authorize(cm(""))
+	assert.True(t, IsTerminalError(err))
+	assert.EqualError(t, err, "the creator of the resource is unknown")
+}
+
+func TestControllerAuthorize(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{
+		context:  Context{Recorder: recorder},
+		resource: exampleResource,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		store:    cache.NewStore(cache.MetaNamespaceKeyFunc),
+		options: ControllerOptions{Authorize: func(obj runtime.Object) error {
+			return NewTerminalError(&CreatorForbiddenError{})
+		}},
+	}
+	defer c.queue.ShutDown()
+
+	// deleted resources are not authorized
+	assert.NoError(t, c.authorize("ns/a"))
+
+	c.store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}})
+	assert.Error(t, c.authorize("ns/a"))
+	assert.Equal(t, "Warning Unauthorized the creator of the resource is unknown", <-recorder.Events)
+
+	now := metav1.Now()
+	c.store.Update(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", DeletionTimestamp: &now}})
+	assert.NoError(t, c.authorize("ns/a"))
+}
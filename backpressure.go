@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// QueuedAnnotation is added by the backpressure mutator to custom resources admitted while the controller is
+// backlogged. The value is the number of keys that were waiting in the queue. The controller removes the
+// annotation after the resource was reconciled.
+const QueuedAnnotation = "operatorkit.io/queued"
+
+// BackpressureMutator returns a mutate func for the webhook of the controller's custom resource that annotates
+// created and updated resources with QueuedAnnotation while more than threshold keys wait in the controller's queue,
+// so users get immediate feedback that processing will be delayed. The next func is optional and its patch is
+// applied before the annotation is added, so annotations set by next are kept.
+func BackpressureMutator(controller *Controller, threshold int, next MutateFunc) MutateFunc {
+	return func(request *AdmissionRequest) ([]JSONPatchOperation, error) {
+		var patch []JSONPatchOperation
+		if next != nil {
+			var err error
+			if patch, err = next(request); err != nil {
+				return nil, err
+			}
+		}
+
+		if request.Operation != "CREATE" && request.Operation != "UPDATE" {
+			return patch, nil
+		}
+		depth := controller.QueueDepth()
+		if depth <= threshold {
+			return patch, nil
+		}
+
+		object, err := applyJSONPatch(request.Object, patch)
+		if err != nil {
+			return nil, err
+		}
+		queued, err := annotationPatch(object, map[string]string{QueuedAnnotation: strconv.Itoa(depth)})
+		if err != nil {
+			return nil, err
+		}
+		return append(patch, queued...), nil
+	}
+}
+
+// clearQueuedAnnotation removes the QueuedAnnotation from the custom resource with the key after it was reconciled.
+// The update of the removal is ignored by the event handlers, so it does not cause another reconcile.
+func (c *Controller) clearQueuedAnnotation(key string) {
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil || !exists {
+		return
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	if _, ok := accessor.GetAnnotations()[QueuedAnnotation]; !ok {
+		return
+	}
+
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, QueuedAnnotation))
+	err = c.client.Patch(types.MergePatchType).Namespace(accessor.GetNamespace()).Resource(c.resource.Plural).
+		Name(accessor.GetName()).Body(patch).Do().Error()
+	if err != nil {
+		c.context.logger().Error(err, "failed to remove the queued annotation", "resource", c.resource.Name, "key", key)
+	}
+}
+
+// queuedAnnotationCleared returns whether the update only removed the QueuedAnnotation, as clearQueuedAnnotation
+// does after a reconcile
+func queuedAnnotationCleared(oldObj, newObj interface{}) bool {
+	oldAccessor, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	newAccessor, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	if _, ok := oldAccessor.GetAnnotations()[QueuedAnnotation]; !ok {
+		return false
+	}
+	if _, ok := newAccessor.GetAnnotations()[QueuedAnnotation]; ok {
+		return false
+	}
+
+	var objects []map[string]interface{}
+	for _, obj := range []interface{}{oldObj, newObj} {
+		object, err := jsonMap(obj)
+		if err != nil {
+			return false
+		}
+		metadata, _ := object["metadata"].(map[string]interface{})
+		delete(metadata, "resourceVersion")
+		delete(metadata, "managedFields")
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, QueuedAnnotation)
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+		objects = append(objects, object)
+	}
+	return reflect.DeepEqual(objects[0], objects[1])
+}
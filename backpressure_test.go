@@ -0,0 +1,85 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBackpressureMutator(t *testing.T) {
+	c := newController(Context{}, exampleResource, nil, nil, ControllerOptions{})
+	next := func(request *AdmissionRequest) ([]JSONPatchOperation, error) {
+		return []JSONPatchOperation{{Op: "add", Path: "/metadata/annotations", Value: map[string]string{"a": "b"}}}, nil
+	}
+	mutate := BackpressureMutator(c, 1, next)
+	request := &AdmissionRequest{Operation: "CREATE", Object: json.RawMessage(`{"metadata":{"name":"a"}}`)}
+
+	patch, err := mutate(request)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(patch))
+
+	// the annotation is added to the annotations set by next rather than replacing them
+	c.queue.Add("ns/a")
+	c.queue.Add("ns/b")
+	patch, err = mutate(request)
+	assert.NoError(t, err)
+	patched, err := applyJSONPatch(request.Object, patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"name":"a","annotations":{"a":"b","operatorkit.io/queued":"2"}}}`, string(patched))
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	patched, err := applyJSONPatch(json.RawMessage(`{"spec":{"ports":[80,443],"x":"y"}}`), []JSONPatchOperation{
+		{Op: "add", Path: "/spec/ports/1", Value: 8080},
+		{Op: "add", Path: "/spec/ports/-", Value: 9090},
+		{Op: "remove", Path: "/spec/x"},
+		{Op: "replace", Path: "/metadata", Value: map[string]string{"name": "a"}},
+		{Op: "add", Path: "/metadata/labels", Value: map[string]string{"a/b": "c"}},
+		{Op: "replace", Path: "/metadata/labels/a~1b", Value: "d"},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"ports":[80,8080,443,9090]},"metadata":{"name":"a","labels":{"a/b":"d"}}}`, string(patched))
+
+	_, err = applyJSONPatch(json.RawMessage(`{}`), []JSONPatchOperation{{Op: "add", Path: "/spec/replicas", Value: 1}})
+	assert.Error(t, err)
+	_, err = applyJSONPatch(json.RawMessage(`{}`), []JSONPatchOperation{{Op: "move", Path: "/spec"}})
+	assert.Error(t, err)
+}
+
+func TestQueuedAnnotationCleared(t *testing.T) {
+	queued := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", ResourceVersion: "1",
+		Annotations: map[string]string{QueuedAnnotation: "20"}}, Data: map[string]string{"a": "1"}}
+	cleared := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", ResourceVersion: "2"},
+		Data: map[string]string{"a": "1"}}
+	changed := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", ResourceVersion: "3"},
+		Data: map[string]string{"a": "2"}}
+	assert.True(t, queuedAnnotationCleared(queued, cleared))
+	assert.False(t, queuedAnnotationCleared(queued, changed))
+	assert.False(t, queuedAnnotationCleared(cleared, changed))
+
+	// the update clearing the annotation after a reconcile does not queue the resource again
+	c := newController(Context{}, exampleResource, nil, nil, ControllerOptions{})
+	handlers := c.eventHandlers()
+	handlers.UpdateFunc(queued, cleared)
+	assert.Equal(t, 0, c.queue.Len())
+	handlers.UpdateFunc(queued, changed)
+	assert.Equal(t, 1, c.queue.Len())
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"strings"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// The errors of CreateCustomResources are returned as they are, so callers tell the failures that go away when
+// retried later, such as ErrCRDNotEstablished, from an operator that is misconfigured for the cluster with a type
+// switch or IsMisconfiguration.
+
+// CRDCondition is a condition of the status of a CRD, in any apiextensions version
+type CRDCondition struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+// ErrCRDNameConflict is returned when the apiserver did not accept the names of the CRD of a resource, usually
+// because another CRD already uses its plural or kind
+type ErrCRDNameConflict struct {
+	Resource string
+	Reason   string
+	Message  string
+}
+
+func (e *ErrCRDNameConflict) Error() string {
+	return fmt.Sprintf("names of %s CRD were not accepted. %s: %s", e.Resource, e.Reason, e.Message)
+}
+
+// ErrCRDNotEstablished is returned when the CRD of a resource was not established before the timeout. The last
+// condition is the Established condition, or the last condition reported if the CRD has none yet, and nil if the
+// CRD had no conditions. The apiserver may only be slow, so the install can be retried.
+type ErrCRDNotEstablished struct {
+	Resource      string
+	LastCondition *CRDCondition
+
+	// Diagnostic is optional and describes why the resource was not established, for TPRs which have no conditions
+	Diagnostic string
+}
+
+func (e *ErrCRDNotEstablished) Error() string {
+	if e.LastCondition == nil {
+		if e.Diagnostic != "" {
+			return fmt.Sprintf("%s CRD was not established before the timeout. %s", e.Resource, e.Diagnostic)
+		}
+		return fmt.Sprintf("%s CRD was not established before the timeout", e.Resource)
+	}
+	return fmt.Sprintf("%s CRD was not established before the timeout. last condition %s=%s %s: %s",
+		e.Resource, e.LastCondition.Type, e.LastCondition.Status, e.LastCondition.Reason, e.LastCondition.Message)
+}
+
+// ErrUnsupportedServerVersion is returned when the cluster serves neither CRDs nor TPRs
+type ErrUnsupportedServerVersion struct {
+	Version string
+}
+
+func (e *ErrUnsupportedServerVersion) Error() string {
+	return fmt.Sprintf("kubernetes %s supports neither CRDs nor TPRs", e.Version)
+}
+
+// ErrCRDMismatch is returned when a CRD of the same name already exists but differs from the definition of the
+// resource, such as in its scope or kind
+type ErrCRDMismatch struct {
+	Resource   string
+	Mismatches []string
+}
+
+func (e *ErrCRDMismatch) Error() string {
+	return fmt.Sprintf("existing %s CRD does not match the resource. %s", e.Resource, strings.Join(e.Mismatches, "; "))
+}
+
+// IsMisconfiguration returns whether the error means the operator cannot install its custom resources in the
+// cluster until its configuration or the cluster is changed, rather than a failure that may go away when retried
+func IsMisconfiguration(err error) bool {
+	switch err.(type) {
+	case *ErrCRDNameConflict, *ErrCRDMismatch, *ErrUnsupportedServerVersion:
+		return true
+	default:
+		return false
+	}
+}
+
+// crdEstablished returns whether the conditions say the CRD of the resource is established, or an
+// ErrCRDNameConflict if its names were not accepted
+func crdEstablished(resource string, conditions []CRDCondition) (bool, error) {
+	for _, cond := range conditions {
+		switch cond.Type {
+		case "Established":
+			if cond.Status == "True" {
+				return true, nil
+			}
+		case "NamesAccepted":
+			if cond.Status == "False" {
+				return false, &ErrCRDNameConflict{Resource: resource, Reason: cond.Reason, Message: cond.Message}
+			}
+		}
+	}
+	return false, nil
+}
+
+// lastCRDCondition returns the Established condition, or the last condition if there is none
+func lastCRDCondition(conditions []CRDCondition) *CRDCondition {
+	if len(conditions) == 0 {
+		return nil
+	}
+	last := conditions[len(conditions)-1]
+	for _, cond := range conditions {
+		if cond.Type == "Established" {
+			last = cond
+		}
+	}
+	return &last
+}
+
+func crdV1beta1Conditions(crd *apiextensionsv1beta1.CustomResourceDefinition) []CRDCondition {
+	var conditions []CRDCondition
+	for _, cond := range crd.Status.Conditions {
+		conditions = append(conditions, CRDCondition{Type: string(cond.Type), Status: string(cond.Status), Reason: cond.Reason, Message: cond.Message})
+	}
+	return conditions
+}
+
+func crdV1Conditions(crd *crdV1) []CRDCondition {
+	var conditions []CRDCondition
+	for _, cond := range crd.Status.Conditions {
+		conditions = append(conditions, CRDCondition{Type: cond.Type, Status: cond.Status, Reason: cond.Reason, Message: cond.Message})
+	}
+	return conditions
+}
+
+// waitForCRDEstablished waits until the conditions returned by getConditions say the CRD of the resource is
+// established. A timeout is returned as an ErrCRDNotEstablished with the last condition seen.
+func waitForCRDEstablished(context Context, resource CustomResource, watchFunc WatchFunc, getConditions func() ([]CRDCondition, error)) error {
+	var last *CRDCondition
+	err := context.waitStrategy().Wait(context.waitInterval(), context.Timeout, watchFunc, func() (bool, error) {
+		conditions, err := getConditions()
+		if err != nil {
+			return false, err
+		}
+		if cond := lastCRDCondition(conditions); cond != nil {
+			last = cond
+		}
+		return crdEstablished(resource.Name, conditions)
+	})
+	if err == wait.ErrWaitTimeout {
+		return &ErrCRDNotEstablished{Resource: resource.Name, LastCondition: last}
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/extensions/v1beta1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclientfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func exampleCRD(conditions ...apiextensionsv1beta1.CustomResourceDefinitionCondition) *apiextensionsv1beta1.CustomResourceDefinition {
+	crd := newCRDv1beta1(exampleResource)
+	crd.Status.Conditions = conditions
+	return crd
+}
+
+func TestWaitForCRDInitNameConflict(t *testing.T) {
+	ctx := Context{
+		APIExtensionClientset: apiextensionsclientfake.NewSimpleClientset(exampleCRD(apiextensionsv1beta1.CustomResourceDefinitionCondition{
+			Type:    apiextensionsv1beta1.NamesAccepted,
+			Status:  apiextensionsv1beta1.ConditionFalse,
+			Reason:  "KindConflict",
+			Message: "kind is already in use",
+		})),
+		WaitStrategy: LinearWaitStrategy{},
+		Interval:     10 * time.Millisecond,
+		Timeout:      time.Second,
+	}
+
+	err := waitForCRDInit(ctx, exampleResource)
+	conflict, ok := err.(*ErrCRDNameConflict)
+	assert.True(t, ok, fmt.Sprintf("%+v", err))
+	assert.Equal(t, "example", conflict.Resource)
+	assert.Equal(t, "KindConflict", conflict.Reason)
+	assert.True(t, IsMisconfiguration(err))
+}
+
+func TestWaitForCRDInitNotEstablished(t *testing.T) {
+	ctx := Context{
+		APIExtensionClientset: apiextensionsclientfake.NewSimpleClientset(exampleCRD(
+			apiextensionsv1beta1.CustomResourceDefinitionCondition{Type: apiextensionsv1beta1.NamesAccepted, Status: apiextensionsv1beta1.ConditionTrue},
+			apiextensionsv1beta1.CustomResourceDefinitionCondition{Type: apiextensionsv1beta1.Established, Status: apiextensionsv1beta1.ConditionFalse, Reason: "Installing"},
+		)),
+		WaitStrategy: LinearWaitStrategy{},
+		Interval:     10 * time.Millisecond,
+		Timeout:      50 * time.Millisecond,
+	}
+
+	err := waitForCRDInit(ctx, exampleResource)
+	notEstablished, ok := err.(*ErrCRDNotEstablished)
+	assert.True(t, ok, fmt.Sprintf("%+v", err))
+	assert.Equal(t, "example", notEstablished.Resource)
+	assert.Equal(t, &CRDCondition{Type: "Established", Status: "False", Reason: "Installing"}, notEstablished.LastCondition)
+	assert.False(t, IsMisconfiguration(err))
+
+	// a CRD without conditions has no last condition
+	crdClient := ctx.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions()
+	assert.NoError(t, crdClient.Delete("examples.example.com", &metav1.DeleteOptions{}))
+	_, err = crdClient.Create(exampleCRD())
+	assert.NoError(t, err)
+	err = waitForCRDInit(ctx, exampleResource)
+	assert.Equal(t, &ErrCRDNotEstablished{Resource: "example"}, err)
+}
+
+func TestDiagnoseTPR(t *testing.T) {
+	tprName := "example.example.com"
+	ctx := Context{Clientset: fake.NewSimpleClientset()}
+	assert.Equal(t, "the TPR example.example.com does not exist, another component may have deleted it",
+		diagnoseTPR(ctx, exampleResource, tprName, nil))
+
+	tpr := &v1beta1.ThirdPartyResource{
+		ObjectMeta: metav1.ObjectMeta{Name: tprName},
+		Versions:   []v1beta1.APIVersion{{Name: "v1"}},
+	}
+	ctx.Clientset = fake.NewSimpleClientset(tpr)
+	assert.Equal(t, "the TPR example.example.com does not declare version v1alpha",
+		diagnoseTPR(ctx, exampleResource, tprName, nil))
+
+	tpr.Versions = []v1beta1.APIVersion{{Name: "v1alpha"}}
+	ctx.Clientset = fake.NewSimpleClientset(tpr)
+	lastErr := errors.NewNotFound(schema.GroupResource{Group: "example.com", Resource: "examples"}, "")
+	diagnostic := diagnoseTPR(ctx, exampleResource, tprName, lastErr)
+	assert.Contains(t, diagnostic, "the apiserver does not serve example.com/v1alpha yet")
+
+	err := &ErrCRDNotEstablished{Resource: "example", Diagnostic: diagnostic}
+	assert.Contains(t, err.Error(), "example CRD was not established before the timeout. the TPR example.example.com exists")
+}
+
+func TestWaitForTPRInitTimeout(t *testing.T) {
+	tprPath := "/apis/extensions/v1beta1/thirdpartyresources/example.example.com"
+	deleted := false
+	clientset, err := kubernetes.NewForConfig(&rest.Config{
+		Host: "http://tpr",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			switch {
+			case req.Method == http.MethodGet && req.URL.Path == "/apis/example.com/v1alpha/examples":
+				recorder.WriteHeader(http.StatusNotFound)
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			case req.Method == http.MethodGet && req.URL.Path == tprPath:
+				recorder.WriteString(`{"kind":"ThirdPartyResource","apiVersion":"extensions/v1beta1",` +
+					`"metadata":{"name":"example.example.com"},"versions":[{"name":"v1alpha"}]}`)
+			case req.Method == http.MethodDelete && req.URL.Path == tprPath:
+				deleted = true
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Success"}`)
+			default:
+				t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+			}
+			return recorder.Result(), nil
+		}),
+	})
+	assert.NoError(t, err)
+	ctx := Context{
+		Clientset:    clientset,
+		WaitStrategy: LinearWaitStrategy{},
+		Interval:     10 * time.Millisecond,
+		Timeout:      50 * time.Millisecond,
+	}
+
+	// the TPR is kept by default, but the timeout is still reported
+	err = waitForTPRInit(ctx, exampleResource)
+	notEstablished, ok := err.(*ErrCRDNotEstablished)
+	assert.True(t, ok, fmt.Sprintf("%+v", err))
+	assert.Contains(t, notEstablished.Diagnostic, "the apiserver does not serve example.com/v1alpha yet")
+	assert.False(t, deleted)
+
+	ctx.TPRTimeoutPolicy = DeleteTPROnTimeout
+	err = waitForTPRInit(ctx, exampleResource)
+	_, ok = err.(*ErrCRDNotEstablished)
+	assert.True(t, ok, fmt.Sprintf("%+v", err))
+	assert.True(t, deleted)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+)
+
+// Version and GitCommit of the operator build. They are set by the build of the operator with
+// -ldflags "-X github.com/rook/operator-kit.Version=v1.2.0 -X github.com/rook/operator-kit.GitCommit=0a1b2c3".
+// When GitCommit is not set, the VCS revision recorded by the Go toolchain is used if available.
+var (
+	Version   = "unknown"
+	GitCommit = ""
+)
+
+// ModuleVersion is a Go module compiled into the operator
+type ModuleVersion struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+}
+
+// BuildInfo describes the build of the operator, so admins can audit which builds run in which clusters
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+
+	// Modules compiled into the operator, sorted by path. Only available when built with Go 1.18 or later.
+	Modules []ModuleVersion `json:"modules,omitempty"`
+
+	// Operands are the versions of the software deployed by the operator, keyed by operand name
+	Operands map[string]string `json:"operands,omitempty"`
+}
+
+// NewBuildInfo returns the build of the running operator with its catalog of operand versions
+func NewBuildInfo(operands map[string]string) *BuildInfo {
+	info := &BuildInfo{
+		Version:   Version,
+		GitCommit: GitCommit,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Operands:  operands,
+	}
+
+	modules, revision := readBuildModules()
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Path < modules[j].Path })
+	info.Modules = modules
+	if info.GitCommit == "" {
+		info.GitCommit = revision
+	}
+	return info
+}
+
+// Handler returns a handler serving the build info as JSON, usually mounted at /version
+func (b *BuildInfo) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b)
+	})
+}
+
+// StatusPatch returns the patch setting the build info at the path of a status, such as "/status/build", for
+// PatchStatus. Modules are left out to keep the status small.
+func (b *BuildInfo) StatusPatch(path string) []JSONPatchOperation {
+	status := *b
+	status.Modules = nil
+	return []JSONPatchOperation{JSONPatchAdd(path, status)}
+}
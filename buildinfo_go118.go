@@ -0,0 +1,47 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import "runtime/debug"
+
+// readBuildModules returns the modules and the VCS revision recorded in the binary by the Go toolchain
+func readBuildModules() ([]ModuleVersion, string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil, ""
+	}
+
+	modules := []ModuleVersion{{Path: info.Main.Path, Version: info.Main.Version, Sum: info.Main.Sum}}
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		modules = append(modules, ModuleVersion{Path: dep.Path, Version: dep.Version, Sum: dep.Sum})
+	}
+
+	revision := ""
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			revision = setting.Value
+		}
+	}
+	return modules, revision
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInfo(t *testing.T) {
+	GitCommit = "0a1b2c3"
+	defer func() { GitCommit = "" }()
+	info := NewBuildInfo(map[string]string{"database": "12.4"})
+	assert.Equal(t, "unknown", info.Version)
+	assert.Equal(t, "0a1b2c3", info.GitCommit)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+
+	w := httptest.NewRecorder()
+	info.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	served := &BuildInfo{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), served))
+	assert.Equal(t, "0a1b2c3", served.GitCommit)
+	assert.Equal(t, "12.4", served.Operands["database"])
+
+	w = httptest.NewRecorder()
+	info.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/version", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	patch := info.StatusPatch("/status/build")
+	assert.Equal(t, 1, len(patch))
+	assert.Equal(t, "add", patch[0].Op)
+	assert.Nil(t, patch[0].Value.(BuildInfo).Modules)
+}
+
+func TestSetBuildInfoMetrics(t *testing.T) {
+	m := NewMetrics("test")
+	m.SetBuildInfo(&BuildInfo{Version: "v1", GitCommit: "abc", GoVersion: "go1.20", Operands: map[string]string{"database": "12.4"}})
+	families, err := m.Registry().Gather()
+	assert.NoError(t, err)
+	found := map[string]bool{}
+	for _, family := range families {
+		found[family.GetName()] = true
+	}
+	assert.True(t, found["test_build_info"])
+	assert.True(t, found["test_operand_info"])
+
+	// a nil metrics ignores the build info
+	var nilMetrics *Metrics
+	nilMetrics.SetBuildInfo(&BuildInfo{})
+}
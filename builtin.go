@@ -0,0 +1,89 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Built-in resources described like custom resources, so they are watched through the same watcher API
+var (
+	PodResource = CustomResource{Name: "pod", Plural: "pods", Version: "v1", Kind: "Pod",
+		Scope: apiextensionsv1beta1.NamespaceScoped}
+	ServiceResource = CustomResource{Name: "service", Plural: "services", Version: "v1", Kind: "Service",
+		Scope: apiextensionsv1beta1.NamespaceScoped}
+	ConfigMapResource = CustomResource{Name: "configmap", Plural: "configmaps", Version: "v1", Kind: "ConfigMap",
+		Scope: apiextensionsv1beta1.NamespaceScoped}
+	SecretResource = CustomResource{Name: "secret", Plural: "secrets", Version: "v1", Kind: "Secret",
+		Scope: apiextensionsv1beta1.NamespaceScoped}
+	NodeResource = CustomResource{Name: "node", Plural: "nodes", Version: "v1", Kind: "Node",
+		Scope: apiextensionsv1beta1.ClusterScoped}
+	DeploymentResource = CustomResource{Name: "deployment", Plural: "deployments", Group: "apps", Version: "v1beta2",
+		Kind: "Deployment", Scope: apiextensionsv1beta1.NamespaceScoped}
+	StatefulSetResource = CustomResource{Name: "statefulset", Plural: "statefulsets", Group: "apps", Version: "v1beta2",
+		Kind: "StatefulSet", Scope: apiextensionsv1beta1.NamespaceScoped}
+	DaemonSetResource = CustomResource{Name: "daemonset", Plural: "daemonsets", Group: "apps", Version: "v1beta2",
+		Kind: "DaemonSet", Scope: apiextensionsv1beta1.NamespaceScoped}
+	JobResource = CustomResource{Name: "job", Plural: "jobs", Group: "batch", Version: "v1", Kind: "Job",
+		Scope: apiextensionsv1beta1.NamespaceScoped}
+)
+
+// NewBuiltinWatcher creates a watcher of a built-in resource, such as PodResource or DeploymentResource, with the
+// REST client of its group and version from the clientset of the context. The watcher works like the watcher of a
+// custom resource, with the typed objects of the resource, such as *v1.Pod, passed to the handlers. The namespace
+// is ignored for cluster scoped resources.
+func NewBuiltinWatcher(context Context, resource CustomResource, namespace string, handlers cache.ResourceEventHandlerFuncs) (*ResourceWatcher, error) {
+	client, err := builtinClient(context, resource)
+	if err != nil {
+		return nil, err
+	}
+	if resource.Scope == apiextensionsv1beta1.ClusterScoped {
+		namespace = ""
+	}
+	return NewWatcher(resource, namespace, handlers, client), nil
+}
+
+// builtinClient returns the REST client of the clientset serving the group and version of the resource
+func builtinClient(context Context, resource CustomResource) (rest.Interface, error) {
+	clientset := context.Clientset
+	if clientset == nil {
+		return nil, fmt.Errorf("the context has no clientset")
+	}
+	switch resource.GroupVersionKind().GroupVersion().String() {
+	case "v1":
+		return clientset.CoreV1().RESTClient(), nil
+	case "apps/v1beta1":
+		return clientset.AppsV1beta1().RESTClient(), nil
+	case "apps/v1beta2":
+		return clientset.AppsV1beta2().RESTClient(), nil
+	case "batch/v1":
+		return clientset.BatchV1().RESTClient(), nil
+	case "extensions/v1beta1":
+		return clientset.ExtensionsV1beta1().RESTClient(), nil
+	case "rbac.authorization.k8s.io/v1":
+		return clientset.RbacV1().RESTClient(), nil
+	case "storage.k8s.io/v1":
+		return clientset.StorageV1().RESTClient(), nil
+	default:
+		return nil, fmt.Errorf("%s/%s is not a built-in resource", resource.Group, resource.Version)
+	}
+}
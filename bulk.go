@@ -0,0 +1,302 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// PausedAnnotation pauses the reconciles of a custom resource while it is set, such as operatorkit.io/paused: "true".
+// The value is "true" or the reason for the pause. A value of "false" does not pause.
+const PausedAnnotation = "operatorkit.io/paused"
+
+const (
+	// PausedConditionType is the type of the condition reporting whether the reconciles of a custom resource are
+	// paused by the PausedAnnotation
+	PausedConditionType = "Paused"
+
+	// ConditionReasonPausedByAnnotation is the reason of the Paused condition while the PausedAnnotation is set
+	ConditionReasonPausedByAnnotation = "PausedByAnnotation"
+
+	// ConditionReasonResumed is the reason of the Paused condition once the PausedAnnotation is removed
+	ConditionReasonResumed = "Resumed"
+)
+
+const (
+	defaultBulkQPS   = 10
+	defaultBulkBurst = 10
+)
+
+// BulkOptions configures a bulk operation
+type BulkOptions struct {
+	// QPS is the rate at which the custom resources are patched or requeued. Defaults to 10.
+	QPS float32
+
+	// Burst is the number of custom resources handled at once before the rate applies. Defaults to 10.
+	Burst int
+
+	// Reason is recorded in the PausedAnnotation when pausing
+	Reason string
+}
+
+// BulkResult reports the keys of the custom resources a bulk operation succeeded and failed on
+type BulkResult struct {
+	Succeeded []string          `json:"succeeded"`
+	Failed    map[string]string `json:"failed,omitempty"`
+}
+
+// Select returns the sorted keys of the custom resources in the store matching the label selector
+func (c *Controller) Select(selector labels.Selector) []string {
+	var keys []string
+	for _, obj := range c.store.List() {
+		accessor, err := meta.Accessor(obj)
+		if err != nil || !selector.Matches(labels.Set(accessor.GetLabels())) {
+			continue
+		}
+		if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Pause sets the PausedAnnotation on all custom resources matching the selector. Their reconciles are skipped until
+// they are resumed.
+func (c *Controller) Pause(selector labels.Selector, options BulkOptions) *BulkResult {
+	reason := options.Reason
+	if reason == "" {
+		reason = "paused"
+	}
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, PausedAnnotation, reason))
+	return c.bulk(selector, options, func(key string) error { return c.patchKey(key, patch) })
+}
+
+// Resume removes the PausedAnnotation from all custom resources matching the selector. The update of each resource
+// queues a reconcile.
+func (c *Controller) Resume(selector labels.Selector, options BulkOptions) *BulkResult {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, PausedAnnotation))
+	return c.bulk(selector, options, func(key string) error { return c.patchKey(key, patch) })
+}
+
+// ForceReconcile queues a reconcile of all custom resources matching the selector
+func (c *Controller) ForceReconcile(selector labels.Selector, options BulkOptions) *BulkResult {
+	return c.bulk(selector, options, func(key string) error {
+		c.queue.Add(key)
+		c.context.Metrics.SetWorkqueueDepth(c.resource.Name, c.queue.Len())
+		return nil
+	})
+}
+
+// bulk runs the operation on the keys matching the selector at the rate of the options. A failure on one key does
+// not stop the operation on the others.
+func (c *Controller) bulk(selector labels.Selector, options BulkOptions, operation func(key string) error) *BulkResult {
+	qps, burst := options.QPS, options.Burst
+	if qps <= 0 {
+		qps = defaultBulkQPS
+	}
+	if burst <= 0 {
+		burst = defaultBulkBurst
+	}
+	limiter := flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	defer limiter.Stop()
+
+	result := &BulkResult{Succeeded: []string{}, Failed: map[string]string{}}
+	for _, key := range c.Select(selector) {
+		limiter.Accept()
+		if err := operation(key); err != nil {
+			c.context.logger().Error(err, "bulk operation failed", "resource", c.resource.Name, "key", key)
+			result.Failed[key] = err.Error()
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, key)
+	}
+	return result
+}
+
+func (c *Controller) patchKey(key string, patch []byte) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	err = c.client.Patch(types.MergePatchType).Namespace(namespace).Resource(c.resource.Plural).Name(name).Body(patch).Do().Error()
+	if err != nil {
+		return fmt.Errorf("failed to patch %s %s. %+v", c.resource.Name, key, err)
+	}
+	return nil
+}
+
+// instancePaused returns whether the custom resource with the key has the PausedAnnotation
+func (c *Controller) instancePaused(key string) bool {
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil || !exists {
+		return false
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	value, paused := accessor.GetAnnotations()[PausedAnnotation]
+	return paused && value != "false"
+}
+
+// reportPaused sets the Paused condition of the custom resource with the key when the controller has the
+// PausedCondition option. The condition is only written when it changes, and a resource that was never paused gets
+// no condition.
+func (c *Controller) reportPaused(key string, paused bool) {
+	if c.options.PausedCondition == nil {
+		return
+	}
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil || !exists {
+		return
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	conditions, err := objectConditions(obj)
+	if err != nil {
+		c.context.logger().Error(err, "failed to read the conditions", "resource", c.resource.Name, "key", key)
+		return
+	}
+
+	existing := FindCondition(conditions, PausedConditionType)
+	wasPaused := existing != nil && existing.Status == v1.ConditionTrue
+	if paused == wasPaused {
+		return
+	}
+	condition := Condition{Type: PausedConditionType, Status: v1.ConditionFalse, Reason: ConditionReasonResumed,
+		Message: "reconciles are resumed"}
+	if paused {
+		reason := accessor.GetAnnotations()[PausedAnnotation]
+		condition.Status = v1.ConditionTrue
+		condition.Reason = ConditionReasonPausedByAnnotation
+		condition.Message = fmt.Sprintf("reconciles are paused by the %s annotation: %s", PausedAnnotation, reason)
+	}
+	conditions = SetCondition(conditions, condition)
+	if err := c.patchConditions(accessor, conditions, *c.options.PausedCondition); err != nil {
+		c.context.logger().Error(err, "failed to set the paused condition", "resource", c.resource.Name, "key", key)
+	}
+}
+
+// patchConditions replaces the conditions in the status of the custom resource with a merge patch
+func (c *Controller) patchConditions(accessor metav1.Object, conditions []Condition, opts StatusUpdateOptions) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": map[string]interface{}{"conditions": conditions}})
+	if err != nil {
+		return fmt.Errorf("failed to serialize the conditions. %+v", err)
+	}
+	request := c.client.Patch(types.MergePatchType).Namespace(accessor.GetNamespace()).Resource(c.resource.Plural).
+		Name(accessor.GetName())
+	if opts.UseSubresource {
+		request = request.SubResource("status")
+	}
+	if err := request.Body(patch).Do().Error(); err != nil {
+		return fmt.Errorf("failed to patch the conditions of %s %s. %+v", c.resource.Name, accessor.GetName(), err)
+	}
+	return nil
+}
+
+// objectConditions returns the conditions in the status of the object
+func objectConditions(obj interface{}) ([]Condition, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	status := struct {
+		Status struct {
+			Conditions []Condition `json:"conditions"`
+		} `json:"status"`
+	}{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	return status.Status.Conditions, nil
+}
+
+// BulkHandler returns a handler serving the bulk operations of the controller for incident response, to be mounted
+// on an administrative server that is not exposed outside of the cluster. It serves POST requests at /pause, /resume,
+// and /reconcile with the label selector in the selector query parameter and optional qps, burst, and reason
+// parameters, and responds with the BulkResult.
+func BulkHandler(c *Controller) http.Handler {
+	operations := map[string]func(labels.Selector, BulkOptions) *BulkResult{
+		"/pause":     c.Pause,
+		"/resume":    c.Resume,
+		"/reconcile": c.ForceReconcile,
+	}
+	mux := http.NewServeMux()
+	for path, operation := range operations {
+		operation := operation
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+				return
+			}
+			selector, options, err := bulkRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(operation(selector, options)); err != nil {
+				http.Error(w, fmt.Sprintf("failed to write the response. %+v", err), http.StatusInternalServerError)
+			}
+		})
+	}
+	return mux
+}
+
+// bulkRequest parses the selector and options of a bulk request. A selector is required so that a request without
+// one does not affect every custom resource by mistake. Use "selector=" with an explicit empty value to select all.
+func bulkRequest(r *http.Request) (labels.Selector, BulkOptions, error) {
+	var options BulkOptions
+	query := r.URL.Query()
+	if _, ok := query["selector"]; !ok {
+		return nil, options, fmt.Errorf("the selector parameter is required")
+	}
+	selector, err := labels.Parse(query.Get("selector"))
+	if err != nil {
+		return nil, options, fmt.Errorf("invalid selector. %+v", err)
+	}
+	if qps := query.Get("qps"); qps != "" {
+		value, err := strconv.ParseFloat(qps, 32)
+		if err != nil {
+			return nil, options, fmt.Errorf("invalid qps %q", qps)
+		}
+		options.QPS = float32(value)
+	}
+	if burst := query.Get("burst"); burst != "" {
+		if options.Burst, err = strconv.Atoi(burst); err != nil {
+			return nil, options, fmt.Errorf("invalid burst %q", burst)
+		}
+	}
+	options.Reason = query.Get("reason")
+	return selector, options, nil
+}
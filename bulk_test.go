@@ -0,0 +1,159 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// newBulkController returns a controller with configmaps labeled by tier in its store. The patches sent by the
+// controller are recorded by path.
+func newBulkController(t *testing.T, patches map[string]string) *Controller {
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host: "http://bulk",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := ioutil.ReadAll(req.Body)
+			patches[req.URL.Path] = string(body)
+			recorder := httptest.NewRecorder()
+			if req.URL.Path == "/api/v1/namespaces/ns/configmaps/broken" {
+				recorder.WriteHeader(http.StatusInternalServerError)
+			} else {
+				recorder.WriteHeader(http.StatusOK)
+			}
+			return recorder.Result(), nil
+		}),
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &schema.GroupVersion{Version: "v1"},
+			NegotiatedSerializer: scheme.Codecs,
+		},
+		APIPath: "/api",
+	})
+	assert.NoError(t, err)
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for name, tier := range map[string]string{"a": "gold", "b": "gold", "broken": "gold", "c": "silver"} {
+		store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Labels: map[string]string{"tier": tier}}})
+	}
+	return &Controller{
+		resource: CustomResource{Name: "configmap", Plural: "configmaps"},
+		client:   client,
+		store:    store,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+}
+
+func TestBulkOperations(t *testing.T) {
+	patches := map[string]string{}
+	c := newBulkController(t, patches)
+	gold := labels.SelectorFromSet(labels.Set{"tier": "gold"})
+	assert.Equal(t, []string{"ns/a", "ns/b", "ns/broken"}, c.Select(gold))
+	assert.Equal(t, 4, len(c.Select(labels.Everything())))
+
+	result := c.Pause(gold, BulkOptions{QPS: 100, Reason: "incident"})
+	assert.Equal(t, []string{"ns/a", "ns/b"}, result.Succeeded)
+	assert.Contains(t, result.Failed, "ns/broken")
+	assert.JSONEq(t, `{"metadata":{"annotations":{"operatorkit.io/paused":"incident"}}}`, patches["/api/v1/namespaces/ns/configmaps/a"])
+
+	result = c.Resume(labels.SelectorFromSet(labels.Set{"tier": "silver"}), BulkOptions{})
+	assert.Equal(t, []string{"ns/c"}, result.Succeeded)
+	assert.JSONEq(t, `{"metadata":{"annotations":{"operatorkit.io/paused":null}}}`, patches["/api/v1/namespaces/ns/configmaps/c"])
+
+	result = c.ForceReconcile(labels.Everything(), BulkOptions{})
+	assert.Equal(t, 4, len(result.Succeeded))
+	assert.Equal(t, 4, c.queue.Len())
+}
+
+func TestInstancePaused(t *testing.T) {
+	c := newBulkController(t, map[string]string{})
+	c.store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "p", Annotations: map[string]string{PausedAnnotation: "incident"}}})
+	assert.True(t, c.instancePaused("ns/p"))
+	assert.False(t, c.instancePaused("ns/a"))
+	assert.False(t, c.instancePaused("ns/missing"))
+}
+
+func TestReportPaused(t *testing.T) {
+	patches := map[string]string{}
+	c := newBulkController(t, patches)
+	c.options.PausedCondition = &StatusUpdateOptions{UseSubresource: true}
+	c.store.Add(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "ns", "name": "p", "annotations": map[string]interface{}{PausedAnnotation: "true"}},
+	}})
+	assert.True(t, c.instancePaused("ns/p"))
+
+	c.reportPaused("ns/p", true)
+	patch := patches["/api/v1/namespaces/ns/configmaps/p/status"]
+	assert.Contains(t, patch, `"type":"Paused","status":"True","reason":"PausedByAnnotation"`)
+
+	// the condition is not written again while it is unchanged
+	c.store.Update(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "ns", "name": "p"},
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Paused", "status": "True", "reason": "PausedByAnnotation"},
+		}},
+	}})
+	delete(patches, "/api/v1/namespaces/ns/configmaps/p/status")
+	c.reportPaused("ns/p", true)
+	assert.Equal(t, "", patches["/api/v1/namespaces/ns/configmaps/p/status"])
+
+	c.reportPaused("ns/p", false)
+	assert.Contains(t, patches["/api/v1/namespaces/ns/configmaps/p/status"], `"type":"Paused","status":"False","reason":"Resumed"`)
+
+	// resources that were never paused get no condition
+	c.reportPaused("ns/a", false)
+	assert.Equal(t, "", patches["/api/v1/namespaces/ns/configmaps/a/status"])
+
+	c.store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "f", Annotations: map[string]string{PausedAnnotation: "false"}}})
+	assert.False(t, c.instancePaused("ns/f"))
+}
+
+func TestBulkHandler(t *testing.T) {
+	c := newBulkController(t, map[string]string{})
+	handler := BulkHandler(c)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/reconcile?selector=tier%3Dsilver&qps=5", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	result := &BulkResult{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+	assert.Equal(t, []string{"ns/c"}, result.Succeeded)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/pause", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/pause?selector=tier%3Dgold&burst=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resume?selector=", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// GetOrCachedObject reads the named custom resource into obj for the common get-mutate-update flow. The object is
+// copied from the informer cache when the informer received it within maxAge, or at any time when maxAge is 0.
+// Otherwise, and when the object is not cached, it is read from the API server. After an update fails with a
+// conflict, call InvalidateCachedObject so that the retry reads the latest version.
+//
+// The maxAge is the time since the informer last delivered the object, not how stale the cached copy is. The
+// informers of the controllers do not resync, so they only deliver an object when it is added or changed, and a
+// resource that rarely changes is read from the API server every time once maxAge has passed. The watch keeps the
+// cache up to date, so a maxAge of 0 combined with InvalidateCachedObject after conflicts suits most reconcilers.
+func (c *Controller) GetOrCachedObject(namespace, name string, obj runtime.Object, maxAge time.Duration) error {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+
+	if cached, ok := c.cachedObject(key, maxAge); ok {
+		target := reflect.ValueOf(obj)
+		source := reflect.ValueOf(cached.DeepCopyObject())
+		if target.Type() != source.Type() || target.Kind() != reflect.Ptr {
+			return fmt.Errorf("cannot copy the cached %T into %T", cached, obj)
+		}
+		target.Elem().Set(source.Elem())
+		return nil
+	}
+
+	namespaced := c.resource.Scope != apiextensionsv1beta1.ClusterScoped
+	err := c.client.Get().NamespaceIfScoped(namespace, namespaced).Resource(c.resource.Plural).Name(name).Do().Into(obj)
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s. %+v", c.resource.Name, key, err)
+	}
+	return nil
+}
+
+// InvalidateCachedObject makes the next GetOrCachedObject of the named custom resource read from the API server,
+// until the informer receives a newer version of it
+func (c *Controller) InvalidateCachedObject(namespace, name string) {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	c.observedLock.Lock()
+	defer c.observedLock.Unlock()
+	delete(c.observed, key)
+}
+
+// cachedObject returns the object with the key from the store if the informer received it within maxAge
+func (c *Controller) cachedObject(key string, maxAge time.Duration) (runtime.Object, bool) {
+	c.observedLock.Lock()
+	observed, ok := c.observed[key]
+	c.observedLock.Unlock()
+	if !ok || (maxAge > 0 && time.Since(observed) > maxAge) {
+		return nil, false
+	}
+
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil || !exists {
+		return nil, false
+	}
+	cached, ok := obj.(runtime.Object)
+	return cached, ok
+}
+
+// observe records the time at which the informer received the object, or forgets it when it was deleted
+func (c *Controller) observe(obj interface{}, deleted bool) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	c.observedLock.Lock()
+	defer c.observedLock.Unlock()
+	if deleted {
+		delete(c.observed, key)
+		return
+	}
+	c.observed[key] = time.Now()
+}
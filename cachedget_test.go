@@ -0,0 +1,86 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGetOrCachedObject(t *testing.T) {
+	gets := 0
+	client := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/ns/configmaps/a", req.URL.Path)
+		gets++
+		recorder.WriteString(`{"metadata":{"name":"a","namespace":"ns","resourceVersion":"2"}}`)
+	})
+	c := NewControllerWithOptions(Context{}, configMapResource, v1.NamespaceAll, client, &v1.ConfigMap{}, nil, ControllerOptions{})
+	c.store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	cached := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", ResourceVersion: "1"}}
+	assert.NoError(t, c.store.Add(cached))
+
+	// an object the informer did not deliver is read from the API server
+	cm := &v1.ConfigMap{}
+	assert.NoError(t, c.GetOrCachedObject("ns", "a", cm, 0))
+	assert.Equal(t, "2", cm.ResourceVersion)
+	assert.Equal(t, 1, gets)
+
+	// a fresh object is copied from the cache
+	c.observe(cached, false)
+	cm = &v1.ConfigMap{}
+	assert.NoError(t, c.GetOrCachedObject("ns", "a", cm, time.Minute))
+	assert.Equal(t, "1", cm.ResourceVersion)
+	assert.Equal(t, 1, gets)
+	cm.Labels = map[string]string{"mutated": "true"}
+	assert.Nil(t, cached.Labels)
+
+	// an object delivered longer than maxAge ago is read again, even though it did not change
+	c.observed["ns/a"] = time.Now().Add(-2 * time.Minute)
+	assert.NoError(t, c.GetOrCachedObject("ns", "a", cm, time.Minute))
+	assert.Equal(t, "2", cm.ResourceVersion)
+	assert.Equal(t, 2, gets)
+	assert.NoError(t, c.GetOrCachedObject("ns", "a", cm, 0))
+	assert.Equal(t, "1", cm.ResourceVersion)
+	assert.Equal(t, 2, gets)
+
+	// an invalidated object is read until the informer delivers it again
+	c.InvalidateCachedObject("ns", "a")
+	assert.NoError(t, c.GetOrCachedObject("ns", "a", cm, 0))
+	assert.Equal(t, "2", cm.ResourceVersion)
+	assert.Equal(t, 3, gets)
+	c.observe(cached, false)
+	assert.NoError(t, c.GetOrCachedObject("ns", "a", cm, 0))
+	assert.Equal(t, "1", cm.ResourceVersion)
+	assert.Equal(t, 3, gets)
+}
+
+func TestGetOrCachedObjectTypeMismatch(t *testing.T) {
+	c := NewControllerWithOptions(Context{}, configMapResource, v1.NamespaceAll, nil, &v1.ConfigMap{}, nil, ControllerOptions{})
+	c.store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	cached := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}}
+	assert.NoError(t, c.store.Add(cached))
+	c.observe(cached, false)
+
+	err := c.GetOrCachedObject("ns", "a", &v1.Secret{}, 0)
+	assert.EqualError(t, err, "cannot copy the cached *v1.ConfigMap into *v1.Secret")
+}
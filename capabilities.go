@@ -0,0 +1,110 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/discovery"
+)
+
+// Capabilities are the features of the Kubernetes server relevant to operators, detected once from discovery
+type Capabilities struct {
+	// GitVersion reported by the server, such as "v1.10.5-gke.3"
+	GitVersion string
+
+	// Major and Minor version of the server. Both are zero if the version could not be parsed.
+	Major int
+	Minor int
+
+	// CRDv1 is true if the server serves apiextensions.k8s.io/v1 CRDs
+	CRDv1 bool
+
+	// CRDv1beta1 is true if the server serves apiextensions.k8s.io/v1beta1 CRDs
+	CRDv1beta1 bool
+
+	// StatusSubresource is true if CRDs can enable the status subresource, from Kubernetes 1.11
+	StatusSubresource bool
+
+	// ServerSideApply is true if the server supports apply patches, from Kubernetes 1.16
+	ServerSideApply bool
+
+	// AdmissionWebhooksV1 is true if the server serves admissionregistration.k8s.io/v1 webhook configurations
+	AdmissionWebhooksV1 bool
+
+	// Leases is true if the server serves coordination.k8s.io/v1 leases
+	Leases bool
+
+	groupVersions map[string]bool
+}
+
+// Serves returns whether the server serves the group version, such as "apps/v1" or "v1" for the core group
+func (c *Capabilities) Serves(groupVersion string) bool {
+	return c.groupVersions[groupVersion]
+}
+
+// DetectCapabilities detects the capabilities of the server once and keeps them in the context. Later calls return
+// the kept capabilities. While the context has capabilities, the API flavor of custom resources is selected from
+// them instead of querying the server again.
+func (c *Context) DetectCapabilities() (*Capabilities, error) {
+	if c.Capabilities != nil {
+		return c.Capabilities, nil
+	}
+	capabilities, err := detectCapabilities(c.Clientset.Discovery())
+	if err != nil {
+		return nil, err
+	}
+	c.Capabilities = capabilities
+	return capabilities, nil
+}
+
+func detectCapabilities(client discovery.DiscoveryInterface) (*Capabilities, error) {
+	info, err := client.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the server version. %+v", err)
+	}
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the server API groups. %+v", err)
+	}
+
+	capabilities := &Capabilities{GitVersion: info.GitVersion, groupVersions: map[string]bool{}}
+	for _, group := range groups.Groups {
+		for _, v := range group.Versions {
+			capabilities.groupVersions[v.GroupVersion] = true
+		}
+	}
+	capabilities.CRDv1 = capabilities.Serves(apiextensionsGroup + "/v1")
+	capabilities.CRDv1beta1 = capabilities.Serves(apiextensionsGroup + "/v1beta1")
+	capabilities.AdmissionWebhooksV1 = capabilities.Serves("admissionregistration.k8s.io/v1")
+	capabilities.Leases = capabilities.Serves("coordination.k8s.io/v1")
+
+	// the features without an API group of their own are derived from the version. If the version cannot be parsed
+	// they are assumed to be available with the v1 CRDs, which were added after both.
+	v, err := parseServerVersion(info)
+	if err != nil {
+		capabilities.StatusSubresource = capabilities.CRDv1
+		capabilities.ServerSideApply = capabilities.CRDv1
+		return capabilities, nil
+	}
+	capabilities.Major = int(v.major)
+	capabilities.Minor = int(v.minor)
+	capabilities.StatusSubresource = v.AtLeast(serverVersionV1110)
+	capabilities.ServerSideApply = v.AtLeast(serverVersionV1160)
+	return capabilities, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+func newDiscoveryServer(gitVersion string, groups string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/version":
+			fmt.Fprintf(w, `{"gitVersion":%q}`, gitVersion)
+		case "/api":
+			fmt.Fprint(w, `{"kind":"APIVersions","versions":["v1"]}`)
+		case "/apis":
+			fmt.Fprintf(w, `{"kind":"APIGroupList","groups":[%s]}`, groups)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestDetectCapabilities(t *testing.T) {
+	server := newDiscoveryServer("v1.16.3-eks.1", `
+		{"name":"apiextensions.k8s.io","versions":[{"groupVersion":"apiextensions.k8s.io/v1","version":"v1"},{"groupVersion":"apiextensions.k8s.io/v1beta1","version":"v1beta1"}]},
+		{"name":"admissionregistration.k8s.io","versions":[{"groupVersion":"admissionregistration.k8s.io/v1","version":"v1"}]}`)
+	defer server.Close()
+	client, err := discovery.NewDiscoveryClientForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+
+	capabilities, err := detectCapabilities(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.16.3-eks.1", capabilities.GitVersion)
+	assert.Equal(t, 1, capabilities.Major)
+	assert.Equal(t, 16, capabilities.Minor)
+	assert.True(t, capabilities.CRDv1)
+	assert.True(t, capabilities.CRDv1beta1)
+	assert.True(t, capabilities.StatusSubresource)
+	assert.True(t, capabilities.ServerSideApply)
+	assert.True(t, capabilities.AdmissionWebhooksV1)
+	assert.False(t, capabilities.Leases)
+	assert.True(t, capabilities.Serves("v1"))
+	assert.False(t, capabilities.Serves("apps/v1"))
+
+	// the flavor is selected from the capabilities without asking the server
+	flavor, err := detectAPIFlavor(Context{Capabilities: capabilities})
+	assert.NoError(t, err)
+	assert.Equal(t, ForceCRDv1, flavor)
+}
+
+func TestDetectCapabilitiesUnparsedVersion(t *testing.T) {
+	server := newDiscoveryServer("custom-build", `
+		{"name":"apiextensions.k8s.io","versions":[{"groupVersion":"apiextensions.k8s.io/v1beta1","version":"v1beta1"}]}`)
+	defer server.Close()
+	client, err := discovery.NewDiscoveryClientForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+
+	capabilities, err := detectCapabilities(client)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, capabilities.Major)
+	assert.False(t, capabilities.CRDv1)
+	assert.True(t, capabilities.CRDv1beta1)
+	assert.False(t, capabilities.StatusSubresource)
+	assert.False(t, capabilities.ServerSideApply)
+}
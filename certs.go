@@ -0,0 +1,324 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultCertValidity      = 365 * 24 * time.Hour
+	defaultCertRotateBefore  = 30 * 24 * time.Hour
+	defaultCertCheckInterval = time.Hour
+
+	// keys of the certificate secret
+	secretCACertKey  = "ca.crt"
+	secretTLSCertKey = v1.TLSCertKey
+	secretTLSKeyKey  = v1.TLSPrivateKeyKey
+)
+
+// CertManagerOptions configures the certificates of the webhooks of an operator
+type CertManagerOptions struct {
+	// SecretNamespace and SecretName of the secret storing the certificates, shared by all replicas of the operator
+	SecretNamespace string
+	SecretName      string
+
+	// ServiceNamespace and ServiceName of the service serving the webhooks. The serving certificate is valid for the
+	// DNS names of the service.
+	ServiceNamespace string
+	ServiceName      string
+
+	// Validity of the generated certificates. Defaults to one year.
+	Validity time.Duration
+
+	// RotateBefore is how long before the expiry new certificates are generated. Defaults to 30 days.
+	RotateBefore time.Duration
+
+	// CheckInterval at which Run checks the expiry. Defaults to one hour.
+	CheckInterval time.Duration
+
+	// Webhooks are registered with the CA bundle every time the certificates change
+	Webhooks []WebhookConfiguration
+
+	// OnRotate is optional and called with the CA bundle every time the certificates change, for example to inject
+	// it into the conversion webhooks of CRDs
+	OnRotate func(caBundle []byte) error
+}
+
+// CertManager generates a self-signed CA and a serving certificate for the webhooks of an operator, stores them in
+// a secret, and rotates them before they expire. After a rotation the CA bundle also holds the previous CA so that
+// replicas still serving the old certificate are trusted until they pick up the new one.
+type CertManager struct {
+	context  Context
+	options  CertManagerOptions
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	caBundle []byte
+}
+
+// NewCertManager creates a certificate manager. Call Ensure or Run before serving the webhooks.
+func NewCertManager(context Context, options CertManagerOptions) *CertManager {
+	return &CertManager{context: context, options: options}
+}
+
+// TLSConfig returns a TLS config for the webhook server that always serves the current certificate
+func (m *CertManager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			m.lock.RLock()
+			defer m.lock.RUnlock()
+			if m.cert == nil {
+				return nil, fmt.Errorf("no serving certificate loaded")
+			}
+			return m.cert, nil
+		},
+	}
+}
+
+// CABundle returns the PEM encoded CAs the webhook configurations must trust
+func (m *CertManager) CABundle() []byte {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.caBundle
+}
+
+// Run ensures the certificates at the check interval until the stop channel is closed
+func (m *CertManager) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := m.Ensure(); err != nil {
+			m.context.logger().Error(err, "failed to ensure the webhook certificates", "secret", m.options.SecretName)
+		}
+	}, durationOrDefault(m.options.CheckInterval, defaultCertCheckInterval), stopCh)
+}
+
+// Ensure loads the certificates from the secret, generating new ones when the secret is missing or the serving
+// certificate is about to expire, and registers the webhooks with the CA bundle when it changed
+func (m *CertManager) Ensure() error {
+	secrets := m.context.Clientset.CoreV1().Secrets(m.options.SecretNamespace)
+	secret, err := secrets.Get(m.options.SecretName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret %s. %+v", m.options.SecretName, err)
+	}
+	exists := err == nil
+
+	if !exists {
+		secret, err = m.rotate(nil)
+		if err != nil {
+			return err
+		}
+	} else if m.rotationDue(secret.Data[secretTLSCertKey]) {
+		secret, err = m.rotate(secret)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.load(secret)
+}
+
+// rotationDue returns whether the serving certificate is missing, invalid, or expires within RotateBefore
+func (m *CertManager) rotationDue(certPEM []byte) bool {
+	expiry, err := certificateExpiry(certPEM)
+	if err != nil {
+		return true
+	}
+	return time.Until(expiry) < durationOrDefault(m.options.RotateBefore, defaultCertRotateBefore)
+}
+
+// rotate generates new certificates and writes them to the existing secret, or a new one if it is nil. When another
+// replica wrote the secret first, its certificates are used instead.
+func (m *CertManager) rotate(existing *v1.Secret) (*v1.Secret, error) {
+	dnsNames := []string{
+		m.options.ServiceName,
+		fmt.Sprintf("%s.%s", m.options.ServiceName, m.options.ServiceNamespace),
+		fmt.Sprintf("%s.%s.svc", m.options.ServiceName, m.options.ServiceNamespace),
+	}
+	caPEM, certPEM, keyPEM, err := generateCertificates(dnsNames, durationOrDefault(m.options.Validity, defaultCertValidity))
+	if err != nil {
+		return nil, err
+	}
+
+	// keep trusting the previous CA until it expires
+	if existing != nil {
+		caPEM = append(caPEM, validCertificates(existing.Data[secretCACertKey])...)
+	}
+	data := map[string][]byte{
+		secretCACertKey:  caPEM,
+		secretTLSCertKey: certPEM,
+		secretTLSKeyKey:  keyPEM,
+	}
+	m.context.logger().Info("rotating the webhook certificates", "secret", m.options.SecretName)
+
+	secrets := m.context.Clientset.CoreV1().Secrets(m.options.SecretNamespace)
+	if existing == nil {
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: m.options.SecretName, Namespace: m.options.SecretNamespace},
+			Type:       v1.SecretTypeTLS,
+			Data:       data,
+		}
+		created, err := secrets.Create(secret)
+		if errors.IsAlreadyExists(err) {
+			return secrets.Get(m.options.SecretName, metav1.GetOptions{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create secret %s. %+v", m.options.SecretName, err)
+		}
+		return created, nil
+	}
+
+	existing.Data = data
+	updated, err := secrets.Update(existing)
+	if errors.IsConflict(err) {
+		return secrets.Get(m.options.SecretName, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update secret %s. %+v", m.options.SecretName, err)
+	}
+	return updated, nil
+}
+
+// load serves the certificates of the secret and registers the webhooks when the CA bundle changed
+func (m *CertManager) load(secret *v1.Secret) error {
+	cert, err := tls.X509KeyPair(secret.Data[secretTLSCertKey], secret.Data[secretTLSKeyKey])
+	if err != nil {
+		return fmt.Errorf("failed to load the certificate of secret %s. %+v", m.options.SecretName, err)
+	}
+	caBundle := secret.Data[secretCACertKey]
+
+	m.lock.Lock()
+	changed := !bytes.Equal(m.caBundle, caBundle)
+	m.cert = &cert
+	m.caBundle = caBundle
+	m.lock.Unlock()
+	if !changed {
+		return nil
+	}
+
+	webhooks := make([]WebhookConfiguration, len(m.options.Webhooks))
+	for i, webhook := range m.options.Webhooks {
+		webhook.CABundle = caBundle
+		webhooks[i] = webhook
+	}
+	if len(webhooks) > 0 {
+		if err := CreateWebhookConfigurations(m.context, webhooks); err != nil {
+			return err
+		}
+	}
+	if m.options.OnRotate != nil {
+		return m.options.OnRotate(caBundle)
+	}
+	return nil
+}
+
+// generateCertificates creates a CA and a serving certificate for the DNS names signed by it
+func generateCertificates(dnsNames []string, validity time.Duration) (caPEM, certPEM, keyPEM []byte, err error) {
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate the CA key. %+v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("%s-ca", dnsNames[0])},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create the CA certificate. %+v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse the CA certificate. %+v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate the serving key. %+v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano() + 1),
+		Subject:      pkix.Name{CommonName: dnsNames[len(dnsNames)-1]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create the serving certificate. %+v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to serialize the serving key. %+v", err)
+	}
+
+	caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return caPEM, certPEM, keyPEM, nil
+}
+
+// certificateExpiry returns the expiry of the first certificate in the PEM data
+func certificateExpiry(certPEM []byte) (time.Time, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("no certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// validCertificates returns the certificates of the PEM data that have not expired
+func validCertificates(certsPEM []byte) []byte {
+	var valid []byte
+	for {
+		var block *pem.Block
+		block, certsPEM = pem.Decode(certsPEM)
+		if block == nil {
+			return valid
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err == nil && time.Now().Before(cert.NotAfter) {
+			valid = append(valid, pem.EncodeToMemory(block)...)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateCertificates(t *testing.T) {
+	dnsNames := []string{"webhook", "webhook.operators", "webhook.operators.svc"}
+	caPEM, certPEM, keyPEM, err := generateCertificates(dnsNames, time.Hour)
+	assert.NoError(t, err)
+
+	_, err = tls.X509KeyPair(certPEM, keyPEM)
+	assert.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(caPEM))
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "webhook.operators.svc", Roots: roots})
+	assert.NoError(t, err)
+
+	expiry, err := certificateExpiry(certPEM)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiry, time.Minute)
+
+	_, err = certificateExpiry([]byte("garbage"))
+	assert.Error(t, err)
+}
+
+func TestValidCertificates(t *testing.T) {
+	valid, _, _, err := generateCertificates([]string{"a"}, time.Hour)
+	assert.NoError(t, err)
+	expired, _, _, err := generateCertificates([]string{"b"}, -time.Minute)
+	assert.NoError(t, err)
+
+	assert.Equal(t, valid, validCertificates(append(append([]byte{}, expired...), valid...)))
+	assert.Empty(t, validCertificates(nil))
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+const defaultCheckpointInterval = 30 * time.Second
+
+// ListCheckpoint is the state of an informer cache at a resourceVersion
+type ListCheckpoint struct {
+	ResourceVersion string            `json:"resourceVersion"`
+	Items           []json.RawMessage `json:"items"`
+}
+
+// CheckpointStore persists the checkpoints of informers across restarts of the operator
+type CheckpointStore interface {
+	// Load returns the checkpoint with the name, or nil if there is none
+	Load(name string) (*ListCheckpoint, error)
+
+	// Save replaces the checkpoint with the name
+	Save(name string, checkpoint *ListCheckpoint) error
+}
+
+// Checkpoints configures the checkpointing of the cache of a controller. The cache is saved with its
+// resourceVersion, and restored when the operator restarts so that the watch resumes from the resourceVersion
+// instead of listing all resources again. If the apiserver no longer has the events since the resourceVersion, the
+// watch fails with a Gone error and the resources are listed as usual.
+type Checkpoints struct {
+	// Store of the checkpoints, usually a FileCheckpointStore on a persistent volume
+	Store CheckpointStore
+
+	// Scheme has the types of the custom resources and of their lists, the kind suffixed with "List"
+	Scheme *runtime.Scheme
+
+	// Interval at which the cache is saved when it changed. Defaults to 30s.
+	Interval time.Duration
+}
+
+// FileCheckpointStore keeps each checkpoint as a JSON file in a directory, such as a persistent volume of the
+// operator pod
+type FileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore creates a store of the checkpoints in the directory, creating the directory if needed
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory %s. %+v", dir, err)
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// Load reads the checkpoint file with the name
+func (s *FileCheckpointStore) Load(name string) (*ListCheckpoint, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, name+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s. %+v", name, err)
+	}
+	checkpoint := &ListCheckpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s. %+v", name, err)
+	}
+	return checkpoint, nil
+}
+
+// Save writes the checkpoint file with the name. The file is replaced atomically so that a crash while saving
+// leaves the previous checkpoint.
+func (s *FileCheckpointStore) Save(name string, checkpoint *ListCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to serialize checkpoint %s. %+v", name, err)
+	}
+	tmp, err := ioutil.TempFile(s.dir, name)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint %s. %+v", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save checkpoint %s. %+v", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save checkpoint %s. %+v", name, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name+".json")); err != nil {
+		return fmt.Errorf("failed to save checkpoint %s. %+v", name, err)
+	}
+	return nil
+}
+
+// informerCheckpoint restores the first list of an informer from its checkpoint and saves the cache with the newest
+// resourceVersion it observed
+type informerCheckpoint struct {
+	checkpoints *Checkpoints
+	resource    CustomResource
+	name        string
+
+	lock            sync.Mutex
+	restored        bool
+	resourceVersion string
+	changed         bool
+}
+
+func newInformerCheckpoint(checkpoints *Checkpoints, resource CustomResource, namespace string) *informerCheckpoint {
+	if namespace == "" {
+		namespace = "all-namespaces"
+	}
+	return &informerCheckpoint{
+		checkpoints: checkpoints,
+		resource:    resource,
+		name:        fmt.Sprintf("%s.%s_%s", resource.Plural, resource.Group, namespace),
+	}
+}
+
+// wrapList serves the first list of the source from the checkpoint, if any. Later lists, such as after the watch
+// failed because the resourceVersion is too old, go to the apiserver.
+func (c *informerCheckpoint) wrapList(source *cache.ListWatch, context Context) {
+	listFunc := source.ListFunc
+	source.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		c.lock.Lock()
+		first := !c.restored
+		c.restored = true
+		c.lock.Unlock()
+
+		if first {
+			list, err := c.restore()
+			if err != nil {
+				context.logger().Error(err, "failed to restore the checkpoint, listing all resources", "resource", c.resource.Name)
+			}
+			if list != nil {
+				context.logger().Info("restored the cache from the checkpoint", "resource", c.resource.Name, "resourceVersion", c.resourceVersion)
+				return list, nil
+			}
+		}
+		return listFunc(options)
+	}
+}
+
+func (c *informerCheckpoint) restore() (runtime.Object, error) {
+	checkpoint, err := c.checkpoints.Store.Load(c.name)
+	if err != nil || checkpoint == nil || checkpoint.ResourceVersion == "" {
+		return nil, err
+	}
+
+	gvk := c.resource.GroupVersionKind().GroupVersion().WithKind(c.resource.Kind + "List")
+	list, err := c.checkpoints.Scheme.New(gvk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s. %+v", gvk.Kind, err)
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": checkpoint.ResourceVersion},
+		"items":    checkpoint.Items,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("failed to decode the checkpoint of %s. %+v", c.resource.Name, err)
+	}
+
+	c.lock.Lock()
+	c.resourceVersion = checkpoint.ResourceVersion
+	c.changed = false
+	c.lock.Unlock()
+	return list, nil
+}
+
+// observe records a change of the cache and the resourceVersion of the object after it entered the cache. The
+// objects of a list arrive in any order and tombstones carry their last known resourceVersion, so only a newer
+// resourceVersion is kept. Watching again from an older resourceVersion than the newest object in the cache only
+// replays events, so the checkpoint stays consistent.
+func (c *informerCheckpoint) observe(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.changed = true
+	if newerResourceVersion(accessor.GetResourceVersion(), c.resourceVersion) {
+		c.resourceVersion = accessor.GetResourceVersion()
+	}
+}
+
+// newerResourceVersion returns whether the resourceVersion is newer than the current one. The resourceVersions are
+// opaque, but compared as numbers when both are, as the etcd revisions of the apiserver are. Otherwise the
+// resourceVersion observed last is the newer.
+func newerResourceVersion(resourceVersion, current string) bool {
+	if resourceVersion == "" {
+		return false
+	}
+	version, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil {
+		return true
+	}
+	currentVersion, err := strconv.ParseUint(current, 10, 64)
+	if err != nil {
+		return true
+	}
+	return version > currentVersion
+}
+
+// save writes the cache to the store if it changed since the last save. The resourceVersion is read before the cache
+// so the cache is at least as new as the checkpoint claims.
+func (c *informerCheckpoint) save(store cache.Store) error {
+	c.lock.Lock()
+	resourceVersion, changed := c.resourceVersion, c.changed
+	if resourceVersion != "" {
+		c.changed = false
+	}
+	c.lock.Unlock()
+	if resourceVersion == "" || !changed {
+		return nil
+	}
+
+	checkpoint := &ListCheckpoint{ResourceVersion: resourceVersion, Items: []json.RawMessage{}}
+	for _, obj := range store.List() {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			c.markChanged()
+			return fmt.Errorf("failed to serialize %s for the checkpoint. %+v", c.resource.Name, err)
+		}
+		checkpoint.Items = append(checkpoint.Items, data)
+	}
+	if err := c.checkpoints.Store.Save(c.name, checkpoint); err != nil {
+		c.markChanged()
+		return err
+	}
+	return nil
+}
+
+// markChanged saves the cache again at the next interval, after a failed save
+func (c *informerCheckpoint) markChanged() {
+	c.lock.Lock()
+	c.changed = true
+	c.lock.Unlock()
+}
+
+func (c *informerCheckpoint) interval() time.Duration {
+	return durationOrDefault(c.checkpoints.Interval, defaultCheckpointInterval)
+}
+
+// observeCheckpoint records the resourceVersion of an object that entered the cache, if the cache is checkpointed
+func (c *Controller) observeCheckpoint(obj interface{}) {
+	if c.checkpoint != nil {
+		c.checkpoint.observe(obj)
+	}
+}
+
+// runCheckpoints saves the cache at the checkpoint interval and a last time when the stop channel is closed
+func (c *Controller) runCheckpoints(stopCh <-chan struct{}) {
+	save := func() {
+		if err := c.checkpoint.save(c.store); err != nil {
+			c.context.logger().Error(err, "failed to checkpoint the cache", "resource", c.resource.Name)
+		}
+	}
+	wait.Until(save, c.checkpoint.interval(), stopCh)
+	save()
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileCheckpointStore(dir)
+	assert.NoError(t, err)
+	checkpoint, err := store.Load("samples")
+	assert.NoError(t, err)
+	assert.Nil(t, checkpoint)
+
+	saved := &ListCheckpoint{ResourceVersion: "12", Items: []json.RawMessage{json.RawMessage(`{"metadata":{"name":"a"}}`)}}
+	assert.NoError(t, store.Save("samples", saved))
+	checkpoint, err = store.Load("samples")
+	assert.NoError(t, err)
+	assert.Equal(t, saved, checkpoint)
+}
+
+func TestCheckpointRestoresFirstList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileStore, err := NewFileCheckpointStore(dir)
+	assert.NoError(t, err)
+
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(schema.GroupVersion{Version: "v1"}, &v1.ConfigMap{}, &v1.ConfigMapList{})
+	checkpoints := &Checkpoints{Store: fileStore, Scheme: scheme}
+	resource := CustomResource{Name: "configmap", Plural: "configmaps", Version: "v1", Kind: "ConfigMap"}
+
+	// save the cache after observing the newest object
+	cached := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"a", "b"} {
+		assert.NoError(t, cached.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", ResourceVersion: "7"}}))
+	}
+	checkpoint := newInformerCheckpoint(checkpoints, resource, "ns")
+	assert.NoError(t, checkpoint.save(cached))
+	saved, err := fileStore.Load(checkpoint.name)
+	assert.NoError(t, err)
+	assert.Nil(t, saved)
+
+	checkpoint.observe(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "ns", ResourceVersion: "7"}})
+	assert.NoError(t, checkpoint.save(cached))
+	saved, err = fileStore.Load(checkpoint.name)
+	assert.NoError(t, err)
+	assert.Equal(t, "7", saved.ResourceVersion)
+	assert.Equal(t, 2, len(saved.Items))
+
+	// after a restart the first list comes from the checkpoint and later lists from the apiserver
+	lists := 0
+	source := &cache.ListWatch{ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+		lists++
+		return &v1.ConfigMapList{ListMeta: metav1.ListMeta{ResourceVersion: "20"}}, nil
+	}}
+	restarted := newInformerCheckpoint(checkpoints, resource, "ns")
+	restarted.wrapList(source, Context{})
+
+	obj, err := source.ListFunc(metav1.ListOptions{})
+	assert.NoError(t, err)
+	list := obj.(*v1.ConfigMapList)
+	assert.Equal(t, 0, lists)
+	assert.Equal(t, "7", list.ResourceVersion)
+	assert.Equal(t, 2, len(list.Items))
+	assert.Equal(t, "a", list.Items[0].Name)
+
+	obj, err = source.ListFunc(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, lists)
+	assert.Equal(t, "20", obj.(*v1.ConfigMapList).ResourceVersion)
+}
+
+// memoryCheckpointStore counts the saves of the checkpoints
+type memoryCheckpointStore struct {
+	lock        sync.Mutex
+	saves       int
+	checkpoints map[string]*ListCheckpoint
+}
+
+func (s *memoryCheckpointStore) Load(name string) (*ListCheckpoint, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.checkpoints[name], nil
+}
+
+func (s *memoryCheckpointStore) Save(name string, checkpoint *ListCheckpoint) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.saves++
+	s.checkpoints[name] = checkpoint
+	return nil
+}
+
+func TestCheckpointSavesNewestResourceVersion(t *testing.T) {
+	store := &memoryCheckpointStore{checkpoints: map[string]*ListCheckpoint{}}
+	resource := CustomResource{Name: "configmap", Plural: "configmaps", Version: "v1", Kind: "ConfigMap"}
+	checkpoint := newInformerCheckpoint(&Checkpoints{Store: store}, resource, "ns")
+	cached := cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	// the items of a list arrive in any order, and tombstones have their last known resourceVersion
+	checkpoint.observe(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "ns", ResourceVersion: "9"}})
+	checkpoint.observe(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "ns", ResourceVersion: "5"}})
+	checkpoint.observe(cache.DeletedFinalStateUnknown{Key: "ns/c",
+		Obj: &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "ns", ResourceVersion: "3"}}})
+	assert.NoError(t, checkpoint.save(cached))
+	assert.Equal(t, "9", store.checkpoints[checkpoint.name].ResourceVersion)
+
+	// the cache is saved only when it changed, even if the resourceVersion did not
+	assert.NoError(t, checkpoint.save(cached))
+	assert.Equal(t, 1, store.saves)
+	checkpoint.observe(cache.DeletedFinalStateUnknown{Key: "ns/b",
+		Obj: &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "ns", ResourceVersion: "5"}}})
+	assert.NoError(t, checkpoint.save(cached))
+	assert.Equal(t, 2, store.saves)
+	assert.Equal(t, "9", store.checkpoints[checkpoint.name].ResourceVersion)
+}
+
+func TestCheckpointConcurrentObserveAndSave(t *testing.T) {
+	store := &memoryCheckpointStore{checkpoints: map[string]*ListCheckpoint{}}
+	resource := CustomResource{Name: "configmap", Plural: "configmaps", Version: "v1", Kind: "ConfigMap"}
+	checkpoint := newInformerCheckpoint(&Checkpoints{Store: store}, resource, "ns")
+	cached := cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	// observed by the informer while saved at the checkpoint interval
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 100; i++ {
+			obj := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "ns", ResourceVersion: strconv.Itoa(i)}}
+			cached.Add(obj)
+			checkpoint.observe(obj)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, checkpoint.save(cached))
+		}
+	}()
+	wg.Wait()
+
+	assert.NoError(t, checkpoint.save(cached))
+	assert.Equal(t, "100", store.checkpoints[checkpoint.name].ResourceVersion)
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ChildStatus is the status of a child object following the conventions of kstatus
+type ChildStatus string
+
+const (
+	// CurrentStatus is the status of a child that reached its desired state
+	CurrentStatus ChildStatus = "Current"
+
+	// InProgressStatus is the status of a child still working towards its desired state
+	InProgressStatus ChildStatus = "InProgress"
+
+	// FailedStatus is the status of a child that cannot reach its desired state without a change
+	FailedStatus ChildStatus = "Failed"
+
+	// TerminatingStatus is the status of a child being deleted
+	TerminatingStatus ChildStatus = "Terminating"
+
+	// NotFoundStatus is the status of a child that does not exist
+	NotFoundStatus ChildStatus = "NotFound"
+
+	// UnknownStatus is the status of a child that could not be read
+	UnknownStatus ChildStatus = "Unknown"
+)
+
+const (
+	// ReadyConditionType is the type of the condition set while all the children of a custom resource are current
+	ReadyConditionType = "Ready"
+
+	// ProgressingConditionType is the type of the condition set while children of a custom resource are in progress
+	ProgressingConditionType = "Progressing"
+
+	// StalledConditionType is the type of the condition set while children of a custom resource failed
+	StalledConditionType = "Stalled"
+
+	// ConditionReasonChildrenCurrent is the reason of the conditions once all the children are current
+	ConditionReasonChildrenCurrent = "ChildrenCurrent"
+
+	// ConditionReasonChildrenInProgress is the reason of the conditions while children are in progress
+	ConditionReasonChildrenInProgress = "ChildrenInProgress"
+
+	// ConditionReasonChildFailed is the reason of the conditions while children failed
+	ConditionReasonChildFailed = "ChildFailed"
+)
+
+// ChildState is the status of a child object of a custom resource
+type ChildState struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Status    ChildStatus
+	Message   string
+}
+
+func (s ChildState) String() string {
+	name := s.Name
+	if s.Namespace != "" {
+		name = s.Namespace + "/" + s.Name
+	}
+	if s.Message == "" {
+		return fmt.Sprintf("%s %s is %s", s.Kind, name, s.Status)
+	}
+	return fmt.Sprintf("%s %s is %s: %s", s.Kind, name, s.Status, s.Message)
+}
+
+// childStatusFields are the fields of any object the status is computed from
+type childStatusFields struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Namespace         string       `json:"namespace"`
+		Name              string       `json:"name"`
+		Generation        int64        `json:"generation"`
+		DeletionTimestamp *metav1.Time `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		Type string `json:"type"`
+	} `json:"spec"`
+	Status struct {
+		ObservedGeneration *int64      `json:"observedGeneration"`
+		Phase              string      `json:"phase"`
+		Conditions         []Condition `json:"conditions"`
+		LoadBalancer       struct {
+			Ingress []interface{} `json:"ingress"`
+		} `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// ComputeChildState returns the status of the typed or unstructured child object. Deployments, stateful sets, jobs,
+// pods, persistent volume claims, and services are checked by kind. The status of other objects, such as custom
+// resources, follows their observed generation and their Stalled, Reconciling, and Ready conditions.
+func ComputeChildState(obj runtime.Object) ChildState {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return ChildState{Status: UnknownStatus, Message: err.Error()}
+	}
+	fields := childStatusFields{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ChildState{Status: UnknownStatus, Message: err.Error()}
+	}
+	state := ChildState{Kind: childKind(obj, fields.Kind), Namespace: fields.Metadata.Namespace, Name: fields.Metadata.Name}
+	state.Status, state.Message = childStatus(state.Kind, data, fields)
+	return state
+}
+
+// childKind returns the kind of the object, which typed objects read from the API server often do not set
+func childKind(obj runtime.Object, kind string) string {
+	if kind != "" {
+		return kind
+	}
+	switch obj.(type) {
+	case *appsv1beta2.Deployment:
+		return "Deployment"
+	case *appsv1beta2.StatefulSet:
+		return "StatefulSet"
+	case *batchv1.Job:
+		return "Job"
+	case *v1.Pod:
+		return "Pod"
+	case *v1.PersistentVolumeClaim:
+		return "PersistentVolumeClaim"
+	case *v1.Service:
+		return "Service"
+	}
+	return ""
+}
+
+func childStatus(kind string, data []byte, fields childStatusFields) (ChildStatus, string) {
+	if fields.Metadata.DeletionTimestamp != nil {
+		return TerminatingStatus, "being deleted"
+	}
+	if observed := fields.Status.ObservedGeneration; observed != nil && *observed < fields.Metadata.Generation {
+		return InProgressStatus, "the latest generation is not observed yet"
+	}
+
+	switch kind {
+	case "Deployment":
+		deployment := &appsv1beta2.Deployment{}
+		if err := json.Unmarshal(data, deployment); err != nil {
+			return UnknownStatus, err.Error()
+		}
+		ready, err := DeploymentReady(deployment)
+		if err != nil {
+			return FailedStatus, err.Error()
+		}
+		if !ready {
+			return InProgressStatus, fmt.Sprintf("%d of %d replicas are updated and available",
+				deployment.Status.AvailableReplicas, desiredReplicas(deployment.Spec.Replicas))
+		}
+	case "StatefulSet":
+		statefulSet := &appsv1beta2.StatefulSet{}
+		if err := json.Unmarshal(data, statefulSet); err != nil {
+			return UnknownStatus, err.Error()
+		}
+		if !StatefulSetReady(statefulSet) {
+			return InProgressStatus, fmt.Sprintf("%d of %d replicas are ready",
+				statefulSet.Status.ReadyReplicas, desiredReplicas(statefulSet.Spec.Replicas))
+		}
+	case "Job":
+		job := &batchv1.Job{}
+		if err := json.Unmarshal(data, job); err != nil {
+			return UnknownStatus, err.Error()
+		}
+		complete, err := JobComplete(job)
+		if err != nil {
+			return FailedStatus, err.Error()
+		}
+		if !complete {
+			return InProgressStatus, "the job is running"
+		}
+	case "Pod":
+		switch fields.Status.Phase {
+		case string(v1.PodSucceeded):
+			return CurrentStatus, ""
+		case string(v1.PodFailed):
+			return FailedStatus, "the pod failed"
+		}
+		if ready := FindCondition(fields.Status.Conditions, string(v1.PodReady)); ready == nil || ready.Status != v1.ConditionTrue {
+			return InProgressStatus, "the pod is not ready"
+		}
+	case "PersistentVolumeClaim":
+		if fields.Status.Phase != string(v1.ClaimBound) {
+			return InProgressStatus, "the claim is not bound"
+		}
+	case "Service":
+		if fields.Spec.Type == string(v1.ServiceTypeLoadBalancer) && len(fields.Status.LoadBalancer.Ingress) == 0 {
+			return InProgressStatus, "the load balancer is not provisioned"
+		}
+	default:
+		conditions := fields.Status.Conditions
+		if stalled := FindCondition(conditions, StalledConditionType); stalled != nil && stalled.Status == v1.ConditionTrue {
+			return FailedStatus, stalled.Message
+		}
+		if reconciling := FindCondition(conditions, "Reconciling"); reconciling != nil && reconciling.Status == v1.ConditionTrue {
+			return InProgressStatus, reconciling.Message
+		}
+		if ready := FindCondition(conditions, ReadyConditionType); ready != nil && ready.Status == v1.ConditionFalse {
+			return InProgressStatus, ready.Message
+		}
+	}
+	return CurrentStatus, ""
+}
+
+// AggregateChildConditions sets the Ready, Progressing, and Stalled conditions from the states of the children of a
+// custom resource. A failed child stalls the resource, while children terminating, missing, unknown, or in progress
+// keep it progressing. The resource is ready once all its children are current.
+func AggregateChildConditions(conditions []Condition, children []ChildState) []Condition {
+	var failed, progressing []string
+	for _, child := range children {
+		switch child.Status {
+		case CurrentStatus:
+		case FailedStatus:
+			failed = append(failed, child.String())
+		default:
+			progressing = append(progressing, child.String())
+		}
+	}
+
+	ready := Condition{Type: ReadyConditionType, Status: v1.ConditionTrue, Reason: ConditionReasonChildrenCurrent,
+		Message: "all the children are current"}
+	progress := Condition{Type: ProgressingConditionType, Status: v1.ConditionFalse, Reason: ConditionReasonChildrenCurrent}
+	stalled := Condition{Type: StalledConditionType, Status: v1.ConditionFalse, Reason: ConditionReasonChildrenCurrent}
+	switch {
+	case len(failed) > 0:
+		message := strings.Join(failed, "; ")
+		ready = Condition{Type: ReadyConditionType, Status: v1.ConditionFalse, Reason: ConditionReasonChildFailed, Message: message}
+		progress.Reason = ConditionReasonChildFailed
+		stalled = Condition{Type: StalledConditionType, Status: v1.ConditionTrue, Reason: ConditionReasonChildFailed, Message: message}
+	case len(progressing) > 0:
+		message := strings.Join(progressing, "; ")
+		ready = Condition{Type: ReadyConditionType, Status: v1.ConditionFalse, Reason: ConditionReasonChildrenInProgress, Message: message}
+		progress = Condition{Type: ProgressingConditionType, Status: v1.ConditionTrue, Reason: ConditionReasonChildrenInProgress,
+			Message: message}
+		stalled.Reason = ConditionReasonChildrenInProgress
+	}
+	for _, condition := range []Condition{ready, progress, stalled} {
+		conditions = SetCondition(conditions, condition)
+	}
+	return conditions
+}
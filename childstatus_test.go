@@ -0,0 +1,92 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestComputeChildState(t *testing.T) {
+	replicas := int32(2)
+	now := metav1.Now()
+	tests := []struct {
+		obj    runtime.Object
+		status ChildStatus
+	}{
+		{&appsv1beta2.Deployment{Spec: appsv1beta2.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1beta2.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}}, InProgressStatus},
+		{&appsv1beta2.Deployment{Spec: appsv1beta2.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1beta2.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}}, CurrentStatus},
+		{&appsv1beta2.Deployment{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}}, TerminatingStatus},
+		{&batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue}}}}, FailedStatus},
+		{&v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning}}, InProgressStatus},
+		{&v1.Pod{Status: v1.PodStatus{Phase: v1.PodSucceeded}}, CurrentStatus},
+		{&v1.PersistentVolumeClaim{Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound}}, CurrentStatus},
+		{&v1.Service{Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer}}, InProgressStatus},
+		{&v1.ConfigMap{}, CurrentStatus},
+		{&unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     "Deployment",
+			"metadata": map[string]interface{}{"name": "d", "generation": int64(3)},
+			"status":   map[string]interface{}{"observedGeneration": int64(2)},
+		}}, InProgressStatus},
+		{&unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":   "Cluster",
+			"status": map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Stalled", "status": "True"}}},
+		}}, FailedStatus},
+		{&unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":   "Cluster",
+			"status": map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "False"}}},
+		}}, InProgressStatus},
+	}
+	for i, test := range tests {
+		state := ComputeChildState(test.obj)
+		assert.Equal(t, test.status, state.Status, "test %d: %s", i, state)
+	}
+
+	state := ComputeChildState(&appsv1beta2.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "d"}})
+	assert.Equal(t, ChildState{Kind: "Deployment", Namespace: "ns", Name: "d", Status: InProgressStatus,
+		Message: "0 of 1 replicas are updated and available"}, state)
+}
+
+func TestAggregateChildConditions(t *testing.T) {
+	current := ChildState{Kind: "Service", Name: "s", Status: CurrentStatus}
+	conditions := AggregateChildConditions(nil, []ChildState{current})
+	assert.Equal(t, v1.ConditionTrue, FindCondition(conditions, ReadyConditionType).Status)
+	assert.Equal(t, v1.ConditionFalse, FindCondition(conditions, ProgressingConditionType).Status)
+	assert.Equal(t, v1.ConditionFalse, FindCondition(conditions, StalledConditionType).Status)
+
+	conditions = AggregateChildConditions(conditions, []ChildState{current, {Kind: "Deployment", Namespace: "ns", Name: "d", Status: NotFoundStatus}})
+	assert.Equal(t, 3, len(conditions))
+	assert.Equal(t, v1.ConditionFalse, FindCondition(conditions, ReadyConditionType).Status)
+	progressing := FindCondition(conditions, ProgressingConditionType)
+	assert.Equal(t, v1.ConditionTrue, progressing.Status)
+	assert.Equal(t, "Deployment ns/d is NotFound", progressing.Message)
+
+	conditions = AggregateChildConditions(conditions, []ChildState{{Kind: "Job", Name: "j", Status: FailedStatus, Message: "failed"}})
+	stalled := FindCondition(conditions, StalledConditionType)
+	assert.Equal(t, v1.ConditionTrue, stalled.Status)
+	assert.Equal(t, ConditionReasonChildFailed, stalled.Reason)
+	assert.Equal(t, "Job j is Failed: failed", stalled.Message)
+	assert.Equal(t, v1.ConditionFalse, FindCondition(conditions, ProgressingConditionType).Status)
+}
@@ -24,10 +24,6 @@ import (
 	"k8s.io/client-go/rest"
 )
 
-const (
-	serverVersionV170 = "v1.7.0"
-)
-
 // NewHTTPClient creates a Kubernetes client to interact with API extensions for Custom Resources
 func NewHTTPClient(group, version string, schemeBuilder runtime.SchemeBuilder) (rest.Interface, *runtime.Scheme, error) {
 	config, err := rest.InClusterConfig()
@@ -45,16 +41,21 @@ func NewHTTPClientFromConfig(group, version string, schemeBuilder runtime.Scheme
 		return nil, nil, err
 	}
 
+	client, err := newRESTClient(config, group, version, scheme)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, scheme, nil
+}
+
+// newRESTClient configures the config for the group and version and creates a client using the scheme's codecs
+func newRESTClient(config *rest.Config, group, version string, scheme *runtime.Scheme) (*rest.RESTClient, error) {
 	config.GroupVersion = &schema.GroupVersion{Group: group, Version: version}
 
 	config.APIPath = "/apis"
 	config.ContentType = runtime.ContentTypeJSON
 	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)}
 
-	client, err := rest.RESTClientFor(config)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return client, scheme, nil
+	return rest.RESTClientFor(config)
 }
@@ -0,0 +1,279 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// CloudEventSpecVersion is the version of the CloudEvents specification of the published events
+	CloudEventSpecVersion = "1.0"
+
+	// CloudEventResourceCreated is the type of the events of created custom resources
+	CloudEventResourceCreated = "io.operatorkit.resource.created"
+	// CloudEventResourceUpdated is the type of the events of updated custom resources
+	CloudEventResourceUpdated = "io.operatorkit.resource.updated"
+	// CloudEventResourceDeleted is the type of the events of deleted custom resources
+	CloudEventResourceDeleted = "io.operatorkit.resource.deleted"
+	// CloudEventReconcileSucceeded is the type of the events of successful reconciles
+	CloudEventReconcileSucceeded = "io.operatorkit.reconcile.succeeded"
+	// CloudEventReconcileFailed is the type of the events of failed reconciles
+	CloudEventReconcileFailed = "io.operatorkit.reconcile.failed"
+
+	cloudEventContentType    = "application/cloudevents+json"
+	defaultCloudEventBuffer  = 100
+	defaultCloudEventTimeout = 10 * time.Second
+)
+
+// defaultCloudEventClient bounds the time a stalled sink blocks the publishing of the events
+var defaultCloudEventClient = &http.Client{Timeout: defaultCloudEventTimeout}
+
+// CloudEvent is a CloudEvent in the structured JSON format. The subject is the key of the custom resource.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// ReconcileEventData is the data of the reconcile events
+type ReconcileEventData struct {
+	Key         string  `json:"key"`
+	ReconcileID string  `json:"reconcileID"`
+	Result      string  `json:"result"`
+	Duration    float64 `json:"durationSeconds"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// CloudEventSink publishes CloudEvents to an external system
+type CloudEventSink interface {
+	Send(event CloudEvent) error
+}
+
+// CloudEventSinkFunc is a func publishing CloudEvents, such as one publishing the marshaled event to a NATS subject
+type CloudEventSinkFunc func(event CloudEvent) error
+
+// Send calls the func
+func (f CloudEventSinkFunc) Send(event CloudEvent) error {
+	return f(event)
+}
+
+// HTTPCloudEventSink posts CloudEvents in the structured JSON mode to the URL
+type HTTPCloudEventSink struct {
+	URL string
+
+	// Client sends the requests. Defaults to a client with a timeout of 10s.
+	Client *http.Client
+
+	// Headers are added to each request, such as an authorization header
+	Headers map[string]string
+}
+
+// Send posts the event, failing unless the response has a 2xx status
+func (s *HTTPCloudEventSink) Send(event CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the event %s. %+v", event.ID, err)
+	}
+	request, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create the request for %s. %+v", s.URL, err)
+	}
+	request.Header.Set("Content-Type", cloudEventContentType)
+	for name, value := range s.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := s.client().Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send the event %s to %s. %+v", event.ID, s.URL, err)
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("failed to send the event %s to %s. status %d", event.ID, s.URL, response.StatusCode)
+	}
+	return nil
+}
+
+func (s *HTTPCloudEventSink) client() *http.Client {
+	if s.Client == nil {
+		return defaultCloudEventClient
+	}
+	return s.Client
+}
+
+// CloudEventOptions configures the CloudEvents published by a controller on the creation, update, and deletion of
+// the custom resources and on the results of their reconciles. The resources listed when the controller starts
+// are not published as created. The data of the resource events is the redacted resource.
+type CloudEventOptions struct {
+	Sink CloudEventSink
+
+	// Source is the source of the events. Defaults to /apis/<group>/<version>/<plural>.
+	Source string
+
+	// Buffer is the number of events queued while the sink is slow or unavailable. Further events are dropped.
+	// Defaults to 100.
+	Buffer int
+}
+
+// newCloudEvent creates an event with a random ID and the data marshaled to JSON
+func newCloudEvent(source, eventType, subject string, data interface{}) (CloudEvent, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to generate an event ID. %+v", err)
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to marshal the data of %s. %+v", subject, err)
+	}
+	return CloudEvent{
+		SpecVersion:     CloudEventSpecVersion,
+		ID:              hex.EncodeToString(id),
+		Source:          source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            raw,
+	}, nil
+}
+
+// cloudEventSource returns the source of the events of the controller
+func (c *Controller) cloudEventSource() string {
+	if c.options.CloudEvents.Source != "" {
+		return c.options.CloudEvents.Source
+	}
+	return fmt.Sprintf("/apis/%s/%s/%s", c.resource.Group, c.resource.Version, c.resource.Plural)
+}
+
+// skipReplayedAdds records the keys in the cache of a running shared informer when the controller is added to it.
+// The informer replays them to the controller as adds, which are not published as created.
+func (c *Controller) skipReplayedAdds(keys []string) {
+	if c.cloudEvents == nil || len(keys) == 0 {
+		return
+	}
+	c.replayedLock.Lock()
+	defer c.replayedLock.Unlock()
+	c.replayed = map[string]bool{}
+	for _, key := range keys {
+		c.replayed[key] = true
+	}
+}
+
+// replayedAdd returns whether the add of the key is the replay of a resource that existed when the controller was
+// added to a running shared informer
+func (c *Controller) replayedAdd(key string) bool {
+	c.replayedLock.Lock()
+	defer c.replayedLock.Unlock()
+	if !c.replayed[key] {
+		return false
+	}
+	delete(c.replayed, key)
+	return true
+}
+
+// emitResourceEvent queues the event of an added, updated, or deleted custom resource. Adds of the initial list of
+// the controller, before the cache is synced or replayed by a running shared informer, are the existing resources,
+// and updates without a new resourceVersion are resyncs.
+func (c *Controller) emitResourceEvent(eventType string, oldObj, obj interface{}) {
+	if c.cloudEvents == nil {
+		return
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if eventType == CloudEventResourceUpdated {
+		old, oldErr := meta.Accessor(oldObj)
+		updated, err := meta.Accessor(obj)
+		if oldErr == nil && err == nil && old.GetResourceVersion() == updated.GetResourceVersion() {
+			return
+		}
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	if eventType == CloudEventResourceCreated && ((c.hasSynced != nil && !c.hasSynced()) || c.replayedAdd(key)) {
+		return
+	}
+	c.emitCloudEvent(eventType, key, Redact(obj))
+}
+
+// emitReconcileEvent queues the event of the result of a reconcile
+func (c *Controller) emitReconcileEvent(trace ReconcileTrace, outcome string, duration time.Duration, err error) {
+	if c.cloudEvents == nil {
+		return
+	}
+	data := ReconcileEventData{Key: trace.Key, ReconcileID: trace.ID, Result: outcome, Duration: duration.Seconds()}
+	eventType := CloudEventReconcileSucceeded
+	if err != nil {
+		eventType = CloudEventReconcileFailed
+		data.Error = err.Error()
+		if obj, exists, getErr := c.store.GetByKey(trace.Key); getErr == nil && exists {
+			data.Error = RedactText(data.Error, obj)
+		}
+	}
+	c.emitCloudEvent(eventType, trace.Key, data)
+}
+
+// emitCloudEvent queues an event without blocking, dropping it if the buffer is full
+func (c *Controller) emitCloudEvent(eventType, key string, data interface{}) {
+	event, err := newCloudEvent(c.cloudEventSource(), eventType, key, data)
+	if err != nil {
+		c.context.logger().Error(err, "failed to create the cloud event", "resource", c.resource.Name, "key", key)
+		return
+	}
+	select {
+	case c.cloudEvents <- event:
+	default:
+		c.context.logger().Info("dropping the cloud event since the buffer is full", "resource", c.resource.Name,
+			"key", key, "type", eventType)
+	}
+}
+
+// publishCloudEvents sends the queued events to the sink until the stop channel is closed
+func (c *Controller) publishCloudEvents(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case event := <-c.cloudEvents:
+			if err := c.options.CloudEvents.Sink.Send(event); err != nil {
+				c.context.logger().Error(err, "failed to publish the cloud event", "resource", c.resource.Name,
+					"key", event.Subject, "type", event.Type)
+			}
+		}
+	}
+}
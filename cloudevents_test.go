@@ -0,0 +1,134 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestHTTPCloudEventSink(t *testing.T) {
+	var received CloudEvent
+	var contentType, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType, auth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		if received.Subject == "ns/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	sink := &HTTPCloudEventSink{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}}
+	event, err := newCloudEvent("/apis/example.com/v1alpha/examples", CloudEventResourceCreated, "ns/a", map[string]string{"a": "b"})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Send(event))
+	assert.Equal(t, "application/cloudevents+json", contentType)
+	assert.Equal(t, "Bearer token", auth)
+	assert.Equal(t, CloudEventSpecVersion, received.SpecVersion)
+	assert.Equal(t, event.ID, received.ID)
+	assert.Equal(t, CloudEventResourceCreated, received.Type)
+	assert.Equal(t, "ns/a", received.Subject)
+	assert.JSONEq(t, `{"a":"b"}`, string(received.Data))
+
+	event.Subject = "ns/broken"
+	assert.Error(t, sink.Send(event))
+}
+
+func TestHTTPCloudEventSinkDefaultClient(t *testing.T) {
+	assert.Equal(t, 10*time.Second, (&HTTPCloudEventSink{}).client().Timeout)
+	client := &http.Client{}
+	assert.Equal(t, client, (&HTTPCloudEventSink{Client: client}).client())
+}
+
+func TestControllerCloudEvents(t *testing.T) {
+	c := newController(Context{}, exampleResource, nil, nil, ControllerOptions{
+		CloudEvents: &CloudEventOptions{Sink: CloudEventSinkFunc(func(CloudEvent) error { return nil })},
+	})
+	c.store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	handlers := c.eventHandlers()
+	old := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", ResourceVersion: "1"}}
+	updated := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", ResourceVersion: "2"}}
+
+	handlers.AddFunc(old)
+	handlers.UpdateFunc(old, old)
+	handlers.UpdateFunc(old, updated)
+	handlers.DeleteFunc(cache.DeletedFinalStateUnknown{Key: "ns/a", Obj: updated})
+	c.emitReconcileEvent(ReconcileTrace{Key: "ns/a", ID: "abc"}, resultError, 0, errors.New("failed"))
+
+	// the resync without a new resourceVersion is not published
+	var types []string
+	for len(c.cloudEvents) > 0 {
+		event := <-c.cloudEvents
+		assert.Equal(t, "/apis/example.com/v1alpha/examples", event.Source)
+		assert.Equal(t, "ns/a", event.Subject)
+		types = append(types, event.Type)
+		if event.Type == CloudEventReconcileFailed {
+			assert.JSONEq(t, `{"key":"ns/a","reconcileID":"abc","result":"error","durationSeconds":0,"error":"failed"}`, string(event.Data))
+		}
+	}
+	assert.Equal(t, []string{CloudEventResourceCreated, CloudEventResourceUpdated, CloudEventResourceDeleted, CloudEventReconcileFailed}, types)
+}
+
+func TestCloudEventsSkipListedResources(t *testing.T) {
+	c := newController(Context{}, exampleResource, nil, nil, ControllerOptions{
+		CloudEvents: &CloudEventOptions{Sink: CloudEventSinkFunc(func(CloudEvent) error { return nil }), Buffer: 1},
+	})
+	synced := false
+	c.hasSynced = func() bool { return synced }
+	handlers := c.eventHandlers()
+
+	handlers.AddFunc(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "listed"}})
+	assert.Equal(t, 0, len(c.cloudEvents))
+
+	// events beyond the buffer are dropped rather than blocking the informer
+	synced = true
+	handlers.AddFunc(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}})
+	handlers.AddFunc(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "b"}})
+	assert.Equal(t, 1, len(c.cloudEvents))
+	assert.Equal(t, "ns/a", (<-c.cloudEvents).Subject)
+}
+
+func TestCloudEventsSkipReplayedResources(t *testing.T) {
+	c := newController(Context{}, exampleResource, nil, nil, ControllerOptions{
+		CloudEvents: &CloudEventOptions{Sink: CloudEventSinkFunc(func(CloudEvent) error { return nil })},
+	})
+	c.hasSynced = func() bool { return true }
+	c.skipReplayedAdds([]string{"ns/listed"})
+	handlers := c.eventHandlers()
+
+	// the shared informer was synced before the controller was added to it, and replays its cache
+	listed := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "listed"}}
+	handlers.AddFunc(listed)
+	assert.Equal(t, 0, len(c.cloudEvents))
+
+	handlers.AddFunc(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}})
+	handlers.AddFunc(listed)
+	assert.Equal(t, 2, len(c.cloudEvents))
+	assert.Equal(t, "ns/a", (<-c.cloudEvents).Subject)
+	assert.Equal(t, "ns/listed", (<-c.cloudEvents).Subject)
+}
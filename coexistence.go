@@ -0,0 +1,132 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CRDOwnerLabel is set on the CRDs created by a primary operator to the OperatorName of its context
+const CRDOwnerLabel = "operatorkit.io/owner"
+
+// CRDRole declares whether the operator owns the CRD of a custom resource or shares a CRD owned by another operator
+type CRDRole int
+
+const (
+	// PrimaryCRDRole creates the CRD, and recreates it if the controller is configured to. The CRD is labeled with
+	// the OperatorName of the context, and a CRD labeled by another operator fails to install.
+	PrimaryCRDRole CRDRole = iota
+	// SecondaryCRDRole never creates, updates, or deletes the CRD. The install waits for the primary operator to
+	// create the CRD and verifies that it serves the kind and version of the custom resource.
+	SecondaryCRDRole
+)
+
+// crdDefinition is what is verified on an existing CRD, by a secondary operator on a CRD owned by another operator
+// and by a primary operator on a CRD that already existed
+type crdDefinition struct {
+	group    string
+	scope    string
+	kind     string
+	versions []string
+}
+
+// crdLabels returns the labels of a CRD created by the operator of the context
+func (c Context) crdLabels() map[string]string {
+	if c.OperatorName == "" {
+		return nil
+	}
+	return map[string]string{CRDOwnerLabel: c.OperatorName}
+}
+
+// checkCRDOwner fails if the existing CRD of a primary operator is owned by another operator. CRDs without the
+// owner label were created before the policy or by hand and are adopted as they are.
+func checkCRDOwner(context Context, resource CustomResource, labels map[string]string) error {
+	owner := labels[CRDOwnerLabel]
+	if owner == "" || context.OperatorName == "" || owner == context.OperatorName {
+		return nil
+	}
+	return fmt.Errorf("%s CRD is owned by operator %s. declare the resource with the secondary role to share the CRD", resource.Name, owner)
+}
+
+// verifyCRD waits for another operator to create the CRD of the resource and checks that it serves the kind and
+// version of the resource. get returns nil while the CRD does not exist.
+func verifyCRD(context Context, resource CustomResource, get func() (*crdDefinition, error)) (InstallOutcome, error) {
+	var crd *crdDefinition
+	err := context.waitStrategy().Wait(context.waitInterval(), context.Timeout, nil, func() (bool, error) {
+		var err error
+		crd, err = get()
+		return crd != nil, err
+	})
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("%s CRD was not created by the primary operator. %+v", resource.Name, err)
+	}
+
+	if crd.kind != resource.Kind {
+		return OutcomeFailed, fmt.Errorf("%s CRD has kind %s instead of %s", resource.Name, crd.kind, resource.Kind)
+	}
+	for _, version := range crd.versions {
+		if version == resource.Version {
+			return OutcomeVerified, nil
+		}
+	}
+	return OutcomeFailed, fmt.Errorf("%s CRD does not serve version %s", resource.Name, resource.Version)
+}
+
+func verifyCRDv1beta1(context Context, resource CustomResource) (InstallOutcome, error) {
+	crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
+	return verifyCRD(context, resource, func() (*crdDefinition, error) {
+		crd, err := context.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions().Get(crdName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		definition := crdV1beta1Definition(crd)
+		return &definition, nil
+	})
+}
+
+func verifyCRDv1(context Context, resource CustomResource) (InstallOutcome, error) {
+	crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
+	restcli := context.APIExtensionClientset.Discovery().RESTClient()
+	return verifyCRD(context, resource, func() (*crdDefinition, error) {
+		raw, err := restcli.Get().AbsPath(crdV1Path, crdName).DoRaw()
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		crd := &crdV1{}
+		if err := json.Unmarshal(raw, crd); err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s. %+v", crdName, err)
+		}
+		definition := crdV1Definition(crd)
+		return &definition, nil
+	})
+}
+
+// verifyTPR fails since TPRs have no kind or versions to verify and are created by whichever operator runs first
+func verifyTPR(context Context, resource CustomResource) (InstallOutcome, error) {
+	return OutcomeFailed, fmt.Errorf("%s cannot have the secondary role since the cluster only supports TPRs", resource.Name)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsclientfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCRDRoles(t *testing.T) {
+	resource := exampleResource
+	resource.Kind = "Example"
+	secondaryResource := resource
+	secondaryResource.Role = SecondaryCRDRole
+
+	primary := NewFakeContext()
+	primary.OperatorName = "first"
+	secondary := primary
+	secondary.OperatorName = "second"
+	secondary.Timeout = 100 * time.Millisecond
+
+	// the secondary waits for the primary to create the CRD
+	report, err := CreateCustomResourcesWithReport(secondary, []CustomResource{secondaryResource})
+	assert.Error(t, err)
+	assert.Equal(t, OutcomeFailed, report.Resources[0].Outcome)
+	assert.Equal(t, 0, countCRDActions(secondary, "create"))
+
+	report, err = CreateCustomResourcesWithReport(primary, []CustomResource{resource})
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeCreated, report.Resources[0].Outcome)
+	crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
+	crd, err := primary.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions().Get(crdName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "first", crd.Labels[CRDOwnerLabel])
+
+	report, err = CreateCustomResourcesWithReport(secondary, []CustomResource{secondaryResource})
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeVerified, report.Resources[0].Outcome)
+	// only the create of the primary was sent
+	assert.Equal(t, 1, countCRDActions(secondary, "create"))
+	assert.Equal(t, 0, countCRDActions(secondary, "update"))
+
+	// a second primary does not take over the CRD
+	_, err = CreateCustomResourcesWithReport(secondary, []CustomResource{resource})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "owned by operator first")
+
+	// the secondary fails when the CRD does not match its resource
+	mismatched := secondaryResource
+	mismatched.Version = "v2"
+	_, err = CreateCustomResourcesWithReport(secondary, []CustomResource{mismatched})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not serve version v2")
+}
+
+func countCRDActions(context Context, verb string) int {
+	count := 0
+	for _, action := range context.APIExtensionClientset.(*apiextensionsclientfake.Clientset).Actions() {
+		if action.GetVerb() == verb && action.GetResource().Resource == "customresourcedefinitions" {
+			count++
+		}
+	}
+	return count
+}
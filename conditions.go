@@ -0,0 +1,223 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Condition is an observation of the state of a custom resource, kept in the conditions of its status
+type Condition struct {
+	Type               string             `json:"type"`
+	Status             v1.ConditionStatus `json:"status"`
+	Reason             string             `json:"reason,omitempty"`
+	Message            string             `json:"message,omitempty"`
+	LastTransitionTime metav1.Time        `json:"lastTransitionTime,omitempty"`
+}
+
+// ConditionReason is a reason a condition may be set for
+type ConditionReason struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ConditionType declares a condition type and the reasons it may be set for
+type ConditionType struct {
+	Type        string            `json:"type"`
+	Description string            `json:"description"`
+	Reasons     []ConditionReason `json:"reasons"`
+
+	// AlertStatus is optional and makes AlertRules alert while a resource has the condition with the status, such
+	// as "False" for a Ready condition. The conditions must be recorded with Metrics.SetConditions, or by the
+	// controller with ResourceMetricsOptions.Conditions.
+	AlertStatus v1.ConditionStatus `json:"alertStatus,omitempty"`
+
+	// AlertFor is how long a resource has the status before the alert fires. Defaults to 15m.
+	AlertFor time.Duration `json:"alertFor,omitempty"`
+
+	// AlertSeverity labels the alert. Defaults to "warning".
+	AlertSeverity string `json:"alertSeverity,omitempty"`
+}
+
+// conditionNameRE matches the CamelCase names of condition types and reasons
+var conditionNameRE = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// ConditionCatalog is the vocabulary of the conditions of an operator. Operators declare each condition type with
+// its reasons, set conditions through the catalog so that undeclared types or reasons are caught, and export the
+// catalog for documentation and alert rules. A catalog shared by the operators of a fleet keeps their status
+// consistent.
+type ConditionCatalog struct {
+	lock  sync.RWMutex
+	types []ConditionType
+}
+
+// NewConditionCatalog creates an empty catalog
+func NewConditionCatalog() *ConditionCatalog {
+	return &ConditionCatalog{}
+}
+
+// Register declares the condition type and its reasons
+func (c *ConditionCatalog) Register(conditionType ConditionType) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.types = append(c.types, conditionType)
+}
+
+// Types returns the declared condition types sorted by type
+func (c *ConditionCatalog) Types() []ConditionType {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	types := append([]ConditionType{}, c.types...)
+	sort.SliceStable(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// Lint returns an aggregate of the problems of the declarations, or nil if there are none. Types and reasons must be
+// CamelCase, described, and declared once.
+func (c *ConditionCatalog) Lint() error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, t := range c.Types() {
+		if seen[t.Type] {
+			errs = append(errs, fmt.Errorf("condition type %s is registered more than once", t.Type))
+		}
+		seen[t.Type] = true
+		if !conditionNameRE.MatchString(t.Type) {
+			errs = append(errs, fmt.Errorf("condition type %q is not CamelCase", t.Type))
+		}
+		if strings.TrimSpace(t.Description) == "" {
+			errs = append(errs, fmt.Errorf("condition type %s has no description", t.Type))
+		}
+
+		reasons := map[string]bool{}
+		for _, reason := range t.Reasons {
+			if reasons[reason.Name] {
+				errs = append(errs, fmt.Errorf("reason %s of condition type %s is registered more than once", reason.Name, t.Type))
+			}
+			reasons[reason.Name] = true
+			if !conditionNameRE.MatchString(reason.Name) {
+				errs = append(errs, fmt.Errorf("reason %q of condition type %s is not CamelCase", reason.Name, t.Type))
+			}
+			if strings.TrimSpace(reason.Description) == "" {
+				errs = append(errs, fmt.Errorf("reason %s of condition type %s has no description", reason.Name, t.Type))
+			}
+		}
+	}
+	return errorsUtil.NewAggregate(errs)
+}
+
+// Validate returns an error if the type or the reason of the condition is not declared. A condition without a
+// reason is valid for any declared type.
+func (c *ConditionCatalog) Validate(condition Condition) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, t := range c.types {
+		if t.Type != condition.Type {
+			continue
+		}
+		if condition.Reason == "" {
+			return nil
+		}
+		for _, reason := range t.Reasons {
+			if reason.Name == condition.Reason {
+				return nil
+			}
+		}
+		return fmt.Errorf("reason %s of condition type %s is not registered", condition.Reason, condition.Type)
+	}
+	return fmt.Errorf("condition type %s is not registered", condition.Type)
+}
+
+// Set validates the condition and sets it in the conditions like SetCondition. The conditions are returned
+// unchanged with an error if the condition is not declared.
+func (c *ConditionCatalog) Set(conditions []Condition, condition Condition) ([]Condition, error) {
+	if err := c.Validate(condition); err != nil {
+		return conditions, err
+	}
+	return SetCondition(conditions, condition), nil
+}
+
+// ExportJSON writes the declared condition types as a JSON array
+func (c *ConditionCatalog) ExportJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c.Types()); err != nil {
+		return fmt.Errorf("failed to export the condition catalog. %+v", err)
+	}
+	return nil
+}
+
+// ExportMarkdown writes the declared condition types and reasons as a markdown table
+func (c *ConditionCatalog) ExportMarkdown(out io.Writer) error {
+	lines := []string{"| Type | Reason | Description |", "| --- | --- | --- |"}
+	for _, t := range c.Types() {
+		lines = append(lines, fmt.Sprintf("| %s | | %s |", t.Type, markdownCell(t.Description)))
+		for _, reason := range t.Reasons {
+			lines = append(lines, fmt.Sprintf("| %s | %s | %s |", t.Type, reason.Name, markdownCell(reason.Description)))
+		}
+	}
+	if _, err := io.WriteString(out, strings.Join(lines, "\n")+"\n"); err != nil {
+		return fmt.Errorf("failed to export the condition catalog. %+v", err)
+	}
+	return nil
+}
+
+func markdownCell(text string) string {
+	return strings.Replace(strings.Replace(text, "|", `\|`, -1), "\n", " ", -1)
+}
+
+// FindCondition returns the condition of the type, or nil if there is none
+func FindCondition(conditions []Condition, conditionType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds the condition, or replaces the condition of the same type. The last transition time is kept
+// when the status did not change, and set to now otherwise unless the condition has one.
+func SetCondition(conditions []Condition, condition Condition) []Condition {
+	existing := FindCondition(conditions, condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		return append(conditions, condition)
+	}
+
+	if existing.Status == condition.Status {
+		condition.LastTransitionTime = existing.LastTransitionTime
+	} else if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	*existing = condition
+	return conditions
+}
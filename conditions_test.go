@@ -0,0 +1,113 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestConditionCatalog() *ConditionCatalog {
+	catalog := NewConditionCatalog()
+	catalog.Register(ConditionType{
+		Type:        "Ready",
+		Description: "The cluster serves requests",
+		Reasons: []ConditionReason{
+			{Name: "AllMembersUp", Description: "All members are up"},
+			{Name: "QuorumLost", Description: "Less than a majority of the members are up"},
+		},
+	})
+	catalog.Register(ConditionType{Type: "Degraded", Description: "Some members are down"})
+	return catalog
+}
+
+func TestConditionCatalogLint(t *testing.T) {
+	catalog := newTestConditionCatalog()
+	assert.NoError(t, catalog.Lint())
+
+	catalog.Register(ConditionType{Type: "Ready", Description: "again"})
+	catalog.Register(ConditionType{Type: "backup-done", Reasons: []ConditionReason{{Name: "OK"}, {Name: "OK", Description: "ok"}}})
+	err := catalog.Lint()
+	assert.Error(t, err)
+	for _, msg := range []string{
+		"condition type Ready is registered more than once",
+		`condition type "backup-done" is not CamelCase`,
+		"condition type backup-done has no description",
+		"reason OK of condition type backup-done has no description",
+		"reason OK of condition type backup-done is registered more than once",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+
+	operator := NewOperator(Context{}, nil, OperatorOptions{Conditions: catalog})
+	assert.Error(t, operator.Validate())
+}
+
+func TestConditionCatalogSet(t *testing.T) {
+	catalog := newTestConditionCatalog()
+	conditions, err := catalog.Set(nil, Condition{Type: "Ready", Status: v1.ConditionTrue, Reason: "AllMembersUp"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(conditions))
+	assert.False(t, conditions[0].LastTransitionTime.IsZero())
+
+	_, err = catalog.Set(conditions, Condition{Type: "Ready", Status: v1.ConditionFalse, Reason: "Unknown"})
+	assert.EqualError(t, err, "reason Unknown of condition type Ready is not registered")
+	_, err = catalog.Set(conditions, Condition{Type: "Progressing", Status: v1.ConditionTrue})
+	assert.EqualError(t, err, "condition type Progressing is not registered")
+	conditions, err = catalog.Set(conditions, Condition{Type: "Degraded", Status: v1.ConditionFalse})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(conditions))
+}
+
+func TestSetCondition(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	conditions := []Condition{{Type: "Ready", Status: v1.ConditionTrue, LastTransitionTime: then}}
+
+	// the transition time is kept while the status does not change
+	conditions = SetCondition(conditions, Condition{Type: "Ready", Status: v1.ConditionTrue, Message: "still up"})
+	assert.Equal(t, 1, len(conditions))
+	assert.Equal(t, then, conditions[0].LastTransitionTime)
+	assert.Equal(t, "still up", conditions[0].Message)
+
+	conditions = SetCondition(conditions, Condition{Type: "Ready", Status: v1.ConditionFalse})
+	assert.True(t, conditions[0].LastTransitionTime.After(then.Time))
+	assert.Equal(t, v1.ConditionFalse, FindCondition(conditions, "Ready").Status)
+	assert.Nil(t, FindCondition(conditions, "Degraded"))
+}
+
+func TestConditionCatalogExport(t *testing.T) {
+	catalog := newTestConditionCatalog()
+
+	var out bytes.Buffer
+	assert.NoError(t, catalog.ExportMarkdown(&out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 6, len(lines))
+	assert.Equal(t, "| Degraded | | Some members are down |", lines[2])
+	assert.Equal(t, "| Ready | QuorumLost | Less than a majority of the members are up |", lines[5])
+
+	out.Reset()
+	assert.NoError(t, catalog.ExportJSON(&out))
+	var types []ConditionType
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &types))
+	assert.Equal(t, catalog.Types(), types)
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// ConfigSchema is the JSON schema of the operator config file. Configs are validated against it when loaded, and it
+// can be given to editors to complete and check the files.
+const ConfigSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["resources"],
+  "properties": {
+    "namespace": {"type": "string"},
+    "resources": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["name", "plural", "group", "version", "kind"],
+        "properties": {
+          "name": {"type": "string", "pattern": "^[a-z][a-z0-9-]*$"},
+          "plural": {"type": "string", "pattern": "^[a-z][a-z0-9-]*$"},
+          "group": {"type": "string", "pattern": "^[a-z0-9]([a-z0-9.-]*[a-z0-9])?$"},
+          "version": {"type": "string", "pattern": "^v[0-9]+((alpha|beta)[0-9]*)?$"},
+          "kind": {"type": "string", "pattern": "^[A-Z][A-Za-z0-9]*$"},
+          "scope": {"type": "string", "enum": ["Namespaced", "Cluster"]},
+          "role": {"type": "string", "enum": ["primary", "secondary"]},
+          "controller": {
+            "type": "object",
+            "additionalProperties": false,
+            "required": ["reconciler"],
+            "properties": {
+              "reconciler": {"type": "string"},
+              "namespace": {"type": "string"},
+              "workers": {"type": "integer", "minimum": 1},
+              "maxRetries": {"type": "integer", "minimum": 0},
+              "pageSize": {"type": "integer", "minimum": 0}
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// OperatorConfig declares the custom resources of an operator and the controllers reconciling them, so that simple
+// operators can change the watched resources and their options without being rebuilt
+type OperatorConfig struct {
+	// Namespace watched by the controllers that do not set their own. Defaults to all namespaces.
+	Namespace string `json:"namespace,omitempty"`
+
+	Resources []ResourceConfig `json:"resources"`
+}
+
+// ResourceConfig declares a custom resource and its controller
+type ResourceConfig struct {
+	Name    string `json:"name"`
+	Plural  string `json:"plural"`
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+
+	// Scope of the CRD. Defaults to Namespaced.
+	Scope string `json:"scope,omitempty"`
+
+	// Role of the operator for the CRD, "primary" or "secondary". Defaults to primary.
+	Role string `json:"role,omitempty"`
+
+	// Controller is optional. Resources without a controller are skipped by the validation of the operator.
+	Controller *ControllerConfig `json:"controller,omitempty"`
+}
+
+// ControllerConfig wires a controller to a reconciler registered by name with AddConfig
+type ControllerConfig struct {
+	Reconciler string `json:"reconciler"`
+
+	// Namespace watched by the controller. Defaults to the namespace of the config.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Workers of the controller. Defaults to 1.
+	Workers int `json:"workers,omitempty"`
+
+	MaxRetries int   `json:"maxRetries,omitempty"`
+	PageSize   int64 `json:"pageSize,omitempty"`
+}
+
+// ControllerFactory creates the controller of a configured resource. The factory provides the client, object type,
+// and reconciler of the resource, and passes the namespace and options on to NewControllerWithOptions.
+type ControllerFactory func(resource CustomResource, namespace string, options ControllerOptions) (*Controller, error)
+
+// ParseOperatorConfig parses a YAML or JSON config and validates it against the ConfigSchema. All problems are
+// returned together.
+func ParseOperatorConfig(data []byte) (*OperatorConfig, error) {
+	raw, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the config. %+v", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse the config. %+v", err)
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(ConfigSchema), &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse the config schema. %+v", err)
+	}
+	if errs := schema.validate("config", doc); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config. %+v", errorsUtil.NewAggregate(errs))
+	}
+
+	config := &OperatorConfig{}
+	if err := json.Unmarshal(raw, config); err != nil {
+		return nil, fmt.Errorf("failed to parse the config. %+v", err)
+	}
+	return config, nil
+}
+
+// LoadOperatorConfig reads and parses the config file at the path
+func LoadOperatorConfig(path string) (*OperatorConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config %s. %+v", path, err)
+	}
+	return ParseOperatorConfig(data)
+}
+
+// CustomResources returns the custom resources declared by the config
+func (c *OperatorConfig) CustomResources() []CustomResource {
+	resources := make([]CustomResource, 0, len(c.Resources))
+	for _, r := range c.Resources {
+		resources = append(resources, r.customResource())
+	}
+	return resources
+}
+
+func (r ResourceConfig) customResource() CustomResource {
+	resource := CustomResource{
+		Name:    r.Name,
+		Plural:  r.Plural,
+		Group:   r.Group,
+		Version: r.Version,
+		Kind:    r.Kind,
+		Scope:   apiextensionsv1beta1.NamespaceScoped,
+	}
+	if r.Scope != "" {
+		resource.Scope = apiextensionsv1beta1.ResourceScope(r.Scope)
+	}
+	if r.Role == "secondary" {
+		resource.Role = SecondaryCRDRole
+	}
+	return resource
+}
+
+// AddConfig registers the custom resources of the config, and creates their controllers with the factories of the
+// reconcilers named by the config. Resources without a controller are skipped. All problems are returned together
+// and nothing is registered when there are any.
+func (o *Operator) AddConfig(config *OperatorConfig, factories map[string]ControllerFactory) error {
+	var errs []error
+	var resources []CustomResource
+	var controllers []operatorController
+	for _, r := range config.Resources {
+		resource := r.customResource()
+		resources = append(resources, resource)
+		if r.Controller == nil {
+			continue
+		}
+		factory, ok := factories[r.Controller.Reconciler]
+		if !ok {
+			errs = append(errs, fmt.Errorf("resource %s has unknown reconciler %s", r.Name, r.Controller.Reconciler))
+			continue
+		}
+		namespace := r.Controller.Namespace
+		if namespace == "" {
+			namespace = config.Namespace
+		}
+		controller, err := factory(resource, namespace, ControllerOptions{
+			MaxRetries: r.Controller.MaxRetries,
+			PageSize:   r.Controller.PageSize,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create the controller of %s. %+v", r.Name, err))
+			continue
+		}
+		workers := r.Controller.Workers
+		if workers == 0 {
+			workers = 1
+		}
+		controllers = append(controllers, operatorController{controller: controller, workers: workers})
+	}
+	if len(errs) > 0 {
+		return errorsUtil.NewAggregate(errs)
+	}
+
+	for _, r := range config.Resources {
+		if r.Controller == nil {
+			o.SkipController(r.customResource())
+		}
+	}
+	o.resources = append(o.resources, resources...)
+	o.controllers = append(o.controllers, controllers...)
+	return nil
+}
+
+// jsonSchema is the subset of JSON schema used by ConfigSchema
+type jsonSchema struct {
+	Type                 string                `json:"type"`
+	Required             []string              `json:"required"`
+	Properties           map[string]jsonSchema `json:"properties"`
+	AdditionalProperties *bool                 `json:"additionalProperties"`
+	Items                *jsonSchema           `json:"items"`
+	Enum                 []string              `json:"enum"`
+	Pattern              string                `json:"pattern"`
+	Minimum              *float64              `json:"minimum"`
+}
+
+// validate returns the problems of the value at the path, such as "config.resources[0].kind"
+func (s jsonSchema) validate(path string, value interface{}) []error {
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []error{fmt.Errorf("%s must be an object", path)}
+		}
+		return s.validateObject(path, obj)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []error{fmt.Errorf("%s must be an array", path)}
+		}
+		var errs []error
+		for i, item := range items {
+			if s.Items != nil {
+				errs = append(errs, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+		return errs
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []error{fmt.Errorf("%s must be a string", path)}
+		}
+		return s.validateString(path, str)
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return []error{fmt.Errorf("%s must be an integer", path)}
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			return []error{fmt.Errorf("%s must be at least %v", path, *s.Minimum)}
+		}
+	}
+	return nil
+}
+
+func (s jsonSchema) validateObject(path string, obj map[string]interface{}) []error {
+	var errs []error
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			errs = append(errs, fmt.Errorf("%s.%s is required", path, name))
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				errs = append(errs, fmt.Errorf("%s.%s is not a known field", path, name))
+			}
+			continue
+		}
+		errs = append(errs, property.validate(path+"."+name, obj[name])...)
+	}
+	return errs
+}
+
+func (s jsonSchema) validateString(path, str string) []error {
+	if len(s.Enum) > 0 {
+		for _, value := range s.Enum {
+			if str == value {
+				return nil
+			}
+		}
+		return []error{fmt.Errorf("%s must be one of %v", path, s.Enum)}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return []error{fmt.Errorf("%s has an invalid pattern. %+v", path, err)}
+		}
+		if !re.MatchString(str) {
+			return []error{fmt.Errorf("%s %q does not match %s", path, str, s.Pattern)}
+		}
+	}
+	return nil
+}
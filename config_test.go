@@ -0,0 +1,120 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+)
+
+const exampleConfig = `
+namespace: samples
+resources:
+- name: sample
+  plural: samples
+  group: example.com
+  version: v1alpha1
+  kind: Sample
+  controller:
+    reconciler: sample
+    workers: 3
+    maxRetries: 5
+- name: other
+  plural: others
+  group: example.com
+  version: v1
+  kind: Other
+  scope: Cluster
+  role: secondary
+`
+
+func TestParseOperatorConfig(t *testing.T) {
+	config, err := ParseOperatorConfig([]byte(exampleConfig))
+	assert.NoError(t, err)
+	assert.Equal(t, "samples", config.Namespace)
+	assert.Equal(t, &ControllerConfig{Reconciler: "sample", Workers: 3, MaxRetries: 5}, config.Resources[0].Controller)
+
+	resources := config.CustomResources()
+	assert.Equal(t, CustomResource{Name: "sample", Plural: "samples", Group: "example.com", Version: "v1alpha1", Kind: "Sample",
+		Scope: apiextensionsv1beta1.NamespaceScoped}, resources[0])
+	assert.Equal(t, apiextensionsv1beta1.ClusterScoped, resources[1].Scope)
+	assert.Equal(t, SecondaryCRDRole, resources[1].Role)
+
+	_, err = ParseOperatorConfig([]byte(`
+resources:
+- name: Sample
+  plural: samples
+  group: example.com
+  scope: Global
+  replicas: 2
+  controller:
+    workers: 0
+`))
+	assert.Error(t, err)
+	for _, problem := range []string{
+		"config.resources[0].version is required",
+		"config.resources[0].kind is required",
+		`config.resources[0].name "Sample" does not match`,
+		"config.resources[0].scope must be one of [Namespaced Cluster]",
+		"config.resources[0].replicas is not a known field",
+		"config.resources[0].controller.reconciler is required",
+		"config.resources[0].controller.workers must be at least 1",
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+
+	_, err = ParseOperatorConfig([]byte(`resources: {}`))
+	assert.Contains(t, err.Error(), "config.resources must be an array")
+}
+
+func TestOperatorAddConfig(t *testing.T) {
+	config, err := ParseOperatorConfig([]byte(exampleConfig))
+	assert.NoError(t, err)
+
+	var options ControllerOptions
+	var namespace string
+	factories := map[string]ControllerFactory{
+		"sample": func(resource CustomResource, ns string, opts ControllerOptions) (*Controller, error) {
+			namespace, options = ns, opts
+			return &Controller{resource: resource}, nil
+		},
+	}
+	operator := NewOperator(Context{}, nil, OperatorOptions{})
+	assert.NoError(t, operator.AddConfig(config, factories))
+	assert.Equal(t, "samples", namespace)
+	assert.Equal(t, 5, options.MaxRetries)
+	assert.Equal(t, 2, len(operator.resources))
+	assert.Equal(t, 1, len(operator.controllers))
+	assert.Equal(t, 3, operator.controllers[0].workers)
+	assert.True(t, operator.skipped["other"])
+
+	// nothing is registered when a controller cannot be created
+	operator = NewOperator(Context{}, nil, OperatorOptions{})
+	err = operator.AddConfig(config, map[string]ControllerFactory{
+		"sample": func(CustomResource, string, ControllerOptions) (*Controller, error) {
+			return nil, errors.New("no client")
+		},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create the controller of sample. no client")
+	assert.Equal(t, 0, len(operator.resources))
+
+	err = operator.AddConfig(config, nil)
+	assert.Contains(t, err.Error(), "resource sample has unknown reconciler sample")
+}
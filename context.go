@@ -0,0 +1,119 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"time"
+
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	cacheddiscovery "k8s.io/client-go/discovery/cached"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultInterval = 500 * time.Millisecond
+	defaultTimeout  = 60 * time.Second
+	maxWaitInterval = 10 * time.Second
+)
+
+// DefaultWaitBackoff is the backoff of the default wait strategy. It starts at the Interval of the context and
+// grows by half after each attempt, with up to 50% jitter.
+var DefaultWaitBackoff = wait.Backoff{Factor: 1.5, Jitter: 0.5}
+
+// NewContext creates a context with all clients created from the given config, including the dynamic client pool
+// and the RESTMapper. The interval and timeout are set to defaults that can be changed on the returned context.
+func NewContext(config *rest.Config) (*Context, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s clientset. %+v", err)
+	}
+
+	apiExtClientset, err := apiextensionsclient.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s API extension clientset. %+v", err)
+	}
+
+	return &Context{
+		Clientset:             clientset,
+		APIExtensionClientset: apiExtClientset,
+		DynamicClientPool:     dynamic.NewDynamicClientPool(config),
+		RESTMapper:            NewRESTMapper(clientset.Discovery()),
+		RESTConfig:            config,
+		Interval:              defaultInterval,
+		Timeout:               defaultTimeout,
+	}, nil
+}
+
+// withClients returns a copy of the context whose clients are created again from the config
+func (c Context) withClients(config *rest.Config) (Context, error) {
+	clients, err := NewContext(config)
+	if err != nil {
+		return c, err
+	}
+	c.Clientset = clients.Clientset
+	c.APIExtensionClientset = clients.APIExtensionClientset
+	c.DynamicClientPool = clients.DynamicClientPool
+	c.RESTMapper = clients.RESTMapper
+	c.RESTConfig = clients.RESTConfig
+	return c, nil
+}
+
+// NewRESTMapper creates a RESTMapper that discovers the resources of the cluster when first used. The discovery
+// is cached until the mapper is reset, which CreateCustomResources does after registering new resources.
+func NewRESTMapper(discoveryClient discovery.DiscoveryInterface) *discovery.DeferredDiscoveryRESTMapper {
+	return discovery.NewDeferredDiscoveryRESTMapper(cacheddiscovery.NewMemCacheClient(discoveryClient), meta.InterfacesForUnstructured)
+}
+
+// DynamicClientFor returns a dynamic client for the resource of the given kind in the namespace. The namespace is
+// ignored for cluster scoped resources. The context must have a DynamicClientPool and a RESTMapper.
+func (c Context) DynamicClientFor(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	if c.DynamicClientPool == nil || c.RESTMapper == nil {
+		return nil, fmt.Errorf("the context has no dynamic client pool or RESTMapper")
+	}
+
+	mapping, err := c.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s to a resource. %+v", gvk.String(), err)
+	}
+
+	client, err := c.DynamicClientPool.ClientForGroupVersionKind(gvk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dynamic client for %s. %+v", gvk.String(), err)
+	}
+
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if !namespaced {
+		namespace = ""
+	}
+	return client.Resource(&metav1.APIResource{Name: mapping.Resource, Namespaced: namespaced, Kind: gvk.Kind}, namespace), nil
+}
+
+// resetRESTMapper drops the cached discovery so that newly registered resources can be mapped
+func (c Context) resetRESTMapper() {
+	if c.RESTMapper != nil {
+		c.RESTMapper.Reset()
+	}
+}
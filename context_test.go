@@ -0,0 +1,117 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// newMappingServer serves the discovery of the example.com/v1 group, with a namespaced and a cluster scoped kind,
+// and records the other requests. Discovery fails while broken is set.
+func newMappingServer(paths *[]string, broken *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api", "/apis", "/api/v1", "/apis/example.com/v1":
+			if *broken {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"InternalError","code":500}`)
+				return
+			}
+		}
+		switch r.URL.Path {
+		case "/api":
+			fmt.Fprint(w, `{"kind":"APIVersions","versions":["v1"]}`)
+		case "/apis":
+			fmt.Fprint(w, `{"kind":"APIGroupList","groups":[{"name":"example.com",`+
+				`"versions":[{"groupVersion":"example.com/v1","version":"v1"}],"preferredVersion":{"groupVersion":"example.com/v1","version":"v1"}}]}`)
+		case "/api/v1":
+			fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"v1","resources":[]}`)
+		case "/apis/example.com/v1":
+			fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"example.com/v1","resources":[`+
+				`{"name":"samples","namespaced":true,"kind":"Sample","verbs":["get","list"]},`+
+				`{"name":"clustersamples","namespaced":false,"kind":"ClusterSample","verbs":["get","list"]}]}`)
+		default:
+			*paths = append(*paths, r.URL.Path)
+			fmt.Fprint(w, `{"apiVersion":"example.com/v1","kind":"SampleList","metadata":{},"items":[]}`)
+		}
+	}))
+}
+
+func TestDynamicClientFor(t *testing.T) {
+	var paths []string
+	broken := false
+	server := newMappingServer(&paths, &broken)
+	defer server.Close()
+	config := &rest.Config{Host: server.URL}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	assert.NoError(t, err)
+	ctx := Context{DynamicClientPool: dynamic.NewDynamicClientPool(config), RESTMapper: NewRESTMapper(discoveryClient)}
+
+	client, err := ctx.DynamicClientFor(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Sample"}, "ns")
+	assert.NoError(t, err)
+	_, err = client.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+
+	// the namespace is ignored for cluster scoped kinds
+	client, err = ctx.DynamicClientFor(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "ClusterSample"}, "ns")
+	assert.NoError(t, err)
+	_, err = client.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/apis/example.com/v1/namespaces/ns/samples", "/apis/example.com/v1/clustersamples"}, paths)
+
+	// kinds the server does not serve are not mapped
+	_, err = ctx.DynamicClientFor(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Missing"}, "ns")
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "failed to map example.com/v1, Kind=Missing to a resource. "), err.Error())
+}
+
+func TestDynamicClientForDiscoveryFailure(t *testing.T) {
+	var paths []string
+	broken := true
+	server := newMappingServer(&paths, &broken)
+	defer server.Close()
+	config := &rest.Config{Host: server.URL}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	assert.NoError(t, err)
+	ctx := Context{DynamicClientPool: dynamic.NewDynamicClientPool(config), RESTMapper: NewRESTMapper(discoveryClient)}
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Sample"}
+	_, err = ctx.DynamicClientFor(gvk, "ns")
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "failed to map example.com/v1, Kind=Sample to a resource. "), err.Error())
+
+	// the kind is mapped once discovery recovers and the mapper is reset
+	broken = false
+	ctx.resetRESTMapper()
+	_, err = ctx.DynamicClientFor(gvk, "ns")
+	assert.NoError(t, err)
+	assert.Empty(t, paths)
+
+	_, err = Context{}.DynamicClientFor(gvk, "ns")
+	assert.EqualError(t, err, "the context has no dynamic client pool or RESTMapper")
+}
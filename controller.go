@@ -0,0 +1,575 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	gocontext "context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Reconciler brings the state of the cluster in line with the desired state of a custom resource
+type Reconciler interface {
+	// Reconcile is called with the namespace/name key of a custom resource that was added, updated, or deleted.
+	// The resource might not exist anymore when it is called. Returning an error requeues the key according to the
+	// requeue policies of the controller. Return a TerminalError when retrying does not help.
+	Reconcile(key string) error
+}
+
+// ReconcilerFunc adapts a function to the Reconciler interface
+type ReconcilerFunc func(key string) error
+
+// Reconcile calls f(key)
+func (f ReconcilerFunc) Reconcile(key string) error {
+	return f(key)
+}
+
+// Controller watches a custom resource and passes the key of each changed instance through a rate limited
+// work queue to the reconciler. Keys are never reconciled concurrently by multiple workers.
+type Controller struct {
+	context    Context
+	resource   CustomResource
+	reconciler Reconciler
+	options    ControllerOptions
+	client     rest.Interface
+	queue      workqueue.RateLimitingInterface
+	store      cache.Store
+	indexer    cache.Indexer
+
+	// the informer is run by the controller, or once by the shared informers for all controllers sharing it
+	hasSynced    cache.InformerSynced
+	runInformer  func(stopCh <-chan struct{})
+	informerOnce sync.Once
+
+	// checkpoint is set when the cache is checkpointed to resume watching after a restart
+	checkpoint *informerCheckpoint
+
+	// optionalWatches run with the controller while the CRDs they depend on are established
+	optionalWatches []*OptionalWatch
+
+	// paused is set to 1 while the CRD of the resource is terminating or missing
+	paused int32
+
+	// crdFlavor is the API flavor of the monitored CRD, detected once when the controller first runs
+	crdFlavor APIFlavor
+
+	// stopping is set to 1 once the stop channel is closed so workers stop taking keys from the queue
+	stopping int32
+	workers  sync.WaitGroup
+	stopCh   <-chan struct{}
+
+	// failures counts the consecutive failed reconciles of each key for the retry limit
+	failures     map[interface{}]int
+	failuresLock sync.Mutex
+
+	// driftPending are the keys queued for drift detection before their next reconcile
+	driftPending map[string]bool
+	driftLock    sync.Mutex
+
+	// observed is the time at which the informer last received each cached object
+	observed     map[string]time.Time
+	observedLock sync.Mutex
+
+	// finishedSeen is the time at which the controller first saw each finished custom resource without a finish time
+	finishedSeen map[string]time.Time
+	finishedLock sync.Mutex
+
+	// cloudEvents queues the events published to the sink of the CloudEvents options
+	cloudEvents chan CloudEvent
+
+	// replayed are the keys replayed as adds by a running shared informer the controller was added to
+	replayed     map[string]bool
+	replayedLock sync.Mutex
+}
+
+// ControllerOptions configures the optional behavior of a controller
+type ControllerOptions struct {
+	// CRDDeletionPolicy decides what happens when the CRD of the resource is deleted. Defaults to pausing reconciles.
+	CRDDeletionPolicy CRDDeletionPolicy
+
+	// CRDCheckInterval is the interval at which the CRD is checked for deletion. Defaults to 30s.
+	CRDCheckInterval time.Duration
+
+	// Locks is optional and shared by the controllers acting on the same custom resource, such as separate status
+	// and finalizer controllers, so that they never reconcile the same instance concurrently
+	Locks *KeyLocks
+
+	// RequeuePolicies decide how the key is requeued after the reconciler failed with an error of the class.
+	// Errors of classes without a policy are requeued with the backoff of the queue.
+	RequeuePolicies map[ErrorClass]RequeuePolicy
+
+	// ErrorClassifier is optional and returns the class of a reconcile error. Defaults to ClassifyError.
+	ErrorClassifier func(err error) ErrorClass
+
+	// HealthScores is optional and updated with the outcome of every reconcile. The score of a resource is dropped
+	// once it is no longer in the store.
+	HealthScores *HealthScores
+
+	// Transform is optional and modifies the objects before they enter the store, for example DefaultTransform.
+	// Controllers created by SharedInformers use the transform of the shared informers instead.
+	Transform TransformFunc
+
+	// PageSize is the number of objects per page when the informer lists the resources. Defaults to
+	// DefaultPageSize. Controllers created by SharedInformers always use the default.
+	PageSize int64
+
+	// Scheduler is optional and shared by the controllers of an operator to divide the reconcile time fairly
+	// between them
+	Scheduler *ReconcileScheduler
+
+	// Tenant is optional and returns the tenant of a key, such as NamespaceTenant, so that the scheduler also
+	// divides the reconcile time of the controller fairly between its tenants
+	Tenant func(key string) string
+
+	// MaxRetries is the number of times the key of a failing resource is requeued before it is dropped until the
+	// resource changes again. A RetriesExhausted event is emitted when the key is dropped. Defaults to retrying
+	// forever.
+	MaxRetries int
+
+	// DeadLetter is optional and called with the key and the last error when the retries of the key are exhausted,
+	// for example to set a Failed condition on the resource
+	DeadLetter func(key string, err error)
+
+	// Indexers are optional index funcs of the store, such as an index of the custom resources by the name of the
+	// Secret they reference, for lookups with Indexer. The namespace index is always added.
+	Indexers cache.Indexers
+
+	// Expectations are optional and hold back the reconciles of a resource until the informer of its children
+	// observed the children the last reconcile created or deleted. Children are observed by ChildHandlers.
+	Expectations *Expectations
+
+	// References are optional and track the objects the resources depend on, such as Secrets, so that the resources
+	// are reconciled again when the objects change. Changes are observed by ReferenceHandlers.
+	References *ReferenceTracker
+
+	// Authorize is optional and called before each reconcile of a resource that is not being deleted, such as
+	// CreatorAuthorizer. The resource is not reconciled when it fails.
+	Authorize AuthorizeFunc
+
+	// Checkpoints is optional and saves the cache so that a restarted operator resumes watching from the last
+	// resourceVersion instead of listing all resources. Controllers created by SharedInformers are not checkpointed.
+	Checkpoints *Checkpoints
+
+	// PausedCondition is optional and sets the Paused condition in status.conditions of resources while the
+	// PausedAnnotation pauses them. Only UseSubresource of the options is used.
+	PausedCondition *StatusUpdateOptions
+
+	// TTL is optional and deletes the resources once they finished and their TTL expired, like the
+	// ttlSecondsAfterFinished of Jobs. The TTL of a resource is set by the TTLAnnotation or the default of the options.
+	TTL *TTLOptions
+
+	// Drift is optional and compares the children of the resources with their desired state at the interval of the
+	// options. The context must have a DynamicClientPool.
+	Drift *DriftOptions
+
+	// Resync is optional and enqueues all the resources at the interval or cron schedule, regardless of Kubernetes
+	// events. Run fails if the schedule is invalid.
+	Resync *ResyncSchedule
+
+	// CloudEvents is optional and publishes CloudEvents on the creation, update, and deletion of the resources and
+	// on the results of their reconciles, so that external systems follow the operator without watching the
+	// apiserver.
+	CloudEvents *CloudEventOptions
+}
+
+// NewController creates a controller for the custom resource in the given namespace. Use v1.NamespaceAll to watch
+// all namespaces. The client must be configured for the group and version of the resource.
+func NewController(context Context, resource CustomResource, namespace string, client rest.Interface, objType runtime.Object, reconciler Reconciler) *Controller {
+	return NewControllerWithOptions(context, resource, namespace, client, objType, reconciler, ControllerOptions{})
+}
+
+// NewControllerWithOptions creates a controller like NewController with the given options
+func NewControllerWithOptions(context Context, resource CustomResource, namespace string, client rest.Interface, objType runtime.Object,
+	reconciler Reconciler, options ControllerOptions) *Controller {
+
+	c := newController(context, resource, client, reconciler, options)
+	source := cache.NewListWatchFromClient(client, resource.Plural, namespace, fields.Everything())
+	pageListWatch(source, options.PageSize)
+	instrumentWatch(source, context, resource.Name)
+	if options.Checkpoints != nil {
+		c.checkpoint = newInformerCheckpoint(options.Checkpoints, resource, namespace)
+		c.checkpoint.wrapList(source, context)
+	}
+	transformListWatch(source, context, resource.Name, options.Transform)
+
+	var informer cache.Controller
+	c.indexer, informer = cache.NewIndexerInformer(source, objType, 0, c.eventHandlers(), controllerIndexers(options.Indexers))
+	c.store = c.indexer
+	c.hasSynced = informer.HasSynced
+	c.runInformer = informer.Run
+	return c
+}
+
+func newController(context Context, resource CustomResource, client rest.Interface, reconciler Reconciler, options ControllerOptions) *Controller {
+	c := &Controller{
+		context:      context,
+		resource:     resource,
+		reconciler:   reconciler,
+		options:      options,
+		client:       client,
+		observed:     map[string]time.Time{},
+		finishedSeen: map[string]time.Time{},
+		queue: context.Metrics.instrumentQueue(metricsKind(resource),
+			workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), resource.Plural)),
+	}
+	if options.CloudEvents != nil && options.CloudEvents.Sink != nil {
+		buffer := options.CloudEvents.Buffer
+		if buffer <= 0 {
+			buffer = defaultCloudEventBuffer
+		}
+		c.cloudEvents = make(chan CloudEvent, buffer)
+	}
+	return c
+}
+
+// eventHandlers queue the key of every added, updated, or deleted custom resource
+func (c *Controller) eventHandlers() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.observeWatchEvent(obj, "add")
+			c.emitResourceEvent(CloudEventResourceCreated, nil, obj)
+			c.context.Metrics.observeConditions(c, obj)
+			c.observe(obj, false)
+			c.observeCheckpoint(obj)
+			c.enqueue(obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.observeWatchEvent(newObj, "update")
+			c.emitResourceEvent(CloudEventResourceUpdated, oldObj, newObj)
+			c.context.Metrics.observeConditions(c, newObj)
+			c.observe(newObj, false)
+			c.observeCheckpoint(newObj)
+			if queuedAnnotationCleared(oldObj, newObj) {
+				return
+			}
+			c.enqueue(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			c.observeWatchEvent(obj, "delete")
+			c.emitResourceEvent(CloudEventResourceDeleted, nil, obj)
+			if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+				c.options.Expectations.Delete(key)
+				c.options.References.Forget(key)
+				c.context.Metrics.DeleteConditions(c.resource.Name, key)
+			}
+			c.observe(obj, true)
+			c.observeCheckpoint(obj)
+			c.enqueue(obj)
+		},
+	}
+}
+
+// observeWatchEvent counts the watch event of the custom resource by kind and namespace
+func (c *Controller) observeWatchEvent(obj interface{}, event string) {
+	if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+		c.context.Metrics.observeWatchEvent(metricsKind(c.resource), key, event)
+	}
+}
+
+// Store returns the cache of the watched custom resources. Reconcilers look up the object for a key in the store.
+func (c *Controller) Store() cache.Store {
+	return c.store
+}
+
+// Indexer returns the store of the watched custom resources with its indexes, for cached lookups by index and
+// for typed listers
+func (c *Controller) Indexer() cache.Indexer {
+	return c.indexer
+}
+
+// controllerIndexers returns the indexers of the options with the namespace index
+func controllerIndexers(indexers cache.Indexers) cache.Indexers {
+	result := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for name, indexFunc := range indexers {
+		result[name] = indexFunc
+	}
+	return result
+}
+
+// QueueDepth returns the number of keys waiting to be reconciled
+func (c *Controller) QueueDepth() int {
+	return c.queue.Len()
+}
+
+// HasSynced returns whether the initial list of the custom resources has been loaded into the store
+func (c *Controller) HasSynced() bool {
+	return c.hasSynced()
+}
+
+// Run starts watching the custom resource and reconciling with the given number of workers.
+// The call blocks until the stop channel is closed. Reconciles in flight at that time keep running; use Drain to
+// wait for them.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+	c.stopCh = stopCh
+
+	var resync func(time.Time) time.Time
+	if c.options.Resync != nil {
+		var err error
+		if resync, err = c.options.Resync.next(); err != nil {
+			return fmt.Errorf("invalid resync schedule of %s. %+v", c.resource.Name, err)
+		}
+	}
+
+	c.startInformer(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.hasSynced) {
+		return fmt.Errorf("failed to sync the cache of %s", c.resource.Name)
+	}
+
+	if c.monitorsCRD() {
+		go wait.Until(c.checkCRD, durationOrDefault(c.options.CRDCheckInterval, defaultCRDCheckInterval), stopCh)
+	}
+	for _, watch := range c.optionalWatches {
+		go watch.Run(stopCh)
+	}
+	if resync != nil {
+		go c.runResync(resync, stopCh)
+	}
+	if c.options.Drift != nil {
+		go wait.Until(c.detectAllDrift, durationOrDefault(c.options.Drift.Interval, defaultDriftInterval), stopCh)
+	}
+	if c.cloudEvents != nil {
+		go c.publishCloudEvents(stopCh)
+	}
+
+	for i := 0; i < workers; i++ {
+		c.workers.Add(1)
+		go func() {
+			defer c.workers.Done()
+			wait.Until(c.runWorker, time.Second, stopCh)
+		}()
+	}
+
+	<-stopCh
+	atomic.StoreInt32(&c.stopping, 1)
+	return nil
+}
+
+// WarmCache starts filling the cache of the custom resources without reconciling them, for replicas waiting to be
+// elected. Once elected, Run reuses the warm cache rather than listing all resources again, so the new leader
+// resumes reconciling within seconds. The cache is filled until the stop channel is closed, even if Run is stopped
+// earlier.
+func (c *Controller) WarmCache(stopCh <-chan struct{}) {
+	c.startInformer(stopCh)
+}
+
+// startInformer runs the informer once, by WarmCache or Run, whichever comes first
+func (c *Controller) startInformer(stopCh <-chan struct{}) {
+	c.informerOnce.Do(func() {
+		go c.runInformer(stopCh)
+		if c.checkpoint != nil {
+			go c.runCheckpoints(stopCh)
+		}
+	})
+}
+
+// Drain waits up to the timeout for the reconciles in flight to finish after the controller was stopped. It returns
+// false if reconciles were still running at the deadline. Keys still queued are not reconciled; they are listed
+// again by the informer of the next run.
+func (c *Controller) Drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		c.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		c.context.logger().Error(err, "failed to get the key of the custom resource", "resource", c.resource.Name)
+		return
+	}
+	c.queue.Add(key)
+	c.context.Metrics.SetWorkqueueDepth(c.resource.Name, c.queue.Len())
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+	if atomic.LoadInt32(&c.stopping) == 1 {
+		return false
+	}
+	c.context.Metrics.SetWorkqueueDepth(c.resource.Name, c.queue.Len())
+
+	if c.crdPaused() {
+		// the key is reconciled once the CRD is established again
+		c.queue.AddAfter(key, pausedRequeueDelay)
+		return true
+	}
+
+	if wait := c.options.Expectations.pending(key.(string)); wait > 0 {
+		// the key is queued again when the children are observed, or once the expectations expire
+		c.queue.AddAfter(key, wait)
+		return true
+	}
+
+	if c.instancePaused(key.(string)) {
+		// the key is reconciled again when the annotation is removed
+		c.reportPaused(key.(string), true)
+		c.forget(key)
+		return true
+	}
+	c.reportPaused(key.(string), false)
+
+	if err := c.authorize(key.(string)); err != nil {
+		c.context.logger().Error(err, "not reconciling the unauthorized resource", "resource", c.resource.Name, "key", key)
+		c.requeue(key, err)
+		return true
+	}
+
+	if deleted, err := c.expireTTL(key.(string), time.Now()); err != nil || deleted {
+		if err != nil {
+			c.context.logger().Error(err, "failed to expire the resource", "resource", c.resource.Name, "key", key)
+			c.requeue(key, err)
+		} else {
+			c.forget(key)
+		}
+		return true
+	}
+
+	release, ok := c.acquireSlot(key.(string))
+	if !ok {
+		return false
+	}
+	start := time.Now()
+	trace := NewReconcileTrace(key.(string), c.context.logger().WithValues("resource", c.resource.Name))
+	ctx, span := c.context.startSpan(gocontext.Background(), SpanReconcile, "resource", c.resource.Name, "key", trace.Key,
+		ReconcileIDKey, trace.ID, "retries", c.queue.NumRequeues(key))
+	trace.Ctx = ctx
+	result, err := c.reconcile(trace)
+	release()
+	outcome := resultSuccess
+	if err != nil {
+		outcome = resultError
+	} else if result.RequeueAfter > 0 {
+		outcome = "requeue"
+	}
+	span.SetAttributes("result", outcome)
+	endSpan(span, err)
+	c.context.Metrics.ObserveReconcile(c.resource.Name, time.Since(start), err)
+	c.emitReconcileEvent(trace, outcome, time.Since(start), err)
+	c.observeHealth(key.(string), err)
+	if err != nil {
+		trace.Logger.Error(err, "failed to reconcile")
+		c.recordEvent(key.(string), v1.EventTypeWarning, EventReasonReconcileFailed, trace.EventMessage(err.Error()))
+		c.requeue(key, err)
+		return true
+	}
+
+	c.forget(key)
+	c.clearQueuedAnnotation(key.(string))
+	c.requeueResult(key, result)
+	return true
+}
+
+// reconcile calls the reconciler while holding the lock of the custom resource if the controller shares locks.
+// Traced reconcilers get the trace of the reconcile. Only result reconcilers return a result. Pipelines get both.
+func (c *Controller) reconcile(trace ReconcileTrace) (Result, error) {
+	var result Result
+	reconcile := func() error {
+		c.detectPendingDrift(trace.Key)
+		var err error
+		switch r := c.reconciler.(type) {
+		case *Pipeline:
+			result, err = r.reconcileTracedResult(trace)
+		case ResultReconciler:
+			result, err = r.ReconcileResult(trace.Key)
+		case TracedReconciler:
+			err = r.ReconcileTraced(trace)
+		default:
+			err = r.Reconcile(trace.Key)
+		}
+		return err
+	}
+	if c.options.Locks == nil {
+		err := reconcile()
+		return result, err
+	}
+	err := c.options.Locks.WithLock(lockKey(c.resource, trace.Key), reconcile)
+	return result, err
+}
+
+// observeHealth updates the health score of the custom resource, or drops it if the resource was deleted
+func (c *Controller) observeHealth(key string, err error) {
+	if c.options.HealthScores == nil {
+		return
+	}
+	if _, exists, _ := c.store.GetByKey(key); !exists {
+		c.options.HealthScores.Forget(key)
+		return
+	}
+	c.options.HealthScores.ObserveReconcile(key, err)
+}
+
+// recordEvent emits an event on the custom resource with the given key if it is still in the store. Sensitive values
+// of the resource are redacted from the message.
+func (c *Controller) recordEvent(key, eventType, reason, message string) {
+	if c.context.Recorder == nil {
+		return
+	}
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil || !exists {
+		return
+	}
+	if object, ok := obj.(runtime.Object); ok {
+		c.context.Recorder.Event(object, eventType, reason, RedactText(message, object))
+	}
+}
+
+// instrumentWatch counts every watch opened after the first one as a restart of the watch
+func instrumentWatch(source *cache.ListWatch, context Context, resource string) {
+	watchFunc := source.WatchFunc
+	started := false
+	source.WatchFunc = func(options metav1.ListOptions) (watch.Interface, error) {
+		if started {
+			context.logger().Debug("restarting watch", "resource", resource, "resourceVersion", options.ResourceVersion)
+			context.Metrics.IncWatchRestarts(resource)
+		}
+		started = true
+		return watchFunc(options)
+	}
+}
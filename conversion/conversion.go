@@ -0,0 +1,169 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conversion serves the conversion webhook of custom resources with multiple versions
+package conversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ConvertFunc converts the object between a version and the hub version in place. The apiVersion of the object is
+// set by the converter.
+type ConvertFunc func(obj *unstructured.Unstructured) error
+
+// ConversionReview is the request sent to the webhook by the apiserver and the response sent back. It has the same
+// wire format in apiextensions.k8s.io/v1 and v1beta1.
+type ConversionReview struct {
+	APIVersion string              `json:"apiVersion,omitempty"`
+	Kind       string              `json:"kind,omitempty"`
+	Request    *ConversionRequest  `json:"request,omitempty"`
+	Response   *ConversionResponse `json:"response,omitempty"`
+}
+
+// ConversionRequest holds the objects to convert to the desired version
+type ConversionRequest struct {
+	UID               string            `json:"uid"`
+	DesiredAPIVersion string            `json:"desiredAPIVersion"`
+	Objects           []json.RawMessage `json:"objects"`
+}
+
+// ConversionResponse holds the converted objects, in the order of the request
+type ConversionResponse struct {
+	UID              string            `json:"uid"`
+	ConvertedObjects []json.RawMessage `json:"convertedObjects"`
+	Result           metav1.Status     `json:"result"`
+}
+
+type spoke struct {
+	toHub   ConvertFunc
+	fromHub ConvertFunc
+}
+
+// Converter converts custom resources between versions through a hub version. Each other version only converts to
+// and from the hub, so n versions need 2(n-1) funcs instead of one for every pair of versions.
+type Converter struct {
+	hub    string
+	lock   sync.RWMutex
+	spokes map[string]spoke
+}
+
+// NewConverter creates a converter with the hub apiVersion, such as "example.com/v1"
+func NewConverter(hubAPIVersion string) *Converter {
+	return &Converter{hub: hubAPIVersion, spokes: map[string]spoke{}}
+}
+
+// Register adds a version, such as "example.com/v1alpha1", with the funcs converting it to and from the hub
+func (c *Converter) Register(apiVersion string, toHub, fromHub ConvertFunc) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.spokes[apiVersion] = spoke{toHub: toHub, fromHub: fromHub}
+}
+
+// Convert converts the object to the desired apiVersion in place
+func (c *Converter) Convert(obj *unstructured.Unstructured, desiredAPIVersion string) error {
+	from := obj.GetAPIVersion()
+	if from == desiredAPIVersion {
+		return nil
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if from != c.hub {
+		s, ok := c.spokes[from]
+		if !ok {
+			return fmt.Errorf("unsupported version %s", from)
+		}
+		if err := s.toHub(obj); err != nil {
+			return fmt.Errorf("failed to convert %s from %s to %s. %+v", obj.GetName(), from, c.hub, err)
+		}
+		obj.SetAPIVersion(c.hub)
+	}
+	if desiredAPIVersion != c.hub {
+		s, ok := c.spokes[desiredAPIVersion]
+		if !ok {
+			return fmt.Errorf("unsupported version %s", desiredAPIVersion)
+		}
+		if err := s.fromHub(obj); err != nil {
+			return fmt.Errorf("failed to convert %s from %s to %s. %+v", obj.GetName(), c.hub, desiredAPIVersion, err)
+		}
+		obj.SetAPIVersion(desiredAPIVersion)
+	}
+	return nil
+}
+
+// ServeHTTP serves ConversionReview requests. The handler must be served over TLS with a certificate trusted by the
+// conversion config of the CRD.
+func (c *Converter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if contentType := r.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read the request. %+v", err), http.StatusBadRequest)
+		return
+	}
+	review := &ConversionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		http.Error(w, "the request is not a ConversionReview", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = c.convertAll(review.Request)
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		http.Error(w, fmt.Sprintf("failed to write the response. %+v", err), http.StatusInternalServerError)
+	}
+}
+
+// convertAll converts the objects of the request. A single failure fails the whole request.
+func (c *Converter) convertAll(request *ConversionRequest) *ConversionResponse {
+	response := &ConversionResponse{UID: request.UID, Result: metav1.Status{Status: metav1.StatusSuccess}}
+	for _, raw := range request.Objects {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw); err != nil {
+			return failedResponse(request.UID, fmt.Errorf("failed to parse object. %+v", err))
+		}
+		if err := c.Convert(obj, request.DesiredAPIVersion); err != nil {
+			return failedResponse(request.UID, err)
+		}
+		converted, err := obj.MarshalJSON()
+		if err != nil {
+			return failedResponse(request.UID, fmt.Errorf("failed to serialize object. %+v", err))
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, converted)
+	}
+	return response
+}
+
+func failedResponse(uid string, err error) *ConversionResponse {
+	return &ConversionResponse{UID: uid, Result: metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}}
+}
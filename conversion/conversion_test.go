@@ -0,0 +1,97 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package conversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// v1alpha1 has spec.size as a string, the v1 hub has spec.replicas as an int
+func newTestConverter() *Converter {
+	converter := NewConverter("example.com/v1")
+	converter.Register("example.com/v1alpha1",
+		func(obj *unstructured.Unstructured) error {
+			size, _, _ := unstructured.NestedString(obj.Object, "spec", "size")
+			var replicas int64
+			if _, err := fmt.Sscanf(size, "%d", &replicas); err != nil {
+				return err
+			}
+			unstructured.RemoveNestedField(obj.Object, "spec", "size")
+			return unstructured.SetNestedField(obj.Object, replicas, "spec", "replicas")
+		},
+		func(obj *unstructured.Unstructured) error {
+			replicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+			unstructured.RemoveNestedField(obj.Object, "spec", "replicas")
+			return unstructured.SetNestedField(obj.Object, fmt.Sprintf("%d", replicas), "spec", "size")
+		})
+	return converter
+}
+
+func TestConvert(t *testing.T) {
+	converter := newTestConverter()
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1alpha1",
+		"kind":       "Sample",
+		"spec":       map[string]interface{}{"size": "3"},
+	}}
+
+	assert.NoError(t, converter.Convert(obj, "example.com/v1"))
+	assert.Equal(t, "example.com/v1", obj.GetAPIVersion())
+	replicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	assert.Equal(t, int64(3), replicas)
+
+	assert.NoError(t, converter.Convert(obj, "example.com/v1alpha1"))
+	assert.Equal(t, "example.com/v1alpha1", obj.GetAPIVersion())
+	size, _, _ := unstructured.NestedString(obj.Object, "spec", "size")
+	assert.Equal(t, "3", size)
+
+	assert.Error(t, converter.Convert(obj, "example.com/v2"))
+}
+
+func TestServeHTTP(t *testing.T) {
+	converter := newTestConverter()
+	review := func(objects string) *ConversionResponse {
+		body := []byte(`{"apiVersion":"apiextensions.k8s.io/v1","kind":"ConversionReview","request":{"uid":"1","desiredAPIVersion":"example.com/v1","objects":[` + objects + `]}}`)
+		r := httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		converter.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		result := &ConversionReview{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+		assert.Equal(t, "1", result.Response.UID)
+		return result.Response
+	}
+
+	response := review(`{"apiVersion":"example.com/v1alpha1","kind":"Sample","spec":{"size":"2"}}`)
+	assert.Equal(t, metav1.StatusSuccess, response.Result.Status)
+	assert.Equal(t, 1, len(response.ConvertedObjects))
+	assert.JSONEq(t, `{"apiVersion":"example.com/v1","kind":"Sample","spec":{"replicas":2}}`, string(response.ConvertedObjects[0]))
+
+	response = review(`{"apiVersion":"example.com/v0","kind":"Sample"}`)
+	assert.Equal(t, metav1.StatusFailure, response.Result.Status)
+	assert.Contains(t, response.Result.Message, "unsupported version example.com/v0")
+}
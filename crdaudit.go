@@ -0,0 +1,101 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultCRDAuditInterval = 5 * time.Minute
+
+	// EventReasonCRDRepaired is the reason of the event emitted when a CRD edited out-of-band is updated again
+	EventReasonCRDRepaired = "CRDRepaired"
+)
+
+// CRDAuditor periodically checks the CRDs created by the operator and corrects them when an admin deleted them or
+// changed their group, scope, kind, or served versions. Deleted CRDs are created again and changed CRDs are updated,
+// each with an event and the crd_repairs_total metric. Resources with the secondary role are not audited, since
+// their CRDs belong to another operator.
+type CRDAuditor struct {
+	context   Context
+	interval  time.Duration
+	resources []CustomResource
+}
+
+// NewCRDAuditor creates an auditor of the CRDs of the resources. The interval defaults to five minutes.
+func NewCRDAuditor(context Context, interval time.Duration, resources ...CustomResource) *CRDAuditor {
+	// the audit reconciles mismatches regardless of the policy used when the operator installed the CRDs
+	context.CRDMismatchPolicy = UpdateOnCRDMismatch
+	return &CRDAuditor{
+		context:   context,
+		interval:  durationOrDefault(interval, defaultCRDAuditInterval),
+		resources: resources,
+	}
+}
+
+// Run audits the CRDs at the interval until the stop channel is closed
+func (a *CRDAuditor) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if _, err := a.Audit(); err != nil {
+			a.context.logger().Error(err, "failed to audit the CRDs")
+		}
+	}, a.interval, stopCh)
+}
+
+// Audit checks the CRDs once. It returns the number of CRDs that were corrected and an aggregate of the errors.
+func (a *CRDAuditor) Audit() (int, error) {
+	create, _, _, err := installFuncs(a.context)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	corrected := 0
+	for _, resource := range a.resources {
+		if resource.Role == SecondaryCRDRole {
+			continue
+		}
+		outcome, err := create(a.context, resource)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to audit %s CRD. %+v", resource.Name, err))
+			continue
+		}
+
+		switch outcome {
+		case OutcomeCreated:
+			a.context.logger().Info("recreated the CRD deleted out-of-band", "resource", resource.Name)
+			a.context.Metrics.observeCRDRepair(resource.Name, "recreated")
+			a.context.recordCRDEvent(resource, v1.EventTypeWarning, EventReasonCRDRecreated,
+				fmt.Sprintf("the CRD of %s was deleted out-of-band and created again", resource.Name))
+			corrected++
+		case OutcomeUpdated:
+			a.context.logger().Info("repaired the CRD changed out-of-band", "resource", resource.Name)
+			a.context.Metrics.observeCRDRepair(resource.Name, "repaired")
+			a.context.recordCRDEvent(resource, v1.EventTypeWarning, EventReasonCRDRepaired,
+				fmt.Sprintf("the CRD of %s was changed out-of-band and updated again", resource.Name))
+			corrected++
+		}
+	}
+	return corrected, errorsUtil.NewAggregate(errs)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclientfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCRDAuditor(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	m := NewMetrics("test")
+	ctx := Context{
+		APIExtensionClientset: apiextensionsclientfake.NewSimpleClientset(),
+		APIFlavor:             ForceCRDv1beta1,
+		Recorder:              recorder,
+		Metrics:               m,
+	}
+	resource := exampleResource
+	resource.Kind = "Example"
+	secondary := CustomResource{Name: "other", Plural: "others", Group: "example.com", Version: "v1", Role: SecondaryCRDRole}
+	auditor := NewCRDAuditor(ctx, 0, resource, secondary)
+
+	// the deleted CRD is created again, and the CRD of the secondary resource is left alone
+	corrected, err := auditor.Audit()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, corrected)
+	assert.Equal(t, "Warning CRDRecreated the CRD of example was deleted out-of-band and created again", <-recorder.Events)
+	crdClient := ctx.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions()
+	_, err = crdClient.Get("others.example.com", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	corrected, err = auditor.Audit()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, corrected)
+
+	// an edited kind is repaired
+	crd, err := crdClient.Get("examples.example.com", metav1.GetOptions{})
+	assert.NoError(t, err)
+	crd.Spec.Names.Kind = "Edited"
+	_, err = crdClient.Update(crd)
+	assert.NoError(t, err)
+	corrected, err = auditor.Audit()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, corrected)
+	assert.Equal(t, "Warning CRDRepaired the CRD of example was changed out-of-band and updated again", <-recorder.Events)
+	crd, err = crdClient.Get("examples.example.com", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Example", crd.Spec.Names.Kind)
+
+	// a scope cannot be repaired
+	crd.Spec.Scope = apiextensionsv1beta1.ClusterScoped
+	_, err = crdClient.Update(crd)
+	assert.NoError(t, err)
+	_, err = auditor.Audit()
+	assert.Error(t, err)
+
+	families, err := m.Registry().Gather()
+	assert.NoError(t, err)
+	repairs := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "test_crd_repairs_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "action" {
+					repairs[label.GetValue()] = metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{"recreated": 1, "repaired": 1}, repairs)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+)
+
+const (
+	crdAPIVersionV1      = apiextensionsGroup + "/v1"
+	crdAPIVersionV1beta1 = apiextensionsGroup + "/v1beta1"
+)
+
+// crdManifest is the subset of a CRD manifest of either apiextensions version the loader reads
+type crdManifest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Group   string `json:"group"`
+		Scope   string `json:"scope"`
+		Version string `json:"version"`
+		Names   struct {
+			Singular string `json:"singular"`
+			Plural   string `json:"plural"`
+			Kind     string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name    string `json:"name"`
+			Storage bool   `json:"storage"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// ParseCRDs parses the CRD manifests of a YAML or JSON stream, such as the output of controller-gen, into custom
+// resources. The Version of a resource is the storage version of its CRD. The manifest is kept in the resource, so
+// CreateCustomResources creates the CRD with its schema, and fails if the cluster does not serve the apiextensions
+// version of the manifest. Documents of other kinds are skipped.
+func ParseCRDs(data []byte) ([]CustomResource, error) {
+	var resources []CustomResource
+	err := decodeManifests(bytes.NewReader(data), func(doc map[string]interface{}) error {
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		manifest := &crdManifest{}
+		if err := json.Unmarshal(raw, manifest); err != nil {
+			return fmt.Errorf("failed to parse CRD. %+v", err)
+		}
+		if manifest.Kind != "CustomResourceDefinition" {
+			return nil
+		}
+		if manifest.APIVersion != crdAPIVersionV1 && manifest.APIVersion != crdAPIVersionV1beta1 {
+			return fmt.Errorf("CRD %s has unsupported apiVersion %s", manifest.Metadata.Name, manifest.APIVersion)
+		}
+
+		resource, err := manifest.customResource()
+		if err != nil {
+			return err
+		}
+		resource.Manifest = raw
+		resources = append(resources, resource)
+		return nil
+	})
+	return resources, err
+}
+
+func (m *crdManifest) customResource() (CustomResource, error) {
+	names := m.Spec.Names
+	resource := CustomResource{
+		Name:    names.Singular,
+		Plural:  names.Plural,
+		Group:   m.Spec.Group,
+		Version: m.Spec.Version,
+		Scope:   apiextensionsv1beta1.ResourceScope(m.Spec.Scope),
+		Kind:    names.Kind,
+	}
+	if resource.Name == "" {
+		resource.Name = strings.ToLower(names.Kind)
+	}
+	for _, v := range m.Spec.Versions {
+		if v.Storage {
+			resource.Version = v.Name
+		}
+	}
+
+	if resource.Plural == "" || resource.Group == "" || resource.Kind == "" || resource.Version == "" {
+		return resource, fmt.Errorf("CRD %s is missing the group, plural, kind, or storage version", m.Metadata.Name)
+	}
+	if resource.Scope == "" {
+		resource.Scope = apiextensionsv1beta1.NamespaceScoped
+	}
+	return resource, nil
+}
+
+// manifestOf returns the manifest of the resource with the labels added if the manifest has the apiVersion, or nil
+// if the CRD has to be generated from the fields of the resource
+func manifestOf(resource CustomResource, apiVersion string, labels map[string]string) ([]byte, error) {
+	if len(resource.Manifest) == 0 {
+		return nil, nil
+	}
+	crd := map[string]interface{}{}
+	if err := json.Unmarshal(resource.Manifest, &crd); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest of %s. %+v", resource.Name, err)
+	}
+	if crd["apiVersion"] != apiVersion {
+		return nil, nil
+	}
+
+	if len(labels) > 0 {
+		metadata, _ := crd["metadata"].(map[string]interface{})
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+			crd["metadata"] = metadata
+		}
+		merged, _ := metadata["labels"].(map[string]interface{})
+		if merged == nil {
+			merged = map[string]interface{}{}
+			metadata["labels"] = merged
+		}
+		for key, value := range labels {
+			merged[key] = value
+		}
+	}
+	return json.Marshal(crd)
+}
@@ -0,0 +1,60 @@
+//go:build go1.16
+// +build go1.16
+
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// LoadCRDs parses the CRD manifests of the files matching the patterns in the file system, usually an embed.FS
+// holding the output of controller-gen:
+//
+//	//go:embed config/crd/bases/*.yaml
+//	var crds embed.FS
+//
+//	resources, err := opkit.LoadCRDs(crds, "config/crd/bases/*.yaml")
+func LoadCRDs(fsys fs.FS, patterns ...string) ([]CustomResource, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q. %+v", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	var resources []CustomResource
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s. %+v", file, err)
+		}
+		parsed, err := ParseCRDs(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s. %+v", file, err)
+		}
+		resources = append(resources, parsed...)
+	}
+	return resources, nil
+}
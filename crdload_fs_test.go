@@ -0,0 +1,45 @@
+//go:build go1.16
+// +build go1.16
+
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCRDs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"crds/b.yaml":   {Data: []byte(testCRDs)},
+		"crds/a.yaml":   {Data: []byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: samples.example.com\nspec:\n  group: example.com\n  names:\n    kind: Sample\n    plural: samples\n  versions:\n  - name: v1\n    storage: true\n")},
+		"crds/notes.md": {Data: []byte("not a manifest")},
+	}
+
+	resources, err := LoadCRDs(fsys, "crds/*.yaml")
+	assert.NoError(t, err)
+	var kinds []string
+	for _, resource := range resources {
+		kinds = append(kinds, resource.Kind)
+	}
+	assert.Equal(t, []string{"Sample", "Widget", "Gadget"}, kinds)
+
+	_, err = LoadCRDs(fsys, "[")
+	assert.Error(t, err)
+}
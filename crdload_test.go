@@ -0,0 +1,155 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/rest"
+)
+
+const testCRDs = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: Widget
+    plural: widgets
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: false
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unrelated
+---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: gadgets.example.com
+  labels:
+    app: gadgets
+spec:
+  group: example.com
+  version: v1beta2
+  names:
+    kind: Gadget
+    singular: gadget
+    plural: gadgets
+  validation:
+    openAPIV3Schema:
+      required: ["spec"]
+  subresources:
+    status: {}
+`
+
+func TestParseCRDs(t *testing.T) {
+	resources, err := ParseCRDs([]byte(testCRDs))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(resources))
+
+	widget := resources[0]
+	assert.Equal(t, "widget", widget.Name)
+	assert.Equal(t, "widgets", widget.Plural)
+	assert.Equal(t, "example.com", widget.Group)
+	assert.Equal(t, "v1", widget.Version)
+	assert.Equal(t, "Widget", widget.Kind)
+	assert.Equal(t, apiextensionsv1beta1.ClusterScoped, widget.Scope)
+
+	gadget := resources[1]
+	assert.Equal(t, "gadget", gadget.Name)
+	assert.Equal(t, "v1beta2", gadget.Version)
+	assert.Equal(t, apiextensionsv1beta1.NamespaceScoped, gadget.Scope)
+
+	// the v1 manifest is only used on clusters serving v1 CRDs
+	manifest, err := manifestOf(widget, crdAPIVersionV1beta1, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, manifest)
+
+	_, err = ParseCRDs([]byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: broken\n"))
+	assert.Error(t, err)
+}
+
+func TestCreateCRDFromManifest(t *testing.T) {
+	resources, err := ParseCRDs([]byte(testCRDs))
+	assert.NoError(t, err)
+
+	// the cluster only serves v1beta1 CRDs
+	created := map[string]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost || r.URL.Path != crdV1beta1Path {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		crd := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(body, &crd))
+		created[crd["metadata"].(map[string]interface{})["name"].(string)] = crd
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer server.Close()
+	clientset, err := apiextensionsclient.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	context := Context{APIExtensionClientset: clientset, OperatorName: "gadget-operator"}
+
+	// the manifest is sent as it is, so fields unknown to the typed CRDs are kept
+	outcome, err := createCRD(context, resources[1])
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeCreated, outcome)
+	crd := created["gadgets.example.com"]
+	assert.Equal(t, map[string]interface{}{"app": "gadgets", CRDOwnerLabel: "gadget-operator"},
+		crd["metadata"].(map[string]interface{})["labels"])
+	spec := crd["spec"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"spec"}, spec["validation"].(map[string]interface{})["openAPIV3Schema"].(map[string]interface{})["required"])
+	assert.Equal(t, map[string]interface{}{"status": map[string]interface{}{}}, spec["subresources"])
+
+	// the v1 manifest fails rather than falling back to a CRD without its schema
+	_, err = createCRD(context, resources[0])
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not serve apiextensions.k8s.io/v1")
+	assert.Equal(t, 1, len(created))
+
+	// the exported CRD keeps the schema of the manifest
+	data, err := exportCRD(resources[0], ForceCRDv1)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "openAPIV3Schema")
+	assert.Contains(t, string(data), "v1alpha1")
+	_, err = json.Marshal(resources)
+	assert.NoError(t, err)
+}
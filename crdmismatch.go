@@ -0,0 +1,136 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/client-go/rest"
+)
+
+// CRDMismatchPolicy decides what happens when the CRD of a resource already exists with a different definition
+type CRDMismatchPolicy int
+
+const (
+	// FailOnCRDMismatch fails the install with an ErrCRDMismatch. This is the default.
+	FailOnCRDMismatch CRDMismatchPolicy = iota
+
+	// WarnOnCRDMismatch logs the differences and uses the existing CRD as it is
+	WarnOnCRDMismatch
+
+	// UpdateOnCRDMismatch replaces the spec of the existing CRD. The group and scope of a CRD cannot be changed, so
+	// differences in them still fail the install.
+	UpdateOnCRDMismatch
+)
+
+// crdMismatches returns the differences of the existing CRD from the expected definition. Empty fields of the
+// expected definition are not compared.
+func crdMismatches(expected, existing crdDefinition) (mismatches []string, immutable bool) {
+	if expected.group != "" && expected.group != existing.group {
+		mismatches = append(mismatches, fmt.Sprintf("group is %s instead of %s", existing.group, expected.group))
+		immutable = true
+	}
+	if expected.scope != "" && expected.scope != existing.scope {
+		mismatches = append(mismatches, fmt.Sprintf("scope is %s instead of %s", existing.scope, expected.scope))
+		immutable = true
+	}
+	if expected.kind != "" && expected.kind != existing.kind {
+		mismatches = append(mismatches, fmt.Sprintf("kind is %s instead of %s", existing.kind, expected.kind))
+	}
+	for _, version := range expected.versions {
+		served := false
+		for _, v := range existing.versions {
+			served = served || v == version
+		}
+		if !served {
+			mismatches = append(mismatches, fmt.Sprintf("version %s is not served", version))
+		}
+	}
+	return mismatches, immutable
+}
+
+// checkCRDMismatch applies the mismatch policy of the context to an existing CRD. It returns true when the CRD must
+// be updated.
+func checkCRDMismatch(context Context, resource CustomResource, expected, existing crdDefinition) (bool, error) {
+	mismatches, immutable := crdMismatches(expected, existing)
+	if len(mismatches) == 0 {
+		return false, nil
+	}
+	err := &ErrCRDMismatch{Resource: resource.Name, Mismatches: mismatches}
+	switch context.CRDMismatchPolicy {
+	case WarnOnCRDMismatch:
+		context.logger().Error(err, "using the existing CRD", "resource", resource.Name)
+		return false, nil
+	case UpdateOnCRDMismatch:
+		if immutable {
+			return false, err
+		}
+		context.logger().Info("updating the existing CRD", "resource", resource.Name, "mismatches", mismatches)
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+func crdV1beta1Definition(crd *apiextensionsv1beta1.CustomResourceDefinition) crdDefinition {
+	definition := crdDefinition{group: crd.Spec.Group, scope: string(crd.Spec.Scope), kind: crd.Spec.Names.Kind}
+	if crd.Spec.Version != "" {
+		definition.versions = []string{crd.Spec.Version}
+	}
+	return definition
+}
+
+func crdV1Definition(crd *crdV1) crdDefinition {
+	definition := crdDefinition{group: crd.Spec.Group, scope: crd.Spec.Scope, kind: crd.Spec.Names.Kind}
+	if crd.Spec.Version != "" && len(crd.Spec.Versions) == 0 {
+		definition.versions = []string{crd.Spec.Version}
+	}
+	for _, v := range crd.Spec.Versions {
+		if v.Served {
+			definition.versions = append(definition.versions, v.Name)
+		}
+	}
+	return definition
+}
+
+// updateRawCRDSpec replaces the spec of the existing CRD at the path with the spec of the body. The rest of the
+// existing CRD, such as its resourceVersion, is kept.
+func updateRawCRDSpec(restcli rest.Interface, resource CustomResource, path, name string, existing, body []byte) error {
+	crd := map[string]interface{}{}
+	if err := json.Unmarshal(existing, &crd); err != nil {
+		return fmt.Errorf("failed to parse %s CRD. %+v", resource.Name, err)
+	}
+	desired := struct {
+		Spec json.RawMessage `json:"spec"`
+	}{}
+	if err := json.Unmarshal(body, &desired); err != nil {
+		return fmt.Errorf("failed to parse the definition of %s CRD. %+v", resource.Name, err)
+	}
+	crd["spec"] = desired.Spec
+	updated, err := json.Marshal(crd)
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s CRD. %+v", resource.Name, err)
+	}
+	_, err = restcli.Put().AbsPath(path, name).SetHeader("Content-Type", "application/json").Body(updated).DoRaw()
+	if err != nil {
+		return fmt.Errorf("failed to update %s CRD. %+v", resource.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	defaultCRDCheckInterval = 30 * time.Second
+	pausedRequeueDelay      = 10 * time.Second
+
+	// EventReasonCRDTerminating is the reason of the event emitted when a controller pauses because its CRD is deleted
+	EventReasonCRDTerminating = "CRDTerminating"
+	// EventReasonCRDRecreated is the reason of the event emitted when a deleted CRD is created again
+	EventReasonCRDRecreated = "CRDRecreated"
+	// EventReasonCRDRestored is the reason of the event emitted when a paused controller resumes
+	EventReasonCRDRestored = "CRDRestored"
+)
+
+// CRDDeletionPolicy decides what a controller does when the CRD of its custom resource is deleted
+type CRDDeletionPolicy int
+
+const (
+	// PauseOnCRDDeletion pauses reconciles until the CRD is established again
+	PauseOnCRDDeletion CRDDeletionPolicy = iota
+	// RecreateOnCRDDeletion pauses reconciles and creates the CRD again once the deletion has completed. Resources
+	// with the secondary role are only paused, since the CRD is recreated by the primary operator.
+	RecreateOnCRDDeletion
+)
+
+type crdState int
+
+const (
+	crdStatePending crdState = iota
+	crdStateEstablished
+	crdStateTerminating
+	crdStateMissing
+)
+
+// getCRD reads the CRD of the resource through whichever apiextensions version the cluster serves. The CRD is nil
+// when it does not exist.
+func getCRD(context Context, resource CustomResource) (*crdV1, error) {
+	crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
+	restcli := context.APIExtensionClientset.Discovery().RESTClient()
+
+	for _, version := range []string{"v1", "v1beta1"} {
+		raw, err := restcli.Get().AbsPath("/apis", apiextensionsGroup, version, "customresourcedefinitions", crdName).DoRaw()
+		if err != nil {
+			// the CRD is missing, or the cluster does not serve this version
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get CRD %s. %+v", crdName, err)
+		}
+
+		crd := &crdV1{}
+		if err := json.Unmarshal(raw, crd); err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s. %+v", crdName, err)
+		}
+		return crd, nil
+	}
+	return nil, nil
+}
+
+// getCRDState reads the state of the CRD of the resource
+func getCRDState(context Context, resource CustomResource) (crdState, error) {
+	crd, err := getCRD(context, resource)
+	if err != nil {
+		return crdStatePending, err
+	}
+	if crd == nil {
+		return crdStateMissing, nil
+	}
+	if crd.Metadata.DeletionTimestamp != "" {
+		return crdStateTerminating, nil
+	}
+	for _, cond := range crd.Status.Conditions {
+		if cond.Type == "Terminating" && cond.Status == "True" {
+			return crdStateTerminating, nil
+		}
+		if cond.Type == "Established" && cond.Status == "True" {
+			return crdStateEstablished, nil
+		}
+	}
+	return crdStatePending, nil
+}
+
+// monitorsCRD returns whether the controller watches its CRD for deletion. TPRs are not monitored. The API flavor
+// is only detected the first time, and kept to recreate the CRD.
+func (c *Controller) monitorsCRD() bool {
+	if c.context.APIExtensionClientset == nil {
+		return false
+	}
+	if c.crdFlavor == AutoDetect {
+		flavor, err := detectAPIFlavor(c.context)
+		if err != nil {
+			c.context.logger().Error(err, "not monitoring the CRD for deletion", "resource", c.resource.Name)
+			return false
+		}
+		c.crdFlavor = flavor
+	}
+	return c.crdFlavor != ForceTPR
+}
+
+func (c *Controller) crdPaused() bool {
+	return atomic.LoadInt32(&c.paused) == 1
+}
+
+// checkCRD pauses the controller while the CRD is terminating or missing and resumes it once the CRD is established
+func (c *Controller) checkCRD() {
+	logger := c.context.logger().WithValues("resource", c.resource.Name)
+	state, err := getCRDState(c.context, c.resource)
+	if err != nil {
+		logger.Error(err, "failed to check the CRD")
+		return
+	}
+
+	switch state {
+	case crdStateTerminating, crdStateMissing:
+		if atomic.CompareAndSwapInt32(&c.paused, 0, 1) {
+			logger.Info("pausing reconciles while the CRD is deleted")
+			c.context.Metrics.setCRDTerminating(c.resource.Name, true)
+			c.context.recordCRDEvent(c.resource, v1.EventTypeWarning, EventReasonCRDTerminating,
+				fmt.Sprintf("reconciles of %s are paused while the CRD is deleted", c.resource.Name))
+		}
+		if state == crdStateMissing && c.options.CRDDeletionPolicy == RecreateOnCRDDeletion && c.resource.Role != SecondaryCRDRole {
+			c.recreateCRD()
+		}
+
+	case crdStateEstablished:
+		if atomic.CompareAndSwapInt32(&c.paused, 1, 0) {
+			logger.Info("resuming reconciles since the CRD is established")
+			c.context.Metrics.setCRDTerminating(c.resource.Name, false)
+			c.context.recordCRDEvent(c.resource, v1.EventTypeNormal, EventReasonCRDRestored,
+				fmt.Sprintf("reconciles of %s are resumed", c.resource.Name))
+		}
+	}
+}
+
+func (c *Controller) recreateCRD() {
+	create, _, _ := flavorInstallFuncs(c.crdFlavor)
+	if _, err := create(c.context, c.resource); err != nil {
+		c.context.logger().Error(err, "failed to recreate the CRD", "resource", c.resource.Name)
+		return
+	}
+	c.context.logger().Info("recreated the deleted CRD", "resource", c.resource.Name)
+	c.context.recordCRDEvent(c.resource, v1.EventTypeNormal, EventReasonCRDRecreated,
+		fmt.Sprintf("the CRD of %s was deleted and created again", c.resource.Name))
+}
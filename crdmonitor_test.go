@@ -0,0 +1,159 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	establishedCRD = `{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition",` +
+		`"metadata":{"name":"examples.example.com"},"status":{"conditions":[{"type":"Established","status":"True"}]}}`
+	terminatingCRD = `{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition",` +
+		`"metadata":{"name":"examples.example.com","deletionTimestamp":"2020-01-02T03:04:05Z"},` +
+		`"status":{"conditions":[{"type":"Established","status":"True"},{"type":"Terminating","status":"True"}]}}`
+)
+
+// crdMonitorServer serves the CRD of the example resource from apiextensions.k8s.io/v1, and creates it on POST.
+// The CRD is missing while crd is empty.
+type crdMonitorServer struct {
+	crd     string
+	creates int
+}
+
+func (s *crdMonitorServer) clientset(t *testing.T) apiextensionsclient.Interface {
+	clientset, err := apiextensionsclient.NewForConfig(&rest.Config{
+		Host: "http://crds",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			switch {
+			case req.Method == http.MethodGet && req.URL.Path == crdV1Path+"/examples.example.com" && s.crd != "":
+				recorder.WriteString(s.crd)
+			case req.Method == http.MethodPost && req.URL.Path == crdV1Path:
+				s.creates++
+				s.crd = establishedCRD
+				recorder.WriteHeader(http.StatusCreated)
+				recorder.WriteString(s.crd)
+			default:
+				recorder.WriteHeader(http.StatusNotFound)
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			}
+			return recorder.Result(), nil
+		}),
+	})
+	assert.NoError(t, err)
+	return clientset
+}
+
+func TestCRDMonitorPauseOnDeletion(t *testing.T) {
+	server := &crdMonitorServer{crd: establishedCRD}
+	recorder := record.NewFakeRecorder(10)
+	m := NewMetrics("test")
+	ctx := Context{APIExtensionClientset: server.clientset(t), APIFlavor: ForceCRDv1, Recorder: recorder, Metrics: m}
+	c := NewControllerWithOptions(ctx, exampleResource, v1.NamespaceAll, nil, &v1.ConfigMap{}, nil, ControllerOptions{})
+	assert.True(t, c.monitorsCRD())
+
+	c.checkCRD()
+	assert.False(t, c.crdPaused())
+
+	// reconciles are paused while the CRD is terminating and after it is gone, without recreating it
+	server.crd = terminatingCRD
+	c.checkCRD()
+	assert.True(t, c.crdPaused())
+	assert.Equal(t, "Warning CRDTerminating reconciles of example are paused while the CRD is deleted", <-recorder.Events)
+	assert.Equal(t, map[string]float64{"resource=example": 1}, gatherLabeled(t, m.Registry(), "test_crd_terminating"))
+	server.crd = ""
+	c.checkCRD()
+	assert.True(t, c.crdPaused())
+	assert.Equal(t, 0, server.creates)
+	assert.Len(t, recorder.Events, 0)
+
+	// reconciles resume once another component created the CRD again
+	server.crd = establishedCRD
+	c.checkCRD()
+	assert.False(t, c.crdPaused())
+	assert.Equal(t, "Normal CRDRestored reconciles of example are resumed", <-recorder.Events)
+	assert.Equal(t, map[string]float64{"resource=example": 0}, gatherLabeled(t, m.Registry(), "test_crd_terminating"))
+}
+
+func TestCRDMonitorRecreateOnDeletion(t *testing.T) {
+	server := &crdMonitorServer{}
+	recorder := record.NewFakeRecorder(10)
+	ctx := Context{APIExtensionClientset: server.clientset(t), APIFlavor: ForceCRDv1, Recorder: recorder}
+	resource := exampleResource
+	resource.Kind = "Example"
+	options := ControllerOptions{CRDDeletionPolicy: RecreateOnCRDDeletion}
+	c := NewControllerWithOptions(ctx, resource, v1.NamespaceAll, nil, &v1.ConfigMap{}, nil, options)
+	assert.True(t, c.monitorsCRD())
+
+	c.checkCRD()
+	assert.True(t, c.crdPaused())
+	assert.Equal(t, 1, server.creates)
+	assert.Equal(t, "Warning CRDTerminating reconciles of example are paused while the CRD is deleted", <-recorder.Events)
+	assert.Equal(t, "Normal CRDRecreated the CRD of example was deleted and created again", <-recorder.Events)
+
+	c.checkCRD()
+	assert.False(t, c.crdPaused())
+	assert.Equal(t, "Normal CRDRestored reconciles of example are resumed", <-recorder.Events)
+
+	// the CRD of a secondary resource is created by the primary operator
+	server.crd = ""
+	resource.Role = SecondaryCRDRole
+	secondary := NewControllerWithOptions(ctx, resource, v1.NamespaceAll, nil, &v1.ConfigMap{}, nil, options)
+	assert.True(t, secondary.monitorsCRD())
+	secondary.checkCRD()
+	assert.True(t, secondary.crdPaused())
+	assert.Equal(t, 1, server.creates)
+}
+
+func TestCRDMonitorDetectsFlavorOnce(t *testing.T) {
+	versions := 0
+	clientset, err := kubernetes.NewForConfig(&rest.Config{
+		Host: "http://version",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			assert.Equal(t, "/version", req.URL.Path)
+			versions++
+			recorder.WriteString(`{"major":"1","minor":"20","gitVersion":"v1.20.0"}`)
+			return recorder.Result(), nil
+		}),
+	})
+	assert.NoError(t, err)
+	server := &crdMonitorServer{}
+	ctx := Context{Clientset: clientset, APIExtensionClientset: server.clientset(t)}
+	c := NewControllerWithOptions(ctx, exampleResource, v1.NamespaceAll, nil, &v1.ConfigMap{}, nil, ControllerOptions{})
+
+	assert.True(t, c.monitorsCRD())
+	assert.True(t, c.monitorsCRD())
+	assert.Equal(t, 1, versions)
+	assert.Equal(t, ForceCRDv1, c.crdFlavor)
+
+	// TPRs are not monitored
+	ctx = Context{APIExtensionClientset: server.clientset(t), APIFlavor: ForceTPR}
+	c = NewControllerWithOptions(ctx, exampleResource, v1.NamespaceAll, nil, &v1.ConfigMap{}, nil, ControllerOptions{})
+	assert.False(t, c.monitorsCRD())
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// The typed apiextensions clientset only knows about v1beta1. CRDs of apiextensions.k8s.io/v1, and CRDs from
+// manifests, are sent as raw JSON.
+const (
+	crdV1Path      = "/apis/apiextensions.k8s.io/v1/customresourcedefinitions"
+	crdV1beta1Path = "/apis/apiextensions.k8s.io/v1beta1/customresourcedefinitions"
+)
+
+// crdV1 is the subset of an apiextensions.k8s.io/v1 CustomResourceDefinition the kit reads and writes
+type crdV1 struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   crdV1Metadata `json:"metadata"`
+	Spec       crdV1Spec     `json:"spec"`
+	Status     crdV1Status   `json:"status,omitempty"`
+}
+
+type crdV1Metadata struct {
+	Name              string            `json:"name"`
+	UID               string            `json:"uid,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	DeletionTimestamp string            `json:"deletionTimestamp,omitempty"`
+}
+
+type crdV1Spec struct {
+	Group    string         `json:"group"`
+	Scope    string         `json:"scope"`
+	Names    crdV1Names     `json:"names"`
+	Versions []crdV1Version `json:"versions"`
+
+	// Version is only set by v1beta1 manifests, which may declare a single version
+	Version string `json:"version,omitempty"`
+}
+
+type crdV1Names struct {
+	Singular string `json:"singular,omitempty"`
+	Plural   string `json:"plural"`
+	Kind     string `json:"kind"`
+}
+
+type crdV1Version struct {
+	Name    string                 `json:"name"`
+	Served  bool                   `json:"served"`
+	Storage bool                   `json:"storage"`
+	Schema  map[string]interface{} `json:"schema"`
+}
+
+type crdV1Status struct {
+	Conditions []crdV1Condition `json:"conditions,omitempty"`
+}
+
+type crdV1Condition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func newCRDv1(resource CustomResource) *crdV1 {
+	return &crdV1{
+		APIVersion: "apiextensions.k8s.io/v1",
+		Kind:       "CustomResourceDefinition",
+		Metadata:   crdV1Metadata{Name: fmt.Sprintf("%s.%s", resource.Plural, resource.Group)},
+		Spec: crdV1Spec{
+			Group: resource.Group,
+			Scope: string(resource.Scope),
+			Names: crdV1Names{
+				Singular: resource.Name,
+				Plural:   resource.Plural,
+				Kind:     resource.Kind,
+			},
+			Versions: []crdV1Version{
+				{
+					Name:    resource.Version,
+					Served:  true,
+					Storage: true,
+					// v1 requires a structural schema. Without one from the operator all fields are preserved.
+					Schema: map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type":                                 "object",
+							"x-kubernetes-preserve-unknown-fields": true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func createCRDv1(context Context, resource CustomResource) (InstallOutcome, error) {
+	if len(resource.Manifest) > 0 {
+		return createCRDFromManifest(context, resource)
+	}
+	crd := newCRDv1(resource)
+	crd.Metadata.Labels = context.crdLabels()
+	body, err := json.Marshal(crd)
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to serialize %s CRD. %+v", resource.Name, err)
+	}
+	return createRawCRD(context, resource, crdV1Path, body)
+}
+
+// createCRDFromManifest creates the CRD from the manifest of the resource with the apiextensions version of the
+// manifest, so that none of its fields are dropped by the typed CRDs of the clientset. The install fails if the
+// cluster does not serve that version.
+func createCRDFromManifest(context Context, resource CustomResource) (InstallOutcome, error) {
+	manifest := struct {
+		APIVersion string `json:"apiVersion"`
+	}{}
+	if err := json.Unmarshal(resource.Manifest, &manifest); err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to parse the manifest of %s. %+v", resource.Name, err)
+	}
+	path := crdV1Path
+	switch manifest.APIVersion {
+	case crdAPIVersionV1:
+	case crdAPIVersionV1beta1:
+		path = crdV1beta1Path
+	default:
+		return OutcomeFailed, fmt.Errorf("the manifest of %s has unsupported apiVersion %s", resource.Name, manifest.APIVersion)
+	}
+	body, err := manifestOf(resource, manifest.APIVersion, context.crdLabels())
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	return createRawCRD(context, resource, path, body)
+}
+
+// createRawCRD creates the CRD from its JSON at the path of its apiextensions version. An existing CRD is checked
+// against the definition and updated by the mismatch policy of the context.
+func createRawCRD(context Context, resource CustomResource, path string, body []byte) (InstallOutcome, error) {
+	expected := &crdV1{}
+	if err := json.Unmarshal(body, expected); err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to parse the definition of %s CRD. %+v", resource.Name, err)
+	}
+
+	restcli := context.APIExtensionClientset.Discovery().RESTClient()
+	_, err := restcli.Post().AbsPath(path).SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return OutcomeFailed, fmt.Errorf("failed to create %s CRD since the cluster does not serve %s. %+v",
+				resource.Name, expected.APIVersion, err)
+		}
+		if !errors.IsAlreadyExists(err) {
+			return OutcomeFailed, fmt.Errorf("failed to create %s CRD. %+v", resource.Name, err)
+		}
+		raw, err := restcli.Get().AbsPath(path, expected.Metadata.Name).DoRaw()
+		if err != nil {
+			return OutcomeFailed, fmt.Errorf("failed to get %s CRD. %+v", resource.Name, err)
+		}
+		existing := &crdV1{}
+		if err := json.Unmarshal(raw, existing); err != nil {
+			return OutcomeFailed, fmt.Errorf("failed to parse %s CRD. %+v", resource.Name, err)
+		}
+		if err := checkCRDOwner(context, resource, existing.Metadata.Labels); err != nil {
+			return OutcomeFailed, err
+		}
+		update, err := checkCRDMismatch(context, resource, crdV1Definition(expected), crdV1Definition(existing))
+		if err != nil {
+			return OutcomeFailed, err
+		}
+		if !update {
+			return OutcomeAlreadyExisted, nil
+		}
+		if err := updateRawCRDSpec(restcli, resource, path, expected.Metadata.Name, raw, body); err != nil {
+			return OutcomeFailed, err
+		}
+		return OutcomeUpdated, nil
+	}
+	return OutcomeCreated, nil
+}
+
+func waitForCRDv1Init(context Context, resource CustomResource) error {
+	crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
+	restcli := context.APIExtensionClientset.Discovery().RESTClient()
+	return waitForCRDEstablished(context, resource, nil, func() ([]CRDCondition, error) {
+		raw, err := restcli.Get().AbsPath(crdV1Path, crdName).DoRaw()
+		if err != nil {
+			return nil, err
+		}
+		crd := &crdV1{}
+		if err := json.Unmarshal(raw, crd); err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s. %+v", crdName, err)
+		}
+		return crdV1Conditions(crd), nil
+	})
+}
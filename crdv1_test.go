@@ -0,0 +1,73 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/rest"
+)
+
+func TestCreateRawCRDOfExistingCRD(t *testing.T) {
+	resources, err := ParseCRDs([]byte(testCRDs))
+	assert.NoError(t, err)
+	widgets := resources[0]
+
+	// the existing CRD was created with another kind
+	existing := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(widgets.Manifest, &existing))
+	existing["spec"].(map[string]interface{})["names"].(map[string]interface{})["kind"] = "Gizmo"
+	var updated map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == crdV1Path:
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"AlreadyExists","code":409}`)
+		case r.Method == http.MethodGet && r.URL.Path == crdV1Path+"/widgets.example.com":
+			json.NewEncoder(w).Encode(existing)
+		case r.Method == http.MethodPut && r.URL.Path == crdV1Path+"/widgets.example.com":
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.NoError(t, json.Unmarshal(body, &updated))
+			w.Write(body)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	clientset, err := apiextensionsclient.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+
+	context := Context{APIExtensionClientset: clientset, CRDMismatchPolicy: WarnOnCRDMismatch}
+	outcome, err := createCRD(context, widgets)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeAlreadyExisted, outcome)
+	assert.Nil(t, updated)
+
+	context.CRDMismatchPolicy = UpdateOnCRDMismatch
+	outcome, err = createCRD(context, widgets)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeUpdated, outcome)
+	assert.Equal(t, "Widget", updated["spec"].(map[string]interface{})["names"].(map[string]interface{})["kind"])
+
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+// CustomResourceClient reads and writes the objects of a custom resource. The objects are created from the types
+// registered in the scheme for the kind of the resource and its list kind, which is the kind suffixed with "List".
+type CustomResourceClient struct {
+	client     rest.Interface
+	resource   CustomResource
+	scheme     *runtime.Scheme
+	codec      runtime.ParameterCodec
+	namespaced bool
+}
+
+// NewCustomResourceClient creates a client for the custom resource from the RESTConfig of the context. The scheme
+// must have the types of the resource registered, usually with the AddToScheme func of its API package.
+func NewCustomResourceClient(context Context, resource CustomResource, scheme *runtime.Scheme) (*CustomResourceClient, error) {
+	if context.RESTConfig == nil {
+		return nil, fmt.Errorf("the context has no RESTConfig")
+	}
+	gvk := resource.GroupVersionKind()
+	if !scheme.Recognizes(gvk) || !scheme.Recognizes(gvk.GroupVersion().WithKind(resource.Kind+"List")) {
+		return nil, fmt.Errorf("the scheme has no types registered for %s", gvk.String())
+	}
+
+	config := *context.RESTConfig
+	client, err := newRESTClient(&config, resource.Group, resource.Version, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for %s. %+v", resource.Name, err)
+	}
+
+	return newCustomResourceClient(client, resource, scheme), nil
+}
+
+func newCustomResourceClient(client rest.Interface, resource CustomResource, scheme *runtime.Scheme) *CustomResourceClient {
+	return &CustomResourceClient{
+		client:     client,
+		resource:   resource,
+		scheme:     scheme,
+		codec:      runtime.NewParameterCodec(scheme),
+		namespaced: resource.Scope != apiextensionsv1beta1.ClusterScoped,
+	}
+}
+
+// RESTClient returns the underlying client, for example to create a controller for the resource
+func (c *CustomResourceClient) RESTClient() rest.Interface {
+	return c.client
+}
+
+// Get returns the object with the name. The namespace is ignored for cluster scoped resources.
+func (c *CustomResourceClient) Get(namespace, name string) (runtime.Object, error) {
+	result, err := c.newObject(c.resource.Kind)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Get().NamespaceIfScoped(namespace, c.namespaced).Resource(c.resource.Plural).Name(name).
+		Do().Into(result)
+	return result, err
+}
+
+// List returns the list of objects matching the options. An empty namespace lists the objects in all namespaces.
+func (c *CustomResourceClient) List(namespace string, opts metav1.ListOptions) (runtime.Object, error) {
+	result, err := c.newObject(c.resource.Kind + "List")
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Get().NamespaceIfScoped(namespace, c.namespaced).Resource(c.resource.Plural).
+		VersionedParams(&opts, c.codec).Do().Into(result)
+	return result, err
+}
+
+// ListAll returns all objects matching the options in one list, listing them in pages of pageSize objects. A pageSize
+// of 0 uses DefaultPageSize.
+func (c *CustomResourceClient) ListAll(namespace string, opts metav1.ListOptions, pageSize int64) (runtime.Object, error) {
+	return ListAll(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(namespace, opts)
+	}, opts, pageSize)
+}
+
+// ListPages lists the objects matching the options in pages of pageSize objects and passes each page to f, so that
+// huge lists never have to be held in memory at once
+func (c *CustomResourceClient) ListPages(namespace string, opts metav1.ListOptions, pageSize int64, f func(page runtime.Object) error) error {
+	return ListPages(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return c.List(namespace, opts)
+	}, opts, pageSize, f)
+}
+
+// Watch returns a watch of the objects matching the options. An empty namespace watches all namespaces.
+func (c *CustomResourceClient) Watch(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().NamespaceIfScoped(namespace, c.namespaced).Resource(c.resource.Plural).
+		VersionedParams(&opts, c.codec).Watch()
+}
+
+// Create creates the object in its namespace and returns the server's representation of it
+func (c *CustomResourceClient) Create(obj runtime.Object) (runtime.Object, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.newObject(c.resource.Kind)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Post().NamespaceIfScoped(accessor.GetNamespace(), c.namespaced).Resource(c.resource.Plural).
+		Body(obj).Do().Into(result)
+	return result, err
+}
+
+// Update replaces the object and returns the server's representation of it
+func (c *CustomResourceClient) Update(obj runtime.Object) (runtime.Object, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.newObject(c.resource.Kind)
+	if err != nil {
+		return nil, err
+	}
+	err = c.client.Put().NamespaceIfScoped(accessor.GetNamespace(), c.namespaced).Resource(c.resource.Plural).
+		Name(accessor.GetName()).Body(obj).Do().Into(result)
+	return result, err
+}
+
+// Delete deletes the object with the name. The options are optional.
+func (c *CustomResourceClient) Delete(namespace, name string, options *metav1.DeleteOptions) error {
+	if options == nil {
+		options = &metav1.DeleteOptions{}
+	}
+	return c.client.Delete().NamespaceIfScoped(namespace, c.namespaced).Resource(c.resource.Plural).Name(name).
+		Body(options).Do().Error()
+}
+
+func (c *CustomResourceClient) newObject(kind string) (runtime.Object, error) {
+	obj, err := c.scheme.New(c.resource.GroupVersionKind().GroupVersion().WithKind(kind))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s object. %+v", kind, err)
+	}
+	return obj, nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+var sampleResource = CustomResource{Name: "sample", Plural: "samples", Group: "example.com", Version: "v1", Kind: "Sample",
+	Scope: apiextensionsv1beta1.NamespaceScoped}
+
+// newSampleClient creates a client for the sample resource, stored as config maps, that records the requests sent
+// to the server
+func newSampleClient(t *testing.T, resource CustomResource, requests *[]string) *CustomResourceClient {
+	scheme := runtime.NewScheme()
+	gv := schema.GroupVersion{Group: resource.Group, Version: resource.Version}
+	scheme.AddKnownTypeWithName(gv.WithKind(resource.Kind), &v1.ConfigMap{})
+	scheme.AddKnownTypeWithName(gv.WithKind(resource.Kind+"List"), &v1.ConfigMapList{})
+	metav1.AddToGroupVersion(scheme, gv)
+
+	config := &rest.Config{
+		Host: "http://samples",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			request := req.Method + " " + req.URL.Path
+			if req.URL.RawQuery != "" {
+				request += "?" + req.URL.RawQuery
+			}
+			*requests = append(*requests, request)
+
+			body, _ := ioutil.ReadAll(req.Body)
+			switch req.Method {
+			case http.MethodGet:
+				if req.URL.Path == "/apis/example.com/v1/namespaces/ns/samples/a" || req.URL.Path == "/apis/example.com/v1/samples/a" {
+					recorder.WriteString(`{"apiVersion":"example.com/v1","kind":"Sample","metadata":{"name":"a","namespace":"ns"},"data":{"k":"v"}}`)
+					break
+				}
+				recorder.WriteString(`{"apiVersion":"example.com/v1","kind":"SampleList","metadata":{"resourceVersion":"7"},` +
+					`"items":[{"metadata":{"name":"a","namespace":"ns"}},{"metadata":{"name":"b","namespace":"ns"}}]}`)
+			case http.MethodPost, http.MethodPut:
+				assert.Contains(t, string(body), `"kind":"Sample"`)
+				recorder.Write(body)
+			case http.MethodDelete:
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Success"}`)
+			}
+			return recorder.Result(), nil
+		}),
+	}
+	client, err := NewCustomResourceClient(Context{RESTConfig: config}, resource, scheme)
+	assert.NoError(t, err)
+	return client
+}
+
+func TestCustomResourceClient(t *testing.T) {
+	var requests []string
+	client := newSampleClient(t, sampleResource, &requests)
+
+	created, err := client.Create(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}, Data: map[string]string{"k": "v"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "v", created.(*v1.ConfigMap).Data["k"])
+
+	obj, err := client.Get("ns", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", obj.(*v1.ConfigMap).Name)
+
+	list, err := client.List("ns", metav1.ListOptions{LabelSelector: "app=sample"})
+	assert.NoError(t, err)
+	assert.Len(t, list.(*v1.ConfigMapList).Items, 2)
+	_, err = client.List("", metav1.ListOptions{})
+	assert.NoError(t, err)
+
+	obj.(*v1.ConfigMap).Data["k"] = "v2"
+	updated, err := client.Update(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", updated.(*v1.ConfigMap).Data["k"])
+
+	assert.NoError(t, client.Delete("ns", "a", nil))
+
+	assert.Equal(t, []string{
+		"POST /apis/example.com/v1/namespaces/ns/samples",
+		"GET /apis/example.com/v1/namespaces/ns/samples/a",
+		"GET /apis/example.com/v1/namespaces/ns/samples?labelSelector=app%3Dsample",
+		"GET /apis/example.com/v1/samples",
+		"PUT /apis/example.com/v1/namespaces/ns/samples/a",
+		"DELETE /apis/example.com/v1/namespaces/ns/samples/a",
+	}, requests)
+}
+
+func TestCustomResourceClientClusterScoped(t *testing.T) {
+	var requests []string
+	resource := sampleResource
+	resource.Scope = apiextensionsv1beta1.ClusterScoped
+	client := newSampleClient(t, resource, &requests)
+
+	// the namespace is ignored for cluster scoped resources
+	obj := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}}
+	_, err := client.Create(obj)
+	assert.NoError(t, err)
+	_, err = client.Get("ns", "a")
+	assert.NoError(t, err)
+	_, err = client.List("ns", metav1.ListOptions{})
+	assert.NoError(t, err)
+	_, err = client.Update(obj)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Delete("ns", "a", &metav1.DeleteOptions{}))
+
+	assert.Equal(t, []string{
+		"POST /apis/example.com/v1/samples",
+		"GET /apis/example.com/v1/samples/a",
+		"GET /apis/example.com/v1/samples",
+		"PUT /apis/example.com/v1/samples/a",
+		"DELETE /apis/example.com/v1/samples/a",
+	}, requests)
+}
+
+func TestNewCustomResourceClientErrors(t *testing.T) {
+	_, err := NewCustomResourceClient(Context{}, sampleResource, runtime.NewScheme())
+	assert.EqualError(t, err, "the context has no RESTConfig")
+
+	_, err = NewCustomResourceClient(Context{RESTConfig: &rest.Config{Host: "http://samples"}}, sampleResource, runtime.NewScheme())
+	assert.EqualError(t, err, "the scheme has no types registered for example.com/v1, Kind=Sample")
+}
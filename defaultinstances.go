@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/rest"
+)
+
+// EnsureDefaultInstances creates the instances of the custom resource that do not exist yet, such as a default
+// cluster object the operator seeds after its CRD is established. The instances may be typed or unstructured. The
+// apiVersion and kind of the resource are set if missing. Existing instances are left as they are, so changes made
+// by users are kept across restarts of the operator.
+func EnsureDefaultInstances(client rest.Interface, resource CustomResource, instances ...runtime.Object) error {
+	var errs []error
+	for _, obj := range instances {
+		if err := ensureDefaultInstance(client, resource, obj); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errorsUtil.NewAggregate(errs)
+}
+
+func ensureDefaultInstance(client rest.Interface, resource CustomResource, obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return fmt.Errorf("default %s instance has no metadata. %+v", resource.Name, err)
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to serialize default %s %s. %+v", resource.Name, accessor.GetName(), err)
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return fmt.Errorf("failed to serialize default %s %s. %+v", resource.Name, accessor.GetName(), err)
+	}
+	if body["apiVersion"] == nil || body["apiVersion"] == "" {
+		body["apiVersion"] = resource.GroupVersionKind().GroupVersion().String()
+	}
+	if body["kind"] == nil || body["kind"] == "" {
+		body["kind"] = resource.Kind
+	}
+	if data, err = json.Marshal(body); err != nil {
+		return fmt.Errorf("failed to serialize default %s %s. %+v", resource.Name, accessor.GetName(), err)
+	}
+
+	err = client.Post().Namespace(accessor.GetNamespace()).Resource(resource.Plural).
+		SetHeader("Content-Type", runtime.ContentTypeJSON).Body(data).Do().Error()
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create default %s %s. %+v", resource.Name, accessor.GetName(), err)
+	}
+	return nil
+}
+
+// createDefaultInstances creates the default instances of the resource with a client from the RESTConfig of the
+// context
+func createDefaultInstances(context Context, resource CustomResource) error {
+	if len(resource.DefaultInstances) == 0 {
+		return nil
+	}
+	if context.RESTConfig == nil {
+		return fmt.Errorf("the context has no RESTConfig to create the default %s instances", resource.Name)
+	}
+	// the status types are registered so that conflicts are decoded as AlreadyExists errors
+	scheme := runtime.NewScheme()
+	metav1.AddToGroupVersion(scheme, resource.GroupVersionKind().GroupVersion())
+	config := *context.RESTConfig
+	client, err := newRESTClient(&config, resource.Group, resource.Version, scheme)
+	if err != nil {
+		return fmt.Errorf("failed to create client for %s. %+v", resource.Name, err)
+	}
+	return EnsureDefaultInstances(client, resource, resource.DefaultInstances...)
+}
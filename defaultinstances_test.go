@@ -0,0 +1,112 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+func TestEnsureDefaultInstances(t *testing.T) {
+	created := map[string]map[string]interface{}{}
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host: "http://defaults",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body := map[string]interface{}{}
+			data, _ := ioutil.ReadAll(req.Body)
+			assert.NoError(t, json.Unmarshal(data, &body))
+			name := body["metadata"].(map[string]interface{})["name"].(string)
+			created[req.URL.Path+"/"+name] = body
+
+			recorder := httptest.NewRecorder()
+			switch name {
+			case "existing":
+				recorder.WriteHeader(http.StatusConflict)
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"AlreadyExists","code":409}`)
+			case "broken":
+				recorder.WriteHeader(http.StatusInternalServerError)
+			default:
+				recorder.WriteHeader(http.StatusCreated)
+			}
+			return recorder.Result(), nil
+		}),
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &schema.GroupVersion{Version: "v1"},
+			NegotiatedSerializer: scheme.Codecs,
+		},
+		APIPath: "/api",
+	})
+	assert.NoError(t, err)
+	resource := CustomResource{Name: "configmap", Plural: "configmaps", Version: "v1", Kind: "ConfigMap"}
+
+	typed := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "ns"}, Data: map[string]string{"a": "1"}}
+	unstructuredObj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "existing", "namespace": "ns"},
+	}}
+	assert.NoError(t, EnsureDefaultInstances(client, resource, typed, unstructuredObj))
+
+	body := created["/api/v1/namespaces/ns/configmaps/default"]
+	assert.Equal(t, "v1", body["apiVersion"])
+	assert.Equal(t, "ConfigMap", body["kind"])
+	assert.Equal(t, map[string]interface{}{"a": "1"}, body["data"])
+	assert.Contains(t, created, "/api/v1/namespaces/ns/configmaps/existing")
+
+	broken := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "ns"}}
+	err = EnsureDefaultInstances(client, resource, typed, broken)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create default configmap broken")
+}
+
+func TestCreateDefaultInstancesWithoutConfig(t *testing.T) {
+	resource := exampleResource
+	assert.NoError(t, createDefaultInstances(Context{}, resource))
+
+	resource.DefaultInstances = append(resource.DefaultInstances, &v1.ConfigMap{})
+	assert.Error(t, createDefaultInstances(Context{}, resource))
+}
+
+func TestCreateDefaultInstancesAlreadyExist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/apis/example.com/v1alpha/namespaces/ns/examples", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure",` +
+			`"message":"examples.example.com \"default\" already exists","reason":"AlreadyExists",` +
+			`"details":{"name":"default","group":"example.com","kind":"examples"},"code":409}`))
+	}))
+	defer server.Close()
+
+	resource := exampleResource
+	resource.Kind = "Example"
+	resource.DefaultInstances = []runtime.Object{&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "default", "namespace": "ns"},
+	}}}
+	assert.NoError(t, createDefaultInstances(Context{RESTConfig: &rest.Config{Host: server.URL}}, resource))
+}
@@ -0,0 +1,461 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	defaultDriftInterval = 10 * time.Minute
+
+	// DriftedConditionType is the type of the condition reporting whether the children of a custom resource drifted
+	// from their desired state
+	DriftedConditionType = "Drifted"
+
+	// ConditionReasonChildrenDrifted is the reason of the Drifted condition while children differ from their desired
+	// state
+	ConditionReasonChildrenDrifted = "ChildrenDrifted"
+
+	// ConditionReasonNoDrift is the reason of the Drifted condition once all children match their desired state
+	ConditionReasonNoDrift = "NoDrift"
+
+	// EventReasonDriftDetected is the reason of the event emitted when children of a custom resource drifted
+	EventReasonDriftDetected = "DriftDetected"
+
+	// EventReasonDriftRepaired is the reason of the event emitted when drifted children were repaired
+	EventReasonDriftRepaired = "DriftRepaired"
+)
+
+// DriftAction is what the drift detection does with a child that drifted from its desired state
+type DriftAction int
+
+const (
+	// ReportDrift only reports the drift in an event, the metrics, and optionally the Drifted condition
+	ReportDrift DriftAction = iota
+
+	// RepairDrift creates the missing child, or writes the desired fields over the live child. Fields the desired
+	// state does not set, such as those set by other controllers, are kept. Lists of objects with a name, such as
+	// containers, volumes, and env, are merged by name, so items added by others, such as injected sidecars, are
+	// kept. Other lists are replaced.
+	RepairDrift
+)
+
+// DriftKind configures the drift detection of the children of a kind
+type DriftKind struct {
+	// Resource is the plural name of the kind, such as "deployments"
+	Resource string
+
+	Action DriftAction
+}
+
+// DriftOptions configure the periodic comparison of the children of the custom resources with their desired state
+type DriftOptions struct {
+	// Render returns the desired children of a custom resource
+	Render RenderFunc
+
+	// Kinds of the children to compare by kind, such as "Deployment". Rendered children of other kinds are ignored.
+	Kinds map[string]DriftKind
+
+	// Interval between the comparisons of all resources. Defaults to ten minutes. The resources are queued for the
+	// comparison, which runs in the workers before the next reconcile, so paused and unauthorized resources are
+	// skipped like by reconciles.
+	Interval time.Duration
+
+	// Policies the repaired children are checked against before they are created or updated
+	Policies []Policy
+
+	// Condition is optional and sets the Drifted condition in status.conditions of the resources. Only
+	// UseSubresource of the options is used.
+	Condition *StatusUpdateOptions
+}
+
+// ChildDrift is a child that differs from its desired state
+type ChildDrift struct {
+	Kind      string
+	Namespace string
+	Name      string
+
+	// Missing is set when the child does not exist
+	Missing bool
+
+	// Fields are the paths of the fields that differ, such as "spec.replicas"
+	Fields []string
+
+	// Repaired is set when the child was corrected
+	Repaired bool
+}
+
+func (d ChildDrift) String() string {
+	name := d.Name
+	if d.Namespace != "" {
+		name = d.Namespace + "/" + d.Name
+	}
+	if d.Missing {
+		return fmt.Sprintf("%s %s is missing", d.Kind, name)
+	}
+	return fmt.Sprintf("%s %s differs in %s", d.Kind, name, strings.Join(d.Fields, ", "))
+}
+
+// DriftedFields returns the sorted paths of the fields set in the desired object that differ in the live object.
+// Fields only set in the live object, the status, and the metadata other than the labels and annotations are not
+// compared, so defaults and server fields are not reported as drift. Items of lists of objects with a name are
+// compared with the live item of the same name, and live items missing from the desired list are ignored.
+func DriftedFields(desired, live map[string]interface{}) []string {
+	var fields []string
+	for key, value := range desired {
+		switch key {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			metadata, _ := value.(map[string]interface{})
+			liveMetadata, _ := live["metadata"].(map[string]interface{})
+			for _, field := range []string{"labels", "annotations"} {
+				if _, ok := metadata[field]; ok {
+					fields = append(fields, driftedValue("metadata."+field, metadata[field], liveMetadata[field])...)
+				}
+			}
+			continue
+		}
+		fields = append(fields, driftedValue(key, value, live[key])...)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func driftedValue(path string, desired, live interface{}) []string {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			if len(d) == 0 && live == nil {
+				return nil
+			}
+			return []string{path}
+		}
+		var fields []string
+		for key, value := range d {
+			fields = append(fields, driftedValue(path+"."+key, value, l[key])...)
+		}
+		return fields
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if _, named := namedItems(d); ok && named {
+			if liveItems, liveNamed := namedItems(l); liveNamed {
+				var fields []string
+				for i, item := range d {
+					itemPath := fmt.Sprintf("%s[%d]", path, i)
+					if liveItem, ok := liveItems[itemName(item)]; ok {
+						fields = append(fields, driftedValue(itemPath, item, liveItem)...)
+					} else {
+						fields = append(fields, itemPath)
+					}
+				}
+				return fields
+			}
+		}
+		if !ok || len(l) != len(d) {
+			return []string{path}
+		}
+		var fields []string
+		for i := range d {
+			fields = append(fields, driftedValue(fmt.Sprintf("%s[%d]", path, i), d[i], l[i])...)
+		}
+		return fields
+	default:
+		if !reflect.DeepEqual(desired, live) {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+// overlayDesired writes the fields of the desired object over the live object. Maps and lists of objects with a
+// name are merged, while other lists and values are replaced.
+func overlayDesired(desired, live map[string]interface{}) {
+	for key, value := range desired {
+		live[key] = overlayValue(value, live[key])
+	}
+}
+
+func overlayValue(desired, live interface{}) interface{} {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		if l, ok := live.(map[string]interface{}); ok {
+			overlayDesired(d, l)
+			return l
+		}
+	case []interface{}:
+		l, ok := live.([]interface{})
+		desiredItems, named := namedItems(d)
+		liveItems, liveNamed := namedItems(l)
+		if ok && named && liveNamed {
+			// the live items keep their order, and the missing desired items are appended
+			merged := make([]interface{}, 0, len(l)+len(d))
+			for _, item := range l {
+				if desiredItem, ok := desiredItems[itemName(item)]; ok {
+					overlayDesired(desiredItem, item.(map[string]interface{}))
+				}
+				merged = append(merged, item)
+			}
+			for _, item := range d {
+				if _, ok := liveItems[itemName(item)]; !ok {
+					merged = append(merged, item)
+				}
+			}
+			return merged
+		}
+	}
+	return desired
+}
+
+// namedItems returns the items of the list by name if the list is not empty and all its items are objects with a
+// name
+func namedItems(list []interface{}) (map[string]map[string]interface{}, bool) {
+	items := map[string]map[string]interface{}{}
+	for _, item := range list {
+		name := itemName(item)
+		if name == "" {
+			return nil, false
+		}
+		items[name] = item.(map[string]interface{})
+	}
+	return items, len(items) > 0
+}
+
+// itemName returns the name of an item of a list, or "" if the item is not an object with a name
+func itemName(item interface{}) string {
+	m, _ := item.(map[string]interface{})
+	name, _ := m["name"].(string)
+	return name
+}
+
+// jsonMap converts the object to the map of its JSON, so that numbers compare the same whatever their Go type
+func jsonMap(obj interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DetectDrift compares the children of the custom resource with the key against their desired state, and repairs
+// or reports the drifted children by the options of the controller. It returns the drifted children.
+func (c *Controller) DetectDrift(key string) ([]ChildDrift, error) {
+	options := c.options.Drift
+	if options == nil || options.Render == nil {
+		return nil, fmt.Errorf("the controller of %s has no drift options", c.resource.Name)
+	}
+	if c.context.DynamicClientPool == nil {
+		return nil, fmt.Errorf("the context has no dynamic client pool")
+	}
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil || !exists {
+		return nil, err
+	}
+	parent, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, nil
+	}
+	accessor, err := meta.Accessor(parent)
+	if err != nil || accessor.GetDeletionTimestamp() != nil {
+		return nil, err
+	}
+
+	children, err := options.Render(parent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the children of %s %s. %+v", c.resource.Name, key, err)
+	}
+	var drifts []ChildDrift
+	for _, child := range children {
+		drift, err := c.childDrift(child, options)
+		if err != nil {
+			return drifts, err
+		}
+		if drift != nil {
+			drifts = append(drifts, *drift)
+		}
+	}
+
+	c.reportDrift(key, accessor, drifts, options)
+	return drifts, nil
+}
+
+// childDrift compares the child with the live object, and repairs it if the kind has the repair action
+func (c *Controller) childDrift(child runtime.Object, options *DriftOptions) (*ChildDrift, error) {
+	desired, err := jsonMap(child)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize the child. %+v", err)
+	}
+	desiredObj := &unstructured.Unstructured{Object: desired}
+	kind, ok := options.Kinds[desiredObj.GetKind()]
+	if !ok {
+		return nil, nil
+	}
+	gv, err := schema.ParseGroupVersion(desiredObj.GetAPIVersion())
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiVersion of %s %s. %+v", desiredObj.GetKind(), desiredObj.GetName(), err)
+	}
+	client, err := dynamicResourceClient(c.context.DynamicClientPool, gv.WithResource(kind.Resource), desiredObj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &ChildDrift{Kind: desiredObj.GetKind(), Namespace: desiredObj.GetNamespace(), Name: desiredObj.GetName()}
+	live, err := client.Get(desiredObj.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		drift.Missing = true
+		if kind.Action == RepairDrift {
+			if err := CheckPolicies(desiredObj, options.Policies...); err != nil {
+				return nil, err
+			}
+			if _, err := client.Create(desiredObj); err != nil {
+				return nil, fmt.Errorf("failed to create the missing %s. %+v", drift.Kind, err)
+			}
+			drift.Repaired = true
+		}
+		return drift, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s. %+v", drift.Kind, drift.Name, err)
+	}
+
+	liveMap, err := jsonMap(live.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize %s %s. %+v", drift.Kind, drift.Name, err)
+	}
+	if drift.Fields = DriftedFields(desired, liveMap); len(drift.Fields) == 0 {
+		return nil, nil
+	}
+	if kind.Action == RepairDrift {
+		// only the compared fields are written, so the server fields of the live metadata are kept
+		delete(desired, "status")
+		if metadata, ok := desired["metadata"].(map[string]interface{}); ok {
+			desired["metadata"] = map[string]interface{}{}
+			for _, field := range []string{"labels", "annotations"} {
+				if value, ok := metadata[field]; ok {
+					desired["metadata"].(map[string]interface{})[field] = value
+				}
+			}
+		}
+		overlayDesired(desired, live.Object)
+		if err := CheckPolicies(live, options.Policies...); err != nil {
+			return nil, err
+		}
+		if _, err := client.Update(live); err != nil {
+			return nil, fmt.Errorf("failed to repair %s. %+v", drift, err)
+		}
+		drift.Repaired = true
+	}
+	return drift, nil
+}
+
+// reportDrift records the drift in the events, the metrics, and the condition of the custom resource
+func (c *Controller) reportDrift(key string, accessor metav1.Object, drifts []ChildDrift, options *DriftOptions) {
+	var detected, repaired []string
+	for _, drift := range drifts {
+		action := "detected"
+		if drift.Repaired {
+			action = "repaired"
+			repaired = append(repaired, drift.String())
+		} else {
+			detected = append(detected, drift.String())
+		}
+		c.context.Metrics.observeDrift(c.resource.Name, drift.Kind, action)
+	}
+	if len(detected) > 0 {
+		c.recordEvent(key, v1.EventTypeWarning, EventReasonDriftDetected, strings.Join(detected, "; "))
+	}
+	if len(repaired) > 0 {
+		c.recordEvent(key, v1.EventTypeNormal, EventReasonDriftRepaired, "repaired "+strings.Join(repaired, "; "))
+	}
+
+	if options.Condition == nil {
+		return
+	}
+	obj, _, err := c.store.GetByKey(key)
+	if err != nil {
+		return
+	}
+	conditions, err := objectConditions(obj)
+	if err != nil {
+		c.context.logger().Error(err, "failed to read the conditions", "resource", c.resource.Name, "key", key)
+		return
+	}
+	condition := Condition{Type: DriftedConditionType, Status: v1.ConditionFalse, Reason: ConditionReasonNoDrift,
+		Message: "the children match their desired state"}
+	if len(detected) > 0 {
+		condition = Condition{Type: DriftedConditionType, Status: v1.ConditionTrue, Reason: ConditionReasonChildrenDrifted,
+			Message: strings.Join(detected, "; ")}
+	}
+	existing := FindCondition(conditions, DriftedConditionType)
+	if existing != nil && existing.Status == condition.Status && existing.Message == condition.Message {
+		return
+	}
+	if err := c.patchConditions(accessor, SetCondition(conditions, condition), *options.Condition); err != nil {
+		c.context.logger().Error(err, "failed to set the drifted condition", "resource", c.resource.Name, "key", key)
+	}
+}
+
+// detectAllDrift queues all the custom resources in the store for the comparison of their children. The workers
+// compare the children before reconciling, so the repairs never race a reconcile of the same resource.
+func (c *Controller) detectAllDrift() {
+	keys := c.store.ListKeys()
+	c.driftLock.Lock()
+	if c.driftPending == nil {
+		c.driftPending = map[string]bool{}
+	}
+	for _, key := range keys {
+		c.driftPending[key] = true
+	}
+	c.driftLock.Unlock()
+	for _, key := range keys {
+		c.queue.Add(key)
+	}
+}
+
+// detectPendingDrift compares the children of the custom resource if it was queued by detectAllDrift
+func (c *Controller) detectPendingDrift(key string) {
+	c.driftLock.Lock()
+	pending := c.driftPending[key]
+	delete(c.driftPending, key)
+	c.driftLock.Unlock()
+	if !pending {
+		return
+	}
+	if _, err := c.DetectDrift(key); err != nil {
+		c.context.logger().Error(err, "failed to detect drift", "resource", c.resource.Name, "key", key)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestDriftedFields(t *testing.T) {
+	desired := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "a", "labels": map[string]interface{}{"app": "a"}, "creationTimestamp": nil},
+		"spec": map[string]interface{}{
+			"replicas":  3.0,
+			"template":  map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"image": "v2"}}}},
+			"selector":  map[string]interface{}{},
+			"strategy":  map[string]interface{}{},
+			"unchanged": "x",
+		},
+		"status": map[string]interface{}{"replicas": 1.0},
+	}
+	live := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "a", "uid": "1", "labels": map[string]interface{}{"app": "b", "extra": "x"}},
+		"spec": map[string]interface{}{
+			"replicas":  5.0,
+			"template":  map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"image": "v1", "imagePullPolicy": "Always"}}}},
+			"selector":  "invalid",
+			"unchanged": "x",
+			"paused":    true,
+		},
+	}
+	assert.Equal(t, []string{"metadata.labels.app", "spec.replicas", "spec.selector", "spec.template.spec.containers[0].image"},
+		DriftedFields(desired, live))
+
+	overlayDesired(map[string]interface{}{"spec": map[string]interface{}{"replicas": 3.0}}, live)
+	assert.Equal(t, 3.0, live["spec"].(map[string]interface{})["replicas"])
+	assert.Equal(t, true, live["spec"].(map[string]interface{})["paused"])
+
+	// items with a name are matched by name, so injected sidecars are neither drift nor removed by repairs
+	desired = map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+		map[string]interface{}{"name": "app", "image": "v2"},
+	}}}
+	live = map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+		map[string]interface{}{"name": "proxy", "image": "envoy"},
+		map[string]interface{}{"name": "app", "image": "v1", "imagePullPolicy": "Always"},
+	}}}
+	assert.Equal(t, []string{"spec.containers[0].image"}, DriftedFields(desired, live))
+	overlayDesired(desired, live)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "proxy", "image": "envoy"},
+		map[string]interface{}{"name": "app", "image": "v2", "imagePullPolicy": "Always"},
+	}, live["spec"].(map[string]interface{})["containers"])
+	assert.Empty(t, DriftedFields(desired, live))
+
+	// missing named items are drift and appended by repairs
+	desired["spec"].(map[string]interface{})["containers"] = []interface{}{map[string]interface{}{"name": "init"}}
+	assert.Equal(t, []string{"spec.containers[0]"}, DriftedFields(desired, live))
+	overlayDesired(desired, live)
+	assert.Equal(t, 3, len(live["spec"].(map[string]interface{})["containers"].([]interface{})))
+}
+
+func TestDetectDrift(t *testing.T) {
+	var updated, created string
+	pool := dynamic.NewDynamicClientPool(&rest.Config{
+		Host: "http://drift",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			body, _ := ioutil.ReadAll(req.Body)
+			switch {
+			case req.Method == http.MethodPut:
+				updated = string(body)
+				recorder.Write(body)
+			case req.Method == http.MethodPost:
+				created = string(body)
+				recorder.WriteHeader(http.StatusCreated)
+				recorder.Write(body)
+			case req.URL.Path == "/api/v1/namespaces/ns/configmaps/a-config":
+				recorder.WriteString(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a-config","namespace":"ns","resourceVersion":"7"},"data":{"size":"2","extra":"x"}}`)
+			default:
+				recorder.WriteHeader(http.StatusNotFound)
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			}
+			return recorder.Result(), nil
+		}),
+	})
+
+	c := newBulkController(t, map[string]string{})
+	recorder := record.NewFakeRecorder(10)
+	c.context.DynamicClientPool = pool
+	c.context.Recorder = recorder
+	render := func(obj runtime.Object) ([]runtime.Object, error) {
+		parent := obj.(*v1.ConfigMap)
+		return []runtime.Object{&v1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Namespace: parent.Namespace, Name: parent.Name + "-config"},
+			Data:       map[string]string{"size": "3"},
+		}}, nil
+	}
+	c.options.Drift = &DriftOptions{Render: render, Kinds: map[string]DriftKind{"ConfigMap": {Resource: "configmaps"}}}
+
+	drifts, err := c.DetectDrift("ns/a")
+	assert.NoError(t, err)
+	assert.Equal(t, []ChildDrift{{Kind: "ConfigMap", Namespace: "ns", Name: "a-config", Fields: []string{"data.size"}}}, drifts)
+	assert.Equal(t, "Warning DriftDetected ConfigMap ns/a-config differs in data.size", <-recorder.Events)
+	assert.Equal(t, "", updated)
+
+	c.options.Drift.Kinds["ConfigMap"] = DriftKind{Resource: "configmaps", Action: RepairDrift}
+	drifts, err = c.DetectDrift("ns/a")
+	assert.NoError(t, err)
+	assert.True(t, drifts[0].Repaired)
+	assert.JSONEq(t, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a-config","namespace":"ns","resourceVersion":"7"},"data":{"size":"3","extra":"x"}}`, updated)
+	assert.Equal(t, "Normal DriftRepaired repaired ConfigMap ns/a-config differs in data.size", <-recorder.Events)
+
+	drifts, err = c.DetectDrift("ns/b")
+	assert.NoError(t, err)
+	assert.Equal(t, []ChildDrift{{Kind: "ConfigMap", Namespace: "ns", Name: "b-config", Missing: true, Repaired: true}}, drifts)
+	assert.Contains(t, created, `"name":"b-config"`)
+}
+
+func TestDetectAllDriftInWorkers(t *testing.T) {
+	c := newBulkController(t, map[string]string{})
+	c.context.DynamicClientPool = dynamic.NewDynamicClientPool(&rest.Config{Host: "http://drift"})
+	var rendered []string
+	c.options.Drift = &DriftOptions{Render: func(obj runtime.Object) ([]runtime.Object, error) {
+		rendered = append(rendered, obj.(*v1.ConfigMap).Name)
+		return nil, nil
+	}}
+	c.reconciler = ReconcilerFunc(func(key string) error { return nil })
+
+	// the keys are queued and compared by the workers before the reconcile, never by the drift loop itself
+	c.detectAllDrift()
+	assert.Equal(t, 4, c.queue.Len())
+	assert.Empty(t, rendered)
+
+	_, err := c.reconcile(ReconcileTrace{Key: "ns/a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, rendered)
+
+	// reconciles without a queued comparison do not compare the children
+	_, err = c.reconcile(ReconcileTrace{Key: "ns/a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, rendered)
+}
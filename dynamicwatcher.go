@@ -0,0 +1,55 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewDynamicWatcher creates a watcher of the resource of any group, version, and resource served by the cluster,
+// such as the custom resources of other operators, without their typed clients. The objects passed to the handlers
+// are *unstructured.Unstructured, and Watch must be called with an *unstructured.Unstructured object type. Use the
+// empty namespace for cluster scoped resources or to watch all namespaces. The context must have a
+// DynamicClientPool.
+func NewDynamicWatcher(context Context, gvr schema.GroupVersionResource, namespace string, handlers cache.ResourceEventHandlerFuncs) (*ResourceWatcher, error) {
+	if context.DynamicClientPool == nil {
+		return nil, fmt.Errorf("the context has no dynamic client pool")
+	}
+	client, err := context.DynamicClientPool.ClientForGroupVersionResource(gvr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dynamic client for %s. %+v", gvr.String(), err)
+	}
+	resourceClient := client.Resource(&metav1.APIResource{Name: gvr.Resource, Namespaced: namespace != ""}, namespace)
+
+	w := NewWatcher(CustomResource{Name: gvr.Resource, Plural: gvr.Resource, Group: gvr.Group, Version: gvr.Version}, namespace, handlers, nil)
+	w.source = &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return resourceClient.List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return resourceClient.Watch(options)
+		},
+	}
+	return w, nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNewDynamicWatcher(t *testing.T) {
+	var paths []string
+	pool := dynamic.NewDynamicClientPool(&rest.Config{
+		Host: "http://dynamic",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			paths = append(paths, req.URL.Path)
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			recorder.WriteString(`{"apiVersion":"cert-manager.io/v1","kind":"CertificateList","metadata":{"resourceVersion":"5"},
+				"items":[{"apiVersion":"cert-manager.io/v1","kind":"Certificate","metadata":{"name":"web","namespace":"ns"}}]}`)
+			return recorder.Result(), nil
+		}),
+	})
+	gvr := schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+
+	_, err := NewDynamicWatcher(Context{}, gvr, "ns", cache.ResourceEventHandlerFuncs{})
+	assert.Error(t, err)
+
+	w, err := NewDynamicWatcher(Context{DynamicClientPool: pool}, gvr, "ns", cache.ResourceEventHandlerFuncs{})
+	assert.NoError(t, err)
+	obj, err := w.source.ListFunc(metav1.ListOptions{})
+	assert.NoError(t, err)
+	list := obj.(*unstructured.UnstructuredList)
+	assert.Equal(t, 1, len(list.Items))
+	assert.Equal(t, "web", list.Items[0].GetName())
+	assert.Equal(t, []string{"/apis/cert-manager.io/v1/namespaces/ns/certificates"}, paths)
+}
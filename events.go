@@ -0,0 +1,98 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// EventReasonCRDEstablished is the reason of the event emitted when a custom resource is ready to be used
+	EventReasonCRDEstablished = "CRDEstablished"
+	// EventReasonCRDFailed is the reason of the event emitted when a custom resource could not be installed
+	EventReasonCRDFailed = "CRDFailed"
+	// EventReasonReconcileFailed is the reason of the event emitted when reconciling a custom resource failed
+	EventReasonReconcileFailed = "ReconcileFailed"
+	// EventReasonRetriesExhausted is the reason of the event emitted when a custom resource failed to reconcile more
+	// times than the controller retries
+	EventReasonRetriesExhausted = "RetriesExhausted"
+)
+
+// NewEventRecorder creates a recorder that emits events as the given component. The scheme must contain the
+// types of the custom resources events are emitted on, for example the scheme returned by NewHTTPClient.
+func NewEventRecorder(clientset kubernetes.Interface, scheme *runtime.Scheme, component string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme, v1.EventSource{Component: component})
+}
+
+// recordCRDEvent emits an event on the CRD of the resource if the context has a recorder
+func (c Context) recordCRDEvent(resource CustomResource, eventType, reason, message string) {
+	if c.Recorder == nil {
+		return
+	}
+	c.Recorder.Event(c.crdReference(resource), eventType, reason, message)
+}
+
+// crdReference refers to the CRD, or the TPR, of the resource through the API flavor of the context, with the UID of
+// the object so that the event is listed with it. The UID is left out when the object cannot be read, such as when
+// it failed to be created.
+func (c Context) crdReference(resource CustomResource) *v1.ObjectReference {
+	flavor, err := detectAPIFlavor(c)
+	if err != nil {
+		c.logger().Debug("referring to the v1beta1 CRD", "resource", resource.Name, "reason", err.Error())
+		flavor = ForceCRDv1beta1
+	}
+
+	if flavor == ForceTPR {
+		ref := &v1.ObjectReference{
+			Kind:       "ThirdPartyResource",
+			APIVersion: "extensions/v1beta1",
+			Name:       fmt.Sprintf("%s.%s", resource.Name, resource.Group),
+		}
+		if tpr, err := c.Clientset.ExtensionsV1beta1().ThirdPartyResources().Get(ref.Name, metav1.GetOptions{}); err == nil {
+			ref.UID = tpr.UID
+		}
+		return ref
+	}
+
+	ref := &v1.ObjectReference{
+		Kind:       "CustomResourceDefinition",
+		APIVersion: apiextensionsGroup + "/v1beta1",
+		Name:       fmt.Sprintf("%s.%s", resource.Plural, resource.Group),
+	}
+	if flavor == ForceCRDv1 {
+		ref.APIVersion = apiextensionsGroup + "/v1"
+		if crd, err := getCRD(c, resource); err == nil && crd != nil {
+			ref.UID = types.UID(crd.Metadata.UID)
+		}
+		return ref
+	}
+	if crd, err := c.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions().Get(ref.Name, metav1.GetOptions{}); err == nil {
+		ref.UID = crd.UID
+	}
+	return ref
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apiextensionsclientfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCRDEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	clientset, apiExtClientset := newInstallClients(t)
+	ctx := Context{
+		Clientset:             clientset,
+		APIExtensionClientset: apiExtClientset,
+		Interval:              time.Millisecond,
+		Timeout:               time.Second,
+		Recorder:              recorder,
+	}
+
+	err := CreateCustomResources(ctx, installResources("first", "conflicting"))
+	assert.Error(t, err)
+	events := []string{<-recorder.Events, <-recorder.Events}
+	sort.Strings(events)
+	assert.Equal(t, []string{"Normal CRDEstablished first is established", "Warning CRDFailed " + err.Error()}, events)
+	assert.Len(t, recorder.Events, 0)
+}
+
+func TestCRDReference(t *testing.T) {
+	crdName := "examples.example.com"
+	v1beta1Clientset := apiextensionsclientfake.NewSimpleClientset(&apiextensionsv1beta1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: crdName, UID: "v1beta1-uid"}})
+	context := Context{APIExtensionClientset: v1beta1Clientset, APIFlavor: ForceCRDv1beta1}
+	assert.Equal(t, &v1.ObjectReference{Kind: "CustomResourceDefinition", APIVersion: "apiextensions.k8s.io/v1beta1",
+		Name: crdName, UID: "v1beta1-uid"}, context.crdReference(exampleResource))
+
+	// the UID is left out of the reference to a CRD that could not be read
+	context.APIExtensionClientset = apiextensionsclientfake.NewSimpleClientset()
+	assert.Equal(t, &v1.ObjectReference{Kind: "CustomResourceDefinition", APIVersion: "apiextensions.k8s.io/v1beta1",
+		Name: crdName}, context.crdReference(exampleResource))
+
+	v1Clientset, err := apiextensionsclient.NewForConfig(&rest.Config{
+		Host: "http://v1",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			if req.URL.Path != crdV1Path+"/"+crdName {
+				recorder.WriteHeader(http.StatusNotFound)
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+				return recorder.Result(), nil
+			}
+			recorder.WriteString(`{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition",` +
+				`"metadata":{"name":"examples.example.com","uid":"v1-uid"}}`)
+			return recorder.Result(), nil
+		}),
+	})
+	assert.NoError(t, err)
+	context = Context{APIExtensionClientset: v1Clientset, APIFlavor: ForceCRDv1}
+	assert.Equal(t, &v1.ObjectReference{Kind: "CustomResourceDefinition", APIVersion: "apiextensions.k8s.io/v1",
+		Name: crdName, UID: "v1-uid"}, context.crdReference(exampleResource))
+
+	clientset := fake.NewSimpleClientset(&v1beta1.ThirdPartyResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "example.example.com", UID: "tpr-uid"}})
+	context = Context{Clientset: clientset, APIFlavor: ForceTPR}
+	assert.Equal(t, &v1.ObjectReference{Kind: "ThirdPartyResource", APIVersion: "extensions/v1beta1",
+		Name: "example.example.com", UID: "tpr-uid"}, context.crdReference(exampleResource))
+}
+
+func TestReconcileFailedEvent(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	client := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+		t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+	})
+	reconciler := ReconcilerFunc(func(key string) error {
+		return fmt.Errorf("failed to reconcile %s", key)
+	})
+	c := NewController(Context{Recorder: recorder}, exampleResource, v1.NamespaceAll, client, &v1.ConfigMap{}, reconciler)
+	assert.NoError(t, c.store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}}))
+
+	c.queue.Add("ns/a")
+	assert.True(t, c.processNextItem())
+	event := <-recorder.Events
+	assert.True(t, strings.HasPrefix(event, "Warning ReconcileFailed failed to reconcile ns/a"), event)
+
+	// no event is emitted for a resource that was deleted
+	c.queue.Add("ns/deleted")
+	assert.True(t, c.processNextItem())
+	assert.Len(t, recorder.Events, 0)
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultExpectationsTimeout is how long expectations hold back the reconciles of a resource when the events of its
+// children are never received, such as after a missed watch event
+const DefaultExpectationsTimeout = 5 * time.Minute
+
+// Expectations track the children a reconcile created or deleted until the informer of the children observed them.
+// A reconcile that counts the children in the cache right after creating them would otherwise see too few and
+// create them again. Keys are the namespace/name keys of the owners.
+type Expectations struct {
+	lock    sync.Mutex
+	keys    map[string]*expectation
+	timeout time.Duration
+	now     func() time.Time
+}
+
+// expectation counts the creations and deletions of children not observed yet
+type expectation struct {
+	add, del  int
+	timestamp time.Time
+}
+
+// NewExpectations creates expectations that expire after the timeout. Defaults to DefaultExpectationsTimeout.
+func NewExpectations(timeout time.Duration) *Expectations {
+	return &Expectations{
+		keys:    map[string]*expectation{},
+		timeout: durationOrDefault(timeout, DefaultExpectationsTimeout),
+		now:     time.Now,
+	}
+}
+
+// ExpectCreations records that the owner with the key is about to create the number of children. Call it before
+// creating the children, and CreationObserved for each creation that fails.
+func (e *Expectations) ExpectCreations(key string, count int) {
+	e.expect(key, count, 0)
+}
+
+// ExpectDeletions records that the owner with the key is about to delete the number of children. Call it before
+// deleting the children, and DeletionObserved for each deletion that fails.
+func (e *Expectations) ExpectDeletions(key string, count int) {
+	e.expect(key, 0, count)
+}
+
+func (e *Expectations) expect(key string, add, del int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	exp, ok := e.keys[key]
+	if !ok || exp.satisfied() {
+		exp = &expectation{}
+		e.keys[key] = exp
+	}
+	exp.add += add
+	exp.del += del
+	exp.timestamp = e.now()
+}
+
+// CreationObserved lowers the number of creations expected for the owner with the key
+func (e *Expectations) CreationObserved(key string) {
+	e.observe(key, 1, 0)
+}
+
+// DeletionObserved lowers the number of deletions expected for the owner with the key
+func (e *Expectations) DeletionObserved(key string) {
+	e.observe(key, 0, 1)
+}
+
+func (e *Expectations) observe(key string, add, del int) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if exp, ok := e.keys[key]; ok {
+		exp.add -= add
+		exp.del -= del
+	}
+}
+
+// Satisfied returns whether the owner with the key has no creations or deletions pending, or they expired
+func (e *Expectations) Satisfied(key string) bool {
+	return e.pending(key) == 0
+}
+
+// Delete forgets the expectations of the owner with the key, such as after the owner was deleted
+func (e *Expectations) Delete(key string) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.keys, key)
+}
+
+// pending returns how long the expectations of the owner with the key still hold, or zero if they are satisfied
+func (e *Expectations) pending(key string) time.Duration {
+	if e == nil {
+		return 0
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	exp, ok := e.keys[key]
+	if !ok || exp.satisfied() {
+		return 0
+	}
+	remaining := e.timeout - e.now().Sub(exp.timestamp)
+	if remaining <= 0 {
+		return 0
+	}
+	return remaining
+}
+
+func (exp *expectation) satisfied() bool {
+	return exp.add <= 0 && exp.del <= 0
+}
+
+// ChildHandlers returns the handlers of a watcher of the children of the resource. The key of the controlling owner
+// of a changed child is queued, and its creation or deletion is observed in the expectations of the controller.
+func (c *Controller) ChildHandlers() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if key, ok := c.ownerKey(obj); ok {
+				c.options.Expectations.CreationObserved(key)
+				c.queue.Add(key)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if key, ok := c.ownerKey(newObj); ok {
+				c.queue.Add(key)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if key, ok := c.ownerKey(obj); ok {
+				c.options.Expectations.DeletionObserved(key)
+				c.queue.Add(key)
+			}
+		},
+	}
+}
+
+// ownerKey returns the key of the owner controlling the child if the owner is of the kind of the resource
+func (c *Controller) ownerKey(obj interface{}) (string, bool) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", false
+	}
+	owner := metav1.GetControllerOf(accessor)
+	if owner == nil || owner.Kind != c.resource.Kind {
+		return "", false
+	}
+	if accessor.GetNamespace() == "" {
+		return owner.Name, true
+	}
+	return accessor.GetNamespace() + "/" + owner.Name, true
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestExpectations(t *testing.T) {
+	e := NewExpectations(time.Minute)
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	assert.True(t, e.Satisfied("default/a"))
+	e.ExpectCreations("default/a", 2)
+	e.ExpectDeletions("default/a", 1)
+	assert.False(t, e.Satisfied("default/a"))
+	assert.Equal(t, time.Minute, e.pending("default/a"))
+
+	e.CreationObserved("default/a")
+	e.CreationObserved("default/a")
+	assert.False(t, e.Satisfied("default/a"))
+	e.DeletionObserved("default/a")
+	assert.True(t, e.Satisfied("default/a"))
+
+	// new expectations start over once the previous ones are satisfied
+	e.ExpectCreations("default/a", 1)
+	assert.False(t, e.Satisfied("default/a"))
+
+	// expectations expire in case an event was missed
+	now = now.Add(40 * time.Second)
+	assert.Equal(t, 20*time.Second, e.pending("default/a"))
+	now = now.Add(20 * time.Second)
+	assert.True(t, e.Satisfied("default/a"))
+
+	e.ExpectCreations("default/b", 1)
+	e.Delete("default/b")
+	assert.True(t, e.Satisfied("default/b"))
+
+	// nil expectations are always satisfied
+	var nilExpectations *Expectations
+	assert.True(t, nilExpectations.Satisfied("default/a"))
+	nilExpectations.CreationObserved("default/a")
+}
+
+func TestChildHandlers(t *testing.T) {
+	resource := exampleResource
+	resource.Kind = "Example"
+	c := &Controller{
+		resource: resource,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		options:  ControllerOptions{Expectations: NewExpectations(0)},
+	}
+	defer c.queue.ShutDown()
+	c.options.Expectations.ExpectCreations("default/a", 1)
+	c.options.Expectations.ExpectDeletions("default/a", 1)
+
+	controller := true
+	child := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a-0", OwnerReferences: []metav1.OwnerReference{
+		{APIVersion: "example.com/v1alpha", Kind: "Example", Name: "a", Controller: &controller},
+	}}}
+	handlers := c.ChildHandlers()
+	handlers.AddFunc(child)
+	assert.False(t, c.options.Expectations.Satisfied("default/a"))
+	handlers.DeleteFunc(cache.DeletedFinalStateUnknown{Key: "default/a-0", Obj: child})
+	assert.True(t, c.options.Expectations.Satisfied("default/a"))
+	assert.Equal(t, 1, c.queue.Len())
+	key, _ := c.queue.Get()
+	assert.Equal(t, "default/a", key)
+
+	// children of other kinds and orphans are ignored
+	child.OwnerReferences[0].Kind = "Other"
+	handlers.AddFunc(child)
+	handlers.AddFunc(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"}})
+	assert.Equal(t, 0, c.queue.Len())
+}
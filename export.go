@@ -0,0 +1,129 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+)
+
+// ExportCRDs writes the CRDs of the resources to out as a YAML stream, so they can be applied by tools like Helm or
+// kustomize in clusters where the operator may not create CRDs. The flavor selects apiextensions.k8s.io/v1 or
+// v1beta1. AutoDetect exports v1, since there is no cluster to detect the API from.
+func ExportCRDs(resources []CustomResource, flavor APIFlavor, out io.Writer) error {
+	for _, resource := range resources {
+		data, err := exportCRD(resource, flavor)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "---\n%s", data); err != nil {
+			return fmt.Errorf("failed to write %s CRD. %+v", resource.Name, err)
+		}
+	}
+	return nil
+}
+
+// ExportCRDFiles writes the CRD of each resource to its own file in the directory, named like
+// "samples.example.com.yaml"
+func ExportCRDFiles(resources []CustomResource, flavor APIFlavor, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s. %+v", dir, err)
+	}
+	for _, resource := range resources {
+		data, err := exportCRD(resource, flavor)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s.%s.yaml", resource.Plural, resource.Group))
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s CRD. %+v", resource.Name, err)
+		}
+	}
+	return nil
+}
+
+// RunExportCommand runs a command exporting the CRDs of the resources, to be called from the main of the operator
+// with the command line args after the command name. The flags are:
+//
+//	-api-version: v1 (default) or v1beta1
+//	-output-dir: directory of the CRD files. The CRDs are written to out if empty.
+func RunExportCommand(args []string, resources []CustomResource, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(out)
+	apiVersion := flags.String("api-version", "v1", "version of apiextensions.k8s.io of the CRDs: v1 or v1beta1")
+	outputDir := flags.String("output-dir", "", "directory of the CRD files. The CRDs are written to stdout if empty.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var flavor APIFlavor
+	switch *apiVersion {
+	case "v1":
+		flavor = ForceCRDv1
+	case "v1beta1":
+		flavor = ForceCRDv1beta1
+	default:
+		return fmt.Errorf("unsupported api version %q", *apiVersion)
+	}
+
+	if *outputDir != "" {
+		return ExportCRDFiles(resources, flavor, *outputDir)
+	}
+	return ExportCRDs(resources, flavor, out)
+}
+
+// exportCRD returns the YAML of the CRD without the status, which is only set by the apiserver
+func exportCRD(resource CustomResource, flavor APIFlavor) ([]byte, error) {
+	var crd interface{}
+	apiVersion := crdAPIVersionV1
+	switch flavor {
+	case AutoDetect, ForceCRDv1:
+		crd = newCRDv1(resource)
+	case ForceCRDv1beta1:
+		crd = newCRDv1beta1(resource)
+		apiVersion = crdAPIVersionV1beta1
+	default:
+		return nil, fmt.Errorf("TPRs cannot be exported")
+	}
+
+	data, err := manifestOf(resource, apiVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		if data, err = json.Marshal(crd); err != nil {
+			return nil, fmt.Errorf("failed to serialize %s CRD. %+v", resource.Name, err)
+		}
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "status")
+	if metadata, ok := fields["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	return yaml.Marshal(fields)
+}
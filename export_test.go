@@ -0,0 +1,60 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportCRDs(t *testing.T) {
+	resource := exampleResource
+	resource.Kind = "Example"
+
+	var out bytes.Buffer
+	assert.NoError(t, ExportCRDs([]CustomResource{resource}, AutoDetect, &out))
+	assert.True(t, strings.HasPrefix(out.String(), "---\n"))
+	crd := map[string]interface{}{}
+	assert.NoError(t, yaml.Unmarshal(out.Bytes()[4:], &crd))
+	assert.Equal(t, "apiextensions.k8s.io/v1", crd["apiVersion"])
+	assert.Equal(t, map[string]interface{}{"name": "examples.example.com"}, crd["metadata"])
+	assert.NotContains(t, crd, "status")
+	versions := crd["spec"].(map[string]interface{})["versions"].([]interface{})
+	assert.Equal(t, "v1alpha", versions[0].(map[string]interface{})["name"])
+
+	dir, err := ioutil.TempDir("", "export")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	out.Reset()
+	assert.NoError(t, RunExportCommand([]string{"-api-version", "v1beta1", "-output-dir", dir}, []CustomResource{resource}, &out))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "examples.example.com.yaml"))
+	assert.NoError(t, err)
+	crd = map[string]interface{}{}
+	assert.NoError(t, yaml.Unmarshal(data, &crd))
+	assert.Equal(t, "apiextensions.k8s.io/v1beta1", crd["apiVersion"])
+	assert.Equal(t, "v1alpha", crd["spec"].(map[string]interface{})["version"])
+	assert.NotContains(t, crd, "status")
+
+	assert.Error(t, RunExportCommand([]string{"-api-version", "v2"}, []CustomResource{resource}, &out))
+	assert.Error(t, ExportCRDs([]CustomResource{resource}, ForceTPR, &out))
+}
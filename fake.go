@@ -0,0 +1,77 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"time"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclientfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	fakeInterval      = 10 * time.Millisecond
+	fakeTimeout       = time.Second
+	fakeEventCapacity = 100
+)
+
+// NewFakeContext creates a context for unit tests backed by the fake clientsets of client-go and apiextensions.
+// The objects are added to the fake Kubernetes clientset. CRDs are registered with the v1beta1 API and become
+// established as soon as they are created, so CreateCustomResources succeeds without a cluster. The events are
+// recorded by a record.FakeRecorder.
+func NewFakeContext(objects ...runtime.Object) Context {
+	apiExtClientset := apiextensionsclientfake.NewSimpleClientset()
+	apiExtClientset.PrependReactor("create", "customresourcedefinitions", establishFakeCRD)
+
+	return Context{
+		Clientset:             fake.NewSimpleClientset(objects...),
+		APIExtensionClientset: apiExtClientset,
+		Interval:              fakeInterval,
+		Timeout:               fakeTimeout,
+		APIFlavor:             ForceCRDv1beta1,
+		Recorder:              record.NewFakeRecorder(fakeEventCapacity),
+	}
+}
+
+// establishFakeCRD sets the conditions of a created CRD the way the API server does once its names are accepted.
+// It does not handle the action so that the CRD is still stored by the fake clientset.
+func establishFakeCRD(action clienttesting.Action) (bool, runtime.Object, error) {
+	create, ok := action.(clienttesting.CreateAction)
+	if !ok {
+		return false, nil, nil
+	}
+	crd, ok := create.GetObject().(*apiextensionsv1beta1.CustomResourceDefinition)
+	if !ok {
+		return false, nil, nil
+	}
+	crd.Status.Conditions = append(crd.Status.Conditions,
+		apiextensionsv1beta1.CustomResourceDefinitionCondition{
+			Type:   apiextensionsv1beta1.NamesAccepted,
+			Status: apiextensionsv1beta1.ConditionTrue,
+		},
+		apiextensionsv1beta1.CustomResourceDefinitionCondition{
+			Type:   apiextensionsv1beta1.Established,
+			Status: apiextensionsv1beta1.ConditionTrue,
+		},
+	)
+	return false, nil, nil
+}
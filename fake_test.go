@@ -0,0 +1,54 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFakeContext(t *testing.T) {
+	ctx := NewFakeContext(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "operators"}})
+
+	report, err := CreateCustomResourcesWithReport(ctx, []CustomResource{exampleResource})
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeCreated, report.Resources[0].Outcome)
+
+	_, err = ctx.Clientset.CoreV1().Namespaces().Get("operators", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestCreateCustomResourcesConcurrently(t *testing.T) {
+	ctx := NewFakeContext()
+	ctx.CRDConcurrency = 2
+
+	var resources []CustomResource
+	for _, name := range []string{"alpha", "beta", "gamma", "delta", "epsilon"} {
+		resource := exampleResource
+		resource.Name = name
+		resource.Plural = name + "s"
+		resources = append(resources, resource)
+	}
+	report, err := CreateCustomResourcesWithReport(ctx, resources)
+	assert.NoError(t, err)
+	for i, result := range report.Resources {
+		assert.Equal(t, resources[i].Name, result.Resource.Name)
+		assert.Equal(t, OutcomeCreated, result.Outcome)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// APIRequest describes a request of the clients of a context to the Kubernetes API
+type APIRequest struct {
+	// Verb of the request: get, list, watch, create, update, patch, delete, or deletecollection
+	Verb string
+
+	// Resource is the plural of the resource, such as "configmaps" or "customresourcedefinitions"
+	Resource    string
+	Namespace   string
+	Name        string
+	Subresource string
+}
+
+// Interceptor is called before each request of the clients of a context returned by WithInterceptor. Returning an
+// error fails the request with the error instead of sending it. Errors created with the k8s.io/apimachinery errors
+// package, such as errors.NewConflict, are returned to the client as they are. The interceptor may also sleep to
+// slow down the request.
+type Interceptor interface {
+	Intercept(request APIRequest) error
+}
+
+// InterceptorFunc adapts a function to the Interceptor interface
+type InterceptorFunc func(request APIRequest) error
+
+// Intercept calls the function
+func (f InterceptorFunc) Intercept(request APIRequest) error {
+	return f(request)
+}
+
+// WithInterceptor returns a copy of the context whose clients pass each request to the interceptor first. The
+// clients are created again from the RESTConfig of the context, so that clients created later from the RESTConfig,
+// such as with NewCustomResourceClient, are intercepted as well. The context itself is not intercepted. Contexts
+// without a RESTConfig, such as the context of NewFakeContext, are intercepted with InterceptFakeClientsets.
+func (c Context) WithInterceptor(interceptor Interceptor) (Context, error) {
+	if c.RESTConfig == nil {
+		return c, fmt.Errorf("the context has no RESTConfig to intercept")
+	}
+	config := *c.RESTConfig
+	wrap := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return &interceptingTransport{next: rt, interceptor: interceptor}
+	}
+
+	return c.withClients(&config)
+}
+
+// InterceptFakeClientsets adds a reactor passing each request to the interceptor to the fake clientsets of the
+// context, such as the context of NewFakeContext. Unlike WithInterceptor, the fake clientsets are changed in place,
+// so every context sharing them is intercepted, and the interceptor cannot be removed again.
+func (c Context) InterceptFakeClientsets(interceptor Interceptor) error {
+	intercepted := false
+	for _, clientset := range []interface{}{c.Clientset, c.APIExtensionClientset} {
+		if fake, ok := clientset.(fakeClientset); ok {
+			fake.PrependReactor("*", "*", interceptReactor(interceptor))
+			fake.PrependWatchReactor("*", interceptWatchReactor(interceptor))
+			intercepted = true
+		}
+	}
+	if !intercepted {
+		return fmt.Errorf("the context has no fake clientsets to intercept")
+	}
+	return nil
+}
+
+// Fault is an error or delay injected into the requests matching the verb, resource, and name
+type Fault struct {
+	// Verb, Resource, and Name of the requests to inject the fault into. Empty fields match all requests.
+	Verb     string
+	Resource string
+	Name     string
+
+	// Err fails the request. Requests are only delayed if it is nil.
+	Err error
+
+	// Delay is waited before the request is failed or sent
+	Delay time.Duration
+
+	// Times limits how many requests get the fault. Zero injects it into all matching requests.
+	Times int
+
+	// Probability of injecting the fault into a matching request, between 0 and 1. Zero always injects it.
+	Probability float64
+}
+
+// FaultInjector is an interceptor injecting faults into the requests, for tests of retry behavior and chaos
+// experiments. When several faults match a request, the first fault added wins.
+type FaultInjector struct {
+	lock     sync.Mutex
+	faults   []*injectedFault
+	requests []APIRequest
+}
+
+type injectedFault struct {
+	Fault
+	injected int
+}
+
+// NewFaultInjector creates an injector of the faults
+func NewFaultInjector(faults ...Fault) *FaultInjector {
+	f := &FaultInjector{}
+	for _, fault := range faults {
+		f.Add(fault)
+	}
+	return f
+}
+
+// Add adds a fault to inject into the following requests
+func (f *FaultInjector) Add(fault Fault) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.faults = append(f.faults, &injectedFault{Fault: fault})
+}
+
+// Injected returns the requests that got a fault
+func (f *FaultInjector) Injected() []APIRequest {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]APIRequest(nil), f.requests...)
+}
+
+// Intercept injects the first matching fault into the request
+func (f *FaultInjector) Intercept(request APIRequest) error {
+	f.lock.Lock()
+	var fault *injectedFault
+	for _, candidate := range f.faults {
+		if candidate.matches(request) {
+			fault = candidate
+			break
+		}
+	}
+	if fault == nil {
+		f.lock.Unlock()
+		return nil
+	}
+	fault.injected++
+	f.requests = append(f.requests, request)
+	delay, err := fault.Delay, fault.Err
+	f.lock.Unlock()
+
+	time.Sleep(delay)
+	return err
+}
+
+func (f *injectedFault) matches(request APIRequest) bool {
+	if (f.Verb != "" && f.Verb != request.Verb) ||
+		(f.Resource != "" && f.Resource != request.Resource) ||
+		(f.Name != "" && f.Name != request.Name) {
+		return false
+	}
+	if f.Times > 0 && f.injected >= f.Times {
+		return false
+	}
+	return f.Probability <= 0 || rand.Float64() < f.Probability
+}
+
+// fakeClientset is implemented by the fake clientsets of client-go and apiextensions
+type fakeClientset interface {
+	PrependReactor(verb, resource string, reaction clienttesting.ReactionFunc)
+	PrependWatchReactor(resource string, reaction clienttesting.WatchReactionFunc)
+}
+
+func interceptReactor(interceptor Interceptor) clienttesting.ReactionFunc {
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if err := interceptor.Intercept(apiRequestOfAction(action)); err != nil {
+			return true, nil, err
+		}
+		return false, nil, nil
+	}
+}
+
+func interceptWatchReactor(interceptor Interceptor) clienttesting.WatchReactionFunc {
+	return func(action clienttesting.Action) (bool, watch.Interface, error) {
+		if err := interceptor.Intercept(apiRequestOfAction(action)); err != nil {
+			return true, nil, err
+		}
+		return false, nil, nil
+	}
+}
+
+func apiRequestOfAction(action clienttesting.Action) APIRequest {
+	request := APIRequest{
+		Verb:        action.GetVerb(),
+		Resource:    action.GetResource().Resource,
+		Namespace:   action.GetNamespace(),
+		Subresource: action.GetSubresource(),
+	}
+	switch action := action.(type) {
+	case clienttesting.GetAction:
+		request.Name = action.GetName()
+	case clienttesting.DeleteAction:
+		request.Name = action.GetName()
+	case clienttesting.PatchAction:
+		request.Name = action.GetName()
+	case clienttesting.CreateAction:
+		if accessor, err := meta.Accessor(action.GetObject()); err == nil {
+			request.Name = accessor.GetName()
+		}
+	case clienttesting.UpdateAction:
+		if accessor, err := meta.Accessor(action.GetObject()); err == nil {
+			request.Name = accessor.GetName()
+		}
+	}
+	return request
+}
+
+// interceptingTransport passes the requests to the interceptor before sending them to the next transport. Errors
+// of the interceptor are returned as a Status response, so the clients handle them like errors of the API server.
+type interceptingTransport struct {
+	next        http.RoundTripper
+	interceptor Interceptor
+}
+
+func (t *interceptingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.interceptor.Intercept(apiRequestOfHTTP(req)); err != nil {
+		return statusResponse(req, err)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// apiRequestOfHTTP parses paths like /api/v1/namespaces/default/pods/name/status and
+// /apis/example.com/v1/examples?watch=true
+func apiRequestOfHTTP(req *http.Request) APIRequest {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(segments) > 2 && segments[0] == "api":
+		segments = segments[2:]
+	case len(segments) > 3 && segments[0] == "apis":
+		segments = segments[3:]
+	default:
+		segments = nil
+	}
+
+	request := APIRequest{}
+	if len(segments) > 2 && segments[0] == "namespaces" {
+		request.Namespace = segments[1]
+		segments = segments[2:]
+	}
+	if len(segments) > 0 {
+		request.Resource = segments[0]
+	}
+	if len(segments) > 1 {
+		request.Name = segments[1]
+	}
+	if len(segments) > 2 {
+		request.Subresource = segments[2]
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		if watch := req.URL.Query().Get("watch"); watch == "true" || watch == "1" {
+			request.Verb = "watch"
+		} else if request.Name == "" {
+			request.Verb = "list"
+		} else {
+			request.Verb = "get"
+		}
+	case http.MethodPost:
+		request.Verb = "create"
+	case http.MethodPut:
+		request.Verb = "update"
+	case http.MethodPatch:
+		request.Verb = "patch"
+	case http.MethodDelete:
+		if request.Name == "" {
+			request.Verb = "deletecollection"
+		} else {
+			request.Verb = "delete"
+		}
+	default:
+		request.Verb = strings.ToLower(req.Method)
+	}
+	return request
+}
+
+// statusResponse encodes the error as the Status the API server would respond with
+func statusResponse(req *http.Request, err error) (*http.Response, error) {
+	var status metav1.Status
+	if apiStatus, ok := err.(errors.APIStatus); ok {
+		status = apiStatus.Status()
+	} else {
+		status = errors.NewInternalError(err).ErrStatus
+	}
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+	if status.Code == 0 {
+		status.Code = http.StatusInternalServerError
+	}
+
+	body, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: int(status.Code),
+		Status:     fmt.Sprintf("%d %s", status.Code, http.StatusText(int(status.Code))),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFaultInjectorWithFakeContext(t *testing.T) {
+	configMaps := schema.GroupResource{Resource: "configmaps"}
+	injector := NewFaultInjector(
+		Fault{Verb: "create", Resource: "configmaps", Err: errors.NewConflict(configMaps, "a", nil), Times: 1},
+		Fault{Verb: "get", Resource: "configmaps", Delay: 20 * time.Millisecond})
+	context := NewFakeContext()
+	_, err := context.WithInterceptor(injector)
+	assert.Error(t, err)
+	assert.NoError(t, context.InterceptFakeClientsets(injector))
+	assert.Error(t, Context{}.InterceptFakeClientsets(injector))
+	client := context.Clientset.CoreV1().ConfigMaps("default")
+
+	configMap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "a"}}
+	_, err = client.Create(configMap)
+	assert.True(t, errors.IsConflict(err))
+
+	// the conflict is only injected once
+	_, err = client.Create(configMap)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.Get("a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	assert.Equal(t, []APIRequest{
+		{Verb: "create", Resource: "configmaps", Namespace: "default", Name: "a"},
+		{Verb: "get", Resource: "configmaps", Namespace: "default", Name: "a"},
+	}, injector.Injected())
+}
+
+func TestInterceptingTransport(t *testing.T) {
+	var requests []APIRequest
+	interceptor := InterceptorFunc(func(request APIRequest) error {
+		requests = append(requests, request)
+		if request.Verb == "update" {
+			return errors.NewServiceUnavailable("injected")
+		}
+		return nil
+	})
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		recorder.WriteHeader(http.StatusOK)
+		return recorder.Result(), nil
+	})
+	transport := &interceptingTransport{next: next, interceptor: interceptor}
+
+	send := func(method, url string) *http.Response {
+		resp, err := transport.RoundTrip(httptest.NewRequest(method, url, nil))
+		assert.NoError(t, err)
+		return resp
+	}
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "http://server/api/v1/namespaces/default/pods?watch=true").StatusCode)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "http://server/api/v1/namespaces/default").StatusCode)
+	assert.Equal(t, http.StatusOK, send(http.MethodDelete, "http://server/apis/example.com/v1/examples").StatusCode)
+	resp := send(http.MethodPut, "http://server/apis/example.com/v1/namespaces/ns/examples/a/status")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"reason":"ServiceUnavailable"`)
+
+	assert.Equal(t, []APIRequest{
+		{Verb: "watch", Resource: "pods", Namespace: "default"},
+		{Verb: "get", Resource: "namespaces", Name: "default"},
+		{Verb: "deletecollection", Resource: "examples"},
+		{Verb: "update", Resource: "examples", Namespace: "ns", Name: "a", Subresource: "status"},
+	}, requests)
+}
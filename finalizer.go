@@ -0,0 +1,144 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+)
+
+// FinalizeFunc cleans up after a custom resource that is being deleted. When namespaceTerminating is true the whole
+// namespace is being deleted. Kubernetes then deletes the children in the namespace, so the func should not create
+// or wait for children and only clean up state outside of the namespace.
+type FinalizeFunc func(obj runtime.Object, namespaceTerminating bool) error
+
+// HasFinalizer returns whether the object has the finalizer
+func HasFinalizer(obj metav1.Object, finalizer string) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFinalizer adds the finalizer to the object. It returns false if the object already had it.
+func AddFinalizer(obj metav1.Object, finalizer string) bool {
+	if HasFinalizer(obj, finalizer) {
+		return false
+	}
+	obj.SetFinalizers(append(obj.GetFinalizers(), finalizer))
+	return true
+}
+
+// RemoveFinalizer removes the finalizer from the object. It returns false if the object did not have it.
+func RemoveFinalizer(obj metav1.Object, finalizer string) bool {
+	var finalizers []string
+	for _, f := range obj.GetFinalizers() {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if len(finalizers) == len(obj.GetFinalizers()) {
+		return false
+	}
+	obj.SetFinalizers(finalizers)
+	return true
+}
+
+// NamespaceTerminating returns whether the namespace is being deleted. A namespace that is already gone is
+// reported as terminating. The empty namespace of cluster scoped resources never terminates.
+func NamespaceTerminating(context Context, namespace string) (bool, error) {
+	if namespace == "" {
+		return false, nil
+	}
+	ns, err := context.Clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to get namespace %s. %+v", namespace, err)
+	}
+	return ns.DeletionTimestamp != nil || ns.Status.Phase == v1.NamespaceTerminating, nil
+}
+
+// EnsureFinalizer adds the finalizer to the custom resource and updates it, unless it already has the finalizer or
+// its namespace is terminating. A finalizer added to a resource in a terminating namespace only delays the deletion.
+func EnsureFinalizer(context Context, client rest.Interface, resource CustomResource, obj runtime.Object, finalizer string) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	if HasFinalizer(accessor, finalizer) || accessor.GetDeletionTimestamp() != nil {
+		return nil
+	}
+	terminating, err := NamespaceTerminating(context, accessor.GetNamespace())
+	if err != nil || terminating {
+		return err
+	}
+
+	AddFinalizer(accessor, finalizer)
+	return updateObject(client, resource, accessor, obj)
+}
+
+// HandleDeletion finalizes a custom resource that is being deleted and removes its finalizer. It returns false when
+// the resource is not being deleted, in which case the reconcile continues as usual. When the namespace of the
+// resource is terminating, the finalizer is removed even if the finalize func fails so that the namespace
+// deletion is not blocked.
+func HandleDeletion(context Context, client rest.Interface, resource CustomResource, obj runtime.Object, finalizer string, finalize FinalizeFunc) (bool, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+	if accessor.GetDeletionTimestamp() == nil {
+		return false, nil
+	}
+	if !HasFinalizer(accessor, finalizer) {
+		return true, nil
+	}
+
+	terminating, err := NamespaceTerminating(context, accessor.GetNamespace())
+	if err != nil {
+		return true, err
+	}
+
+	if err := finalize(obj, terminating); err != nil {
+		if !terminating {
+			return true, fmt.Errorf("failed to finalize %s %s. %+v", resource.Name, accessor.GetName(), err)
+		}
+		context.logger().Error(err, "ignoring failure to finalize in terminating namespace",
+			"resource", resource.Name, "namespace", accessor.GetNamespace(), "name", accessor.GetName())
+	}
+
+	RemoveFinalizer(accessor, finalizer)
+	return true, updateObject(client, resource, accessor, obj)
+}
+
+func updateObject(client rest.Interface, resource CustomResource, accessor metav1.Object, obj runtime.Object) error {
+	err := client.Put().Namespace(accessor.GetNamespace()).Resource(resource.Plural).Name(accessor.GetName()).Body(obj).Do().Into(obj)
+	if err != nil {
+		return fmt.Errorf("failed to update %s %s. %+v", resource.Name, accessor.GetName(), err)
+	}
+	return nil
+}
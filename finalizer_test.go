@@ -0,0 +1,64 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFinalizers(t *testing.T) {
+	obj := &v1.ConfigMap{}
+	assert.False(t, HasFinalizer(obj, "example.com/cleanup"))
+
+	assert.True(t, AddFinalizer(obj, "example.com/cleanup"))
+	assert.False(t, AddFinalizer(obj, "example.com/cleanup"))
+	assert.True(t, AddFinalizer(obj, "other"))
+	assert.Equal(t, []string{"example.com/cleanup", "other"}, obj.Finalizers)
+
+	assert.True(t, RemoveFinalizer(obj, "example.com/cleanup"))
+	assert.False(t, RemoveFinalizer(obj, "example.com/cleanup"))
+	assert.Equal(t, []string{"other"}, obj.Finalizers)
+}
+
+func TestNamespaceTerminating(t *testing.T) {
+	ctx := Context{
+		Clientset: fake.NewSimpleClientset(
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "active"}, Status: v1.NamespaceStatus{Phase: v1.NamespaceActive}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "deleted"}, Status: v1.NamespaceStatus{Phase: v1.NamespaceTerminating}},
+		),
+	}
+
+	terminating, err := NamespaceTerminating(ctx, "active")
+	assert.NoError(t, err)
+	assert.False(t, terminating)
+
+	terminating, err = NamespaceTerminating(ctx, "deleted")
+	assert.NoError(t, err)
+	assert.True(t, terminating)
+
+	terminating, err = NamespaceTerminating(ctx, "missing")
+	assert.NoError(t, err)
+	assert.True(t, terminating)
+
+	terminating, err = NamespaceTerminating(ctx, "")
+	assert.NoError(t, err)
+	assert.False(t, terminating)
+}
@@ -0,0 +1,203 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The typed watcher and client require Go 1.18. T is the pointer type of the custom resource, such as *v1alpha1.Sample.
+
+// EventHandler receives the typed objects of a watched custom resource. Any of the funcs may be nil.
+type EventHandler[T runtime.Object] struct {
+	OnAdd    func(obj T)
+	OnUpdate func(oldObj, newObj T)
+	OnDelete func(obj T)
+}
+
+// Watcher watches a custom resource and passes the objects to the handler as T
+type Watcher[T runtime.Object] struct {
+	watcher *ResourceWatcher
+}
+
+// NewTypedWatcher creates a watcher of the custom resource in the namespace. The client must be configured for the
+// group and version of the resource. T must be a pointer type.
+func NewTypedWatcher[T runtime.Object](resource CustomResource, namespace string, handler EventHandler[T], client rest.Interface) (*Watcher[T], error) {
+	if _, err := newTypedObject[T](); err != nil {
+		return nil, err
+	}
+	handlers := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if o, ok := typedObject[T](resource, obj); ok && handler.OnAdd != nil {
+				handler.OnAdd(o)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			o, ok := typedObject[T](resource, oldObj)
+			n, newOK := typedObject[T](resource, newObj)
+			if ok && newOK && handler.OnUpdate != nil {
+				handler.OnUpdate(o, n)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if o, ok := typedObject[T](resource, obj); ok && handler.OnDelete != nil {
+				handler.OnDelete(o)
+			}
+		},
+	}
+	return &Watcher[T]{watcher: NewWatcher(resource, namespace, handlers, client)}, nil
+}
+
+// Watch begins watching the custom resource. The call blocks until the done channel is closed.
+func (w *Watcher[T]) Watch(done <-chan struct{}) error {
+	obj, _ := newTypedObject[T]()
+	return w.watcher.Watch(obj, done)
+}
+
+// Client reads and writes the objects of a custom resource as T
+type Client[T runtime.Object] struct {
+	client *CustomResourceClient
+}
+
+// NewTypedClient creates a client for the custom resource from the RESTConfig of the context. The scheme must have
+// T registered for the kind of the resource. T must be a pointer type.
+func NewTypedClient[T runtime.Object](context Context, resource CustomResource, scheme *runtime.Scheme) (*Client[T], error) {
+	typed, err := newTypedObject[T]()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := scheme.New(resource.GroupVersionKind())
+	if err != nil {
+		return nil, fmt.Errorf("the scheme has no type registered for %s. %+v", resource.GroupVersionKind().String(), err)
+	}
+	if _, ok := obj.(T); !ok {
+		return nil, fmt.Errorf("the scheme has %T registered for %s, not %T", obj, resource.GroupVersionKind().String(), typed)
+	}
+
+	client, err := NewCustomResourceClient(context, resource, scheme)
+	if err != nil {
+		return nil, err
+	}
+	return &Client[T]{client: client}, nil
+}
+
+// RESTClient returns the underlying client, for example to create a watcher for the resource
+func (c *Client[T]) RESTClient() rest.Interface {
+	return c.client.RESTClient()
+}
+
+// Get returns the object with the name. The namespace is ignored for cluster scoped resources.
+func (c *Client[T]) Get(namespace, name string) (T, error) {
+	return c.typed(c.client.Get(namespace, name))
+}
+
+// List returns the objects matching the options. An empty namespace lists the objects in all namespaces. The objects
+// are listed in pages of DefaultPageSize, unless the options set a Limit, in which case only that page is returned.
+func (c *Client[T]) List(namespace string, opts metav1.ListOptions) ([]T, error) {
+	var list runtime.Object
+	var err error
+	if opts.Limit > 0 {
+		list, err = c.client.List(namespace, opts)
+	} else {
+		list, err = c.client.ListAll(namespace, opts, DefaultPageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the %s items. %+v", c.client.resource.Name, err)
+	}
+
+	result := make([]T, 0, len(items))
+	for _, item := range items {
+		obj, err := c.typed(item, nil)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, obj)
+	}
+	return result, nil
+}
+
+// Watch returns a watch of the objects matching the options. The objects of the events are of type T.
+func (c *Client[T]) Watch(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.client.Watch(namespace, opts)
+}
+
+// Create creates the object in its namespace and returns the server's representation of it
+func (c *Client[T]) Create(obj T) (T, error) {
+	return c.typed(c.client.Create(obj))
+}
+
+// Update replaces the object and returns the server's representation of it
+func (c *Client[T]) Update(obj T) (T, error) {
+	return c.typed(c.client.Update(obj))
+}
+
+// Delete deletes the object with the name. The options are optional.
+func (c *Client[T]) Delete(namespace, name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete(namespace, name, options)
+}
+
+func (c *Client[T]) typed(obj runtime.Object, err error) (T, error) {
+	var zero T
+	if err != nil {
+		return zero, err
+	}
+	result, ok := obj.(T)
+	if !ok {
+		return zero, fmt.Errorf("expected %T but got %T for %s", zero, obj, c.client.resource.Name)
+	}
+	return result, nil
+}
+
+// typedObject converts an object received from the informer to T
+func typedObject[T runtime.Object](resource CustomResource, obj interface{}) (T, bool) {
+	result, ok := obj.(T)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected %T received for %s", obj, resource.Name))
+	}
+	return result, ok
+}
+
+// newTypedObject returns a new instance of the type that T points to. T must be a pointer type, since the informer
+// and the client decode into a new instance of it.
+func newTypedObject[T runtime.Object]() (T, error) {
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return zero, fmt.Errorf("the type of the custom resource must be a pointer, not %s", reflect.TypeOf(&zero).Elem())
+	}
+	return reflect.New(t.Elem()).Interface().(T), nil
+}
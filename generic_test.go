@@ -0,0 +1,52 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// valueObject implements runtime.Object with value receivers
+type valueObject struct{}
+
+func (valueObject) GetObjectKind() schema.ObjectKind { return schema.EmptyObjectKind }
+func (o valueObject) DeepCopyObject() runtime.Object { return o }
+
+func TestNewTypedObject(t *testing.T) {
+	obj, err := newTypedObject[*v1.ConfigMap]()
+	assert.NoError(t, err)
+	assert.Equal(t, &v1.ConfigMap{}, obj)
+
+	_, err = newTypedObject[valueObject]()
+	assert.Error(t, err)
+	_, err = newTypedObject[runtime.Object]()
+	assert.Error(t, err)
+}
+
+func TestNewTypedClientOfValueType(t *testing.T) {
+	_, err := NewTypedClient[valueObject](Context{}, exampleResource, runtime.NewScheme())
+	assert.Error(t, err)
+	_, err = NewTypedWatcher[valueObject](exampleResource, "", EventHandler[valueObject]{}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// HealthCheck returns nil when the checked component is healthy
+type HealthCheck func() error
+
+// Health serves the /healthz and /readyz endpoints used by the liveness and readiness probes of the operator
+type Health struct {
+	lock      sync.RWMutex
+	liveness  map[string]HealthCheck
+	readiness map[string]HealthCheck
+}
+
+// NewHealth creates the health endpoints without any checks. Without checks both endpoints report ok.
+func NewHealth() *Health {
+	return &Health{
+		liveness:  map[string]HealthCheck{},
+		readiness: map[string]HealthCheck{},
+	}
+}
+
+// AddLivenessCheck adds a named check to /healthz. A failing liveness check restarts the operator.
+func (h *Health) AddLivenessCheck(name string, check HealthCheck) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.liveness[name] = check
+}
+
+// AddReadinessCheck adds a named check to /readyz
+func (h *Health) AddReadinessCheck(name string, check HealthCheck) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.readiness[name] = check
+}
+
+// Handler returns a handler serving /healthz and /readyz, to be mounted on the operator's own server
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h.serveChecks(w, h.liveness)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		h.serveChecks(w, h.readiness)
+	})
+	return mux
+}
+
+// ListenAndServe serves the health endpoints on the given address until the stop channel is closed
+func (h *Health) ListenAndServe(addr string, stopCh <-chan struct{}) error {
+	server := &http.Server{Addr: addr, Handler: h.Handler()}
+	go func() {
+		<-stopCh
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("failed to serve the health endpoints on %s. %+v", addr, err)
+	}
+	return nil
+}
+
+// serveChecks runs all checks and reports each result on a separate line. The status is 500 if any check fails.
+func (h *Health) serveChecks(w http.ResponseWriter, checks map[string]HealthCheck) {
+	h.lock.RLock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := http.StatusOK
+	var body string
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			status = http.StatusInternalServerError
+			body += fmt.Sprintf("[-] %s failed: %v\n", name, err)
+			continue
+		}
+		body += fmt.Sprintf("[+] %s ok\n", name)
+	}
+	h.lock.RUnlock()
+
+	if status == http.StatusOK {
+		body += "ok\n"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, body)
+}
+
+// CacheSyncedCheck fails until the informer cache has synced, for example with a Controller's HasSynced
+func CacheSyncedCheck(hasSynced cache.InformerSynced) HealthCheck {
+	return func() error {
+		if !hasSynced() {
+			return fmt.Errorf("cache not synced")
+		}
+		return nil
+	}
+}
+
+// CRDEstablishedCheck fails until the CRDs of all the custom resources are established. The CRDs are read through
+// whichever apiextensions version the cluster serves.
+func CRDEstablishedCheck(context Context, resources []CustomResource) HealthCheck {
+	return func() error {
+		for _, resource := range resources {
+			crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
+			crd, err := getCRD(context, resource)
+			if err != nil {
+				return err
+			}
+			if crd == nil {
+				return fmt.Errorf("CRD %s not found", crdName)
+			}
+			established, err := crdEstablished(resource.Name, crdV1Conditions(crd))
+			if err != nil {
+				return err
+			}
+			if !established {
+				return fmt.Errorf("CRD %s not established", crdName)
+			}
+		}
+		return nil
+	}
+}
+
+// LeaderCheck fails while the replica does not hold the leader lease. Use it as a readiness check only when
+// standby replicas should not receive traffic.
+func LeaderCheck(elector *LeaderElector) HealthCheck {
+	return func() error {
+		if !elector.IsLeader() {
+			return fmt.Errorf("not the leader")
+		}
+		return nil
+	}
+}
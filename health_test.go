@@ -0,0 +1,88 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/rest"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	health := NewHealth()
+	synced := false
+	health.AddReadinessCheck("cache", CacheSyncedCheck(func() bool { return synced }))
+	health.AddLivenessCheck("always", func() error { return nil })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		health.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[+] always ok\nok\n", w.Body.String())
+
+	w = get("/readyz")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "[-] cache failed: cache not synced\n", w.Body.String())
+
+	synced = true
+	w = get("/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	health.AddReadinessCheck("broken", func() error { return fmt.Errorf("boom") })
+	w = get("/readyz")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "[-] broken failed: boom\n[+] cache ok\n", w.Body.String())
+}
+
+func TestCRDEstablishedCheckV1Only(t *testing.T) {
+	crd := ""
+	clientset, err := apiextensionsclient.NewForConfig(&rest.Config{
+		Host: "http://v1only",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			// the cluster serves apiextensions.k8s.io/v1 only
+			if req.URL.Path != crdV1Path+"/examples.example.com" || crd == "" {
+				recorder.WriteHeader(http.StatusNotFound)
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+				return recorder.Result(), nil
+			}
+			recorder.WriteString(crd)
+			return recorder.Result(), nil
+		}),
+	})
+	assert.NoError(t, err)
+	check := CRDEstablishedCheck(Context{APIExtensionClientset: clientset}, []CustomResource{exampleResource})
+
+	assert.EqualError(t, check(), "CRD examples.example.com not found")
+
+	crd = `{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"examples.example.com"},` +
+		`"status":{"conditions":[{"type":"NamesAccepted","status":"True"},{"type":"Established","status":"False"}]}}`
+	assert.EqualError(t, check(), "CRD examples.example.com not established")
+
+	crd = `{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"examples.example.com"},` +
+		`"status":{"conditions":[{"type":"NamesAccepted","status":"True"},{"type":"Established","status":"True"}]}}`
+	assert.NoError(t, check())
+}
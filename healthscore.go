@@ -0,0 +1,135 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// DefaultHealthScoreWeight is the weight of the latest observation in the health scores unless configured otherwise
+const DefaultHealthScoreWeight = 0.2
+
+// HealthScore is the health of a custom resource between 0, failing every reconcile and probe, and 1, healthy
+type HealthScore struct {
+	Key   string
+	Score float64
+}
+
+// HealthScores keeps a rolling health score per custom resource as the exponential moving average of its reconcile
+// outcomes and probe results. Recent observations weigh the most, so a resource recovers its score gradually after
+// it stops failing. Controllers update the scores of their resources when the scores are set in their options.
+type HealthScores struct {
+	resource string
+	weight   float64
+	metrics  *Metrics
+	lock     sync.RWMutex
+	scores   map[string]float64
+}
+
+// NewHealthScores creates the health scores of the named resource. The weight of the latest observation is between
+// 0 and 1 and defaults to DefaultHealthScoreWeight. The metrics are optional.
+func NewHealthScores(resource string, weight float64, metrics *Metrics) *HealthScores {
+	if weight <= 0 || weight > 1 {
+		weight = DefaultHealthScoreWeight
+	}
+	return &HealthScores{resource: resource, weight: weight, metrics: metrics, scores: map[string]float64{}}
+}
+
+// ObserveReconcile counts a successful reconcile of the resource with the key as healthy and a failed one as not
+func (h *HealthScores) ObserveReconcile(key string, err error) {
+	h.Observe(key, healthValue(err == nil))
+}
+
+// ObserveProbe records the result of a probe of the resource with the key, such as a check of its operand
+func (h *HealthScores) ObserveProbe(key string, healthy bool) {
+	h.Observe(key, healthValue(healthy))
+}
+
+// Observe moves the score of the resource with the key toward the value between 0 and 1. The first observation
+// of a resource sets its score.
+func (h *HealthScores) Observe(key string, value float64) {
+	value = math.Max(0, math.Min(1, value))
+	h.lock.Lock()
+	score, ok := h.scores[key]
+	if ok {
+		score = h.weight*value + (1-h.weight)*score
+	} else {
+		score = value
+	}
+	h.scores[key] = score
+	h.lock.Unlock()
+
+	h.metrics.setHealthScore(h.resource, key, score)
+}
+
+// Score returns the score of the resource with the key, and false if nothing was observed for it yet
+func (h *HealthScores) Score(key string) (float64, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	score, ok := h.scores[key]
+	return score, ok
+}
+
+// Forget drops the score of a deleted resource
+func (h *HealthScores) Forget(key string) {
+	h.lock.Lock()
+	delete(h.scores, key)
+	h.lock.Unlock()
+
+	h.metrics.deleteHealthScore(h.resource, key)
+}
+
+// Unhealthiest returns up to n scores, lowest first. A negative n returns all scores.
+func (h *HealthScores) Unhealthiest(n int) []HealthScore {
+	h.lock.RLock()
+	scores := make([]HealthScore, 0, len(h.scores))
+	for key, score := range h.scores {
+		scores = append(scores, HealthScore{Key: key, Score: score})
+	}
+	h.lock.RUnlock()
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score < scores[j].Score
+		}
+		return scores[i].Key < scores[j].Key
+	})
+	if n >= 0 && n < len(scores) {
+		scores = scores[:n]
+	}
+	return scores
+}
+
+// StatusPatch returns the patch setting status.healthScore of the resource with the key to its score as a percentage,
+// for PatchStatus. The patch is empty if nothing was observed for the resource yet.
+func (h *HealthScores) StatusPatch(key string) []JSONPatchOperation {
+	score, ok := h.Score(key)
+	if !ok {
+		return nil
+	}
+	return []JSONPatchOperation{JSONPatchAdd("/status/healthScore", int64(math.Floor(score*100+0.5)))}
+}
+
+func healthValue(healthy bool) float64 {
+	if healthy {
+		return 1
+	}
+	return 0
+}
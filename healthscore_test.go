@@ -0,0 +1,57 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthScores(t *testing.T) {
+	scores := NewHealthScores("example", 0.5, nil)
+	_, ok := scores.Score("ns/a")
+	assert.False(t, ok)
+	assert.Nil(t, scores.StatusPatch("ns/a"))
+
+	scores.ObserveReconcile("ns/a", nil)
+	scores.ObserveReconcile("ns/a", fmt.Errorf("failed"))
+	scores.ObserveProbe("ns/a", false)
+	score, ok := scores.Score("ns/a")
+	assert.True(t, ok)
+	assert.InDelta(t, 0.25, score, 0.0001)
+	assert.Equal(t, []JSONPatchOperation{JSONPatchAdd("/status/healthScore", int64(25))}, scores.StatusPatch("ns/a"))
+
+	scores.ObserveReconcile("ns/b", nil)
+	scores.ObserveReconcile("ns/c", nil)
+	scores.Observe("ns/d", 2)
+	assert.Equal(t, []HealthScore{{Key: "ns/a", Score: 0.25}, {Key: "ns/b", Score: 1}}, scores.Unhealthiest(2))
+	assert.Equal(t, 4, len(scores.Unhealthiest(-1)))
+
+	scores.Forget("ns/a")
+	_, ok = scores.Score("ns/a")
+	assert.False(t, ok)
+	assert.Equal(t, "ns/b", scores.Unhealthiest(1)[0].Key)
+}
+
+func TestHealthScoresDefaultWeight(t *testing.T) {
+	scores := NewHealthScores("example", 0, nil)
+	scores.ObserveReconcile("ns/a", nil)
+	scores.ObserveReconcile("ns/a", fmt.Errorf("failed"))
+	score, _ := scores.Score("ns/a")
+	assert.InDelta(t, 1-DefaultHealthScoreWeight, score, 0.0001)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	gocontext "context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	defaultJobBackoffLimit = int32(2)
+	defaultJobLogBytes     = 1024
+
+	// EventReasonJobSucceeded is the reason of the event emitted on the owner of a job run by RunJob that completed
+	EventReasonJobSucceeded = "JobSucceeded"
+
+	// EventReasonJobFailed is the reason of the event emitted on the owner of a job run by RunJob that failed
+	EventReasonJobFailed = "JobFailed"
+)
+
+// JobRunOptions configure how RunJob runs a job for a custom resource
+type JobRunOptions struct {
+	// Owner is the custom resource the job is run for. It becomes the controller owner of the job, so the job is
+	// deleted with it, and the events of the run are emitted on it. Optional.
+	Owner runtime.Object
+
+	// OwnerResource describes the owner for its owner reference
+	OwnerResource CustomResource
+
+	// BackoffLimit is the number of retries of the job unless the job sets it. Defaults to two.
+	BackoffLimit *int32
+
+	// Interval between two checks of the job. Defaults to DefaultReadinessInterval.
+	Interval time.Duration
+
+	// Logs bounds the logs collected from the pods of the job once it finished
+	Logs PodLogOptions
+
+	// LogBytes caps the summary of the logs in the result and the events. Defaults to 1KiB.
+	LogBytes int
+}
+
+// JobRunResult is the outcome of a job run by RunJob
+type JobRunResult struct {
+	Job *batchv1.Job
+
+	// Logs summarizes the end of the logs of the pods of the job, to explain a failure in the status of the owner
+	Logs string
+}
+
+// RunJob creates the job unless it already exists and waits until it completes, fails, or the context is done, for
+// imperative steps of a reconcile such as migrating data or formatting a disk. The name of the job should be stable,
+// so a reconcile retried after a restart waits for the job started before instead of starting another one. Once
+// the job finished, the logs of its pods are summarized in the result and in an event on the owner.
+func RunJob(ctx gocontext.Context, context Context, job *batchv1.Job, opts JobRunOptions) (JobRunResult, error) {
+	result := JobRunResult{}
+	if context.Clientset == nil {
+		return result, fmt.Errorf("the context has no clientset")
+	}
+	if job.Spec.BackoffLimit == nil {
+		backoffLimit := defaultJobBackoffLimit
+		if opts.BackoffLimit != nil {
+			backoffLimit = *opts.BackoffLimit
+		}
+		job.Spec.BackoffLimit = &backoffLimit
+	}
+	if opts.Owner != nil {
+		owner, err := meta.Accessor(opts.Owner)
+		if err != nil {
+			return result, fmt.Errorf("failed to access metadata of the owner. %+v", err)
+		}
+		job.OwnerReferences = append(job.OwnerReferences, *metav1.NewControllerRef(owner, opts.OwnerResource.GroupVersionKind()))
+	}
+
+	jobs := context.Clientset.BatchV1().Jobs(job.Namespace)
+	created, err := jobs.Create(job)
+	if errors.IsAlreadyExists(err) {
+		created, err = jobs.Get(job.Name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to create job %s. %+v", job.Name, err)
+	}
+	result.Job = created
+
+	waitErr := WaitForReady(ctx, opts.Interval, ReadinessFunc(func() (bool, error) {
+		current, err := jobs.Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get job %s. %+v", job.Name, err)
+		}
+		result.Job = current
+		return JobComplete(current)
+	}))
+	if waitErr != nil && ctx.Err() != nil {
+		// the job keeps running, and the next reconcile waits for it again
+		return result, waitErr
+	}
+
+	logs, err := CollectPodLogs(context, job.Namespace, "job-name="+job.Name, opts.Logs)
+	if err != nil {
+		context.logger().Error(err, "failed to collect the logs of the job", "job", job.Name)
+	}
+	logBytes := opts.LogBytes
+	if logBytes <= 0 {
+		logBytes = defaultJobLogBytes
+	}
+	result.Logs = SummarizePodLogs(logs, logBytes)
+
+	if opts.Owner != nil && context.Recorder != nil {
+		if waitErr != nil {
+			context.Recorder.Event(opts.Owner, v1.EventTypeWarning, EventReasonJobFailed, fmt.Sprintf("%+v. %s", waitErr, result.Logs))
+		} else {
+			context.Recorder.Event(opts.Owner, v1.EventTypeNormal, EventReasonJobSucceeded, fmt.Sprintf("job %s completed", job.Name))
+		}
+	}
+	return result, waitErr
+}
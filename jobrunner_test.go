@@ -0,0 +1,66 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	gocontext "context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRunJob(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "migrate"}, Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "format"}, Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded"}}}},
+	)
+	recorder := record.NewFakeRecorder(10)
+	context := Context{Clientset: clientset, Recorder: recorder}
+	owner := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster", UID: "uid"}}
+	opts := JobRunOptions{Owner: owner, OwnerResource: ConfigMapResource, Interval: time.Millisecond}
+
+	// the job started by a previous reconcile is awaited instead of being created again
+	result, err := RunJob(gocontext.Background(), context, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "migrate"}}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "migrate", result.Job.Name)
+	assert.Equal(t, "Normal JobSucceeded job migrate completed", <-recorder.Events)
+
+	_, err = RunJob(gocontext.Background(), context, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "format"}}, opts)
+	assert.Contains(t, err.Error(), "BackoffLimitExceeded")
+	assert.Contains(t, <-recorder.Events, "Warning JobFailed job ns/format failed")
+
+	// a job still running when the context is done keeps running without an event
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 10*time.Millisecond)
+	defer cancel()
+	result, err = RunJob(ctx, context, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "resize"}}, opts)
+	assert.Equal(t, gocontext.DeadlineExceeded, err)
+	assert.Equal(t, 0, len(recorder.Events))
+	job, err := clientset.BatchV1().Jobs("ns").Get("resize", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), *job.Spec.BackoffLimit)
+	assert.Equal(t, 1, len(job.OwnerReferences))
+	assert.Equal(t, "ConfigMap", job.OwnerReferences[0].Kind)
+	assert.Equal(t, "cluster", job.OwnerReferences[0].Name)
+	assert.True(t, *job.OwnerReferences[0].Controller)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"sync"
+)
+
+// KeyLocks serializes the work on the same key, such as the key of a custom resource, across controllers in the
+// operator. Controllers sharing the locks through their options never reconcile the same custom resource at the
+// same time, which avoids conflicting updates. Waiters acquire a key in the order they asked for it.
+type KeyLocks struct {
+	lock *sync.Mutex
+	keys map[string]*keyLock
+}
+
+// keyLock is held by one caller and lists the channels of the waiting callers in order
+type keyLock struct {
+	waiters []chan struct{}
+}
+
+// NewKeyLocks creates an empty set of key locks
+func NewKeyLocks() *KeyLocks {
+	return &KeyLocks{lock: &sync.Mutex{}, keys: map[string]*keyLock{}}
+}
+
+// Lock blocks until the key is available and acquires it
+func (l *KeyLocks) Lock(key string) {
+	l.lock.Lock()
+	k, held := l.keys[key]
+	if !held {
+		l.keys[key] = &keyLock{}
+		l.lock.Unlock()
+		return
+	}
+	ready := make(chan struct{})
+	k.waiters = append(k.waiters, ready)
+	l.lock.Unlock()
+	<-ready
+}
+
+// Unlock releases the key and hands it to the next waiter. It panics if the key is not locked.
+func (l *KeyLocks) Unlock(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	k, held := l.keys[key]
+	if !held {
+		panic(fmt.Sprintf("unlock of unlocked key %s", key))
+	}
+	if len(k.waiters) == 0 {
+		delete(l.keys, key)
+		return
+	}
+	next := k.waiters[0]
+	k.waiters = k.waiters[1:]
+	close(next)
+}
+
+// WithLock calls f while holding the key
+func (l *KeyLocks) WithLock(key string, f func() error) error {
+	l.Lock(key)
+	defer l.Unlock(key)
+	return f()
+}
+
+// lockKey identifies a custom resource across the controllers of the resource
+func lockKey(resource CustomResource, key string) string {
+	return fmt.Sprintf("%s.%s/%s", resource.Plural, resource.Group, key)
+}
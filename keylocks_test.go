@@ -0,0 +1,64 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLocks(t *testing.T) {
+	locks := NewKeyLocks()
+	locks.Lock("a")
+	// other keys are independent
+	locks.Lock("b")
+	locks.Unlock("b")
+
+	var order []int
+	var orderLock sync.Mutex
+	var done sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			locks.WithLock("a", func() error {
+				orderLock.Lock()
+				order = append(order, i)
+				orderLock.Unlock()
+				return nil
+			})
+		}(i)
+		// wait for the goroutine to queue up so that the order is deterministic
+		for {
+			locks.lock.Lock()
+			waiting := len(locks.keys["a"].waiters)
+			locks.lock.Unlock()
+			if waiting == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	locks.Unlock("a")
+	done.Wait()
+	assert.Equal(t, []int{0, 1, 2}, order)
+	assert.Empty(t, locks.keys)
+	assert.Panics(t, func() { locks.Unlock("a") })
+}
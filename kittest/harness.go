@@ -0,0 +1,276 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kittest runs reconcilers against fake clients loaded from YAML fixtures
+package kittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	opkit "github.com/rook/operator-kit"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/fake"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Action is a request made by the reconciler to the Kubernetes clientset or the client of a custom resource
+type Action struct {
+	// Verb of the request: get, list, create, update, patch, or delete
+	Verb string
+
+	// Resource is the plural of the resource, such as "configmaps" or "samples"
+	Resource    string
+	Namespace   string
+	Name        string
+	Subresource string
+}
+
+// String formats the action like "create configmaps default/sample-config"
+func (a Action) String() string {
+	resource := a.Resource
+	if a.Subresource != "" {
+		resource += "/" + a.Subresource
+	}
+	name := a.Name
+	if a.Namespace != "" {
+		name = a.Namespace + "/" + a.Name
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", a.Verb, resource, name))
+}
+
+// Harness holds fake clients loaded with the objects of YAML fixtures. Reconcilers under test are created with the
+// Context, the Client of their custom resource, and its Store, and run with Reconcile.
+type Harness struct {
+	// Context is a fake context whose clientset holds the Kubernetes objects of the fixtures
+	Context opkit.Context
+
+	scheme    *runtime.Scheme
+	codecs    serializer.CodecFactory
+	resources []opkit.CustomResource
+	server    *fakeAPIServer
+	stores    map[string]cache.Store
+}
+
+// NewHarness loads the objects of the YAML fixture files. Objects of the custom resources are served to the clients
+// returned by Client and cached in the stores returned by Store. The scheme must have the types of the custom
+// resources registered. All other objects are added to the fake clientset of the context.
+func NewHarness(scheme *runtime.Scheme, resources []opkit.CustomResource, fixtures ...string) (*Harness, error) {
+	h := &Harness{
+		scheme:    scheme,
+		codecs:    serializer.NewCodecFactory(scheme),
+		resources: resources,
+		server:    newFakeAPIServer(),
+		stores:    map[string]cache.Store{},
+	}
+	for _, resource := range resources {
+		h.server.addResource(toAPIResource(resource))
+		h.stores[resource.Name] = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	}
+
+	var kubeObjects []runtime.Object
+	for _, fixture := range fixtures {
+		docs, err := readFixture(fixture)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			obj, err := h.load(doc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load fixture %s. %+v", fixture, err)
+			}
+			if obj != nil {
+				kubeObjects = append(kubeObjects, obj)
+			}
+		}
+	}
+
+	h.Context = opkit.NewFakeContext(kubeObjects...)
+	return h, nil
+}
+
+// Client returns a client of the custom resource that reads and writes the objects of the harness
+func (h *Harness) Client(resource opkit.CustomResource) (rest.Interface, error) {
+	config := &rest.Config{
+		Host:      "http://kittest",
+		APIPath:   "/apis",
+		Transport: h.server,
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &schema.GroupVersion{Group: resource.Group, Version: resource.Version},
+			ContentType:          runtime.ContentTypeJSON,
+			NegotiatedSerializer: serializer.DirectCodecFactory{CodecFactory: h.codecs},
+		},
+	}
+	return rest.RESTClientFor(config)
+}
+
+// Store returns the cache of the custom resource holding the objects of the fixtures, like the store of a controller
+func (h *Harness) Store(resource opkit.CustomResource) cache.Store {
+	return h.stores[resource.Name]
+}
+
+// Reconcile runs a single reconcile of the key and returns its error. The actions of previous reconciles are
+// discarded, so Actions returns the requests of this reconcile only. Traced reconcilers get a new trace.
+func (h *Harness) Reconcile(reconciler opkit.Reconciler, key string) error {
+	h.server.takeActions()
+	h.clientset().ClearActions()
+	if traced, ok := reconciler.(opkit.TracedReconciler); ok {
+		logger := h.Context.Logger
+		if logger == nil {
+			logger = opkit.NewStdLogger(os.Stderr, opkit.LogLevelInfo)
+		}
+		return traced.ReconcileTraced(opkit.NewReconcileTrace(key, logger))
+	}
+	return reconciler.Reconcile(key)
+}
+
+// Actions returns the requests to the clientset followed by the requests to the custom resource clients since the
+// last reconcile started
+func (h *Harness) Actions() []Action {
+	var actions []Action
+	for _, action := range h.clientset().Actions() {
+		actions = append(actions, toAction(action))
+	}
+	h.server.lock.Lock()
+	actions = append(actions, h.server.actions...)
+	h.server.lock.Unlock()
+	return actions
+}
+
+// Object decodes the current version of the named custom resource into obj
+func (h *Harness) Object(resource opkit.CustomResource, namespace, name string, obj runtime.Object) error {
+	stored, ok := h.server.get(resource.Plural, namespace, name)
+	if !ok {
+		return fmt.Errorf("%s %s/%s not found", resource.Name, namespace, name)
+	}
+	h.server.lock.Lock()
+	raw, err := json.Marshal(stored)
+	h.server.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, obj)
+}
+
+func (h *Harness) clientset() *fake.Clientset {
+	return h.Context.Clientset.(*fake.Clientset)
+}
+
+// load adds a custom resource to the server and the store, or returns the decoded Kubernetes object
+func (h *Harness) load(doc []byte) (runtime.Object, error) {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return nil, err
+	}
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+
+	for _, resource := range h.resources {
+		if apiVersion != resource.Group+"/"+resource.Version || kind != resource.Kind {
+			continue
+		}
+		typed, _, err := h.codecs.UniversalDeserializer().Decode(doc, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s. %+v", kind, err)
+		}
+		if _, err := meta.Accessor(typed); err != nil {
+			return nil, err
+		}
+		h.server.add(toAPIResource(resource), obj)
+		return nil, h.stores[resource.Name].Add(typed)
+	}
+
+	typed, _, err := kubescheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s %s. %+v", apiVersion, kind, err)
+	}
+	return typed, nil
+}
+
+// readFixture returns the YAML documents of the file as JSON
+func readFixture(path string) ([][]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s. %+v", path, err)
+	}
+
+	var docs [][]byte
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		doc := map[string]interface{}{}
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				return docs, nil
+			}
+			return nil, fmt.Errorf("failed to parse fixture %s. %+v", path, err)
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, raw)
+	}
+}
+
+func toAPIResource(resource opkit.CustomResource) apiResource {
+	return apiResource{
+		group:   resource.Group,
+		version: resource.Version,
+		plural:  resource.Plural,
+		kind:    resource.Kind,
+	}
+}
+
+func toAction(action clienttesting.Action) Action {
+	a := Action{
+		Verb:        action.GetVerb(),
+		Resource:    action.GetResource().Resource,
+		Namespace:   action.GetNamespace(),
+		Subresource: action.GetSubresource(),
+	}
+	switch action := action.(type) {
+	case clienttesting.GetAction:
+		a.Name = action.GetName()
+	case clienttesting.DeleteAction:
+		a.Name = action.GetName()
+	case clienttesting.PatchAction:
+		a.Name = action.GetName()
+	case clienttesting.CreateAction:
+		if accessor, err := meta.Accessor(action.GetObject()); err == nil {
+			a.Name = accessor.GetName()
+		}
+	case clienttesting.UpdateAction:
+		if accessor, err := meta.Accessor(action.GetObject()); err == nil {
+			a.Name = accessor.GetName()
+		}
+	}
+	return a
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kittest
+
+import (
+	"testing"
+
+	opkit "github.com/rook/operator-kit"
+	sample "github.com/rook/operator-kit/sample-operator/pkg/apis/myproject/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestHarnessReconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, sample.AddToScheme(scheme))
+	resources := []opkit.CustomResource{sample.SampleResource}
+	h, err := NewHarness(scheme, resources, "testdata/sample.yaml")
+	assert.NoError(t, err)
+	client, err := h.Client(sample.SampleResource)
+	assert.NoError(t, err)
+
+	// the reconciler copies the greeting of the sample into a configmap and marks the sample as greeted
+	reconciler := opkit.ReconcilerFunc(func(key string) error {
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return err
+		}
+		obj := &sample.Sample{}
+		if err := client.Get().Namespace(namespace).Resource(sample.SampleResource.Plural).Name(name).Do().Into(obj); err != nil {
+			return err
+		}
+		configMap := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-greeting", Namespace: namespace},
+			Data:       map[string]string{"hello": obj.Spec.Hello},
+		}
+		if _, err := h.Context.Clientset.CoreV1().ConfigMaps(namespace).Create(configMap); err != nil {
+			return err
+		}
+		obj.Spec.Hello = "greeted " + obj.Spec.Hello
+		return client.Put().Namespace(namespace).Resource(sample.SampleResource.Plural).Name(name).Body(obj).Do().Error()
+	})
+
+	_, exists, err := h.Store(sample.SampleResource).GetByKey("default/mysample")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoError(t, h.Reconcile(reconciler, "default/mysample"))
+	var actions []string
+	for _, action := range h.Actions() {
+		actions = append(actions, action.String())
+	}
+	assert.Equal(t, []string{
+		"create configmaps default/mysample-greeting",
+		"get samples default/mysample",
+		"update samples default/mysample",
+	}, actions)
+
+	configMap, err := h.Context.Clientset.CoreV1().ConfigMaps("default").Get("mysample-greeting", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "world", configMap.Data["hello"])
+	existing, err := h.Context.Clientset.CoreV1().ConfigMaps("default").Get("existing", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", existing.Data["greeting"])
+
+	updated := &sample.Sample{}
+	assert.NoError(t, h.Object(sample.SampleResource, "default", "mysample", updated))
+	assert.Equal(t, "greeted world", updated.Spec.Hello)
+
+	// a second reconcile only reports its own actions and fails on the existing configmap
+	assert.Error(t, h.Reconcile(reconciler, "default/mysample"))
+	assert.Equal(t, 2, len(h.Actions()))
+}
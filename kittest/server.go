@@ -0,0 +1,459 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kittest runs reconcilers against fake clients loaded from YAML fixtures
+package kittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// apiResource is a custom resource served by the fake API server
+type apiResource struct {
+	group   string
+	version string
+	plural  string
+	kind    string
+}
+
+// fakeAPIServer serves custom resources from memory to REST clients that use it as their transport. It supports
+// get, list, create, update, delete, and merge and JSON patches, with resourceVersion conflict checks.
+type fakeAPIServer struct {
+	lock      sync.Mutex
+	resources map[string]apiResource
+	objects   map[string]map[string]interface{}
+	version   int
+	actions   []Action
+}
+
+func newFakeAPIServer() *fakeAPIServer {
+	return &fakeAPIServer{resources: map[string]apiResource{}, objects: map[string]map[string]interface{}{}}
+}
+
+func (s *fakeAPIServer) addResource(r apiResource) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.resources[r.group+"/"+r.version+"/"+r.plural] = r
+}
+
+// add stores the object without recording an action
+func (s *fakeAPIServer) add(r apiResource, obj map[string]interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	metadata := metadataOf(obj)
+	namespace, _ := metadata["namespace"].(string)
+	name, _ := metadata["name"].(string)
+	s.setResourceVersion(obj)
+	s.objects[objectKey(r.plural, namespace, name)] = obj
+}
+
+// get returns the stored object without recording an action
+func (s *fakeAPIServer) get(plural, namespace, name string) (map[string]interface{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	obj, ok := s.objects[objectKey(plural, namespace, name)]
+	return obj, ok
+}
+
+func (s *fakeAPIServer) takeActions() []Action {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	actions := s.actions
+	s.actions = nil
+	return actions
+}
+
+// request is a parsed request path of the form /apis/group/version[/namespaces/ns]/plural[/name[/subresource]]
+type request struct {
+	resource    apiResource
+	namespace   string
+	name        string
+	subresource string
+}
+
+func (s *fakeAPIServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r, err := s.parse(req.URL.Path)
+	if err != nil {
+		return statusResponse(http.StatusNotFound, metav1.StatusReasonNotFound, err.Error()), nil
+	}
+	var body []byte
+	if req.Body != nil {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		if req.URL.Query().Get("watch") == "true" {
+			return statusResponse(http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, "watch is not supported by the test harness"), nil
+		}
+		if r.name == "" {
+			s.record("list", r)
+			return s.list(r), nil
+		}
+		s.record("get", r)
+		obj, ok := s.objects[objectKey(r.resource.plural, r.namespace, r.name)]
+		if !ok {
+			return notFound(r), nil
+		}
+		return jsonResponse(http.StatusOK, obj), nil
+	case http.MethodPost:
+		return s.create(r, body), nil
+	case http.MethodPut:
+		return s.update(r, body), nil
+	case http.MethodPatch:
+		return s.patch(r, req.Header.Get("Content-Type"), body), nil
+	case http.MethodDelete:
+		s.record("delete", r)
+		key := objectKey(r.resource.plural, r.namespace, r.name)
+		if _, ok := s.objects[key]; !ok {
+			return notFound(r), nil
+		}
+		delete(s.objects, key)
+		return jsonResponse(http.StatusOK, metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: metav1.StatusSuccess}), nil
+	}
+	return statusResponse(http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, req.Method+" is not supported"), nil
+}
+
+func (s *fakeAPIServer) parse(path string) (request, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "apis" {
+		return request{}, fmt.Errorf("unsupported path %s", path)
+	}
+	r := request{}
+	group, version := parts[1], parts[2]
+	parts = parts[3:]
+	if len(parts) >= 2 && parts[0] == "namespaces" {
+		r.namespace = parts[1]
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		return request{}, fmt.Errorf("unsupported path %s", path)
+	}
+	resource, ok := s.resources[group+"/"+version+"/"+parts[0]]
+	if !ok {
+		return request{}, fmt.Errorf("the server could not find the requested resource %s", path)
+	}
+	r.resource = resource
+	if len(parts) > 1 {
+		r.name = parts[1]
+	}
+	if len(parts) > 2 {
+		r.subresource = parts[2]
+	}
+	return r, nil
+}
+
+func (s *fakeAPIServer) record(verb string, r request) {
+	s.actions = append(s.actions, Action{
+		Verb:        verb,
+		Resource:    r.resource.plural,
+		Namespace:   r.namespace,
+		Name:        r.name,
+		Subresource: r.subresource,
+	})
+}
+
+func (s *fakeAPIServer) list(r request) *http.Response {
+	var keys []string
+	prefix := r.resource.plural + "/"
+	if r.namespace != "" {
+		prefix += r.namespace + "/"
+	}
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	items := []interface{}{}
+	for _, key := range keys {
+		items = append(items, s.objects[key])
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"apiVersion": r.resource.group + "/" + r.resource.version,
+		"kind":       r.resource.kind + "List",
+		"metadata":   map[string]interface{}{"resourceVersion": strconv.Itoa(s.version)},
+		"items":      items,
+	})
+}
+
+func (s *fakeAPIServer) create(r request, body []byte) *http.Response {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return statusResponse(http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+	}
+	metadata := metadataOf(obj)
+	r.name, _ = metadata["name"].(string)
+	s.record("create", r)
+	if r.namespace != "" {
+		metadata["namespace"] = r.namespace
+	}
+
+	key := objectKey(r.resource.plural, r.namespace, r.name)
+	if _, exists := s.objects[key]; exists {
+		return statusResponse(http.StatusConflict, metav1.StatusReasonAlreadyExists,
+			fmt.Sprintf("%s %q already exists", r.resource.plural, r.name))
+	}
+	s.setResourceVersion(obj)
+	s.objects[key] = obj
+	return jsonResponse(http.StatusCreated, obj)
+}
+
+func (s *fakeAPIServer) update(r request, body []byte) *http.Response {
+	s.record("update", r)
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return statusResponse(http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+	}
+	key := objectKey(r.resource.plural, r.namespace, r.name)
+	existing, ok := s.objects[key]
+	if !ok {
+		return notFound(r)
+	}
+	if version, _ := metadataOf(obj)["resourceVersion"].(string); version != "" && version != metadataOf(existing)["resourceVersion"] {
+		return conflict(r)
+	}
+	if r.namespace != "" {
+		metadataOf(obj)["namespace"] = r.namespace
+	}
+	s.setResourceVersion(obj)
+	s.objects[key] = obj
+	return jsonResponse(http.StatusOK, obj)
+}
+
+func (s *fakeAPIServer) patch(r request, contentType string, body []byte) *http.Response {
+	s.record("patch", r)
+	key := objectKey(r.resource.plural, r.namespace, r.name)
+	existing, ok := s.objects[key]
+	if !ok {
+		return notFound(r)
+	}
+	// patch a copy so that a failed patch leaves the object unchanged
+	obj := map[string]interface{}{}
+	raw, _ := json.Marshal(existing)
+	json.Unmarshal(raw, &obj)
+
+	switch {
+	case strings.HasPrefix(contentType, "application/json-patch+json"):
+		var ops []jsonPatchOperation
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return statusResponse(http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+		}
+		for _, op := range ops {
+			patched, err := patchValue(obj, pointerSegments(op.Path), op.Op, op.Value)
+			if err != nil {
+				return statusResponse(http.StatusUnprocessableEntity, metav1.StatusReasonInvalid, err.Error())
+			}
+			obj, _ = patched.(map[string]interface{})
+		}
+	case strings.HasPrefix(contentType, "application/merge-patch+json"),
+		strings.HasPrefix(contentType, "application/strategic-merge-patch+json"):
+		// strategic merge patches are applied as merge patches, which replace lists instead of merging them
+		patch := map[string]interface{}{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return statusResponse(http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+		}
+		obj = mergePatch(obj, patch)
+	default:
+		return statusResponse(http.StatusUnsupportedMediaType, metav1.StatusReasonUnsupportedMediaType,
+			fmt.Sprintf("unsupported patch type %s", contentType))
+	}
+
+	s.setResourceVersion(obj)
+	s.objects[key] = obj
+	return jsonResponse(http.StatusOK, obj)
+}
+
+func (s *fakeAPIServer) setResourceVersion(obj map[string]interface{}) {
+	s.version++
+	metadataOf(obj)["resourceVersion"] = strconv.Itoa(s.version)
+}
+
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// patchValue applies a JSON patch operation at the path segments below the node and returns the updated node
+func patchValue(node interface{}, segments []string, op string, value interface{}) (interface{}, error) {
+	if len(segments) == 0 {
+		switch op {
+		case "add", "replace":
+			return value, nil
+		case "test":
+			if !reflect.DeepEqual(node, value) {
+				return nil, fmt.Errorf("test failed")
+			}
+			return node, nil
+		}
+		return nil, fmt.Errorf("unsupported operation %s of the whole document", op)
+	}
+
+	key := segments[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, exists := n[key]
+		if len(segments) > 1 {
+			if !exists {
+				return nil, fmt.Errorf("path %s not found", key)
+			}
+			updated, err := patchValue(child, segments[1:], op, value)
+			n[key] = updated
+			return n, err
+		}
+		switch op {
+		case "add":
+			n[key] = value
+		case "replace", "remove", "test":
+			if !exists {
+				return nil, fmt.Errorf("path %s not found", key)
+			}
+			if op == "replace" {
+				n[key] = value
+			} else if op == "remove" {
+				delete(n, key)
+			} else if !reflect.DeepEqual(child, value) {
+				return nil, fmt.Errorf("test of %s failed", key)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported operation %s", op)
+		}
+		return n, nil
+
+	case []interface{}:
+		if key == "-" && len(segments) == 1 && op == "add" {
+			return append(n, value), nil
+		}
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(n) || (i == len(n) && (op != "add" || len(segments) > 1)) {
+			return nil, fmt.Errorf("index %s out of range", key)
+		}
+		if len(segments) > 1 {
+			updated, err := patchValue(n[i], segments[1:], op, value)
+			n[i] = updated
+			return n, err
+		}
+		switch op {
+		case "add":
+			n = append(n[:i], append([]interface{}{value}, n[i:]...)...)
+		case "replace":
+			n[i] = value
+		case "remove":
+			n = append(n[:i], n[i+1:]...)
+		case "test":
+			if !reflect.DeepEqual(n[i], value) {
+				return nil, fmt.Errorf("test of %s failed", key)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported operation %s", op)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("path %s not found", key)
+}
+
+// pointerSegments splits a JSON pointer (RFC 6901) into its unescaped segments
+func pointerSegments(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = strings.Replace(strings.Replace(segment, "~1", "/", -1), "~0", "~", -1)
+	}
+	return segments
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to the target
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if patchMap, ok := value.(map[string]interface{}); ok {
+			targetMap, ok := target[key].(map[string]interface{})
+			if !ok {
+				targetMap = map[string]interface{}{}
+			}
+			target[key] = mergePatch(targetMap, patchMap)
+			continue
+		}
+		target[key] = value
+	}
+	return target
+}
+
+func objectKey(plural, namespace, name string) string {
+	return plural + "/" + namespace + "/" + name
+}
+
+func metadataOf(obj map[string]interface{}) map[string]interface{} {
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	return metadata
+}
+
+func notFound(r request) *http.Response {
+	return statusResponse(http.StatusNotFound, metav1.StatusReasonNotFound, fmt.Sprintf("%s %q not found", r.resource.plural, r.name))
+}
+
+func conflict(r request) *http.Response {
+	return statusResponse(http.StatusConflict, metav1.StatusReasonConflict,
+		fmt.Sprintf("the object %s %q has been modified", r.resource.plural, r.name))
+}
+
+func statusResponse(code int, reason metav1.StatusReason, message string) *http.Response {
+	return jsonResponse(code, metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Reason:   reason,
+		Message:  message,
+		Code:     int32(code),
+	})
+}
+
+func jsonResponse(code int, obj interface{}) *http.Response {
+	body, _ := json.Marshal(obj)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		StatusCode: code,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeAPIServer(t *testing.T) {
+	server := newFakeAPIServer()
+	server.addResource(apiResource{group: "example.com", version: "v1", plural: "samples", kind: "Sample"})
+	client := &http.Client{Transport: server}
+	base := "http://kittest/apis/example.com/v1/namespaces/default/samples"
+
+	send := func(method, url, contentType, body string) (int, map[string]interface{}) {
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		result := map[string]interface{}{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp.StatusCode, result
+	}
+
+	code, obj := send(http.MethodPost, base, "application/json", `{"apiVersion":"example.com/v1","kind":"Sample","metadata":{"name":"a"},"spec":{"size":1}}`)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "default", metadataOf(obj)["namespace"])
+	code, _ = send(http.MethodPost, base, "application/json", `{"metadata":{"name":"a"}}`)
+	assert.Equal(t, http.StatusConflict, code)
+
+	// updates with a stale resourceVersion conflict
+	code, _ = send(http.MethodPut, base+"/a", "application/json", `{"metadata":{"name":"a","resourceVersion":"0"}}`)
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, obj = send(http.MethodPatch, base+"/a", "application/merge-patch+json", `{"spec":{"size":2,"extra":true}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"size": float64(2), "extra": true}, obj["spec"])
+
+	code, obj = send(http.MethodPatch, base+"/a/status", "application/json-patch+json",
+		`[{"op":"test","path":"/spec/size","value":2},{"op":"add","path":"/status","value":{"items":["x"]}},{"op":"add","path":"/status/items/-","value":"y"}]`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"items": []interface{}{"x", "y"}}, obj["status"])
+
+	code, _ = send(http.MethodPatch, base+"/a", "application/json-patch+json", `[{"op":"test","path":"/spec/size","value":3}]`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	code, obj = send(http.MethodGet, base, "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "SampleList", obj["kind"])
+	assert.Equal(t, 1, len(obj["items"].([]interface{})))
+
+	code, _ = send(http.MethodDelete, base+"/a", "", "")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = send(http.MethodGet, base+"/a", "", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	var verbs []string
+	for _, action := range server.takeActions() {
+		verbs = append(verbs, action.Verb)
+	}
+	assert.Equal(t, []string{"create", "create", "update", "patch", "patch", "patch", "list", "delete", "get"}, verbs)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ThreeWayLastAppliedAnnotation records the desired state last applied to a child object by the ThreeWayMergePatch
+// strategy. It differs from the LastAppliedAnnotation of kubectl, which DefaultTransform strips from the cache.
+const ThreeWayLastAppliedAnnotation = "operatorkit.io/last-applied-configuration"
+
+// SetLastApplied records the object without the annotation itself in the ThreeWayLastAppliedAnnotation of the object
+func SetLastApplied(obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	annotations := accessor.GetAnnotations()
+	applied := map[string]string{}
+	for key, value := range annotations {
+		if key != ThreeWayLastAppliedAnnotation {
+			applied[key] = value
+		}
+	}
+	if len(applied) == 0 {
+		applied = nil
+	}
+	accessor.SetAnnotations(applied)
+	data, err := json.Marshal(obj)
+	if err != nil {
+		accessor.SetAnnotations(annotations)
+		return err
+	}
+
+	if applied == nil {
+		applied = map[string]string{}
+	}
+	applied[ThreeWayLastAppliedAnnotation] = string(data)
+	accessor.SetAnnotations(applied)
+	return nil
+}
+
+// LastApplied returns the desired state last applied to the object, or nil if it was never recorded
+func LastApplied(obj runtime.Object) []byte {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+	if value, ok := accessor.GetAnnotations()[ThreeWayLastAppliedAnnotation]; ok {
+		return []byte(value)
+	}
+	return nil
+}
+
+// CreateThreeWayMergePatch returns the JSON merge patch that updates the current object to the modified object.
+// Fields of the original object that were removed in the modified object are deleted, and fields only set in the
+// current object are kept. Null values in the modified object are treated as unset.
+func CreateThreeWayMergePatch(original, modified, current []byte) ([]byte, error) {
+	var originalMap, modifiedMap, currentMap map[string]interface{}
+	for _, doc := range []struct {
+		data []byte
+		into *map[string]interface{}
+	}{{original, &originalMap}, {modified, &modifiedMap}, {current, &currentMap}} {
+		if len(doc.data) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(doc.data, doc.into); err != nil {
+			return nil, fmt.Errorf("failed to parse the object. %+v", err)
+		}
+	}
+	patch := threeWayMerge(originalMap, modifiedMap, currentMap)
+	if patch == nil {
+		patch = map[string]interface{}{}
+	}
+	return json.Marshal(patch)
+}
+
+func threeWayMerge(original, modified, current map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for key, value := range modified {
+		if value == nil {
+			continue
+		}
+		if desired, ok := value.(map[string]interface{}); ok {
+			if live, ok := current[key].(map[string]interface{}); ok {
+				last, _ := original[key].(map[string]interface{})
+				if nested := threeWayMerge(last, desired, live); len(nested) > 0 {
+					patch[key] = nested
+				}
+				continue
+			}
+		}
+		if !reflect.DeepEqual(value, current[key]) {
+			patch[key] = value
+		}
+	}
+	for key, value := range original {
+		if value == nil || modified[key] != nil {
+			continue
+		}
+		if _, ok := current[key]; ok {
+			patch[key] = nil
+		}
+	}
+	return patch
+}
+
+// threeWayPatch computes the patch from the last applied state recorded in the live object
+func threeWayPatch(desired, live []byte) ([]byte, error) {
+	var current struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(live, &current); err != nil {
+		return nil, fmt.Errorf("failed to parse the live object. %+v", err)
+	}
+	return CreateThreeWayMergePatch([]byte(current.Metadata.Annotations[ThreeWayLastAppliedAnnotation]), desired, live)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+func TestCreateThreeWayMergePatch(t *testing.T) {
+	tests := []struct {
+		name                        string
+		original, modified, current string
+		patch                       string
+	}{
+		{"unchanged", `{"a":1,"b":{"c":2}}`, `{"a":1,"b":{"c":2}}`, `{"a":1,"b":{"c":2,"d":3}}`, `{}`},
+		{"changed", `{"a":1}`, `{"a":2}`, `{"a":1,"x":true}`, `{"a":2}`},
+		{"removed", `{"a":1,"b":{"c":2,"d":3}}`, `{"a":1,"b":{"c":2}}`, `{"a":1,"b":{"c":2,"d":3,"e":4}}`, `{"b":{"d":null}}`},
+		{"removed by others", `{"a":1,"b":2}`, `{"a":1}`, `{"a":1}`, `{}`},
+		{"never applied", ``, `{"a":1}`, `{"a":3,"b":2}`, `{"a":1}`},
+		{"lists replaced", `{"l":[1,2]}`, `{"l":[1]}`, `{"l":[1,2]}`, `{"l":[1]}`},
+		{"null is unset", `{"a":1}`, `{"a":1,"t":null}`, `{"a":1,"t":"now"}`, `{}`},
+	}
+	for _, test := range tests {
+		patch, err := CreateThreeWayMergePatch([]byte(test.original), []byte(test.modified), []byte(test.current))
+		assert.NoError(t, err, test.name)
+		assert.JSONEq(t, test.patch, string(patch), test.name)
+	}
+
+	_, err := CreateThreeWayMergePatch(nil, []byte(`{`), nil)
+	assert.Error(t, err)
+}
+
+func TestSetLastApplied(t *testing.T) {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Annotations: map[string]string{"x": "y", ThreeWayLastAppliedAnnotation: "old"}},
+		Data:       map[string]string{"k": "v"},
+	}
+	assert.NoError(t, SetLastApplied(cm))
+	assert.Equal(t, "y", cm.Annotations["x"])
+	assert.JSONEq(t, `{"metadata":{"name":"a","creationTimestamp":null,"annotations":{"x":"y"}},"data":{"k":"v"}}`,
+		string(LastApplied(cm)))
+	assert.Nil(t, LastApplied(&v1.ConfigMap{}))
+}
+
+func TestApplyThreeWayMergePatch(t *testing.T) {
+	var patch string
+	live := `{"metadata":{"name":"a","namespace":"ns","annotations":{"` + ThreeWayLastAppliedAnnotation + `":` +
+		`"{\"metadata\":{\"name\":\"a\",\"namespace\":\"ns\",\"creationTimestamp\":null},\"data\":{\"k\":\"v\",\"old\":\"x\"}}"}},` +
+		`"data":{"k":"v","old":"x","injected":"y"}}`
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host: "http://apply",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			switch req.Method {
+			case http.MethodPost:
+				recorder.WriteHeader(http.StatusConflict)
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"AlreadyExists","code":409}`)
+			case http.MethodGet:
+				recorder.WriteString(live)
+			case http.MethodPatch:
+				body, _ := ioutil.ReadAll(req.Body)
+				patch = string(body)
+				assert.Equal(t, "application/merge-patch+json", req.Header.Get("Content-Type"))
+				recorder.WriteString(live)
+			}
+			return recorder.Result(), nil
+		}),
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &schema.GroupVersion{Version: "v1"},
+			NegotiatedSerializer: scheme.Codecs,
+		},
+		APIPath: "/api",
+	})
+	assert.NoError(t, err)
+
+	// the key removed from the desired state is deleted, while the injected key is kept
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}, Data: map[string]string{"k": "v2"}}
+	assert.NoError(t, Apply(client, "configmaps", cm, ApplyOptions{Strategy: ThreeWayMergePatch}))
+	assert.Contains(t, patch, `"data":{"k":"v2","old":null}`)
+	assert.Contains(t, patch, ThreeWayLastAppliedAnnotation)
+
+	// nothing is patched when the live object matches the desired state
+	patch = ""
+	cm = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}, Data: map[string]string{"k": "v", "old": "x"}}
+	assert.NoError(t, Apply(client, "configmaps", cm, ApplyOptions{Strategy: ThreeWayMergePatch}))
+	assert.Equal(t, "", patch)
+	assert.Equal(t, "y", cm.Data["injected"])
+}
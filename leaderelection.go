@@ -0,0 +1,309 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// LeaderElectionConfig configures the election of the operator replica that is allowed to run the controllers
+type LeaderElectionConfig struct {
+	// LockName is the name of the lock shared by all replicas of the operator
+	LockName string
+
+	// LockNamespace is the namespace of the lock, usually the namespace the operator runs in
+	LockNamespace string
+
+	// Identity of this replica. Defaults to the hostname, which is the pod name when running in a cluster.
+	Identity string
+
+	// LeaseDuration is how long non-leaders wait before trying to take over an expired lease. Defaults to 15s.
+	LeaseDuration time.Duration
+
+	// RenewDeadline is how long the leader retries renewing the lease before giving up leadership. Defaults to 10s.
+	RenewDeadline time.Duration
+
+	// RetryPeriod is the interval between attempts to acquire or renew the lease. Defaults to 2s.
+	RetryPeriod time.Duration
+
+	// Lock is the kind of object recording the lease. Defaults to a Lease on clusters serving them. All replicas
+	// must use the same kind, or two replicas may lead at once while a rollout changes it.
+	Lock LeaderElectionLock
+}
+
+// LeaderElectionLock is the kind of object recording the lease of the leader election
+type LeaderElectionLock string
+
+const (
+	// AutoDetectLeaderElectionLock records the lease on a Lease if the server serves coordination.k8s.io/v1 and the
+	// context has a RESTConfig, and on a ConfigMap otherwise. This is the default.
+	AutoDetectLeaderElectionLock LeaderElectionLock = ""
+
+	// LeaseLeaderElectionLock records the lease on a coordination.k8s.io/v1 Lease, from Kubernetes 1.14
+	LeaseLeaderElectionLock LeaderElectionLock = "Lease"
+
+	// ConfigMapLeaderElectionLock records the lease in an annotation of a ConfigMap, for older clusters
+	ConfigMapLeaderElectionLock LeaderElectionLock = "ConfigMap"
+)
+
+// LeaderElector gates the startup of controllers so only one replica of the operator reconciles at a time.
+// The lease is recorded on a Lease, or on a ConfigMap on clusters without the coordination Lease API.
+type LeaderElector struct {
+	elector  *leaderelection.LeaderElector
+	lock     *releasableLock
+	identity string
+}
+
+// releasableLock fails all operations of the elector once the lease is released. The elector of the pinned
+// client-go cannot be stopped, so this is how its renewals stop rather than taking the released lease again.
+// An elector that never acquired the lease would retry forever, so once released it is handed a phantom lease
+// instead: the lease is reported missing, its creation is not written, and the renewals that follow fail, so the
+// elector returns without running the controllers.
+type releasableLock struct {
+	resourcelock.Interface
+	mutex    sync.Mutex
+	released bool
+	acquired bool
+	phantom  bool
+}
+
+var errLeaseReleased = fmt.Errorf("the leader election lease was released")
+
+func (l *releasableLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.released {
+		if !l.acquired && !l.phantom {
+			return nil, errors.NewNotFound(schema.GroupResource{}, l.Describe())
+		}
+		return nil, errLeaseReleased
+	}
+	return l.Interface.Get()
+}
+
+func (l *releasableLock) Create(record resourcelock.LeaderElectionRecord) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.released {
+		if !l.acquired && !l.phantom {
+			l.phantom = true
+			return nil
+		}
+		return errLeaseReleased
+	}
+	if err := l.Interface.Create(record); err != nil {
+		return err
+	}
+	l.acquired = l.acquired || record.HolderIdentity == l.Identity()
+	return nil
+}
+
+func (l *releasableLock) Update(record resourcelock.LeaderElectionRecord) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.released {
+		return errLeaseReleased
+	}
+	if err := l.Interface.Update(record); err != nil {
+		return err
+	}
+	l.acquired = l.acquired || record.HolderIdentity == l.Identity()
+	return nil
+}
+
+func (l *releasableLock) RecordEvent(event string) {
+	if !l.isPhantom() {
+		l.Interface.RecordEvent(event)
+	}
+}
+
+func (l *releasableLock) isPhantom() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.phantom
+}
+
+// NewLeaderElector creates a leader elector. When the lease is acquired, run is started with a channel that is
+// closed when the lease is lost. The stopped func is called after leadership is lost and is optional.
+func NewLeaderElector(context Context, config LeaderElectionConfig, run func(stop <-chan struct{}), stopped func()) (*LeaderElector, error) {
+	if config.LockName == "" || config.LockNamespace == "" {
+		return nil, fmt.Errorf("the leader election lock name and namespace are required")
+	}
+
+	identity := config.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the hostname for the leader election identity. %+v", err)
+		}
+		identity = hostname
+	}
+
+	recorder := context.Recorder
+	if recorder == nil {
+		recorder = NewEventRecorder(context.Clientset, scheme.Scheme, config.LockName)
+	}
+
+	lockConfig := resourcelock.ResourceLockConfig{Identity: identity, EventRecorder: recorder}
+	kind, err := leaderElectionLockOf(context, config.Lock)
+	if err != nil {
+		return nil, err
+	}
+	context.logger().Info("recording the leader election lease", "lock", kind, "namespace", config.LockNamespace, "name", config.LockName)
+	lock := &releasableLock{}
+	switch kind {
+	case LeaseLeaderElectionLock:
+		client, err := newCoordinationClient(context)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the client of the leader election lease. %+v", err)
+		}
+		lock.Interface = &leaseLock{namespace: config.LockNamespace, name: config.LockName, client: client, config: lockConfig}
+	case ConfigMapLeaderElectionLock:
+		lock.Interface = &resourcelock.ConfigMapLock{
+			ConfigMapMeta: metav1.ObjectMeta{
+				Name:      config.LockName,
+				Namespace: config.LockNamespace,
+			},
+			Client:     context.Clientset.CoreV1(),
+			LockConfig: lockConfig,
+		}
+	default:
+		return nil, fmt.Errorf("unknown leader election lock %q", kind)
+	}
+
+	if stopped == nil {
+		stopped = func() {}
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: durationOrDefault(config.LeaseDuration, defaultLeaseDuration),
+		RenewDeadline: durationOrDefault(config.RenewDeadline, defaultRenewDeadline),
+		RetryPeriod:   durationOrDefault(config.RetryPeriod, defaultRetryPeriod),
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				if !lock.isPhantom() {
+					run(stop)
+				}
+			},
+			OnStoppedLeading: func() {
+				if !lock.isPhantom() {
+					stopped()
+				}
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create leader elector. %+v", err)
+	}
+
+	return &LeaderElector{elector: elector, lock: lock, identity: identity}, nil
+}
+
+// leaderElectionLockOf returns the kind of lock, detecting whether the server serves Leases if it is not set
+func leaderElectionLockOf(context Context, kind LeaderElectionLock) (LeaderElectionLock, error) {
+	if kind != AutoDetectLeaderElectionLock {
+		return kind, nil
+	}
+	if context.RESTConfig == nil {
+		return ConfigMapLeaderElectionLock, nil
+	}
+	capabilities, err := context.DetectCapabilities()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the leader election lock. %+v", err)
+	}
+	if capabilities.Leases {
+		return LeaseLeaderElectionLock, nil
+	}
+	return ConfigMapLeaderElectionLock, nil
+}
+
+// Run blocks until the lease is acquired, runs the controllers while the lease is renewed, and returns after
+// leadership is lost. Operators usually exit when Run returns so that a restarted replica can rejoin the election.
+// Run also returns once the lease is released, whether or not it was acquired.
+func (l *LeaderElector) Run() {
+	l.elector.Run()
+}
+
+// IsLeader returns whether this replica currently holds the lease
+func (l *LeaderElector) IsLeader() bool {
+	return l.elector.IsLeader() && !l.lock.isPhantom()
+}
+
+// Release gives up the lease if this replica holds it, so another replica takes over without waiting for the lease
+// to expire. The renewals of the elector stop first, so it never takes the released lease again, and Run returns
+// once the renew deadline passes, also when the lease was never acquired. Call Release after the controllers stopped, right before the operator exits.
+func (l *LeaderElector) Release() error {
+	// holding the mutex waits for a renewal in flight, and no renewal starts once released
+	l.lock.mutex.Lock()
+	defer l.lock.mutex.Unlock()
+	l.lock.released = true
+
+	record, err := l.lock.Interface.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get the leader election record. %+v", err)
+	}
+	if record.HolderIdentity != l.identity {
+		return nil
+	}
+
+	now := metav1.Now()
+	err = l.lock.Interface.Update(resourcelock.LeaderElectionRecord{
+		LeaseDurationSeconds: 1,
+		AcquireTime:          now,
+		RenewTime:            now,
+		LeaderTransitions:    record.LeaderTransitions,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release the leader election lease. %+v", err)
+	}
+	return nil
+}
+
+// RunWithLeaderElection blocks until this replica is elected and runs the controllers until leadership is lost
+func RunWithLeaderElection(context Context, config LeaderElectionConfig, run func(stop <-chan struct{})) error {
+	elector, err := NewLeaderElector(context, config, run, nil)
+	if err != nil {
+		return err
+	}
+	elector.Run()
+	return nil
+}
+
+func durationOrDefault(d, defaultDuration time.Duration) time.Duration {
+	if d == 0 {
+		return defaultDuration
+	}
+	return d
+}
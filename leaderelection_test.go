@@ -0,0 +1,192 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestLeaderElector(t *testing.T, holder string) *LeaderElector {
+	context := Context{Clientset: fake.NewSimpleClientset(), Recorder: record.NewFakeRecorder(10)}
+	elector, err := NewLeaderElector(context, LeaderElectionConfig{LockName: "lock", LockNamespace: "ns", Identity: "a",
+		LeaseDuration: 100 * time.Millisecond, RenewDeadline: 50 * time.Millisecond, RetryPeriod: 10 * time.Millisecond},
+		func(<-chan struct{}) { assert.Fail(t, "the controllers ran without the lease") }, nil)
+	assert.NoError(t, err)
+	now := metav1.Now()
+	assert.NoError(t, elector.lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: holder, LeaseDurationSeconds: 15,
+		AcquireTime: now, RenewTime: now}))
+	return elector
+}
+
+func TestLeaderElectorRun(t *testing.T) {
+	context := Context{Clientset: fake.NewSimpleClientset()}
+	_, err := NewLeaderElector(context, LeaderElectionConfig{LockName: "lock"}, func(<-chan struct{}) {}, nil)
+	assert.Error(t, err)
+
+	started := make(chan struct{})
+	elector, err := NewLeaderElector(context, LeaderElectionConfig{LockName: "lock", LockNamespace: "ns", Identity: "a",
+		LeaseDuration: 100 * time.Millisecond, RenewDeadline: 50 * time.Millisecond, RetryPeriod: 10 * time.Millisecond},
+		func(<-chan struct{}) { close(started) }, nil)
+	assert.NoError(t, err)
+	assert.False(t, elector.IsLeader())
+	go elector.Run()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the lease was not acquired")
+	}
+	assert.True(t, elector.IsLeader())
+
+	// the lease is recorded on the lock configmap
+	configMap, err := context.Clientset.CoreV1().ConfigMaps("ns").Get("lock", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, configMap.Annotations[resourcelock.LeaderElectionRecordAnnotationKey], `"holderIdentity":"a"`)
+}
+
+func TestLeaderElectorRelease(t *testing.T) {
+	elector := newTestLeaderElector(t, "a")
+	assert.NoError(t, elector.Release())
+	record, err := elector.lock.Interface.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "", record.HolderIdentity)
+	assert.Equal(t, 1, record.LeaseDurationSeconds)
+
+	// the elector can neither renew nor take the released lease again
+	_, err = elector.lock.Get()
+	assert.Equal(t, errLeaseReleased, err)
+	assert.Equal(t, errLeaseReleased, elector.lock.Update(*record))
+	assert.Equal(t, errLeaseReleased, elector.lock.Create(*record))
+}
+
+func TestLeaderElectorReleaseOfAnotherHolder(t *testing.T) {
+	elector := newTestLeaderElector(t, "b")
+	assert.NoError(t, elector.Release())
+	record, err := elector.lock.Interface.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "b", record.HolderIdentity)
+}
+
+func TestLeaderElectorRunReleasedBeforeAcquired(t *testing.T) {
+	// another replica holds the lease, so this one keeps trying to acquire it until it is released
+	elector := newTestLeaderElector(t, "b")
+	done := make(chan struct{})
+	go func() {
+		elector.Run()
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, elector.Release())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Run did not return after the lease was released")
+	}
+	assert.False(t, elector.lock.acquired)
+	assert.False(t, elector.IsLeader())
+
+	// the lease of the other replica was left alone
+	record, err := elector.lock.Interface.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "b", record.HolderIdentity)
+}
+
+func TestLeaderElectorLock(t *testing.T) {
+	config := LeaderElectionConfig{LockName: "lock", LockNamespace: "ns", Identity: "a"}
+	newLock := func(context Context, config LeaderElectionConfig) (resourcelock.Interface, error) {
+		context.Clientset = fake.NewSimpleClientset()
+		context.Recorder = record.NewFakeRecorder(10)
+		elector, err := NewLeaderElector(context, config, func(<-chan struct{}) {}, nil)
+		if err != nil {
+			return nil, err
+		}
+		return elector.lock.Interface, nil
+	}
+
+	// the lease is recorded on a Lease when the server serves them
+	lock, err := newLock(Context{}, config)
+	assert.NoError(t, err)
+	assert.IsType(t, &resourcelock.ConfigMapLock{}, lock)
+	context := Context{RESTConfig: &rest.Config{Host: "http://localhost"}, Capabilities: &Capabilities{}}
+	lock, err = newLock(context, config)
+	assert.NoError(t, err)
+	assert.IsType(t, &resourcelock.ConfigMapLock{}, lock)
+	context.Capabilities.Leases = true
+	lock, err = newLock(context, config)
+	assert.NoError(t, err)
+	assert.IsType(t, &leaseLock{}, lock)
+
+	config.Lock = ConfigMapLeaderElectionLock
+	lock, err = newLock(context, config)
+	assert.NoError(t, err)
+	assert.IsType(t, &resourcelock.ConfigMapLock{}, lock)
+	config.Lock = LeaseLeaderElectionLock
+	_, err = newLock(Context{}, config)
+	assert.Error(t, err)
+	config.Lock = "Endpoints"
+	_, err = newLock(context, config)
+	assert.Error(t, err)
+}
+
+func TestLeaseLock(t *testing.T) {
+	server := newLeaseServer(t, "lock")
+	defer server.Close()
+	recorder := record.NewFakeRecorder(10)
+	context := Context{Clientset: fake.NewSimpleClientset(), Recorder: recorder, RESTConfig: &rest.Config{Host: server.URL}}
+	elector, err := NewLeaderElector(context, LeaderElectionConfig{LockName: "lock", LockNamespace: "ns", Identity: "a",
+		Lock: LeaseLeaderElectionLock}, func(<-chan struct{}) {}, nil)
+	assert.NoError(t, err)
+	lock := elector.lock.Interface.(*leaseLock)
+	assert.Equal(t, "ns/lock", lock.Describe())
+	assert.Equal(t, "a", lock.Identity())
+
+	now := metav1.NewTime(time.Now().Truncate(time.Microsecond))
+	acquired := resourcelock.LeaderElectionRecord{HolderIdentity: "a", LeaseDurationSeconds: 15, AcquireTime: now, RenewTime: now}
+	_, err = lock.Get()
+	assert.True(t, errors.IsNotFound(err))
+	assert.Error(t, lock.Update(acquired))
+	assert.NoError(t, lock.Create(acquired))
+	got, err := lock.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", got.HolderIdentity)
+	assert.Equal(t, 15, got.LeaseDurationSeconds)
+	assert.True(t, now.Time.Equal(got.RenewTime.Time))
+
+	// an update from a stale read of the lease conflicts
+	other := &leaseLock{namespace: "ns", name: "lock", client: lock.client}
+	_, err = other.Get()
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Update(acquired))
+	assert.True(t, errors.IsConflict(other.Update(acquired)))
+
+	lock.RecordEvent("became leader")
+	assert.Equal(t, "Normal LeaderElection a became leader", <-recorder.Events)
+
+	assert.NoError(t, elector.Release())
+	got, err = other.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "", got.HolderIdentity)
+	assert.Equal(t, 1, got.LeaseDurationSeconds)
+}
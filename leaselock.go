@@ -0,0 +1,211 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	coordinationGroup   = "coordination.k8s.io"
+	coordinationVersion = "v1"
+
+	// leaseTimeFormat is the format of the MicroTime fields of a Lease
+	leaseTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// newCoordinationClient creates a client of the coordination.k8s.io/v1 API from the RESTConfig of the context. The
+// clientsets of the pinned client-go do not support the API, so its objects are read and written as JSON.
+func newCoordinationClient(context Context) (rest.Interface, error) {
+	if context.RESTConfig == nil {
+		return nil, fmt.Errorf("the context has no RESTConfig for the %s API", coordinationGroup)
+	}
+	config := *context.RESTConfig
+	return newRESTClient(&config, coordinationGroup, coordinationVersion, runtime.NewScheme())
+}
+
+// leaseLock records the leader election on a coordination.k8s.io/v1 Lease, as the Lease lock of later client-go
+// versions does
+type leaseLock struct {
+	namespace string
+	name      string
+	client    rest.Interface
+	config    resourcelock.ResourceLockConfig
+
+	// lease is the Lease read or written last, whose resourceVersion guards the next update
+	lease *lease
+}
+
+type lease struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   metav1.ObjectMeta `json:"metadata"`
+	Spec       leaseSpec         `json:"spec"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string     `json:"holderIdentity"`
+	LeaseDurationSeconds int        `json:"leaseDurationSeconds"`
+	AcquireTime          *leaseTime `json:"acquireTime,omitempty"`
+	RenewTime            *leaseTime `json:"renewTime,omitempty"`
+	LeaseTransitions     int        `json:"leaseTransitions"`
+}
+
+// leaseTime is serialized with microseconds like the MicroTime of the Lease API
+type leaseTime struct {
+	time.Time
+}
+
+func (t leaseTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(leaseTimeFormat))
+}
+
+func (t *leaseTime) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed.Local()
+	return nil
+}
+
+// Get returns the election record of the Lease. The error of a missing Lease is returned as it is, since the
+// elector creates the Lease when the error is NotFound.
+func (l *leaseLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	raw, err := l.client.Get().AbsPath(l.path(), l.name).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	if err := l.read(raw); err != nil {
+		return nil, err
+	}
+	return l.lease.Spec.record(), nil
+}
+
+// Create creates the Lease with the election record
+func (l *leaseLock) Create(record resourcelock.LeaderElectionRecord) error {
+	body, err := json.Marshal(&lease{
+		APIVersion: coordinationGroup + "/" + coordinationVersion,
+		Kind:       "Lease",
+		Metadata:   metav1.ObjectMeta{Name: l.name, Namespace: l.namespace},
+		Spec:       leaseSpecOf(record),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize lease %s. %+v", l.Describe(), err)
+	}
+	raw, err := l.client.Post().AbsPath(l.path()).SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	if err != nil {
+		return err
+	}
+	return l.read(raw)
+}
+
+// Update writes the election record to the Lease read last, failing with a conflict if it changed since
+func (l *leaseLock) Update(record resourcelock.LeaderElectionRecord) error {
+	if l.lease == nil {
+		return fmt.Errorf("lease not initialized, call get or create first")
+	}
+	updated := *l.lease
+	updated.Spec = leaseSpecOf(record)
+	body, err := json.Marshal(&updated)
+	if err != nil {
+		return fmt.Errorf("failed to serialize lease %s. %+v", l.Describe(), err)
+	}
+	raw, err := l.client.Put().AbsPath(l.path(), l.name).SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	if err != nil {
+		return err
+	}
+	return l.read(raw)
+}
+
+// RecordEvent records an event of the election on the Lease
+func (l *leaseLock) RecordEvent(s string) {
+	if l.config.EventRecorder == nil {
+		return
+	}
+	ref := &v1.ObjectReference{
+		APIVersion: coordinationGroup + "/" + coordinationVersion,
+		Kind:       "Lease",
+		Namespace:  l.namespace,
+		Name:       l.name,
+	}
+	if l.lease != nil {
+		ref.UID = l.lease.Metadata.UID
+	}
+	l.config.EventRecorder.Eventf(ref, v1.EventTypeNormal, "LeaderElection", "%v %v", l.config.Identity, s)
+}
+
+// Identity returns the identity of this replica
+func (l *leaseLock) Identity() string {
+	return l.config.Identity
+}
+
+// Describe returns the namespace and name of the Lease
+func (l *leaseLock) Describe() string {
+	return fmt.Sprintf("%v/%v", l.namespace, l.name)
+}
+
+func (l *leaseLock) path() string {
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/leases", coordinationGroup, coordinationVersion, l.namespace)
+}
+
+func (l *leaseLock) read(raw []byte) error {
+	read := &lease{}
+	if err := json.Unmarshal(raw, read); err != nil {
+		return fmt.Errorf("failed to parse lease %s. %+v", l.Describe(), err)
+	}
+	l.lease = read
+	return nil
+}
+
+func leaseSpecOf(record resourcelock.LeaderElectionRecord) leaseSpec {
+	return leaseSpec{
+		HolderIdentity:       record.HolderIdentity,
+		LeaseDurationSeconds: record.LeaseDurationSeconds,
+		AcquireTime:          &leaseTime{record.AcquireTime.Time},
+		RenewTime:            &leaseTime{record.RenewTime.Time},
+		LeaseTransitions:     record.LeaderTransitions,
+	}
+}
+
+func (s leaseSpec) record() *resourcelock.LeaderElectionRecord {
+	record := &resourcelock.LeaderElectionRecord{
+		HolderIdentity:       s.HolderIdentity,
+		LeaseDurationSeconds: s.LeaseDurationSeconds,
+		LeaderTransitions:    s.LeaseTransitions,
+	}
+	if s.AcquireTime != nil {
+		record.AcquireTime = metav1.NewTime(s.AcquireTime.Time)
+	}
+	if s.RenewTime != nil {
+		record.RenewTime = metav1.NewTime(s.RenewTime.Time)
+	}
+	return record
+}
@@ -0,0 +1,102 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Lister reads the objects of a custom resource as T from the cache of a controller, such as from its Indexer,
+// instead of from the apiserver. The objects are shared with the cache and must not be modified.
+type Lister[T runtime.Object] struct {
+	resource CustomResource
+	indexer  cache.Indexer
+}
+
+// NewTypedLister creates a lister of the custom resource reading from the indexer
+func NewTypedLister[T runtime.Object](resource CustomResource, indexer cache.Indexer) *Lister[T] {
+	return &Lister[T]{resource: resource, indexer: indexer}
+}
+
+// Get returns the object with the name, or a NotFound error if it is not in the cache. The namespace is empty for
+// cluster scoped resources.
+func (l *Lister[T]) Get(namespace, name string) (T, error) {
+	var zero T
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	obj, exists, err := l.indexer.GetByKey(key)
+	if err != nil {
+		return zero, err
+	}
+	if !exists {
+		return zero, errors.NewNotFound(schema.GroupResource{Group: l.resource.Group, Resource: l.resource.Plural}, name)
+	}
+	result, _ := typedObject[T](l.resource, obj)
+	return result, nil
+}
+
+// List returns the objects matching the selector. An empty namespace lists the objects in all namespaces.
+func (l *Lister[T]) List(namespace string, selector labels.Selector) ([]T, error) {
+	var result []T
+	appendFn := func(obj interface{}) {
+		if o, ok := typedObject[T](l.resource, obj); ok {
+			result = append(result, o)
+		}
+	}
+	if namespace == "" {
+		return result, cache.ListAll(l.indexer, selector, appendFn)
+	}
+	return result, cache.ListAllByNamespace(l.indexer, namespace, selector, appendFn)
+}
+
+// ByIndex returns the objects with the indexed value in the named index, such as the custom resources referencing
+// a Secret
+func (l *Lister[T]) ByIndex(indexName, value string) ([]T, error) {
+	objs, err := l.indexer.ByIndex(indexName, value)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, 0, len(objs))
+	for _, obj := range objs {
+		if o, ok := typedObject[T](l.resource, obj); ok {
+			result = append(result, o)
+		}
+	}
+	return result, nil
+}
+
+// TypedIndexFunc adapts a func returning the indexed values of T to a cache.IndexFunc, for the Indexers of the
+// controller options. Objects of other types are not indexed.
+func TypedIndexFunc[T runtime.Object](index func(obj T) []string) cache.IndexFunc {
+	return func(obj interface{}) ([]string, error) {
+		o, ok := obj.(T)
+		if !ok {
+			return nil, nil
+		}
+		return index(o), nil
+	}
+}
@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestTypedLister(t *testing.T) {
+	bySecret := TypedIndexFunc(func(cm *v1.ConfigMap) []string {
+		return []string{cm.Data["secret"]}
+	})
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, controllerIndexers(cache.Indexers{"secret": bySecret}))
+	indexer.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", Labels: map[string]string{"tier": "web"}}, Data: map[string]string{"secret": "tls"}})
+	indexer.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "b"}, Data: map[string]string{"secret": "db"}})
+	indexer.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "c", Labels: map[string]string{"tier": "web"}}, Data: map[string]string{"secret": "tls"}})
+	lister := NewTypedLister[*v1.ConfigMap](exampleResource, indexer)
+
+	cm, err := lister.Get("ns", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", cm.Name)
+	_, err = lister.Get("ns", "missing")
+	assert.True(t, errors.IsNotFound(err))
+
+	all, err := lister.List("", labels.Everything())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(all))
+	web, err := lister.List("ns", labels.SelectorFromSet(labels.Set{"tier": "web"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(web))
+	assert.Equal(t, "a", web[0].Name)
+
+	tls, err := lister.ByIndex("secret", "tls")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(tls))
+	_, err = lister.ByIndex("missing", "tls")
+	assert.Error(t, err)
+}
@@ -0,0 +1,362 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger is the structured, leveled logger the kit reports its internal steps to. Operators adapt their own
+// logging library to it. The keysAndValues are alternating keys and values, such as "resource", "samples".
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Error(err error, msg string, keysAndValues ...interface{})
+
+	// WithValues returns a logger that adds the keys and values to every message
+	WithValues(keysAndValues ...interface{}) Logger
+}
+
+// LogLevel is the minimum level of the messages written by the standard logger
+type LogLevel int
+
+const (
+	// LogLevelDebug writes all messages
+	LogLevelDebug LogLevel = iota
+	// LogLevelInfo writes info and error messages
+	LogLevelInfo
+	// LogLevelError writes only error messages
+	LogLevelError
+)
+
+// LogFormat is the encoding of the messages written by the standard logger
+type LogFormat int
+
+const (
+	// LogFormatKeyValue writes a line of quoted key=value pairs per message
+	LogFormatKeyValue LogFormat = iota
+	// LogFormatJSON writes a JSON object per message, for log collectors
+	LogFormatJSON
+	// LogFormatConsole writes a line with the time, level, and message in columns followed by the values, for humans
+	LogFormatConsole
+)
+
+// LogComponentKey is the key of the value naming the component of a logger, such as
+// logger.WithValues(LogComponentKey, "webhook"). Components can have their own level in the LogConfig.
+const LogComponentKey = "component"
+
+// LogConfig configures the messages written by the loggers of a LogSink
+type LogConfig struct {
+	Format LogFormat
+	Level  LogLevel
+
+	// ComponentLevels overrides the level of the loggers of the components
+	ComponentLevels map[string]LogLevel
+
+	// Sampling is optional and limits how often the same error is logged
+	Sampling *LogSampling
+}
+
+// LogSampling writes the first Initial occurrences of the same error message in each Interval, then only every
+// Thereafter-th occurrence. A Thereafter of zero drops all occurrences after the first Initial.
+type LogSampling struct {
+	Initial    int
+	Thereafter int
+	Interval   time.Duration
+}
+
+var defaultLogger = NewStdLogger(os.Stderr, LogLevelInfo)
+
+func (c Context) logger() Logger {
+	if c.Logger == nil {
+		return defaultLogger
+	}
+	return c.Logger
+}
+
+// ParseLogLevel parses a level named "debug", "info", or "error"
+func ParseLogLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LogLevelDebug, nil
+	case "info":
+		return LogLevelInfo, nil
+	case "error":
+		return LogLevelError, nil
+	}
+	return LogLevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// ParseLogFormat parses a format named "keyvalue", "json", or "console"
+func ParseLogFormat(name string) (LogFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "keyvalue", "":
+		return LogFormatKeyValue, nil
+	case "json":
+		return LogFormatJSON, nil
+	case "console":
+		return LogFormatConsole, nil
+	}
+	return LogFormatKeyValue, fmt.Errorf("unknown log format %q", name)
+}
+
+// ParseLogConfig reads the config from the data of a ConfigMap, so it can be changed while the operator runs.
+// The keys are "format", "level", "level.<component>", "sampling.initial", "sampling.thereafter", and
+// "sampling.interval" as a duration such as "1s". Missing keys keep their defaults.
+func ParseLogConfig(data map[string]string) (LogConfig, error) {
+	config := LogConfig{Level: LogLevelInfo}
+	var err error
+	if config.Format, err = ParseLogFormat(data["format"]); err != nil {
+		return config, err
+	}
+	if level, ok := data["level"]; ok {
+		if config.Level, err = ParseLogLevel(level); err != nil {
+			return config, err
+		}
+	}
+
+	sampling := LogSampling{Interval: time.Second}
+	sampled := false
+	for key, value := range data {
+		switch {
+		case strings.HasPrefix(key, "level."):
+			level, err := ParseLogLevel(value)
+			if err != nil {
+				return config, fmt.Errorf("invalid %s. %+v", key, err)
+			}
+			if config.ComponentLevels == nil {
+				config.ComponentLevels = map[string]LogLevel{}
+			}
+			config.ComponentLevels[strings.TrimPrefix(key, "level.")] = level
+		case key == "sampling.initial" || key == "sampling.thereafter":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return config, fmt.Errorf("invalid %s %q", key, value)
+			}
+			if key == "sampling.initial" {
+				sampling.Initial = n
+			} else {
+				sampling.Thereafter = n
+			}
+			sampled = true
+		case key == "sampling.interval":
+			if sampling.Interval, err = time.ParseDuration(value); err != nil || sampling.Interval <= 0 {
+				return config, fmt.Errorf("invalid %s %q", key, value)
+			}
+			sampled = true
+		}
+	}
+	if sampled {
+		config.Sampling = &sampling
+	}
+	return config, nil
+}
+
+// LogSink writes the messages of its loggers with a config that can be changed while they are in use
+type LogSink struct {
+	lock   sync.Mutex
+	out    io.Writer
+	config LogConfig
+
+	// occurrences of each sampled error in the current sampling interval
+	sampleStart time.Time
+	samples     map[string]int
+}
+
+// NewLogSink creates a sink writing to out
+func NewLogSink(out io.Writer, config LogConfig) *LogSink {
+	return &LogSink{out: out, config: config, samples: map[string]int{}}
+}
+
+// SetConfig changes the config of all loggers of the sink
+func (s *LogSink) SetConfig(config LogConfig) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.config = config
+	s.samples = map[string]int{}
+}
+
+// Logger returns a logger writing to the sink
+func (s *LogSink) Logger() Logger {
+	return &stdLogger{sink: s}
+}
+
+// stdLogger writes messages to the sink in the format of its config
+type stdLogger struct {
+	sink   *LogSink
+	values []interface{}
+}
+
+// NewStdLogger creates a logger writing messages at or above the level to out, one key=value line per message.
+// It is the logger used by the kit when the context has none. Use a LogSink for other formats and sampling.
+func NewStdLogger(out io.Writer, level LogLevel) Logger {
+	return NewLogSink(out, LogConfig{Level: level}).Logger()
+}
+
+func (l *stdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.write(LogLevelDebug, "debug", msg, nil, keysAndValues)
+}
+
+func (l *stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.write(LogLevelInfo, "info", msg, nil, keysAndValues)
+}
+
+func (l *stdLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.write(LogLevelError, "error", msg, err, keysAndValues)
+}
+
+func (l *stdLogger) WithValues(keysAndValues ...interface{}) Logger {
+	values := append(append([]interface{}{}, l.values...), keysAndValues...)
+	return &stdLogger{sink: l.sink, values: values}
+}
+
+// component returns the last component set on the logger
+func (l *stdLogger) component() string {
+	component := ""
+	for i := 0; i+1 < len(l.values); i += 2 {
+		if l.values[i] == LogComponentKey {
+			component = fmt.Sprint(l.values[i+1])
+		}
+	}
+	return component
+}
+
+func (l *stdLogger) write(level LogLevel, levelName, msg string, err error, keysAndValues []interface{}) {
+	now := time.Now()
+	l.sink.lock.Lock()
+	defer l.sink.lock.Unlock()
+	config := l.sink.config
+
+	minLevel := config.Level
+	if componentLevel, ok := config.ComponentLevels[l.component()]; ok {
+		minLevel = componentLevel
+	}
+	if level < minLevel {
+		return
+	}
+	if err != nil && config.Sampling != nil && !l.sink.sample(now, msg+"\x00"+err.Error()) {
+		return
+	}
+
+	var line bytes.Buffer
+	switch config.Format {
+	case LogFormatJSON:
+		encodeJSON(&line, now, levelName, msg, err, l.values, keysAndValues)
+	case LogFormatConsole:
+		encodeConsole(&line, now, levelName, msg, err, l.values, keysAndValues)
+	default:
+		encodeKeyValue(&line, now, levelName, msg, err, l.values, keysAndValues)
+	}
+	line.WriteByte('\n')
+	l.sink.out.Write(line.Bytes())
+}
+
+// sample returns whether the occurrence of the error is written. The lock of the sink must be held.
+func (s *LogSink) sample(now time.Time, key string) bool {
+	sampling := s.config.Sampling
+	if now.Sub(s.sampleStart) >= sampling.Interval {
+		s.sampleStart = now
+		s.samples = map[string]int{}
+	}
+	s.samples[key]++
+	n := s.samples[key]
+	if n <= sampling.Initial {
+		return true
+	}
+	return sampling.Thereafter > 0 && (n-sampling.Initial)%sampling.Thereafter == 0
+}
+
+func encodeKeyValue(line *bytes.Buffer, now time.Time, levelName, msg string, err error, values ...[]interface{}) {
+	fmt.Fprintf(line, "time=%s level=%s msg=%q", now.UTC().Format(time.RFC3339), levelName, msg)
+	if err != nil {
+		fmt.Fprintf(line, " error=%q", err.Error())
+	}
+	for _, keysAndValues := range values {
+		forEachKeyValue(keysAndValues, func(key string, value interface{}) {
+			fmt.Fprintf(line, " %s=%q", key, fmt.Sprint(value))
+		})
+	}
+}
+
+func encodeConsole(line *bytes.Buffer, now time.Time, levelName, msg string, err error, values ...[]interface{}) {
+	fmt.Fprintf(line, "%s\t%-5s\t%s", now.Format("15:04:05.000"), strings.ToUpper(levelName), msg)
+	if err != nil {
+		fmt.Fprintf(line, "\terror: %s", err.Error())
+	}
+	for _, keysAndValues := range values {
+		forEachKeyValue(keysAndValues, func(key string, value interface{}) {
+			fmt.Fprintf(line, "\t%s=%v", key, value)
+		})
+	}
+}
+
+func encodeJSON(line *bytes.Buffer, now time.Time, levelName, msg string, err error, values ...[]interface{}) {
+	fields := [][2]interface{}{{"time", now.UTC().Format(time.RFC3339Nano)}, {"level", levelName}, {"msg", msg}}
+	if err != nil {
+		fields = append(fields, [2]interface{}{"error", err.Error()})
+	}
+	for _, keysAndValues := range values {
+		forEachKeyValue(keysAndValues, func(key string, value interface{}) {
+			fields = append(fields, [2]interface{}{key, value})
+		})
+	}
+
+	line.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, _ := json.Marshal(field[0])
+		value, err := json.Marshal(jsonValue(field[1]))
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(field[1]))
+		}
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(value)
+	}
+	line.WriteByte('}')
+}
+
+// jsonValue keeps the values JSON encodes natively and formats the others, such as errors and durations
+func jsonValue(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, string, bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		return value
+	}
+	return fmt.Sprint(value)
+}
+
+// forEachKeyValue calls f with each key and value. Values of types with a registered redactor are redacted.
+func forEachKeyValue(keysAndValues []interface{}, f func(key string, value interface{})) {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "(missing)"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		f(fmt.Sprint(keysAndValues[i]), Redact(value))
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewStdLogger(&out, LogLevelInfo).WithValues("resource", "samples")
+
+	logger.Debug("hidden")
+	assert.Equal(t, 0, out.Len())
+
+	logger.Info("created", "outcome", OutcomeCreated)
+	assert.True(t, strings.HasSuffix(out.String(), ` level=info msg="created" resource="samples" outcome="Created"`+"\n"), out.String())
+
+	out.Reset()
+	logger.Error(fmt.Errorf("boom"), "failed", "key")
+	assert.True(t, strings.HasSuffix(out.String(), ` level=error msg="failed" error="boom" resource="samples" key="(missing)"`+"\n"), out.String())
+}
+
+func TestLogSinkFormats(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewLogSink(&out, LogConfig{Format: LogFormatJSON, Level: LogLevelInfo})
+	logger := sink.Logger().WithValues("resource", "samples")
+
+	logger.Error(fmt.Errorf("boom"), "failed", "attempt", 2, "key")
+	assert.True(t, strings.HasSuffix(out.String(), `"level":"error","msg":"failed","error":"boom","resource":"samples","attempt":2,"key":"(missing)"}`+"\n"), out.String())
+
+	out.Reset()
+	sink.SetConfig(LogConfig{Format: LogFormatConsole, Level: LogLevelDebug})
+	logger.Debug("waiting", "duration", "1s")
+	assert.True(t, strings.HasSuffix(out.String(), "\tDEBUG\twaiting\tresource=samples\tduration=1s\n"), out.String())
+}
+
+func TestLogSinkComponentLevels(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewLogSink(&out, LogConfig{Level: LogLevelError, ComponentLevels: map[string]LogLevel{"webhook": LogLevelDebug}})
+	sink.Logger().Info("hidden")
+	assert.Equal(t, 0, out.Len())
+	sink.Logger().WithValues(LogComponentKey, "webhook").Debug("shown")
+	assert.Contains(t, out.String(), `msg="shown" component="webhook"`)
+}
+
+func TestLogSinkSampling(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewLogSink(&out, LogConfig{Sampling: &LogSampling{Initial: 2, Thereafter: 3, Interval: time.Hour}})
+	logger := sink.Logger()
+	for i := 0; i < 8; i++ {
+		logger.Error(fmt.Errorf("boom"), "failed")
+		logger.Info("not sampled")
+	}
+	logger.Error(fmt.Errorf("other"), "failed")
+
+	// the 1st, 2nd, 5th, and 8th occurrence of the same error are written
+	assert.Equal(t, 4, strings.Count(out.String(), `error="boom"`))
+	assert.Equal(t, 8, strings.Count(out.String(), "not sampled"))
+	assert.Equal(t, 1, strings.Count(out.String(), `error="other"`))
+}
+
+func TestParseLogConfig(t *testing.T) {
+	config, err := ParseLogConfig(map[string]string{
+		"format":             "json",
+		"level":              "error",
+		"level.controller":   "debug",
+		"sampling.initial":   "5",
+		"sampling.interval":  "10s",
+		"unrelated.settings": "ignored",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, LogFormatJSON, config.Format)
+	assert.Equal(t, LogLevelError, config.Level)
+	assert.Equal(t, map[string]LogLevel{"controller": LogLevelDebug}, config.ComponentLevels)
+	assert.Equal(t, &LogSampling{Initial: 5, Interval: 10 * time.Second}, config.Sampling)
+
+	config, err = ParseLogConfig(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, LogConfig{Level: LogLevelInfo}, config)
+
+	_, err = ParseLogConfig(map[string]string{"level.webhook": "verbose"})
+	assert.Error(t, err)
+	_, err = ParseLogConfig(map[string]string{"sampling.initial": "-1"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,314 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// Runnable is a component run by a Manager until the stop channel is closed, such as an Operator
+type Runnable interface {
+	Run(stopCh <-chan struct{}) error
+}
+
+// RunnableFunc adapts a function to the Runnable interface
+type RunnableFunc func(stopCh <-chan struct{}) error
+
+// Run calls f(stopCh)
+func (f RunnableFunc) Run(stopCh <-chan struct{}) error {
+	return f(stopCh)
+}
+
+// ManagerOptions configures a manager
+type ManagerOptions struct {
+	// ShutdownTimeout bounds the time spent stopping the servers and draining the reconciles in flight. Defaults
+	// to 30s, which fits in the default termination grace period of pods.
+	ShutdownTimeout time.Duration
+
+	// Signals that start the shutdown. Defaults to SIGTERM and SIGINT.
+	Signals []os.Signal
+
+	// LeaderElection is optional. When set, the controllers and runnables only run while this replica holds the
+	// lease, and the lease is released on shutdown so another replica takes over right away.
+	LeaderElection *LeaderElectionConfig
+
+	// WarmStandby fills the caches of the controllers while waiting to be elected, so the controllers resume
+	// reconciling within seconds of a failover instead of listing all resources first. Standby replicas then hold
+	// the resources in memory like the leader.
+	WarmStandby bool
+
+	// Scheme has the types of the custom resources of the controllers created with NewController
+	Scheme *runtime.Scheme
+}
+
+// Manager owns the controllers, runnables, and servers of an operator and shuts them down gracefully on a signal,
+// so that restarts during upgrades neither cut reconciles short nor wait for the leader lease to expire
+type Manager struct {
+	context     Context
+	options     ManagerOptions
+	controllers []operatorController
+	runnables   []Runnable
+	servers     []*http.Server
+	informers   *SharedInformers
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// NewManager creates a manager with the context
+func NewManager(context Context, options ManagerOptions) *Manager {
+	return &Manager{
+		context:   context,
+		options:   options,
+		informers: NewSharedInformers(context, options.Scheme),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Informers returns the informers and clients shared by the controllers created with NewController
+func (m *Manager) Informers() *SharedInformers {
+	return m.informers
+}
+
+// NewController creates a controller sharing its informer cache and client with the other controllers of the
+// manager, and registers it to run with the number of workers
+func (m *Manager) NewController(resource CustomResource, namespace string, objType runtime.Object, reconciler Reconciler, workers int,
+	options ControllerOptions) (*Controller, error) {
+
+	controller, err := m.informers.NewController(resource, namespace, objType, reconciler, options)
+	if err != nil {
+		return nil, err
+	}
+	m.AddController(controller, workers)
+	return controller, nil
+}
+
+// AddController registers a controller to run with the number of workers. Its reconciles in flight are drained on
+// shutdown.
+func (m *Manager) AddController(controller *Controller, workers int) {
+	m.controllers = append(m.controllers, operatorController{controller: controller, workers: workers})
+}
+
+// AddRunnable registers a component to run with the controllers
+func (m *Manager) AddRunnable(runnable Runnable) {
+	m.runnables = append(m.runnables, runnable)
+}
+
+// AddServer registers a server, such as the health, metrics, or webhook server. Servers run on all replicas,
+// leaders or not, and finish serving their requests in flight on shutdown.
+func (m *Manager) AddServer(server *http.Server) {
+	m.servers = append(m.servers, server)
+}
+
+// Stop starts the shutdown as if a signal was received
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+}
+
+// Run starts the servers, and the controllers and runnables, once elected if leader election is configured. The call
+// blocks until a signal is received, Stop is called, or a component fails, and returns after the shutdown. A
+// shutdown that does not finish before the deadline returns an error.
+func (m *Manager) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, m.signals()...)
+	defer signal.Stop(signals)
+
+	errCh := make(chan error, len(m.servers)+len(m.controllers)+len(m.runnables)+1)
+	for _, server := range m.servers {
+		go func(server *http.Server) {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errCh <- fmt.Errorf("failed to serve on %s. %+v", server.Addr, err)
+			}
+		}(server)
+	}
+
+	group := &runGroup{stopCh: make(chan struct{})}
+	var elector *LeaderElector
+	if m.options.LeaderElection != nil {
+		var err error
+		elector, err = NewLeaderElector(m.context, *m.options.LeaderElection, func(leading <-chan struct{}) {
+			m.start(group, leading, errCh)
+		}, nil)
+		if err != nil {
+			return err
+		}
+		if m.options.WarmStandby {
+			for _, c := range m.controllers {
+				c.controller.WarmCache(group.stopCh)
+			}
+		}
+		go func() {
+			elector.Run()
+			errCh <- fmt.Errorf("lost the leader election lease")
+		}()
+	} else {
+		m.start(group, nil, errCh)
+	}
+
+	var err error
+	select {
+	case sig := <-signals:
+		m.context.logger().Info("shutting down", "signal", sig.String())
+	case <-m.stopCh:
+		m.context.logger().Info("shutting down")
+	case err = <-errCh:
+		m.context.logger().Error(err, "shutting down")
+	}
+	return m.shutdown(group, elector, err)
+}
+
+// start runs the controllers and runnables until the group is stopped or, with leader election, the lease is lost
+func (m *Manager) start(group *runGroup, leading <-chan struct{}, errCh chan<- error) {
+	var stopCh <-chan struct{} = group.stopCh
+	if leading != nil {
+		stopCh = anyClosed(group.stopCh, leading)
+	}
+	for _, c := range m.controllers {
+		c := c
+		group.Go(func() {
+			if err := c.controller.Run(c.workers, stopCh); err != nil {
+				errCh <- fmt.Errorf("controller of %s failed. %+v", c.controller.resource.Name, err)
+			}
+		})
+	}
+	for _, runnable := range m.runnables {
+		runnable := runnable
+		group.Go(func() {
+			if err := runnable.Run(stopCh); err != nil {
+				errCh <- fmt.Errorf("runnable failed. %+v", err)
+			}
+		})
+	}
+}
+
+// shutdown stops taking new work, drains the servers and reconciles in flight until the deadline, and releases the
+// leader lease
+func (m *Manager) shutdown(group *runGroup, elector *LeaderElector, cause error) error {
+	deadline := time.Now().Add(durationOrDefault(m.options.ShutdownTimeout, defaultShutdownTimeout))
+	var errs []error
+	if cause != nil {
+		errs = append(errs, cause)
+	}
+
+	group.Stop()
+	ctx, cancel := gocontext.WithDeadline(gocontext.Background(), deadline)
+	defer cancel()
+	for _, server := range m.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down the server on %s. %+v", server.Addr, err))
+			server.Close()
+		}
+	}
+
+	if !group.Wait(time.Until(deadline)) {
+		errs = append(errs, fmt.Errorf("components still running at the shutdown deadline"))
+	} else {
+		for _, c := range m.controllers {
+			if !c.controller.Drain(time.Until(deadline)) {
+				errs = append(errs, fmt.Errorf("reconciles of %s still running at the shutdown deadline", c.controller.resource.Name))
+			}
+		}
+	}
+
+	if elector != nil {
+		if err := elector.Release(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errorsUtil.NewAggregate(errs)
+}
+
+func (m *Manager) signals() []os.Signal {
+	if len(m.options.Signals) > 0 {
+		return m.options.Signals
+	}
+	return []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+}
+
+// runGroup tracks the goroutines running components. No goroutine is started once the group is stopped, so that
+// a lease acquired during the shutdown does not start the controllers again.
+type runGroup struct {
+	lock    sync.Mutex
+	stopped bool
+	stopCh  chan struct{}
+	running sync.WaitGroup
+}
+
+// Go runs f in a goroutine unless the group is stopped
+func (g *runGroup) Go(f func()) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.stopped {
+		return
+	}
+	g.running.Add(1)
+	go func() {
+		defer g.running.Done()
+		f()
+	}()
+}
+
+// Stop closes the stop channel of the group
+func (g *runGroup) Stop() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if !g.stopped {
+		g.stopped = true
+		close(g.stopCh)
+	}
+}
+
+// Wait waits up to the timeout for the goroutines of the group to return, and returns whether they did
+func (g *runGroup) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		g.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// anyClosed returns a channel closed when either channel is closed
+func anyClosed(a, b <-chan struct{}) <-chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		select {
+		case <-a:
+		case <-b:
+		}
+	}()
+	return closed
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerShutdown(t *testing.T) {
+	m := NewManager(Context{}, ManagerOptions{ShutdownTimeout: time.Second})
+	started := make(chan struct{})
+	stopped := false
+	m.AddRunnable(RunnableFunc(func(stopCh <-chan struct{}) error {
+		close(started)
+		<-stopCh
+		stopped = true
+		return nil
+	}))
+
+	go func() {
+		<-started
+		m.Stop()
+	}()
+	assert.NoError(t, m.Run())
+	assert.True(t, stopped)
+}
+
+func TestManagerShutdownDeadline(t *testing.T) {
+	m := NewManager(Context{}, ManagerOptions{ShutdownTimeout: 10 * time.Millisecond})
+	block := make(chan struct{})
+	defer close(block)
+	m.AddRunnable(RunnableFunc(func(stopCh <-chan struct{}) error {
+		<-block
+		return nil
+	}))
+
+	m.Stop()
+	err := m.Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "shutdown deadline")
+}
+
+func TestRunGroupStopped(t *testing.T) {
+	group := &runGroup{stopCh: make(chan struct{})}
+	group.Stop()
+	group.Stop()
+	ran := false
+	group.Go(func() { ran = true })
+	assert.True(t, group.Wait(time.Second))
+	assert.False(t, ran)
+
+	a, b := make(chan struct{}), make(chan struct{})
+	closed := anyClosed(a, b)
+	close(b)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestWarmCache(t *testing.T) {
+	started := make(chan (<-chan struct{}), 2)
+	c := &Controller{runInformer: func(stopCh <-chan struct{}) { started <- stopCh }}
+
+	warmStop := make(chan struct{})
+	c.WarmCache(warmStop)
+	c.WarmCache(make(chan struct{}))
+	c.startInformer(make(chan struct{}))
+	assert.Equal(t, (<-chan struct{})(warmStop), <-started)
+	select {
+	case <-started:
+		t.Fatal("informer started twice")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestControllerDrain(t *testing.T) {
+	c := &Controller{}
+	c.workers.Add(1)
+	assert.False(t, c.Drain(10*time.Millisecond))
+	c.workers.Done()
+	assert.True(t, c.Drain(time.Second))
+}
@@ -0,0 +1,439 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+// Metrics records Prometheus metrics for the custom resource setup and the controllers of an operator.
+// All methods are safe to call on a nil *Metrics, in which case nothing is recorded.
+type Metrics struct {
+	registry   *prometheus.Registry
+	registerer prometheus.Registerer
+	gatherer   prometheus.Gatherer
+	namespace  string
+
+	crdCreations      *prometheus.CounterVec
+	crdEstablishment  *prometheus.HistogramVec
+	reconciles        *prometheus.CounterVec
+	reconcileDuration *prometheus.HistogramVec
+	workqueueDepth    *prometheus.GaugeVec
+	watchRestarts     *prometheus.CounterVec
+	crdTerminating    *prometheus.GaugeVec
+	buildInfo         *prometheus.GaugeVec
+	operandInfo       *prometheus.GaugeVec
+	healthScore       *prometheus.GaugeVec
+	admissionRequests *prometheus.CounterVec
+	resourceCondition *prometheus.GaugeVec
+	deprecatedWrites  *prometheus.CounterVec
+	crdRepairs        *prometheus.CounterVec
+	reconcileSteps    *prometheus.CounterVec
+	stepDuration      *prometheus.HistogramVec
+	childDrift        *prometheus.CounterVec
+	queueAdds         *prometheus.CounterVec
+	queueRetries      *prometheus.CounterVec
+	watchEvents       *prometheus.CounterVec
+
+	// queues are the instrumented work queues of the controllers, read by the queue collector
+	queues     []*instrumentedQueue
+	queuesLock sync.Mutex
+
+	// auditUsers are the user labels of the admission metrics, capped to keep the cardinality bounded
+	auditUsers     map[string]bool
+	auditUsersLock sync.Mutex
+
+	// conditionTypes are the condition types recorded per resource and key, so that the series of the types a
+	// resource no longer has are deleted
+	conditionTypes     map[conditionsKey]map[string]bool
+	conditionTypesLock sync.Mutex
+
+	// conditionControllers are the controllers recording the conditions of their resources
+	conditionControllers     map[*Controller]bool
+	conditionControllersLock sync.Mutex
+}
+
+type conditionsKey struct {
+	resource string
+	key      string
+}
+
+// NewMetrics creates the metrics in a new registry. The namespace prefixes all metric names, for example the
+// name of the operator.
+func NewMetrics(namespace string) *Metrics {
+	return NewMetricsWithRegistry(namespace, prometheus.NewRegistry())
+}
+
+// NewMetricsWithRegistry creates the metrics in the registry, such as the registry the operator already serves
+func NewMetricsWithRegistry(namespace string, registry *prometheus.Registry) *Metrics {
+	return NewMetricsWithRegisterer(namespace, registry, registry)
+}
+
+// NewMetricsWithRegisterer creates the metrics in the registerer and serves them from the gatherer, such as
+// prometheus.DefaultRegisterer and prometheus.DefaultGatherer
+func NewMetricsWithRegisterer(namespace string, registerer prometheus.Registerer, gatherer prometheus.Gatherer) *Metrics {
+	registry, _ := registerer.(*prometheus.Registry)
+	m := &Metrics{
+		registry:   registry,
+		registerer: registerer,
+		gatherer:   gatherer,
+		namespace:  namespace,
+		crdCreations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "crd_creation_attempts_total",
+			Help:      "Number of attempts to create a custom resource definition by outcome.",
+		}, []string{"resource", "outcome"}),
+		crdEstablishment: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "crd_establishment_wait_seconds",
+			Help:      "Time spent waiting for a custom resource definition to be established.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+		}, []string{"resource", "result"}),
+		reconciles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconcile_total",
+			Help:      "Number of reconciles by result.",
+		}, []string{"resource", "result"}),
+		reconcileDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "reconcile_duration_seconds",
+			Help:      "Time spent reconciling a custom resource.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"resource"}),
+		workqueueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "workqueue_depth",
+			Help:      "Number of keys waiting in the work queue of a controller.",
+		}, []string{"resource"}),
+		watchRestarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "watch_restarts_total",
+			Help:      "Number of times the watch of a custom resource was restarted.",
+		}, []string{"resource"}),
+		crdTerminating: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "crd_terminating",
+			Help:      "Whether the controller of a custom resource is paused because its CRD is deleted.",
+		}, []string{"resource"}),
+		buildInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "build_info",
+			Help:      "Always 1, labeled with the version, git commit, and Go version of the operator build.",
+		}, []string{"version", "git_commit", "go_version"}),
+		operandInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "operand_info",
+			Help:      "Always 1, labeled with the versions of the operand catalog of the operator build.",
+		}, []string{"operand", "version"}),
+		healthScore: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "health_score",
+			Help:      "Rolling health score of a custom resource between 0 and 1 from its reconcile outcomes and probes.",
+		}, []string{"resource", "namespace", "name"}),
+		admissionRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "admission_requests_total",
+			Help:      "Number of admission requests by resource, operation, requesting user, and decision.",
+		}, []string{"resource", "operation", "user", "allowed"}),
+		resourceCondition: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "resource_condition",
+			Help:      "Whether a custom resource has the condition of the type with the status.",
+		}, []string{"resource", "namespace", "name", "type", "status"}),
+		deprecatedWrites: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deprecated_status_field_writes_total",
+			Help:      "Number of writes of deprecated status fields by clients other than the operator.",
+		}, []string{"resource", "field", "user"}),
+		crdRepairs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "crd_repairs_total",
+			Help:      "Number of CRDs deleted or changed out-of-band that were recreated or repaired.",
+		}, []string{"resource", "action"}),
+		reconcileSteps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconcile_step_total",
+			Help:      "Number of runs of the steps of reconcile pipelines by result.",
+		}, []string{"resource", "step", "result"}),
+		stepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "reconcile_step_duration_seconds",
+			Help:      "Time spent in a step of a reconcile pipeline, including its retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"resource", "step"}),
+		childDrift: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "child_drift_total",
+			Help:      "Number of children found drifted from their desired state by kind and whether they were repaired.",
+		}, []string{"resource", "kind", "action"}),
+		queueAdds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "workqueue_adds_total",
+			Help:      "Number of keys added to the work queue of a controller by kind and namespace.",
+		}, []string{"kind", "namespace"}),
+		queueRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "workqueue_retries_total",
+			Help:      "Number of keys requeued after a failed reconcile by kind and namespace.",
+		}, []string{"kind", "namespace"}),
+		watchEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "watch_events_total",
+			Help:      "Number of watch events of custom resources by kind, namespace, and event.",
+		}, []string{"kind", "namespace", "event"}),
+		auditUsers:           map[string]bool{},
+		conditionTypes:       map[conditionsKey]map[string]bool{},
+		conditionControllers: map[*Controller]bool{},
+	}
+
+	m.registerer.MustRegister(
+		m.crdCreations,
+		m.crdEstablishment,
+		m.reconciles,
+		m.reconcileDuration,
+		m.workqueueDepth,
+		m.watchRestarts,
+		m.crdTerminating,
+		m.buildInfo,
+		m.operandInfo,
+		m.healthScore,
+		m.admissionRequests,
+		m.resourceCondition,
+		m.deprecatedWrites,
+		m.crdRepairs,
+		m.reconcileSteps,
+		m.stepDuration,
+		m.childDrift,
+		m.queueAdds,
+		m.queueRetries,
+		m.watchEvents,
+		newQueueCollector(m, namespace),
+	)
+	return m
+}
+
+// Registry returns the registry holding the metrics so operators can register their own collectors with it.
+// It is nil if the metrics were created with a registerer that is not a registry.
+func (m *Metrics) Registry() *prometheus.Registry {
+	if m == nil {
+		return nil
+	}
+	return m.registry
+}
+
+// Registerer returns the registerer holding the metrics
+func (m *Metrics) Registerer() prometheus.Registerer {
+	if m == nil {
+		return nil
+	}
+	return m.registerer
+}
+
+// Gatherer returns the gatherer serving the metrics
+func (m *Metrics) Gatherer() prometheus.Gatherer {
+	if m == nil {
+		return nil
+	}
+	return m.gatherer
+}
+
+// Handler returns an http.Handler serving the metrics in the Prometheus format, usually mounted at /metrics
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
+
+// ObserveReconcile records the result and duration of a reconcile of the named resource
+func (m *Metrics) ObserveReconcile(resource string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.reconciles.WithLabelValues(resource, resultLabel(err)).Inc()
+	m.reconcileDuration.WithLabelValues(resource).Observe(duration.Seconds())
+}
+
+// SetWorkqueueDepth records the number of keys waiting in the work queue of the named resource
+func (m *Metrics) SetWorkqueueDepth(resource string, depth int) {
+	if m == nil {
+		return
+	}
+	m.workqueueDepth.WithLabelValues(resource).Set(float64(depth))
+}
+
+// IncWatchRestarts records that the watch of the named resource was restarted
+func (m *Metrics) IncWatchRestarts(resource string) {
+	if m == nil {
+		return
+	}
+	m.watchRestarts.WithLabelValues(resource).Inc()
+}
+
+// SetBuildInfo records the build of the operator and its operand versions
+func (m *Metrics) SetBuildInfo(info *BuildInfo) {
+	if m == nil || info == nil {
+		return
+	}
+	m.buildInfo.Reset()
+	m.buildInfo.WithLabelValues(info.Version, info.GitCommit, info.GoVersion).Set(1)
+	m.operandInfo.Reset()
+	for operand, version := range info.Operands {
+		m.operandInfo.WithLabelValues(operand, version).Set(1)
+	}
+}
+
+// SetConditions records the conditions of the custom resource with the key. Each condition has a single series for
+// its current status, so alerts on a status resolve when the status changes. The series of the statuses and types
+// the resource no longer has are deleted.
+func (m *Metrics) SetConditions(resource, key string, conditions []Condition) {
+	if m == nil {
+		return
+	}
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	types := map[string]bool{}
+	for _, condition := range conditions {
+		types[condition.Type] = true
+		for _, status := range []v1.ConditionStatus{v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown} {
+			if condition.Status == status {
+				m.resourceCondition.WithLabelValues(resource, namespace, name, condition.Type, string(status)).Set(1)
+				continue
+			}
+			m.resourceCondition.DeleteLabelValues(resource, namespace, name, condition.Type, string(status))
+		}
+	}
+
+	m.conditionTypesLock.Lock()
+	defer m.conditionTypesLock.Unlock()
+	for t := range m.conditionTypes[conditionsKey{resource, key}] {
+		if !types[t] {
+			m.deleteConditionType(resource, namespace, name, t)
+		}
+	}
+	m.conditionTypes[conditionsKey{resource, key}] = types
+}
+
+// DeleteConditions deletes the condition series of the deleted custom resource with the key. Controllers delete
+// the series of their resources when the resources are deleted.
+func (m *Metrics) DeleteConditions(resource, key string) {
+	if m == nil {
+		return
+	}
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	m.conditionTypesLock.Lock()
+	defer m.conditionTypesLock.Unlock()
+	for t := range m.conditionTypes[conditionsKey{resource, key}] {
+		m.deleteConditionType(resource, namespace, name, t)
+	}
+	delete(m.conditionTypes, conditionsKey{resource, key})
+}
+
+func (m *Metrics) deleteConditionType(resource, namespace, name, conditionType string) {
+	for _, status := range []v1.ConditionStatus{v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown} {
+		m.resourceCondition.DeleteLabelValues(resource, namespace, name, conditionType, string(status))
+	}
+}
+
+func (m *Metrics) observeDeprecatedStatusWrite(resource, field string, user AdmissionUserInfo) {
+	if m == nil {
+		return
+	}
+	m.deprecatedWrites.WithLabelValues(resource, field, m.auditUser(user)).Inc()
+}
+
+func (m *Metrics) observeReconcileStep(resource, step string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.reconcileSteps.WithLabelValues(resource, step, resultLabel(err)).Inc()
+	m.stepDuration.WithLabelValues(resource, step).Observe(duration.Seconds())
+}
+
+func (m *Metrics) observeDrift(resource, kind, action string) {
+	if m == nil {
+		return
+	}
+	m.childDrift.WithLabelValues(resource, kind, action).Inc()
+}
+
+func (m *Metrics) observeCRDRepair(resource, action string) {
+	if m == nil {
+		return
+	}
+	m.crdRepairs.WithLabelValues(resource, action).Inc()
+}
+
+func (m *Metrics) observeCRDCreation(resource string, outcome InstallOutcome) {
+	if m == nil {
+		return
+	}
+	m.crdCreations.WithLabelValues(resource, string(outcome)).Inc()
+}
+
+func (m *Metrics) observeEstablishment(resource string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.crdEstablishment.WithLabelValues(resource, resultLabel(err)).Observe(duration.Seconds())
+}
+
+func (m *Metrics) setCRDTerminating(resource string, terminating bool) {
+	if m == nil {
+		return
+	}
+	value := 0.0
+	if terminating {
+		value = 1
+	}
+	m.crdTerminating.WithLabelValues(resource).Set(value)
+}
+
+func (m *Metrics) setHealthScore(resource, key string, score float64) {
+	if m == nil {
+		return
+	}
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	m.healthScore.WithLabelValues(resource, namespace, name).Set(score)
+}
+
+func (m *Metrics) deleteHealthScore(resource, key string) {
+	if m == nil {
+		return
+	}
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	m.healthScore.DeleteLabelValues(resource, namespace, name)
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultSuccess
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// gatherLabeled returns the values of the metrics of the family by their labels, such as "kind=Example,namespace=ns"
+func gatherLabeled(t *testing.T, registry prometheus.Gatherer, name string) map[string]float64 {
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	values := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			var labels []string
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+			sort.Strings(labels)
+			value := metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+			values[strings.Join(labels, ",")] = value
+		}
+	}
+	return values
+}
+
+// histogramCounts returns the number of observations of the histograms by family name
+func histogramCounts(t *testing.T, registry prometheus.Gatherer) map[string]uint64 {
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	counts := map[string]uint64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if metric.GetHistogram() != nil {
+				counts[family.GetName()] += metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return counts
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics("operator")
+	m.ObserveReconcile("example", time.Second, nil)
+	m.ObserveReconcile("example", time.Second, errors.New("failed"))
+	m.ObserveReconcile("example", time.Second, nil)
+	m.SetWorkqueueDepth("example", 3)
+	m.IncWatchRestarts("example")
+
+	assert.Equal(t, map[string]float64{"resource=example,result=error": 1, "resource=example,result=success": 2},
+		gatherLabeled(t, m.Registry(), "operator_reconcile_total"))
+	assert.Equal(t, map[string]float64{"resource=example": 3}, gatherLabeled(t, m.Registry(), "operator_workqueue_depth"))
+	assert.Equal(t, map[string]float64{"resource=example": 1}, gatherLabeled(t, m.Registry(), "operator_watch_restarts_total"))
+	assert.Equal(t, uint64(3), histogramCounts(t, m.Registry())["operator_reconcile_duration_seconds"])
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+	assert.Contains(t, string(body), `operator_reconcile_total{resource="example",result="success"} 2`)
+}
+
+func TestCRDSetupMetrics(t *testing.T) {
+	m := NewMetrics("operator")
+	clientset, apiExtClientset := newInstallClients(t)
+	ctx := Context{
+		Clientset:             clientset,
+		APIExtensionClientset: apiExtClientset,
+		Interval:              time.Millisecond,
+		Timeout:               time.Second,
+		Metrics:               m,
+	}
+
+	assert.Error(t, CreateCustomResources(ctx, installResources("first", "conflicting")))
+	assert.Equal(t, map[string]float64{"outcome=Created,resource=first": 1, "outcome=Created,resource=conflicting": 1},
+		gatherLabeled(t, m.Registry(), "operator_crd_creation_attempts_total"))
+	assert.Equal(t, uint64(2), histogramCounts(t, m.Registry())["operator_crd_establishment_wait_seconds"])
+}
+
+func TestControllerMetrics(t *testing.T) {
+	m := NewMetrics("operator")
+	client := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+		t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+	})
+	reconciler := ReconcilerFunc(func(key string) error {
+		if key == "ns/failing" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	c := NewController(Context{Metrics: m}, exampleResource, v1.NamespaceAll, client, &v1.ConfigMap{}, reconciler)
+	defer c.queue.ShutDown()
+
+	c.enqueue(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}})
+	c.enqueue(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "failing"}})
+	assert.Equal(t, map[string]float64{"resource=example": 2}, gatherLabeled(t, m.Registry(), "operator_workqueue_depth"))
+
+	assert.True(t, c.processNextItem())
+	assert.True(t, c.processNextItem())
+	assert.Equal(t, map[string]float64{"resource=example": 0}, gatherLabeled(t, m.Registry(), "operator_workqueue_depth"))
+	assert.Equal(t, map[string]float64{"resource=example,result=error": 1, "resource=example,result=success": 1},
+		gatherLabeled(t, m.Registry(), "operator_reconcile_total"))
+}
+
+func TestMetricsWithRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := NewMetricsWithRegistry("operator", registry)
+	assert.Equal(t, registry, m.Registry())
+	assert.Equal(t, prometheus.Registerer(registry), m.Registerer())
+
+	// the registry is not exposed for other registerers, while the metrics are served from the gatherer
+	other := prometheus.NewRegistry()
+	registerer := struct{ prometheus.Registerer }{other}
+	m = NewMetricsWithRegisterer("operator", registerer, other)
+	assert.Nil(t, m.Registry())
+	assert.Equal(t, prometheus.Registerer(registerer), m.Registerer())
+	m.IncWatchRestarts("example")
+	assert.Equal(t, map[string]float64{"resource=example": 1}, gatherLabeled(t, m.Gatherer(), "operator_watch_restarts_total"))
+
+	// the metrics cannot be registered twice in the same registry
+	assert.Panics(t, func() { NewMetricsWithRegistry("operator", registry) })
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.ObserveReconcile("example", time.Second, nil)
+	m.SetWorkqueueDepth("example", 1)
+	m.IncWatchRestarts("example")
+	m.observeCRDCreation("example", OutcomeCreated)
+	m.observeEstablishment("example", time.Second, nil)
+	assert.Nil(t, m.Registry())
+	assert.Nil(t, m.Registerer())
+	assert.Nil(t, m.Gatherer())
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 404, recorder.Code)
+}
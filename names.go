@@ -0,0 +1,136 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+)
+
+// nameHashLength is the number of hex digits of the hash suffixed to names that were shortened or sanitized
+const nameHashLength = 8
+
+// ChildName returns the name of a child resource derived from the name of its parent custom resource and the
+// suffixes, joined with dashes, such as "mycluster-mon-a". The name is a valid DNS-1123 subdomain of up to 253
+// characters. Invalid characters are replaced and long names are truncated. A hash of the full name is then suffixed
+// so that different parents never share a child name.
+func ChildName(parent string, suffixes ...string) string {
+	return childName(validation.DNS1123SubdomainMaxLength, true, parent, suffixes...)
+}
+
+// ChildLabelName returns the name of a child like ChildName, as a DNS-1123 label of up to 63 characters. Use it for
+// the names of services and of other resources whose name is used as a host name or a label value.
+func ChildLabelName(parent string, suffixes ...string) string {
+	return childName(validation.DNS1123LabelMaxLength, false, parent, suffixes...)
+}
+
+func childName(maxLength int, allowDots bool, parent string, suffixes ...string) string {
+	full := strings.Join(append([]string{parent}, suffixes...), "-")
+	name := sanitizeName(full, allowDots)
+	if name == full && len(name) <= maxLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(full))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+	if len(name) > maxLength-nameHashLength-1 {
+		name = name[:maxLength-nameHashLength-1]
+	}
+	name = strings.TrimRight(name, "-.")
+	if name == "" {
+		return hash
+	}
+	return name + "-" + hash
+}
+
+// sanitizeName lowercases the name and replaces each run of characters that are not allowed in DNS-1123 labels by a
+// single dash. With dots allowed, each label between dots is sanitized and empty labels are dropped.
+func sanitizeName(name string, allowDots bool) string {
+	if !allowDots {
+		return sanitizeLabel(name)
+	}
+	var labels []string
+	for _, label := range strings.Split(name, ".") {
+		if label = sanitizeLabel(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func sanitizeLabel(label string) string {
+	var b bytes.Buffer
+	replaced := false
+	for _, r := range strings.ToLower(label) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			replaced = false
+		case r == '-' || !replaced:
+			b.WriteRune('-')
+			replaced = r != '-'
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+// NameTaken returns whether an object with the name exists in the store and is not controlled by the owner, so
+// creating a child with the name would fail or adopt an object that belongs to someone else
+func NameTaken(store cache.Store, owner metav1.Object, namespace, name string) bool {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	obj, exists, err := store.GetByKey(key)
+	if err != nil || !exists {
+		return false
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return true
+	}
+	for _, ref := range accessor.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() && ref.Controller != nil && *ref.Controller {
+			return false
+		}
+	}
+	return true
+}
+
+// UniqueChildName returns the ChildName of the owner and suffixes in the namespace of the owner, unless the name is
+// taken in the store of children by another object. A name derived from the UID of the owner is then returned. It
+// fails if that name is taken as well.
+func UniqueChildName(store cache.Store, owner metav1.Object, suffixes ...string) (string, error) {
+	name := ChildName(owner.GetName(), suffixes...)
+	if !NameTaken(store, owner, owner.GetNamespace(), name) {
+		return name, nil
+	}
+	unique := ChildName(owner.GetName(), append(append([]string{}, suffixes...), string(owner.GetUID()))...)
+	if !NameTaken(store, owner, owner.GetNamespace(), unique) {
+		return unique, nil
+	}
+	return "", fmt.Errorf("child names %s and %s of %s are taken by other objects", name, unique, owner.GetName())
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestChildName(t *testing.T) {
+	assert.Equal(t, "mycluster-mon-a", ChildName("mycluster", "mon", "a"))
+	assert.Equal(t, "my.cluster-mon", ChildName("my.cluster", "mon"))
+
+	// invalid names are sanitized and made unique by a hash of the original name
+	sanitized := ChildName("My_Cluster", "mon")
+	assert.True(t, strings.HasPrefix(sanitized, "my-cluster-mon-"), sanitized)
+	assert.NotEqual(t, sanitized, ChildName("my_cluster", "mon"))
+	assert.Empty(t, validation.IsDNS1123Subdomain(ChildName("..Café..Über", "mon")))
+	assert.Empty(t, validation.IsDNS1123Subdomain(ChildName("日本", "")))
+
+	long := strings.Repeat("a", 300)
+	name := ChildName(long, "mon")
+	assert.Equal(t, validation.DNS1123SubdomainMaxLength, len(name))
+	assert.Empty(t, validation.IsDNS1123Subdomain(name))
+	assert.NotEqual(t, name, ChildName(long, "osd"))
+}
+
+func TestChildLabelName(t *testing.T) {
+	assert.Equal(t, "mycluster-mgr", ChildLabelName("mycluster", "mgr"))
+
+	name := ChildLabelName("my.cluster", strings.Repeat("x", 70))
+	assert.Equal(t, validation.DNS1123LabelMaxLength, len(name))
+	assert.Empty(t, validation.IsDNS1123Label(name))
+	assert.True(t, strings.HasPrefix(name, "my-cluster-xxx"), name)
+}
+
+func TestUniqueChildName(t *testing.T) {
+	isController := true
+	owner := &metav1.ObjectMeta{Name: "mycluster", Namespace: "ns", UID: "1234"}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	name, err := UniqueChildName(store, owner, "mon")
+	assert.NoError(t, err)
+	assert.Equal(t, "mycluster-mon", name)
+
+	// a child owned by the owner keeps its name
+	store.Add(&metav1.ObjectMeta{Name: "mycluster-mon", Namespace: "ns", OwnerReferences: []metav1.OwnerReference{{UID: "1234", Controller: &isController}}})
+	name, err = UniqueChildName(store, owner, "mon")
+	assert.NoError(t, err)
+	assert.Equal(t, "mycluster-mon", name)
+
+	// an object of another owner with the name is not adopted
+	store.Update(&metav1.ObjectMeta{Name: "mycluster-mon", Namespace: "ns"})
+	name, err = UniqueChildName(store, owner, "mon")
+	assert.NoError(t, err)
+	assert.Equal(t, "mycluster-mon-1234", name)
+
+	store.Add(&metav1.ObjectMeta{Name: "mycluster-mon-1234", Namespace: "ns"})
+	_, err = UniqueChildName(store, owner, "mon")
+	assert.Error(t, err)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OperationHistoryLimit is the number of finished operations kept in the status. Running operations are never dropped.
+const OperationHistoryLimit = 10
+
+// OperationPhase is the state of a long-running operation
+type OperationPhase string
+
+const (
+	// OperationRunning means the operation was started and has not finished
+	OperationRunning OperationPhase = "Running"
+	// OperationSucceeded means the operation finished without error
+	OperationSucceeded OperationPhase = "Succeeded"
+	// OperationFailed means the operation finished with an error
+	OperationFailed OperationPhase = "Failed"
+)
+
+// Operation is a long-running asynchronous task of the operator, such as a backup or restore
+type Operation struct {
+	ID       string         `json:"id"`
+	Type     string         `json:"type"`
+	Phase    OperationPhase `json:"phase"`
+	Started  metav1.Time    `json:"started"`
+	Finished *metav1.Time   `json:"finished,omitempty"`
+	Progress string         `json:"progress,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// Operations records the long-running operations of a custom resource. Add it to the status of the resource so that
+// users can see which operations are in progress, and write the status with UpdateStatus after each change.
+type Operations []Operation
+
+// Start records a running operation. An operation with the same id is restarted.
+func (o *Operations) Start(id, operationType string) {
+	o.remove(id)
+	*o = append(*o, Operation{ID: id, Type: operationType, Phase: OperationRunning, Started: metav1.Now()})
+}
+
+// SetProgress updates the progress of the running operation, such as "3/10 volumes restored".
+// It returns false if the operation is unknown.
+func (o Operations) SetProgress(id, progress string) bool {
+	op := o.Get(id)
+	if op == nil {
+		return false
+	}
+	op.Progress = progress
+	return true
+}
+
+// Finish marks the operation as succeeded, or failed with the error, and drops the oldest finished operations beyond
+// the OperationHistoryLimit. It returns false if the operation is unknown.
+func (o *Operations) Finish(id string, err error) bool {
+	op := o.Get(id)
+	if op == nil {
+		return false
+	}
+	now := metav1.Now()
+	op.Finished = &now
+	op.Phase = OperationSucceeded
+	if err != nil {
+		op.Phase = OperationFailed
+		op.Error = err.Error()
+	}
+	o.trim(OperationHistoryLimit)
+	return true
+}
+
+// Get returns the operation with the id, or nil if it is unknown
+func (o Operations) Get(id string) *Operation {
+	for i := range o {
+		if o[i].ID == id {
+			return &o[i]
+		}
+	}
+	return nil
+}
+
+// Running returns the operations that have not finished
+func (o Operations) Running() []Operation {
+	var running []Operation
+	for _, op := range o {
+		if op.Phase == OperationRunning {
+			running = append(running, op)
+		}
+	}
+	return running
+}
+
+func (o *Operations) remove(id string) {
+	var kept Operations
+	for _, op := range *o {
+		if op.ID != id {
+			kept = append(kept, op)
+		}
+	}
+	*o = kept
+}
+
+// trim drops the oldest finished operations until at most limit of them are left
+func (o *Operations) trim(limit int) {
+	finished := 0
+	for _, op := range *o {
+		if op.Phase != OperationRunning {
+			finished++
+		}
+	}
+
+	var kept Operations
+	for _, op := range *o {
+		if op.Phase != OperationRunning && finished > limit {
+			finished--
+			continue
+		}
+		kept = append(kept, op)
+	}
+	*o = kept
+}
+
+// DeepCopyInto copies the operation into out, for the generated deep copy functions of the status types
+func (in *Operation) DeepCopyInto(out *Operation) {
+	*out = *in
+	in.Started.DeepCopyInto(&out.Started)
+	if in.Finished != nil {
+		out.Finished = in.Finished.DeepCopy()
+	}
+}
+
+// DeepCopy returns a copy of the operation
+func (in *Operation) DeepCopy() *Operation {
+	if in == nil {
+		return nil
+	}
+	out := new(Operation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the operations into out, for the generated deep copy functions of the status types
+func (in Operations) DeepCopyInto(out *Operations) {
+	*out = make(Operations, len(in))
+	for i := range in {
+		in[i].DeepCopyInto(&(*out)[i])
+	}
+}
+
+// DeepCopy returns a copy of the operations
+func (in Operations) DeepCopy() Operations {
+	if in == nil {
+		return nil
+	}
+	out := new(Operations)
+	in.DeepCopyInto(out)
+	return *out
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperations(t *testing.T) {
+	var ops Operations
+	ops.Start("restore-1", "restore")
+	assert.True(t, ops.SetProgress("restore-1", "3/10 volumes restored"))
+	assert.False(t, ops.SetProgress("missing", "none"))
+	assert.Equal(t, 1, len(ops.Running()))
+	assert.Equal(t, "3/10 volumes restored", ops.Get("restore-1").Progress)
+
+	assert.True(t, ops.Finish("restore-1", fmt.Errorf("volume lost")))
+	assert.Equal(t, OperationFailed, ops.Get("restore-1").Phase)
+	assert.Equal(t, "volume lost", ops.Get("restore-1").Error)
+	assert.NotNil(t, ops.Get("restore-1").Finished)
+	assert.Empty(t, ops.Running())
+
+	// the oldest finished operations are dropped, running ones are kept
+	ops.Start("backup", "backup")
+	for i := 0; i < OperationHistoryLimit+2; i++ {
+		id := fmt.Sprintf("op-%d", i)
+		ops.Start(id, "check")
+		ops.Finish(id, nil)
+	}
+	assert.Equal(t, OperationHistoryLimit+1, len(ops))
+	assert.Nil(t, ops.Get("restore-1"))
+	assert.Nil(t, ops.Get("op-0"))
+	assert.NotNil(t, ops.Get("backup"))
+
+	copied := ops.DeepCopy()
+	copied.SetProgress("backup", "changed")
+	assert.Equal(t, "", ops.Get("backup").Progress)
+}
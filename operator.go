@@ -0,0 +1,233 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// OperatorOptions configures an operator
+type OperatorOptions struct {
+	// Strict makes Run fail with a report of all registration problems before anything is started. Otherwise the
+	// problems are logged and the operator runs anyway, serving only the first webhook of a colliding path.
+	Strict bool
+
+	// Preflight makes Run check that the operator has the permissions to run the registered resources and the
+	// children before anything is created
+	Preflight bool
+
+	// Children are the resources created or read by the controllers, for the preflight
+	Children []ChildResource
+
+	// Namespace watched by the controllers, for the preflight. Defaults to all namespaces.
+	Namespace string
+
+	// Conditions is optional and declares the conditions set by the controllers. Problems of the declarations are
+	// reported as registration problems.
+	Conditions *ConditionCatalog
+
+	// SLOs are optional and declare the objectives alerted on by the rules of AlertRules
+	SLOs []SLO
+}
+
+// Operator registers the custom resources, controllers, and webhooks of an operator and runs them together
+type Operator struct {
+	context     Context
+	scheme      *runtime.Scheme
+	options     OperatorOptions
+	resources   []CustomResource
+	controllers []operatorController
+	skipped     map[string]bool
+	webhooks    []operatorWebhook
+	renderers   []operatorRenderer
+}
+
+type operatorController struct {
+	controller *Controller
+	workers    int
+}
+
+type operatorWebhook struct {
+	path    string
+	handler http.Handler
+}
+
+// NewOperator creates an operator with the context. The scheme is optional and, when given, must have the types of
+// all custom resources registered.
+func NewOperator(context Context, scheme *runtime.Scheme, options OperatorOptions) *Operator {
+	return &Operator{context: context, scheme: scheme, options: options, skipped: map[string]bool{}}
+}
+
+// AddResource registers a custom resource to be created when the operator runs. Each resource needs a controller
+// or must be skipped with SkipController.
+func (o *Operator) AddResource(resource CustomResource) {
+	o.resources = append(o.resources, resource)
+}
+
+// AddController registers a controller to run with the number of workers
+func (o *Operator) AddController(controller *Controller, workers int) {
+	o.controllers = append(o.controllers, operatorController{controller: controller, workers: workers})
+}
+
+// SkipController opts the resource out of requiring a controller, for resources that are only created by the
+// operator or reconciled by another component
+func (o *Operator) SkipController(resource CustomResource) {
+	o.skipped[resource.Name] = true
+}
+
+// AddWebhook registers the handler of a webhook at the path of the webhook server
+func (o *Operator) AddWebhook(path string, handler http.Handler) {
+	o.webhooks = append(o.webhooks, operatorWebhook{path: path, handler: handler})
+}
+
+// Validate returns an aggregate of all registration problems, or nil if there are none
+func (o *Operator) Validate() error {
+	var errs []error
+
+	controlled := map[string]bool{}
+	for _, c := range o.controllers {
+		controlled[c.controller.resource.Name] = true
+	}
+	for _, resource := range o.resources {
+		if !controlled[resource.Name] && !o.skipped[resource.Name] {
+			errs = append(errs, fmt.Errorf("resource %s has no controller and was not skipped", resource.Name))
+		}
+		if o.scheme == nil {
+			continue
+		}
+		gv := resource.GroupVersionKind().GroupVersion()
+		for _, kind := range []string{resource.Kind, resource.Kind + "List"} {
+			if !o.scheme.Recognizes(gv.WithKind(kind)) {
+				errs = append(errs, fmt.Errorf("kind %s of resource %s is not registered in the scheme", gv.WithKind(kind).String(), resource.Name))
+			}
+		}
+	}
+
+	paths := map[string]bool{}
+	for _, webhook := range o.webhooks {
+		if webhook.handler == nil {
+			errs = append(errs, fmt.Errorf("webhook %s has no handler", webhook.path))
+		}
+		if paths[webhook.path] {
+			errs = append(errs, fmt.Errorf("webhook path %s is registered more than once", webhook.path))
+		}
+		paths[webhook.path] = true
+	}
+
+	if o.options.Conditions != nil {
+		if err := o.options.Conditions.Lint(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, slo := range o.options.SLOs {
+		if err := slo.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errorsUtil.NewAggregate(errs)
+}
+
+// AlertRules generates the alerting rules of the declared SLOs and conditions. The metrics namespace must be the one
+// given to NewMetrics.
+func (o *Operator) AlertRules(group, metricsNamespace string) (AlertRuleGroup, error) {
+	return AlertRules(group, metricsNamespace, o.options.SLOs, o.options.Conditions)
+}
+
+// WebhookHandler returns the handler serving all registered webhooks at their paths. When paths collide only the
+// first webhook is served.
+func (o *Operator) WebhookHandler() http.Handler {
+	mux := http.NewServeMux()
+	paths := map[string]bool{}
+	for _, webhook := range o.webhooks {
+		if webhook.handler == nil || webhook.path == "" || paths[webhook.path] {
+			continue
+		}
+		paths[webhook.path] = true
+		mux.Handle(webhook.path, webhook.handler)
+	}
+	return mux
+}
+
+// Run validates the registrations, creates the custom resources, and runs the controllers. The call blocks until
+// the stop channel is closed or a controller fails, which stops the other controllers before returning.
+func (o *Operator) Run(stopCh <-chan struct{}) error {
+	if err := o.Validate(); err != nil {
+		if o.options.Strict {
+			return fmt.Errorf("invalid operator registration. %+v", err)
+		}
+		o.context.logger().Error(err, "invalid operator registration")
+	}
+
+	if o.options.Preflight {
+		if err := Preflight(o.context, o.options.Namespace, o.resources, o.options.Children); err != nil {
+			return fmt.Errorf("failed preflight. %+v", err)
+		}
+	}
+
+	if err := CreateCustomResources(o.context, o.resources); err != nil {
+		return fmt.Errorf("failed to create custom resources. %+v", err)
+	}
+
+	return o.runControllers(stopCh)
+}
+
+// runControllers runs the controllers until the stop channel is closed or a controller fails. A failed controller
+// stops all others, which are waited for before returning its error.
+func (o *Operator) runControllers(stopCh <-chan struct{}) error {
+	if len(o.controllers) == 0 {
+		<-stopCh
+		return nil
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	stopAll := func() { stopOnce.Do(func() { close(stop) }) }
+	go func() {
+		select {
+		case <-stopCh:
+			stopAll()
+		case <-stop:
+		}
+	}()
+
+	errCh := make(chan error, len(o.controllers))
+	for _, c := range o.controllers {
+		go func(c operatorController) {
+			errCh <- c.controller.Run(c.workers, stop)
+		}(c)
+	}
+
+	var err error
+	for range o.controllers {
+		if runErr := <-errCh; runErr != nil && err == nil {
+			err = runErr
+		}
+		stopAll()
+	}
+	if err != nil {
+		return fmt.Errorf("controller failed. %+v", err)
+	}
+	return nil
+}
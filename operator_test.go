@@ -0,0 +1,70 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestOperatorValidate(t *testing.T) {
+	controlled := CustomResource{Name: "sample", Plural: "samples", Group: "example.com", Version: "v1", Kind: "Sample"}
+	skipped := CustomResource{Name: "other", Plural: "others", Group: "example.com", Version: "v1", Kind: "Other"}
+	missing := CustomResource{Name: "missing", Plural: "missings", Group: "example.com", Version: "v1", Kind: "Missing"}
+
+	scheme := runtime.NewScheme()
+	_, err := AddToScheme(scheme, controlled, &metav1.Status{}, &metav1.List{})
+	assert.NoError(t, err)
+
+	operator := NewOperator(Context{}, scheme, OperatorOptions{Strict: true})
+	operator.AddResource(controlled)
+	operator.AddController(&Controller{resource: controlled}, 1)
+	handler := http.NotFoundHandler()
+	operator.AddWebhook("/mutate", handler)
+	assert.NoError(t, operator.Validate())
+
+	operator.AddResource(skipped)
+	operator.SkipController(skipped)
+	operator.AddResource(missing)
+	operator.AddWebhook("/mutate", handler)
+
+	err = operator.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "resource missing has no controller")
+	assert.Contains(t, err.Error(), "example.com/v1, Kind=OtherList")
+	assert.Contains(t, err.Error(), "example.com/v1, Kind=Missing")
+	assert.Contains(t, err.Error(), "webhook path /mutate is registered more than once")
+	assert.NotContains(t, err.Error(), "resource other has no controller")
+}
+
+func TestOperatorRunStopsControllersOnFailure(t *testing.T) {
+	running := newController(Context{}, exampleResource, nil, nil, ControllerOptions{})
+	running.runInformer = func(stopCh <-chan struct{}) {}
+	running.hasSynced = func() bool { return true }
+	failing := newController(Context{}, exampleResource, nil, nil, ControllerOptions{Resync: &ResyncSchedule{}})
+	o := &Operator{controllers: []operatorController{{controller: running}, {controller: failing}}}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	assert.Error(t, o.runControllers(stopCh))
+	// the running controller returned before the failure was
+	assert.Equal(t, int32(1), atomic.LoadInt32(&running.stopping))
+}
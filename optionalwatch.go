@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+// OptionalWatch runs a watch of a custom resource defined by another operator, such as a ServiceMonitor, only while
+// its CRD is established. The watch starts once the CRD appears and stops when the CRD is deleted, so the operator
+// does not fail at startup on clusters without the CRD.
+type OptionalWatch struct {
+	context  Context
+	resource CustomResource
+	start    func(stopCh <-chan struct{})
+	interval time.Duration
+	state    func() (crdState, error)
+
+	lock   sync.Mutex
+	stopCh chan struct{}
+}
+
+// NewOptionalWatch creates an optional watch that calls start while the CRD of the resource is established. The
+// stop channel passed to start is closed when the CRD is deleted or the optional watch is stopped. The CRD is
+// checked at the interval, 30s by default.
+func NewOptionalWatch(context Context, resource CustomResource, interval time.Duration, start func(stopCh <-chan struct{})) *OptionalWatch {
+	w := &OptionalWatch{
+		context:  context,
+		resource: resource,
+		start:    start,
+		interval: durationOrDefault(interval, defaultCRDCheckInterval),
+	}
+	w.state = func() (crdState, error) { return getCRDState(w.context, w.resource) }
+	return w
+}
+
+// NewOptionalDynamicWatch creates an optional watch of the resource with a dynamic watcher, which passes
+// *unstructured.Unstructured objects to the handlers
+func NewOptionalDynamicWatch(context Context, resource CustomResource, namespace string, handlers cache.ResourceEventHandlerFuncs) *OptionalWatch {
+	gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Plural}
+	return NewOptionalWatch(context, resource, 0, func(stopCh <-chan struct{}) {
+		watcher, err := NewDynamicWatcher(context, gvr, namespace, handlers)
+		if err != nil {
+			context.logger().Error(err, "failed to start the optional watch", "resource", resource.Name)
+			return
+		}
+		watcher.Watch(&unstructured.Unstructured{}, stopCh)
+	})
+}
+
+// Active returns whether the watch is running since the CRD is established
+func (w *OptionalWatch) Active() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.stopCh != nil
+}
+
+// Run checks the CRD at the interval until the stop channel is closed, starting and stopping the watch as the CRD
+// appears and disappears
+func (w *OptionalWatch) Run(stopCh <-chan struct{}) {
+	wait.Until(w.check, w.interval, stopCh)
+	w.deactivate()
+}
+
+// check starts the watch when the CRD is established and stops it when the CRD is terminating or missing
+func (w *OptionalWatch) check() {
+	state, err := w.state()
+	if err != nil {
+		w.context.logger().Error(err, "failed to check the CRD of the optional watch", "resource", w.resource.Name)
+		return
+	}
+	switch state {
+	case crdStateEstablished:
+		w.activate()
+	case crdStateTerminating, crdStateMissing:
+		w.deactivate()
+	}
+}
+
+func (w *OptionalWatch) activate() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stopCh != nil {
+		return
+	}
+	w.context.logger().Info("starting the optional watch since the CRD is established", "resource", w.resource.Name)
+	w.stopCh = make(chan struct{})
+	go w.start(w.stopCh)
+}
+
+func (w *OptionalWatch) deactivate() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stopCh == nil {
+		return
+	}
+	w.context.logger().Info("stopping the optional watch since the CRD is gone", "resource", w.resource.Name)
+	close(w.stopCh)
+	w.stopCh = nil
+}
+
+// AddOptionalWatch runs the optional watch with the controller, for the custom resources of other operators the
+// controller reacts to when they are installed
+func (c *Controller) AddOptionalWatch(watch *OptionalWatch) {
+	c.optionalWatches = append(c.optionalWatches, watch)
+}
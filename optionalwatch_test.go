@@ -0,0 +1,64 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionalWatch(t *testing.T) {
+	started := make(chan (<-chan struct{}), 2)
+	w := NewOptionalWatch(Context{}, exampleResource, time.Millisecond, func(stopCh <-chan struct{}) {
+		started <- stopCh
+	})
+	state := crdStateMissing
+	w.state = func() (crdState, error) { return state, nil }
+
+	// the watch does not start without the CRD
+	w.check()
+	assert.False(t, w.Active())
+
+	state = crdStateEstablished
+	w.check()
+	w.check()
+	assert.True(t, w.Active())
+	first := <-started
+	assert.Equal(t, 0, len(started))
+
+	// the watch stops when the CRD is deleted and starts again when it is recreated
+	state = crdStateTerminating
+	w.check()
+	assert.False(t, w.Active())
+	_, open := <-first
+	assert.False(t, open)
+
+	state = crdStateEstablished
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.Run(stopCh)
+		close(done)
+	}()
+	second := <-started
+	close(stopCh)
+	<-done
+	_, open = <-second
+	assert.False(t, open)
+	assert.False(t, w.Active())
+}
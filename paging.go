@@ -0,0 +1,110 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultPageSize is the number of objects requested per page by the lists of the kit
+const DefaultPageSize = 500
+
+// ListFunc lists objects with the options, such as the List of a clientset or CustomResourceClient with the
+// namespace bound
+type ListFunc func(opts metav1.ListOptions) (runtime.Object, error)
+
+// ListPages lists the objects in pages of up to pageSize objects, with the limit and continue options, and passes
+// each page to f. The apiserver then never builds the response of a huge list at once. A pageSize of 0 uses
+// DefaultPageSize, and a negative pageSize lists all objects in one page.
+func ListPages(list ListFunc, opts metav1.ListOptions, pageSize int64, f func(page runtime.Object) error) error {
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > 0 {
+		opts.Limit = pageSize
+	}
+	opts.Continue = ""
+
+	for {
+		page, err := list(opts)
+		if err != nil {
+			return err
+		}
+		if err := f(page); err != nil {
+			return err
+		}
+		listMeta, err := meta.ListAccessor(page)
+		if err != nil {
+			return fmt.Errorf("failed to read the list metadata. %+v", err)
+		}
+		if listMeta.GetContinue() == "" {
+			return nil
+		}
+		opts.Continue = listMeta.GetContinue()
+	}
+}
+
+// ListAll lists all objects in pages and returns them in one list. If the continue token of the pages expires
+// before the last page, the objects are listed again in one page.
+func ListAll(list ListFunc, opts metav1.ListOptions, pageSize int64) (runtime.Object, error) {
+	var result runtime.Object
+	var items []runtime.Object
+	err := ListPages(list, opts, pageSize, func(page runtime.Object) error {
+		pageItems, err := meta.ExtractList(page)
+		if err != nil {
+			return fmt.Errorf("failed to extract the items of the list. %+v", err)
+		}
+		// the last page holds the items since it has no continue token
+		result = page
+		items = append(items, pageItems...)
+		return nil
+	})
+	if err != nil {
+		if !continueExpired(err) {
+			return nil, err
+		}
+		opts.Limit = 0
+		return list(opts)
+	}
+
+	if err := meta.SetList(result, items); err != nil {
+		return nil, fmt.Errorf("failed to combine the pages of the list. %+v", err)
+	}
+	return result, nil
+}
+
+// continueExpired returns whether the error reports an expired continue token
+func continueExpired(err error) bool {
+	status, ok := err.(errors.APIStatus)
+	return ok && status.Status().Code == http.StatusGone
+}
+
+// pageListWatch lists the objects of the source in pages. The informer still receives all objects in one list.
+func pageListWatch(source *cache.ListWatch, pageSize int64) {
+	listFunc := source.ListFunc
+	source.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		return ListAll(listFunc, options, pageSize)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// pagedConfigMaps serves count configmaps in pages. The continue token is the index of the next item.
+func pagedConfigMaps(count int, requests *[]metav1.ListOptions, expire bool) ListFunc {
+	return func(opts metav1.ListOptions) (runtime.Object, error) {
+		*requests = append(*requests, opts)
+		start := 0
+		if opts.Continue != "" {
+			if expire {
+				return nil, errors.NewGone("continue token expired")
+			}
+			start, _ = strconv.Atoi(opts.Continue)
+		}
+		end := count
+		if opts.Limit > 0 && start+int(opts.Limit) < count {
+			end = start + int(opts.Limit)
+		}
+
+		list := &v1.ConfigMapList{ListMeta: metav1.ListMeta{ResourceVersion: "10"}}
+		for i := start; i < end; i++ {
+			list.Items = append(list.Items, v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("cm%d", i)}})
+		}
+		if end < count {
+			list.Continue = strconv.Itoa(end)
+		}
+		return list, nil
+	}
+}
+
+func TestListPages(t *testing.T) {
+	var requests []metav1.ListOptions
+	var pages []int
+	err := ListPages(pagedConfigMaps(5, &requests, false), metav1.ListOptions{LabelSelector: "app=a"}, 2, func(page runtime.Object) error {
+		pages = append(pages, len(page.(*v1.ConfigMapList).Items))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 1}, pages)
+	assert.Equal(t, 3, len(requests))
+	assert.Equal(t, "app=a", requests[2].LabelSelector)
+	assert.Equal(t, int64(2), requests[2].Limit)
+	assert.Equal(t, "4", requests[2].Continue)
+}
+
+func TestListAll(t *testing.T) {
+	var requests []metav1.ListOptions
+	list, err := ListAll(pagedConfigMaps(1200, &requests, false), metav1.ListOptions{}, 0)
+	assert.NoError(t, err)
+	items := list.(*v1.ConfigMapList).Items
+	assert.Equal(t, 1200, len(items))
+	assert.Equal(t, "cm1199", items[1199].Name)
+	assert.Equal(t, "", list.(*v1.ConfigMapList).Continue)
+	assert.Equal(t, 3, len(requests))
+
+	// an expired continue token lists everything again in one page
+	requests = nil
+	list, err = ListAll(pagedConfigMaps(5, &requests, true), metav1.ListOptions{}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(list.(*v1.ConfigMapList).Items))
+	assert.Equal(t, int64(0), requests[2].Limit)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The names of the usual steps of a reconcile. Pipelines may use any other names.
+const (
+	StepFetch          = "fetch"
+	StepValidate       = "validate"
+	StepEnsureChildren = "ensure-children"
+	StepUpdateStatus   = "update-status"
+)
+
+const defaultStepRetryDelay = 100 * time.Millisecond
+
+// ErrStopPipeline is returned by a step to end the reconcile successfully without running the following steps, such
+// as by the fetch step when the custom resource was deleted
+var ErrStopPipeline = errors.New("stop the pipeline")
+
+// StepState is passed from step to step during a reconcile
+type StepState struct {
+	Trace ReconcileTrace
+
+	// Object is optional and set by a step, usually the fetch step, for the following steps
+	Object runtime.Object
+
+	// Values are shared between the steps, such as the children rendered by one step and applied by another
+	Values map[string]interface{}
+
+	// Result of the reconcile, which steps may set to requeue the key
+	Result Result
+}
+
+// StepFunc runs a step of a reconcile
+type StepFunc func(state *StepState) error
+
+// Step is a named step of a reconcile pipeline
+type Step struct {
+	Name string
+	Run  StepFunc
+
+	// Retries of the step within the reconcile before its error fails the reconcile. Terminal errors are not retried.
+	Retries int
+
+	// RetryDelay between the retries of the step. Defaults to 100ms.
+	RetryDelay time.Duration
+}
+
+// Pipeline is a reconciler running named steps in order, such as fetch, validate, ensure-children, and
+// update-status. Each step is timed and counted in the reconcile_step metrics, so large reconcilers are observable
+// step by step. The error of a failed step is returned as it is, so the controller classifies it as usual.
+type Pipeline struct {
+	context  Context
+	resource CustomResource
+	steps    []Step
+}
+
+// NewPipeline creates a pipeline reconciling the custom resource with the steps
+func NewPipeline(context Context, resource CustomResource, steps ...Step) *Pipeline {
+	return &Pipeline{context: context, resource: resource, steps: steps}
+}
+
+// Steps returns the names of the steps in order
+func (p *Pipeline) Steps() []string {
+	names := make([]string, 0, len(p.steps))
+	for _, step := range p.steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+// Append adds the step at the end of the pipeline
+func (p *Pipeline) Append(step Step) {
+	p.steps = append(p.steps, step)
+}
+
+// InsertBefore adds the step before the named step, such as a custom check before ensure-children
+func (p *Pipeline) InsertBefore(name string, step Step) error {
+	return p.insert(name, 0, step)
+}
+
+// InsertAfter adds the step after the named step
+func (p *Pipeline) InsertAfter(name string, step Step) error {
+	return p.insert(name, 1, step)
+}
+
+func (p *Pipeline) insert(name string, offset int, step Step) error {
+	for i := range p.steps {
+		if p.steps[i].Name == step.Name {
+			return fmt.Errorf("pipeline already has step %s", step.Name)
+		}
+	}
+	for i := range p.steps {
+		if p.steps[i].Name != name {
+			continue
+		}
+		at := i + offset
+		p.steps = append(p.steps[:at], append([]Step{step}, p.steps[at:]...)...)
+		return nil
+	}
+	return fmt.Errorf("pipeline has no step %s", name)
+}
+
+// Reconcile runs the steps for the key
+func (p *Pipeline) Reconcile(key string) error {
+	_, err := p.reconcileTracedResult(NewReconcileTrace(key, p.context.logger()))
+	return err
+}
+
+// ReconcileTraced runs the steps for the key of the trace
+func (p *Pipeline) ReconcileTraced(trace ReconcileTrace) error {
+	_, err := p.reconcileTracedResult(trace)
+	return err
+}
+
+// ReconcileResult runs the steps for the key and returns the result they set
+func (p *Pipeline) ReconcileResult(key string) (Result, error) {
+	return p.reconcileTracedResult(NewReconcileTrace(key, p.context.logger()))
+}
+
+// reconcileTracedResult is called by the controller so that the steps get both the trace and the result
+func (p *Pipeline) reconcileTracedResult(trace ReconcileTrace) (Result, error) {
+	state := &StepState{Trace: trace, Values: map[string]interface{}{}}
+	for _, step := range p.steps {
+		err := p.runStep(step, state)
+		if err == ErrStopPipeline {
+			trace.Logger.Debug("pipeline stopped", "step", step.Name)
+			break
+		}
+		if err != nil {
+			trace.Logger.Error(err, "reconcile step failed", "step", step.Name)
+			return state.Result, err
+		}
+	}
+	return state.Result, nil
+}
+
+// runStep runs the step with its retries and records its metrics
+func (p *Pipeline) runStep(step Step, state *StepState) error {
+	start := time.Now()
+	err := step.Run(state)
+	for attempt := 0; attempt < step.Retries && err != nil && err != ErrStopPipeline && !IsTerminalError(err); attempt++ {
+		state.Trace.Logger.Debug("retrying reconcile step", "step", step.Name, "error", err.Error())
+		time.Sleep(durationOrDefault(step.RetryDelay, defaultStepRetryDelay))
+		err = step.Run(state)
+	}
+	stepErr := err
+	if stepErr == ErrStopPipeline {
+		stepErr = nil
+	}
+	p.context.Metrics.observeReconcileStep(p.resource.Name, step.Name, time.Since(start), stepErr)
+	return err
+}
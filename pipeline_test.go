@@ -0,0 +1,105 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+)
+
+func TestPipeline(t *testing.T) {
+	var ran []string
+	step := func(name string, err error) Step {
+		return Step{Name: name, Run: func(state *StepState) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+	m := NewMetrics("test")
+	resource := CustomResource{Name: "sample"}
+	pipeline := NewPipeline(Context{Metrics: m}, resource, step(StepFetch, nil), step(StepEnsureChildren, nil))
+	assert.NoError(t, pipeline.InsertBefore(StepEnsureChildren, step(StepValidate, nil)))
+	assert.NoError(t, pipeline.InsertAfter(StepEnsureChildren, Step{Name: StepUpdateStatus, Run: func(state *StepState) error {
+		ran = append(ran, StepUpdateStatus)
+		state.Result.RequeueAfter = time.Minute
+		return nil
+	}}))
+	assert.Error(t, pipeline.InsertAfter("missing", step("custom", nil)))
+	assert.Error(t, pipeline.InsertAfter(StepFetch, step(StepValidate, nil)))
+	assert.Equal(t, []string{StepFetch, StepValidate, StepEnsureChildren, StepUpdateStatus}, pipeline.Steps())
+
+	result, err := pipeline.ReconcileResult("ns/a")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, result.RequeueAfter)
+	assert.Equal(t, []string{StepFetch, StepValidate, StepEnsureChildren, StepUpdateStatus}, ran)
+
+	// the following steps are skipped once a step stops the pipeline
+	ran = nil
+	pipeline = NewPipeline(Context{}, resource, step(StepFetch, ErrStopPipeline), step(StepValidate, nil))
+	assert.NoError(t, pipeline.Reconcile("ns/a"))
+	assert.Equal(t, []string{StepFetch}, ran)
+
+	// terminal errors are returned as they are and not retried
+	ran = nil
+	invalid := NewTerminalError(errors.New("invalid spec"))
+	pipeline = NewPipeline(Context{}, resource, Step{Name: StepValidate, Retries: 3, Run: step(StepValidate, invalid).Run})
+	assert.Equal(t, invalid, pipeline.Reconcile("ns/a"))
+	assert.Equal(t, []string{StepValidate}, ran)
+
+	families, err := m.Registry().Gather()
+	assert.NoError(t, err)
+	steps := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "test_reconcile_step_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "step" {
+					steps[label.GetValue()] = metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{StepFetch: 1, StepValidate: 1, StepEnsureChildren: 1, StepUpdateStatus: 1}, steps)
+}
+
+func TestPipelineRetries(t *testing.T) {
+	attempts := 0
+	pipeline := NewPipeline(Context{}, CustomResource{Name: "sample"}, Step{
+		Name:       StepEnsureChildren,
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+		Run: func(state *StepState) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("conflict")
+			}
+			state.Object = &v1.ConfigMap{}
+			return nil
+		},
+	})
+	assert.NoError(t, pipeline.Reconcile("ns/a"))
+	assert.Equal(t, 3, attempts)
+
+	attempts = -10
+	assert.EqualError(t, pipeline.Reconcile("ns/a"), "conflict")
+	assert.Equal(t, -7, attempts)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	defaultLogTailLines  = 50
+	defaultLogLimitBytes = 16 * 1024
+	defaultLogMaxPods    = 5
+)
+
+// PodLogOptions bounds the logs collected from operand pods
+type PodLogOptions struct {
+	// Container to read the logs of. Defaults to the only container of the pod.
+	Container string
+
+	// TailLines is the number of lines read from the end of the log of each pod. Defaults to 50.
+	TailLines int64
+
+	// LimitBytes caps the bytes read from each pod. Defaults to 16KiB.
+	LimitBytes int64
+
+	// MaxPods is the number of pods the logs are collected from. Pods that are not ready come first. Defaults to 5.
+	MaxPods int
+
+	// Previous reads the logs of the previous instance of the container, such as before a crash
+	Previous bool
+}
+
+// PodLogs are the logs collected from the container of a pod
+type PodLogs struct {
+	Pod       string
+	Container string
+	Logs      string
+
+	// Err is set when the logs could not be read
+	Err error
+}
+
+// CollectPodLogs reads the tail of the logs of the pods in the namespace matching the label selector, such as
+// "app=database", to explain failures of operands in events, conditions, or diagnostics
+func CollectPodLogs(context Context, namespace, selector string, opts PodLogOptions) ([]PodLogs, error) {
+	list, err := ListAll(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return context.Clientset.CoreV1().Pods(namespace).List(opts)
+	}, metav1.ListOptions{LabelSelector: selector}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods matching %s. %+v", selector, err)
+	}
+
+	items := list.(*v1.PodList).Items
+	sort.SliceStable(items, func(i, j int) bool {
+		return !podReady(&items[i]) && podReady(&items[j])
+	})
+	maxPods := opts.MaxPods
+	if maxPods <= 0 {
+		maxPods = defaultLogMaxPods
+	}
+	if len(items) > maxPods {
+		items = items[:maxPods]
+	}
+
+	tailLines := opts.TailLines
+	if tailLines <= 0 {
+		tailLines = defaultLogTailLines
+	}
+	limitBytes := opts.LimitBytes
+	if limitBytes <= 0 {
+		limitBytes = defaultLogLimitBytes
+	}
+
+	var result []PodLogs
+	for _, pod := range items {
+		logOptions := &v1.PodLogOptions{
+			Container:  opts.Container,
+			TailLines:  &tailLines,
+			LimitBytes: &limitBytes,
+			Previous:   opts.Previous,
+		}
+		raw, err := context.Clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, logOptions).DoRaw()
+		logs := PodLogs{Pod: pod.Name, Container: opts.Container, Logs: string(raw)}
+		if err != nil {
+			logs.Logs = ""
+			logs.Err = fmt.Errorf("failed to get logs of pod %s. %+v", pod.Name, err)
+		}
+		result = append(result, logs)
+	}
+	return result, nil
+}
+
+// SummarizePodLogs formats the collected logs into a message of at most maxBytes, keeping the end of each log,
+// for example for the message of an event or condition
+func SummarizePodLogs(logs []PodLogs, maxBytes int) string {
+	if len(logs) == 0 || maxBytes <= 0 {
+		return ""
+	}
+	perPod := maxBytes / len(logs)
+
+	var summary bytes.Buffer
+	for _, l := range logs {
+		var section string
+		if l.Err != nil {
+			section = fmt.Sprintf("pod %s: %v\n", l.Pod, l.Err)
+		} else {
+			header := fmt.Sprintf("pod %s:\n", l.Pod)
+			text := l.Logs
+			if room := perPod - len(header); room <= 0 {
+				text = ""
+			} else if len(text) > room {
+				text = text[len(text)-room:]
+			}
+			section = header + text
+		}
+		if len(section) > perPod {
+			section = section[:perPod]
+		}
+		summary.WriteString(section)
+	}
+	return summary.String()
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
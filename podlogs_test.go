@@ -0,0 +1,38 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizePodLogs(t *testing.T) {
+	logs := []PodLogs{
+		{Pod: "db-0", Logs: strings.Repeat("x", 100) + "fatal: disk full\n"},
+		{Pod: "db-1", Err: fmt.Errorf("not found")},
+	}
+	summary := SummarizePodLogs(logs, 80)
+	assert.True(t, len(summary) <= 80)
+	assert.Contains(t, summary, "pod db-0:\n")
+	assert.Contains(t, summary, "fatal: disk full\n")
+	assert.Contains(t, summary, "pod db-1: not found")
+
+	assert.Equal(t, "", SummarizePodLogs(nil, 80))
+}
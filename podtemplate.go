@@ -0,0 +1,313 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// PodTemplateSchemaMode decides how much of an embedded pod template is described by the schema of a CRD. The full
+// schema of a PodSpec is hundreds of kilobytes and, repeated in every version of the CRD, exceeds the size limit of
+// etcd objects.
+type PodTemplateSchemaMode string
+
+const (
+	// PodTemplateOpaque describes the template as an object and preserves all of its fields
+	PodTemplateOpaque PodTemplateSchemaMode = "Opaque"
+
+	// PodTemplatePruned describes the labels, annotations, and the name and image of the containers, and preserves
+	// the other fields without describing them
+	PodTemplatePruned PodTemplateSchemaMode = "Pruned"
+)
+
+// podTemplateMergeKeys are the keys identifying the items of the lists merged by MergePodTemplate. Other lists are
+// replaced.
+var podTemplateMergeKeys = map[string]string{
+	"containers":       "name",
+	"initContainers":   "name",
+	"volumes":          "name",
+	"imagePullSecrets": "name",
+	"env":              "name",
+	"volumeMounts":     "mountPath",
+	"ports":            "containerPort",
+}
+
+// PodTemplateSchema returns the openAPIV3Schema of a property embedding a pod template, to include in the schema of
+// a CRD
+func PodTemplateSchema(mode PodTemplateSchemaMode) map[string]interface{} {
+	if mode != PodTemplatePruned {
+		return preservedObjectSchema()
+	}
+
+	stringMap := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"type": "string"},
+	}
+	container := preservedObjectSchema()
+	container["required"] = []interface{}{"name"}
+	container["properties"] = map[string]interface{}{
+		"name":  map[string]interface{}{"type": "string"},
+		"image": map[string]interface{}{"type": "string"},
+	}
+	containers := map[string]interface{}{"type": "array", "items": container}
+
+	spec := preservedObjectSchema()
+	spec["properties"] = map[string]interface{}{
+		"containers":     containers,
+		"initContainers": containers,
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"labels":      stringMap,
+					"annotations": stringMap,
+				},
+			},
+			"spec": spec,
+		},
+	}
+}
+
+func preservedObjectSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                                 "object",
+		"x-kubernetes-preserve-unknown-fields": true,
+	}
+}
+
+// ValidatePodTemplate checks the pod template embedded in a custom resource at the path, so that invalid templates
+// are rejected by a webhook or reported in the status rather than failing when the operator creates the pods. The
+// labels of the template must match the selector, if any.
+func ValidatePodTemplate(template *v1.PodTemplateSpec, path *field.Path, selector map[string]string) field.ErrorList {
+	var errs field.ErrorList
+	if len(selector) > 0 && !labels.SelectorFromSet(selector).Matches(labels.Set(template.Labels)) {
+		errs = append(errs, field.Invalid(path.Child("metadata", "labels"), template.Labels, "must match the selector"))
+	}
+	for key, value := range template.Labels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(path.Child("metadata", "labels"), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			errs = append(errs, field.Invalid(path.Child("metadata", "labels").Key(key), value, msg))
+		}
+	}
+
+	specPath := path.Child("spec")
+	if len(template.Spec.Containers) == 0 {
+		errs = append(errs, field.Required(specPath.Child("containers"), "at least one container is required"))
+	}
+	names := sets.NewString()
+	errs = append(errs, validateContainers(template.Spec.InitContainers, specPath.Child("initContainers"), names)...)
+	errs = append(errs, validateContainers(template.Spec.Containers, specPath.Child("containers"), names)...)
+
+	volumes := sets.NewString()
+	for i, volume := range template.Spec.Volumes {
+		volumePath := specPath.Child("volumes").Index(i)
+		if volumes.Has(volume.Name) {
+			errs = append(errs, field.Duplicate(volumePath.Child("name"), volume.Name))
+		}
+		volumes.Insert(volume.Name)
+		for _, msg := range validation.IsDNS1123Label(volume.Name) {
+			errs = append(errs, field.Invalid(volumePath.Child("name"), volume.Name, msg))
+		}
+	}
+	for _, containers := range []struct {
+		list []v1.Container
+		path *field.Path
+	}{{template.Spec.InitContainers, specPath.Child("initContainers")}, {template.Spec.Containers, specPath.Child("containers")}} {
+		for i, container := range containers.list {
+			for j, mount := range container.VolumeMounts {
+				if !volumes.Has(mount.Name) {
+					errs = append(errs, field.NotFound(containers.path.Index(i).Child("volumeMounts").Index(j).Child("name"), mount.Name))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// validateContainers checks the names and images of the containers. Names must be unique across the init and app
+// containers of the pod.
+func validateContainers(containers []v1.Container, path *field.Path, names sets.String) field.ErrorList {
+	var errs field.ErrorList
+	for i, container := range containers {
+		containerPath := path.Index(i)
+		if names.Has(container.Name) {
+			errs = append(errs, field.Duplicate(containerPath.Child("name"), container.Name))
+		}
+		names.Insert(container.Name)
+		for _, msg := range validation.IsDNS1123Label(container.Name) {
+			errs = append(errs, field.Invalid(containerPath.Child("name"), container.Name, msg))
+		}
+		if strings.TrimSpace(container.Image) == "" {
+			errs = append(errs, field.Required(containerPath.Child("image"), ""))
+		}
+	}
+	return errs
+}
+
+// DefaultPodTemplate sets the fields of the pod template that the apiserver would default, so that the template
+// rendered by the operator compares equal to the template read back from the cluster and does not cause endless
+// updates. The labels are added to the template without replacing labels set by the user.
+func DefaultPodTemplate(template *v1.PodTemplateSpec, podLabels map[string]string) {
+	for key, value := range podLabels {
+		if template.Labels == nil {
+			template.Labels = map[string]string{}
+		}
+		if _, ok := template.Labels[key]; !ok {
+			template.Labels[key] = value
+		}
+	}
+
+	spec := &template.Spec
+	if spec.RestartPolicy == "" {
+		spec.RestartPolicy = v1.RestartPolicyAlways
+	}
+	if spec.DNSPolicy == "" {
+		spec.DNSPolicy = v1.DNSClusterFirst
+	}
+	if spec.SchedulerName == "" {
+		spec.SchedulerName = v1.DefaultSchedulerName
+	}
+	if spec.TerminationGracePeriodSeconds == nil {
+		period := int64(v1.DefaultTerminationGracePeriodSeconds)
+		spec.TerminationGracePeriodSeconds = &period
+	}
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &v1.PodSecurityContext{}
+	}
+	for i := range spec.InitContainers {
+		defaultContainer(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		defaultContainer(&spec.Containers[i])
+	}
+}
+
+func defaultContainer(container *v1.Container) {
+	if container.ImagePullPolicy == "" {
+		container.ImagePullPolicy = v1.PullIfNotPresent
+		if image := container.Image; strings.HasSuffix(image, ":latest") || !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") {
+			container.ImagePullPolicy = v1.PullAlways
+		}
+	}
+	if container.TerminationMessagePath == "" {
+		container.TerminationMessagePath = v1.TerminationMessagePathDefault
+	}
+	if container.TerminationMessagePolicy == "" {
+		container.TerminationMessagePolicy = v1.TerminationMessageReadFile
+	}
+	for i := range container.Ports {
+		if container.Ports[i].Protocol == "" {
+			container.Ports[i].Protocol = v1.ProtocolTCP
+		}
+	}
+}
+
+// MergePodTemplate merges the template of a custom resource, such as the overrides of a user, into the template
+// rendered by the operator. Maps are merged, and containers, volumes, env vars, volume mounts, and ports are merged
+// with the item of the same name, mount path, or port. Other fields set in the override replace the base.
+func MergePodTemplate(base, override *v1.PodTemplateSpec) (*v1.PodTemplateSpec, error) {
+	var baseMap, overrideMap map[string]interface{}
+	if err := roundTripJSON(base, &baseMap); err != nil {
+		return nil, fmt.Errorf("failed to serialize the pod template. %+v", err)
+	}
+	if err := roundTripJSON(override, &overrideMap); err != nil {
+		return nil, fmt.Errorf("failed to serialize the pod template override. %+v", err)
+	}
+
+	merged := &v1.PodTemplateSpec{}
+	if err := roundTripJSON(mergeJSONValue("", baseMap, overrideMap), merged); err != nil {
+		return nil, fmt.Errorf("failed to merge the pod template. %+v", err)
+	}
+	return merged, nil
+}
+
+func roundTripJSON(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// mergeJSONValue merges the override into the base value of the named field
+func mergeJSONValue(name string, base, override interface{}) interface{} {
+	switch overrideValue := override.(type) {
+	case map[string]interface{}:
+		baseValue, ok := base.(map[string]interface{})
+		if !ok {
+			return overrideValue
+		}
+		merged := map[string]interface{}{}
+		for key, value := range baseValue {
+			merged[key] = value
+		}
+		for key, value := range overrideValue {
+			merged[key] = mergeJSONValue(key, baseValue[key], value)
+		}
+		return merged
+	case []interface{}:
+		baseValue, ok := base.([]interface{})
+		mergeKey := podTemplateMergeKeys[name]
+		if !ok || mergeKey == "" {
+			return overrideValue
+		}
+		return mergeJSONList(mergeKey, baseValue, overrideValue)
+	case nil:
+		return base
+	default:
+		return overrideValue
+	}
+}
+
+// mergeJSONList merges the items of the override into the base items with the same merge key, and appends the
+// other items in their order
+func mergeJSONList(mergeKey string, base, override []interface{}) []interface{} {
+	merged := append([]interface{}{}, base...)
+	for _, item := range override {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			merged = append(merged, item)
+			continue
+		}
+		found := false
+		for i, baseItem := range merged {
+			if baseMap, ok := baseItem.(map[string]interface{}); ok && baseMap[mergeKey] == itemMap[mergeKey] {
+				merged[i] = mergeJSONValue("", baseMap, itemMap)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
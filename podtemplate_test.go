@@ -0,0 +1,124 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestPodTemplateSchema(t *testing.T) {
+	opaque := PodTemplateSchema(PodTemplateOpaque)
+	assert.Equal(t, true, opaque["x-kubernetes-preserve-unknown-fields"])
+	assert.Nil(t, opaque["properties"])
+
+	pruned := PodTemplateSchema(PodTemplatePruned)
+	spec := pruned["properties"].(map[string]interface{})["spec"].(map[string]interface{})
+	assert.Equal(t, true, spec["x-kubernetes-preserve-unknown-fields"])
+	containers := spec["properties"].(map[string]interface{})["containers"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"name"}, containers["items"].(map[string]interface{})["required"])
+}
+
+func TestValidatePodTemplate(t *testing.T) {
+	template := &v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "sample"}},
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{{Name: "data"}},
+			Containers: []v1.Container{
+				{Name: "main", Image: "sample:1.0", VolumeMounts: []v1.VolumeMount{{Name: "data", MountPath: "/data"}}},
+			},
+		},
+	}
+	path := field.NewPath("spec", "template")
+	assert.Empty(t, ValidatePodTemplate(template, path, map[string]string{"app": "sample"}))
+
+	errs := ValidatePodTemplate(template, path, map[string]string{"app": "other"})
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, "spec.template.metadata.labels", errs[0].Field)
+
+	template.Spec.InitContainers = []v1.Container{{Name: "main"}}
+	template.Spec.Containers[0].VolumeMounts[0].Name = "missing"
+	errs = ValidatePodTemplate(template, path, nil)
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	assert.Equal(t, []string{
+		"spec.template.spec.initContainers[0].image",
+		"spec.template.spec.containers[0].name",
+		"spec.template.spec.containers[0].volumeMounts[0].name",
+	}, fields)
+
+	errs = ValidatePodTemplate(&v1.PodTemplateSpec{}, path, nil)
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, field.ErrorTypeRequired, errs[0].Type)
+}
+
+func TestDefaultPodTemplate(t *testing.T) {
+	template := &v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "custom"}},
+		Spec: v1.PodSpec{Containers: []v1.Container{
+			{Name: "pinned", Image: "registry:5000/sample:1.0", Ports: []v1.ContainerPort{{ContainerPort: 80}}},
+			{Name: "latest", Image: "registry:5000/sample"},
+		}},
+	}
+	DefaultPodTemplate(template, map[string]string{"app": "sample", "owner": "operator"})
+
+	assert.Equal(t, map[string]string{"app": "custom", "owner": "operator"}, template.Labels)
+	assert.Equal(t, v1.RestartPolicyAlways, template.Spec.RestartPolicy)
+	assert.Equal(t, int64(30), *template.Spec.TerminationGracePeriodSeconds)
+	assert.Equal(t, v1.PullIfNotPresent, template.Spec.Containers[0].ImagePullPolicy)
+	assert.Equal(t, v1.ProtocolTCP, template.Spec.Containers[0].Ports[0].Protocol)
+	assert.Equal(t, v1.PullAlways, template.Spec.Containers[1].ImagePullPolicy)
+}
+
+func TestMergePodTemplate(t *testing.T) {
+	base := &v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "sample"}},
+		Spec: v1.PodSpec{
+			ServiceAccountName: "sample",
+			Containers: []v1.Container{{
+				Name:  "main",
+				Image: "sample:1.0",
+				Env:   []v1.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}},
+			}},
+		},
+	}
+	override := &v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "storage"}},
+		Spec: v1.PodSpec{
+			NodeSelector: map[string]string{"disk": "ssd"},
+			Containers: []v1.Container{
+				{Name: "main", Env: []v1.EnvVar{{Name: "B", Value: "3"}}},
+				{Name: "sidecar", Image: "proxy:2.0"},
+			},
+		},
+	}
+
+	merged, err := MergePodTemplate(base, override)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "sample", "team": "storage"}, merged.Labels)
+	assert.Equal(t, "sample", merged.Spec.ServiceAccountName)
+	assert.Equal(t, map[string]string{"disk": "ssd"}, merged.Spec.NodeSelector)
+	assert.Equal(t, 2, len(merged.Spec.Containers))
+	assert.Equal(t, "sample:1.0", merged.Spec.Containers[0].Image)
+	assert.Equal(t, []v1.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "3"}}, merged.Spec.Containers[0].Env)
+	assert.Equal(t, "proxy:2.0", merged.Spec.Containers[1].Image)
+}
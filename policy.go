@@ -0,0 +1,177 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// PolicyFunc checks a child object and returns an error describing how it violates the policy, or nil
+type PolicyFunc func(child *unstructured.Unstructured) error
+
+// Policy is an organization rule checked on each child object before the kit applies it, such as required labels.
+// Policies are plain funcs so they can be shared across the operators of several teams.
+type Policy struct {
+	// Name identifies the policy in violations
+	Name string
+
+	// Kinds the policy applies to, such as "Deployment". Empty means all kinds.
+	Kinds []string
+
+	Check PolicyFunc
+}
+
+// PolicyViolation is returned when a child object violates a policy
+type PolicyViolation struct {
+	Policy    string
+	Kind      string
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (v *PolicyViolation) Error() string {
+	name := v.Name
+	if v.Namespace != "" {
+		name = v.Namespace + "/" + v.Name
+	}
+	return fmt.Sprintf("%s %s violates policy %s. %+v", v.Kind, name, v.Policy, v.Err)
+}
+
+// IsPolicyViolation returns whether the error or one of the errors it aggregates is a policy violation
+func IsPolicyViolation(err error) bool {
+	if aggregate, ok := err.(errorsUtil.Aggregate); ok {
+		for _, e := range aggregate.Errors() {
+			if IsPolicyViolation(e) {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := err.(*PolicyViolation)
+	return ok
+}
+
+// CheckPolicies checks the typed or unstructured child object against the policies of its kind. It returns an
+// aggregate of the violations, or nil if there are none.
+func CheckPolicies(obj runtime.Object, policies ...Policy) error {
+	if len(policies) == 0 {
+		return nil
+	}
+	object, err := jsonMap(obj)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the child. %+v", err)
+	}
+	child := &unstructured.Unstructured{Object: object}
+	kind := childKind(obj, child.GetKind())
+	if child.GetKind() == "" {
+		child.SetKind(kind)
+	}
+
+	var errs []error
+	for _, policy := range policies {
+		if !policy.appliesTo(kind) {
+			continue
+		}
+		if err := policy.Check(child); err != nil {
+			errs = append(errs, &PolicyViolation{Policy: policy.Name, Kind: kind, Namespace: child.GetNamespace(),
+				Name: child.GetName(), Err: err})
+		}
+	}
+	return errorsUtil.NewAggregate(errs)
+}
+
+func (p Policy) appliesTo(kind string) bool {
+	if len(p.Kinds) == 0 {
+		return true
+	}
+	for _, k := range p.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireLabels is a policy requiring the labels on all children
+func RequireLabels(keys ...string) Policy {
+	return Policy{
+		Name: "RequireLabels",
+		Check: func(child *unstructured.Unstructured) error {
+			var missing []string
+			for _, key := range keys {
+				if _, ok := child.GetLabels()[key]; !ok {
+					missing = append(missing, key)
+				}
+			}
+			if len(missing) > 0 {
+				sort.Strings(missing)
+				return fmt.Errorf("missing labels %s", strings.Join(missing, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// DenyPrivilegedContainers is a policy rejecting privileged containers in pods and in the pod templates of
+// workloads, such as deployments, jobs, and cron jobs
+func DenyPrivilegedContainers() Policy {
+	return Policy{
+		Name: "DenyPrivilegedContainers",
+		Check: func(child *unstructured.Unstructured) error {
+			var privileged []string
+			spec := podSpecOf(child)
+			for _, field := range []string{"initContainers", "containers"} {
+				containers, _ := spec[field].([]interface{})
+				for _, container := range containers {
+					c, _ := container.(map[string]interface{})
+					securityContext, _ := c["securityContext"].(map[string]interface{})
+					if securityContext["privileged"] == true {
+						privileged = append(privileged, fmt.Sprint(c["name"]))
+					}
+				}
+			}
+			if len(privileged) > 0 {
+				return fmt.Errorf("privileged containers %s", strings.Join(privileged, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// podSpecOf returns the spec of the pod or of the pod template of the object, or nil if it has none
+func podSpecOf(obj *unstructured.Unstructured) map[string]interface{} {
+	path := []string{"spec", "template", "spec"}
+	switch obj.GetKind() {
+	case "Pod":
+		path = []string{"spec"}
+	case "CronJob":
+		path = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	}
+	current := obj.Object
+	for _, field := range path {
+		current, _ = current[field].(map[string]interface{})
+	}
+	return current
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCheckPolicies(t *testing.T) {
+	privileged := true
+	deployment := &appsv1beta2.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "osd", Labels: map[string]string{"team": "storage"}},
+		Spec: appsv1beta2.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "init"}},
+			Containers:     []v1.Container{{Name: "osd", SecurityContext: &v1.SecurityContext{Privileged: &privileged}}},
+		}}},
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Labels: map[string]string{"team": "storage", "app": "p"}},
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "c"}}}}
+
+	assert.NoError(t, CheckPolicies(deployment))
+	assert.NoError(t, CheckPolicies(pod, RequireLabels("team", "app"), DenyPrivilegedContainers()))
+
+	err := CheckPolicies(deployment, RequireLabels("team", "app", "cost-center"), DenyPrivilegedContainers())
+	assert.True(t, IsPolicyViolation(err))
+	assert.Contains(t, err.Error(), "Deployment ns/osd violates policy RequireLabels. missing labels app, cost-center")
+	assert.Contains(t, err.Error(), "Deployment ns/osd violates policy DenyPrivilegedContainers. privileged containers osd")
+
+	// policies of other kinds are skipped
+	onlyPods := Policy{Name: "NoDeployments", Kinds: []string{"Pod"}, Check: func(child *unstructured.Unstructured) error {
+		return fmt.Errorf("denied")
+	}}
+	assert.NoError(t, CheckPolicies(deployment, onlyPods))
+	assert.Error(t, CheckPolicies(pod, onlyPods))
+	assert.False(t, IsPolicyViolation(fmt.Errorf("other")))
+
+	// the violation is returned before calling the apiserver
+	err = Apply(nil, "deployments", deployment, ApplyOptions{Policies: []Policy{DenyPrivilegedContainers()}})
+	assert.True(t, IsPolicyViolation(err))
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// PermissionError lists the permissions the operator is missing
+type PermissionError struct {
+	Missing []authorizationv1.ResourceAttributes
+}
+
+func (e *PermissionError) Error() string {
+	missing := make([]string, 0, len(e.Missing))
+	for _, attributes := range e.Missing {
+		missing = append(missing, describeAttributes(attributes))
+	}
+	return fmt.Sprintf("missing permissions: %s", strings.Join(missing, "; "))
+}
+
+// Preflight checks that the operator has the PolicyRules of the custom resources and children in the namespace,
+// or in all namespaces if the namespace is empty. Rules on CRDs are always checked at the cluster scope.
+func Preflight(context Context, namespace string, resources []CustomResource, children []ChildResource) error {
+	return CheckPermissions(context, namespace, PolicyRules(resources, children))
+}
+
+// CheckPermissions runs a SelfSubjectAccessReview for every verb on every resource of the rules. All missing
+// permissions are returned together in a *PermissionError, so they can be fixed at once rather than failing the
+// operator one Forbidden error at a time.
+func CheckPermissions(context Context, namespace string, rules []rbacv1.PolicyRule) error {
+	var missing []authorizationv1.ResourceAttributes
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				for _, verb := range rule.Verbs {
+					attributes := resourceAttributes(namespace, group, resource, verb)
+					allowed, err := accessAllowed(context, attributes)
+					if err != nil {
+						return err
+					}
+					if !allowed {
+						missing = append(missing, attributes)
+					}
+				}
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return &PermissionError{Missing: missing}
+	}
+	return nil
+}
+
+func accessAllowed(context Context, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+	}
+	result, err := context.Clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+	if err != nil {
+		return false, fmt.Errorf("failed to review access to %s. %+v", describeAttributes(attributes), err)
+	}
+	return result.Status.Allowed, nil
+}
+
+// resourceAttributes splits the subresource from a rule resource such as "examples/status"
+func resourceAttributes(namespace, group, resource, verb string) authorizationv1.ResourceAttributes {
+	attributes := authorizationv1.ResourceAttributes{Namespace: namespace, Group: group, Resource: resource, Verb: verb}
+	if parts := strings.SplitN(resource, "/", 2); len(parts) == 2 {
+		attributes.Resource = parts[0]
+		attributes.Subresource = parts[1]
+	}
+	if group == apiextensionsGroup && attributes.Resource == crdResourceName {
+		attributes.Namespace = ""
+	}
+	return attributes
+}
+
+// describeAttributes returns a description such as "update examples.example.com/status in namespace ns"
+func describeAttributes(attributes authorizationv1.ResourceAttributes) string {
+	resource := attributes.Resource
+	if attributes.Group != "" {
+		resource += "." + attributes.Group
+	}
+	if attributes.Subresource != "" {
+		resource += "/" + attributes.Subresource
+	}
+	description := fmt.Sprintf("%s %s", attributes.Verb, resource)
+	if attributes.Namespace != "" {
+		description += " in namespace " + attributes.Namespace
+	}
+	return description
+}
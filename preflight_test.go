@@ -0,0 +1,65 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestPreflight(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	var reviewed []authorizationv1.ResourceAttributes
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := *review.Spec.ResourceAttributes
+		reviewed = append(reviewed, attributes)
+		review.Status.Allowed = !(attributes.Resource == "customresourcedefinitions" && attributes.Verb == "create") &&
+			!(attributes.Subresource == "status" && attributes.Verb == "update")
+		return true, review, nil
+	})
+	context := Context{Clientset: clientset}
+
+	err := Preflight(context, "ns", []CustomResource{exampleResource}, []ChildResource{{Resource: "configmaps"}})
+	permissionErr, ok := err.(*PermissionError)
+	assert.True(t, ok)
+	assert.Equal(t, []authorizationv1.ResourceAttributes{
+		{Verb: "create", Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"},
+		{Namespace: "ns", Verb: "update", Group: "example.com", Resource: "examples", Subresource: "status"},
+	}, permissionErr.Missing)
+	assert.Equal(t, "missing permissions: create customresourcedefinitions.apiextensions.k8s.io; update examples.example.com/status in namespace ns", err.Error())
+	assert.Equal(t, 7+2+4+3+5+1, len(reviewed))
+
+	assert.NoError(t, CheckPermissions(context, "", PolicyRules(nil, []ChildResource{{Resource: "secrets", Verbs: []string{"get"}}})))
+}
+
+func TestPreflightReviewFailure(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("unavailable")
+	})
+
+	err := Preflight(Context{Clientset: clientset}, "", []CustomResource{exampleResource}, nil)
+	assert.Error(t, err)
+	_, ok := err.(*PermissionError)
+	assert.False(t, ok)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// ProtectedAnnotation protects a custom resource from deletion while its value is "true"
+	ProtectedAnnotation = "operatorkit.io/protected"
+
+	// ProtectionFinalizer is kept by EnsureProtection on protected custom resources so that a deletion does not
+	// complete until the ProtectedAnnotation is removed
+	ProtectionFinalizer = "operatorkit.io/protection"
+)
+
+// ProtectedError is returned when the deletion of a protected custom resource is rejected
+type ProtectedError struct {
+	Namespace string
+	Name      string
+}
+
+func (e *ProtectedError) Error() string {
+	name := e.Name
+	if e.Namespace != "" {
+		name = e.Namespace + "/" + e.Name
+	}
+	return fmt.Sprintf("%s is protected from deletion. remove the %s annotation to delete it", name, ProtectedAnnotation)
+}
+
+// IsProtected returns whether the object has the ProtectedAnnotation set to "true"
+func IsProtected(obj metav1.Object) bool {
+	return obj.GetAnnotations()[ProtectedAnnotation] == "true"
+}
+
+// DeletionProtector returns a validate func for the webhook of a custom resource that rejects the deletion of
+// protected resources. The webhook configuration must include the DELETE operation. The deleted object is only sent
+// to webhooks from Kubernetes 1.15, so older clusters need EnsureProtection instead. The next func is optional and
+// validates the other requests.
+func DeletionProtector(next ValidateFunc) ValidateFunc {
+	return func(request *AdmissionRequest) error {
+		if request.Operation == "DELETE" {
+			protected, err := rawAnnotation(request.OldObject, ProtectedAnnotation)
+			if err != nil {
+				return err
+			}
+			if protected == "true" {
+				return &ProtectedError{Namespace: request.Namespace, Name: request.Name}
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		return next(request)
+	}
+}
+
+// EnsureProtection keeps the ProtectionFinalizer on the custom resource while it is protected and removes it once
+// the ProtectedAnnotation is removed, which lets a blocked deletion complete. It returns true when the resource is
+// being deleted and the deletion is blocked, in which case the reconcile should neither finalize nor recreate the
+// resource. Call it before HandleDeletion.
+func EnsureProtection(context Context, client rest.Interface, resource CustomResource, obj runtime.Object) (bool, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+	protected := IsProtected(accessor)
+	deleting := accessor.GetDeletionTimestamp() != nil
+
+	if !protected {
+		if RemoveFinalizer(accessor, ProtectionFinalizer) {
+			return false, updateObject(client, resource, accessor, obj)
+		}
+		return false, nil
+	}
+	if HasFinalizer(accessor, ProtectionFinalizer) {
+		if deleting {
+			context.logger().Info("deletion blocked by the protected annotation", "resource", resource.Name,
+				"namespace", accessor.GetNamespace(), "name", accessor.GetName())
+		}
+		return deleting, nil
+	}
+	if deleting {
+		// finalizers cannot be added to a resource that is being deleted
+		return false, nil
+	}
+	AddFinalizer(accessor, ProtectionFinalizer)
+	return false, updateObject(client, resource, accessor, obj)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+func TestDeletionProtector(t *testing.T) {
+	handler := NewValidatingWebhookHandler(DeletionProtector(nil))
+	review := func(operation, oldObject string) *AdmissionResponse {
+		body := []byte(`{"request":{"uid":"1","namespace":"ns","name":"a","operation":"` + operation + `","oldObject":` + oldObject + `}}`)
+		r := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		result := &AdmissionReview{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+		return result.Response
+	}
+
+	protected := `{"metadata":{"annotations":{"operatorkit.io/protected":"true"}}}`
+	response := review("DELETE", protected)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "ns/a is protected from deletion. remove the operatorkit.io/protected annotation to delete it", response.Result.Message)
+
+	assert.True(t, review("DELETE", `{"metadata":{"annotations":{"operatorkit.io/protected":"false"}}}`).Allowed)
+	assert.True(t, review("DELETE", `null`).Allowed)
+	assert.True(t, review("UPDATE", protected).Allowed)
+}
+
+func TestEnsureProtection(t *testing.T) {
+	var updates []string
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host: "http://protection",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := ioutil.ReadAll(req.Body)
+			updates = append(updates, string(body))
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			recorder.Write(body)
+			return recorder.Result(), nil
+		}),
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &schema.GroupVersion{Version: "v1"},
+			NegotiatedSerializer: scheme.Codecs,
+		},
+		APIPath: "/api",
+	})
+	assert.NoError(t, err)
+	resource := CustomResource{Name: "configmap", Plural: "configmaps"}
+
+	obj := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a", Annotations: map[string]string{ProtectedAnnotation: "true"}}}
+	blocked, err := EnsureProtection(Context{}, client, resource, obj)
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+	assert.Equal(t, []string{ProtectionFinalizer}, obj.Finalizers)
+	assert.Equal(t, 1, len(updates))
+
+	// the deletion is blocked until the annotation is removed
+	now := metav1.NewTime(time.Now())
+	obj.DeletionTimestamp = &now
+	blocked, err = EnsureProtection(Context{}, client, resource, obj)
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+	assert.Equal(t, 1, len(updates))
+
+	delete(obj.Annotations, ProtectedAnnotation)
+	blocked, err = EnsureProtection(Context{}, client, resource, obj)
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+	assert.Equal(t, 0, len(obj.Finalizers))
+	assert.Equal(t, 2, len(updates))
+}
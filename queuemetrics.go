@@ -0,0 +1,198 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// instrumentedQueue counts the adds and retries of the keys of a controller by namespace, and tracks the keys waiting
+// in the queue and being reconciled for the queue collector of the metrics
+type instrumentedQueue struct {
+	workqueue.RateLimitingInterface
+	metrics *Metrics
+	kind    string
+
+	lock    sync.Mutex
+	waiting map[string]bool
+	running map[string]time.Time
+}
+
+// instrumentQueue wraps the work queue of a controller of the kind. The queue is returned as is without metrics.
+func (m *Metrics) instrumentQueue(kind string, queue workqueue.RateLimitingInterface) workqueue.RateLimitingInterface {
+	if m == nil {
+		return queue
+	}
+	q := &instrumentedQueue{RateLimitingInterface: queue, metrics: m, kind: kind, waiting: map[string]bool{},
+		running: map[string]time.Time{}}
+	m.queuesLock.Lock()
+	m.queues = append(m.queues, q)
+	m.queuesLock.Unlock()
+	return q
+}
+
+// ShutDown stops the queue and removes it from the queue collector, for example when its controller stops
+func (q *instrumentedQueue) ShutDown() {
+	q.metrics.queuesLock.Lock()
+	for i, queue := range q.metrics.queues {
+		if queue == q {
+			q.metrics.queues = append(q.metrics.queues[:i], q.metrics.queues[i+1:]...)
+			break
+		}
+	}
+	q.metrics.queuesLock.Unlock()
+	q.RateLimitingInterface.ShutDown()
+}
+
+func (q *instrumentedQueue) Add(item interface{}) {
+	q.added(item, false)
+	q.RateLimitingInterface.Add(item)
+}
+
+func (q *instrumentedQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.added(item, false)
+	q.RateLimitingInterface.AddAfter(item, duration)
+}
+
+func (q *instrumentedQueue) AddRateLimited(item interface{}) {
+	q.added(item, true)
+	q.RateLimitingInterface.AddRateLimited(item)
+}
+
+func (q *instrumentedQueue) Get() (interface{}, bool) {
+	item, shutdown := q.RateLimitingInterface.Get()
+	if key, ok := item.(string); ok {
+		q.lock.Lock()
+		delete(q.waiting, key)
+		q.running[key] = time.Now()
+		q.lock.Unlock()
+	}
+	return item, shutdown
+}
+
+func (q *instrumentedQueue) Done(item interface{}) {
+	if key, ok := item.(string); ok {
+		q.lock.Lock()
+		delete(q.running, key)
+		q.lock.Unlock()
+	}
+	q.RateLimitingInterface.Done(item)
+}
+
+// added counts the key, which waits in the queue until a worker gets it, including during its delay
+func (q *instrumentedQueue) added(item interface{}, retry bool) {
+	key, ok := item.(string)
+	if !ok {
+		return
+	}
+	namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+	q.metrics.queueAdds.WithLabelValues(q.kind, namespace).Inc()
+	if retry {
+		q.metrics.queueRetries.WithLabelValues(q.kind, namespace).Inc()
+	}
+	q.lock.Lock()
+	q.waiting[key] = true
+	q.lock.Unlock()
+}
+
+// queueCollector reports the keys waiting in the instrumented queues and the longest running reconcile by kind and
+// namespace when the metrics are gathered. The queues of several controllers of the same kind, such as controllers
+// sharing an informer, are reported together.
+type queueCollector struct {
+	metrics        *Metrics
+	depth          *prometheus.Desc
+	longestRunning *prometheus.Desc
+}
+
+func newQueueCollector(m *Metrics, namespace string) *queueCollector {
+	return &queueCollector{
+		metrics: m,
+		depth: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "workqueue_namespace_depth"),
+			"Number of keys waiting in the work queue of a controller by kind and namespace, including keys waiting for a retry.",
+			[]string{"kind", "namespace"}, nil),
+		longestRunning: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "reconcile_longest_running_seconds"),
+			"Time spent so far by the longest reconcile in progress by kind and namespace.",
+			[]string{"kind", "namespace"}, nil),
+	}
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+	ch <- c.longestRunning
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	c.metrics.queuesLock.Lock()
+	queues := append([]*instrumentedQueue{}, c.metrics.queues...)
+	c.metrics.queuesLock.Unlock()
+
+	now := time.Now()
+	depth := map[queueLabels]int{}
+	longest := map[queueLabels]time.Duration{}
+	for _, q := range queues {
+		q.lock.Lock()
+		for key := range q.waiting {
+			namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+			depth[queueLabels{kind: q.kind, namespace: namespace}]++
+		}
+		for key, start := range q.running {
+			namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+			labels := queueLabels{kind: q.kind, namespace: namespace}
+			if running := now.Sub(start); running > longest[labels] {
+				longest[labels] = running
+			}
+		}
+		q.lock.Unlock()
+	}
+
+	for labels, keys := range depth {
+		ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(keys), labels.kind, labels.namespace)
+	}
+	for labels, running := range longest {
+		ch <- prometheus.MustNewConstMetric(c.longestRunning, prometheus.GaugeValue, running.Seconds(), labels.kind,
+			labels.namespace)
+	}
+}
+
+// queueLabels are the labels of the metrics of the queue collector
+type queueLabels struct {
+	kind      string
+	namespace string
+}
+
+// observeWatchEvent counts an event of the watch of the kind for the custom resource with the key
+func (m *Metrics) observeWatchEvent(kind, key, event string) {
+	if m == nil {
+		return
+	}
+	namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+	m.watchEvents.WithLabelValues(kind, namespace, event).Inc()
+}
+
+// metricsKind is the kind label of the metrics of the resource, which falls back to its name without a kind
+func metricsKind(resource CustomResource) string {
+	if resource.Kind != "" {
+		return resource.Kind
+	}
+	return resource.Name
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestQueueMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := NewMetricsWithRegistry("test", registry)
+	queue := m.instrumentQueue("Example", workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()))
+	defer queue.ShutDown()
+
+	queue.Add("ns1/a")
+	queue.Add("ns1/b")
+	queue.AddRateLimited("ns2/c")
+	key, _ := queue.Get()
+	assert.Equal(t, "ns1/a", key)
+
+	assert.Equal(t, map[string]float64{"kind=Example,namespace=ns1": 2, "kind=Example,namespace=ns2": 1},
+		gatherLabeled(t, registry, "test_workqueue_adds_total"))
+	assert.Equal(t, map[string]float64{"kind=Example,namespace=ns2": 1},
+		gatherLabeled(t, registry, "test_workqueue_retries_total"))
+	assert.Equal(t, map[string]float64{"kind=Example,namespace=ns1": 1, "kind=Example,namespace=ns2": 1},
+		gatherLabeled(t, registry, "test_workqueue_namespace_depth"))
+	longest := gatherLabeled(t, registry, "test_reconcile_longest_running_seconds")
+	_, ok := longest["kind=Example,namespace=ns1"]
+	assert.True(t, ok)
+
+	queue.Done(key)
+	assert.Equal(t, 0, len(gatherLabeled(t, registry, "test_reconcile_longest_running_seconds")))
+
+	m.observeWatchEvent("Example", "ns1/a", "update")
+	assert.Equal(t, map[string]float64{"event=update,kind=Example,namespace=ns1": 1},
+		gatherLabeled(t, registry, "test_watch_events_total"))
+
+	// without metrics the queue is not wrapped
+	var none *Metrics
+	plain := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	assert.Equal(t, plain, none.instrumentQueue("Example", plain))
+	assert.Equal(t, "example", metricsKind(exampleResource))
+}
+
+func TestQueueMetricsOfControllersOfOneKind(t *testing.T) {
+	m := NewMetrics("test")
+	first := m.instrumentQueue("Example", workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()))
+	second := m.instrumentQueue("Example", workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()))
+	defer second.ShutDown()
+
+	// the queues are reported together rather than as duplicate series
+	first.Add("ns/a")
+	second.Add("ns/b")
+	second.Add("ns/c")
+	first.Get()
+	second.Get()
+	assert.Equal(t, map[string]float64{"kind=Example,namespace=ns": 1}, gatherLabeled(t, m.Gatherer(), "test_workqueue_namespace_depth"))
+	assert.Equal(t, 1, len(gatherLabeled(t, m.Gatherer(), "test_reconcile_longest_running_seconds")))
+
+	// stopped queues are not reported anymore
+	first.ShutDown()
+	assert.Equal(t, 1, len(m.queues))
+	second.Add("ns/d")
+	assert.Equal(t, map[string]float64{"kind=Example,namespace=ns": 2}, gatherLabeled(t, m.Gatherer(), "test_workqueue_namespace_depth"))
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// DefaultChildVerbs are granted on child resources declared without verbs
+	DefaultChildVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+	customResourceVerbs = []string{"get", "list", "watch", "update", "patch"}
+	subresourceVerbs    = []string{"get", "update", "patch"}
+	primaryCRDVerbs     = []string{"get", "list", "watch", "create"}
+	secondaryCRDVerbs   = []string{"get", "list", "watch"}
+	eventVerbs          = []string{"create", "patch"}
+)
+
+const crdResourceName = "customresourcedefinitions"
+
+// ChildResource is a resource a controller creates or reads for its custom resources, such as "deployments" of
+// the "apps" group
+type ChildResource struct {
+	// Group of the resource, empty for the core group
+	Group string
+
+	// Resource is the plural name of the resource
+	Resource string
+
+	// Verbs needed on the resource. Defaults to DefaultChildVerbs.
+	Verbs []string
+}
+
+// PolicyRules returns the minimal rules to run the controllers of the custom resources and manage their children:
+// reading and updating the custom resources with their status and finalizers, creating or, for the secondary role,
+// reading the CRDs, emitting events, and the verbs of the children. Resources with the same verbs are merged into
+// one rule per group.
+func PolicyRules(resources []CustomResource, children []ChildResource) []rbacv1.PolicyRule {
+	rules := policyRules{}
+	for _, resource := range resources {
+		rules.add(resource.Group, resource.Plural, customResourceVerbs)
+		rules.add(resource.Group, resource.Plural+"/status", subresourceVerbs)
+		rules.add(resource.Group, resource.Plural+"/finalizers", []string{"update"})
+		if resource.Role == SecondaryCRDRole {
+			rules.add(apiextensionsGroup, crdResourceName, secondaryCRDVerbs)
+		} else {
+			rules.add(apiextensionsGroup, crdResourceName, primaryCRDVerbs)
+		}
+	}
+	rules.add("", "events", eventVerbs)
+	for _, child := range children {
+		verbs := child.Verbs
+		if len(verbs) == 0 {
+			verbs = DefaultChildVerbs
+		}
+		rules.add(child.Group, child.Resource, verbs)
+	}
+	return rules.merge()
+}
+
+// NewClusterRole returns a cluster role with the PolicyRules of the custom resources and children
+func NewClusterRole(name string, resources []CustomResource, children []ChildResource) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Rules:      PolicyRules(resources, children),
+	}
+}
+
+// NewRole returns a role for operators watching a single namespace. The rules on CRDs are left out since they are
+// cluster scoped and need a cluster role of their own, unless the CRDs are installed ahead of time.
+func NewRole(name, namespace string, resources []CustomResource, children []ChildResource) *rbacv1.Role {
+	var rules []rbacv1.PolicyRule
+	for _, rule := range PolicyRules(resources, children) {
+		if len(rule.APIGroups) == 1 && rule.APIGroups[0] == apiextensionsGroup {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return &rbacv1.Role{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Rules:      rules,
+	}
+}
+
+// ExportRBAC writes the cluster role of the custom resources and children to out as YAML
+func ExportRBAC(name string, resources []CustomResource, children []ChildResource, out io.Writer) error {
+	data, err := yaml.Marshal(NewClusterRole(name, resources, children))
+	if err != nil {
+		return fmt.Errorf("failed to serialize cluster role %s. %+v", name, err)
+	}
+	_, err = fmt.Fprintf(out, "---\n%s", data)
+	return err
+}
+
+// policyRules collects the verbs needed on each resource of each group
+type policyRules map[string]map[string]map[string]bool
+
+func (p policyRules) add(group, resource string, verbs []string) {
+	if p[group] == nil {
+		p[group] = map[string]map[string]bool{}
+	}
+	if p[group][resource] == nil {
+		p[group][resource] = map[string]bool{}
+	}
+	for _, verb := range verbs {
+		p[group][resource][verb] = true
+	}
+}
+
+// merge returns a rule per group and set of verbs, sorted by group and verbs
+func (p policyRules) merge() []rbacv1.PolicyRule {
+	var rules []rbacv1.PolicyRule
+	groups := make([]string, 0, len(p))
+	for group := range p {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		byVerbs := map[string][]string{}
+		for resource, verbs := range p[group] {
+			key := strings.Join(sortedVerbs(verbs), ",")
+			byVerbs[key] = append(byVerbs[key], resource)
+		}
+		keys := make([]string, 0, len(byVerbs))
+		for key := range byVerbs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			resources := byVerbs[key]
+			sort.Strings(resources)
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups: []string{group},
+				Resources: resources,
+				Verbs:     strings.Split(key, ","),
+			})
+		}
+	}
+	return rules
+}
+
+func sortedVerbs(verbs map[string]bool) []string {
+	sorted := make([]string, 0, len(verbs))
+	for verb := range verbs {
+		sorted = append(sorted, verb)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestPolicyRules(t *testing.T) {
+	children := []ChildResource{
+		{Group: "apps", Resource: "deployments"},
+		{Resource: "configmaps"},
+		{Resource: "secrets", Verbs: []string{"get"}},
+		{Resource: "events", Verbs: []string{"list"}},
+	}
+	rules := PolicyRules([]CustomResource{exampleResource}, children)
+
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"create", "delete", "get", "list", "patch", "update", "watch"}},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "list", "patch"}},
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+		{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"create", "get", "list", "watch"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create", "delete", "get", "list", "patch", "update", "watch"}},
+		{APIGroups: []string{"example.com"}, Resources: []string{"examples"}, Verbs: []string{"get", "list", "patch", "update", "watch"}},
+		{APIGroups: []string{"example.com"}, Resources: []string{"examples/status"}, Verbs: []string{"get", "patch", "update"}},
+		{APIGroups: []string{"example.com"}, Resources: []string{"examples/finalizers"}, Verbs: []string{"update"}},
+	}, rules)
+
+	secondary := exampleResource
+	secondary.Role = SecondaryCRDRole
+	rules = PolicyRules([]CustomResource{secondary}, nil)
+	assert.Equal(t, []string{"get", "list", "watch"}, rules[1].Verbs)
+}
+
+func TestNewRole(t *testing.T) {
+	role := NewRole("example-operator", "ns", []CustomResource{exampleResource}, nil)
+	assert.Equal(t, "ns", role.Namespace)
+	for _, rule := range role.Rules {
+		assert.NotEqual(t, []string{"apiextensions.k8s.io"}, rule.APIGroups)
+	}
+	assert.Equal(t, 4, len(role.Rules))
+}
+
+func TestExportRBAC(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, ExportRBAC("example-operator", []CustomResource{exampleResource}, nil, &out))
+	assert.True(t, strings.HasPrefix(out.String(), "---\n"))
+
+	role := rbacv1.ClusterRole{}
+	assert.NoError(t, yaml.Unmarshal(out.Bytes()[4:], &role))
+	assert.Equal(t, "ClusterRole", role.Kind)
+	assert.Equal(t, "example-operator", role.Name)
+	assert.Equal(t, 5, len(role.Rules))
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	gocontext "context"
+	"fmt"
+	"time"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultReadinessInterval is the interval between two readiness checks of the Wait helpers
+const DefaultReadinessInterval = 2 * time.Second
+
+// Readiness reports whether a child workload finished rolling out
+type Readiness interface {
+	// Ready returns true once the workload is ready, or an error if it cannot become ready, such as a failed job
+	Ready() (bool, error)
+}
+
+// ReadinessFunc adapts a function to the Readiness interface
+type ReadinessFunc func() (bool, error)
+
+// Ready calls f()
+func (f ReadinessFunc) Ready() (bool, error) {
+	return f()
+}
+
+// WaitForReady checks the readiness at each interval until it is ready, the check fails, or the context is done.
+// The first check is immediate. When the context is done its error is returned.
+func WaitForReady(ctx gocontext.Context, interval time.Duration, readiness Readiness) error {
+	ticker := time.NewTicker(durationOrDefault(interval, DefaultReadinessInterval))
+	defer ticker.Stop()
+	for {
+		ready, err := readiness.Ready()
+		if err != nil || ready {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForDeploymentReady waits until the deployment rolled out its current generation and all its replicas are
+// updated and available. It fails once the deployment exceeded its progress deadline.
+func WaitForDeploymentReady(ctx gocontext.Context, clientset kubernetes.Interface, namespace, name string) error {
+	return WaitForReady(ctx, DefaultReadinessInterval, ReadinessFunc(func() (bool, error) {
+		deployment, err := clientset.AppsV1beta2().Deployments(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get deployment %s/%s. %+v", namespace, name, err)
+		}
+		return DeploymentReady(deployment)
+	}))
+}
+
+// WaitForStatefulSetReady waits until the stateful set rolled out its current generation and all its replicas are
+// updated and ready
+func WaitForStatefulSetReady(ctx gocontext.Context, clientset kubernetes.Interface, namespace, name string) error {
+	return WaitForReady(ctx, DefaultReadinessInterval, ReadinessFunc(func() (bool, error) {
+		statefulSet, err := clientset.AppsV1beta2().StatefulSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get stateful set %s/%s. %+v", namespace, name, err)
+		}
+		return StatefulSetReady(statefulSet), nil
+	}))
+}
+
+// WaitForJobComplete waits until the job completed. It fails once the job failed.
+func WaitForJobComplete(ctx gocontext.Context, clientset kubernetes.Interface, namespace, name string) error {
+	return WaitForReady(ctx, DefaultReadinessInterval, ReadinessFunc(func() (bool, error) {
+		job, err := clientset.BatchV1().Jobs(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get job %s/%s. %+v", namespace, name, err)
+		}
+		return JobComplete(job)
+	}))
+}
+
+// DeploymentReady returns whether the deployment rolled out its current generation, and an error if the rollout
+// exceeded its progress deadline
+func DeploymentReady(deployment *appsv1beta2.Deployment) (bool, error) {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1beta2.DeploymentProgressing && condition.Status == v1.ConditionFalse &&
+			condition.Reason == "ProgressDeadlineExceeded" {
+			return false, fmt.Errorf("deployment %s/%s exceeded its progress deadline. %s",
+				deployment.Namespace, deployment.Name, condition.Message)
+		}
+	}
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false, nil
+	}
+	replicas := desiredReplicas(deployment.Spec.Replicas)
+	status := deployment.Status
+	return status.UpdatedReplicas == replicas && status.Replicas == replicas && status.AvailableReplicas == replicas, nil
+}
+
+// StatefulSetReady returns whether the stateful set rolled out its current generation. With a partitioned rolling
+// update only the replicas above the partition must be updated.
+func StatefulSetReady(statefulSet *appsv1beta2.StatefulSet) bool {
+	status := statefulSet.Status
+	if status.ObservedGeneration < statefulSet.Generation {
+		return false
+	}
+	replicas := desiredReplicas(statefulSet.Spec.Replicas)
+	if status.ReadyReplicas != replicas {
+		return false
+	}
+	if statefulSet.Spec.UpdateStrategy.Type == appsv1beta2.OnDeleteStatefulSetStrategyType {
+		return true
+	}
+	if rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+		return status.UpdatedReplicas >= replicas-*rollingUpdate.Partition
+	}
+	return status.UpdatedReplicas == replicas && status.CurrentRevision == status.UpdateRevision
+}
+
+// JobComplete returns whether the job completed, and an error if the job failed
+func JobComplete(job *batchv1.Job) (bool, error) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			return false, fmt.Errorf("job %s/%s failed. %s: %s", job.Namespace, job.Name, condition.Reason, condition.Message)
+		}
+	}
+	return false, nil
+}
+
+// desiredReplicas returns the replicas of a workload spec, which default to one
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
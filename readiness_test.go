@@ -0,0 +1,108 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	gocontext "context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeploymentReady(t *testing.T) {
+	replicas := int32(2)
+	deployment := &appsv1beta2.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "d", Generation: 2},
+		Spec:       appsv1beta2.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1beta2.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+	}
+	ready, err := DeploymentReady(deployment)
+	assert.NoError(t, err)
+	assert.False(t, ready)
+
+	// an old replica is still running
+	deployment.Status = appsv1beta2.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2}
+	ready, _ = DeploymentReady(deployment)
+	assert.False(t, ready)
+
+	deployment.Status.Replicas = 2
+	ready, _ = DeploymentReady(deployment)
+	assert.True(t, ready)
+
+	deployment.Status.Conditions = []appsv1beta2.DeploymentCondition{{Type: appsv1beta2.DeploymentProgressing,
+		Status: v1.ConditionFalse, Reason: "ProgressDeadlineExceeded", Message: "timed out"}}
+	_, err = DeploymentReady(deployment)
+	assert.Error(t, err)
+}
+
+func TestStatefulSetReady(t *testing.T) {
+	replicas, partition := int32(3), int32(2)
+	statefulSet := &appsv1beta2.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Generation: 1},
+		Spec:       appsv1beta2.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1beta2.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 3, UpdatedReplicas: 1,
+			CurrentRevision: "a", UpdateRevision: "b"},
+	}
+	assert.False(t, StatefulSetReady(statefulSet))
+
+	statefulSet.Spec.UpdateStrategy.RollingUpdate = &appsv1beta2.RollingUpdateStatefulSetStrategy{Partition: &partition}
+	assert.True(t, StatefulSetReady(statefulSet))
+
+	statefulSet.Spec.UpdateStrategy = appsv1beta2.StatefulSetUpdateStrategy{Type: appsv1beta2.OnDeleteStatefulSetStrategyType}
+	assert.True(t, StatefulSetReady(statefulSet))
+	statefulSet.Status.ReadyReplicas = 2
+	assert.False(t, StatefulSetReady(statefulSet))
+}
+
+func TestWaitForJobComplete(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "done"}, Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "failed"}, Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded"}}}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "running"}},
+	)
+	ctx := gocontext.Background()
+	assert.NoError(t, WaitForJobComplete(ctx, clientset, "ns", "done"))
+	err := WaitForJobComplete(ctx, clientset, "ns", "failed")
+	assert.Contains(t, err.Error(), "BackoffLimitExceeded")
+	assert.Error(t, WaitForJobComplete(ctx, clientset, "ns", "missing"))
+
+	ctx, cancel := gocontext.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, gocontext.DeadlineExceeded, WaitForJobComplete(ctx, clientset, "ns", "running"))
+}
+
+func TestWaitForReady(t *testing.T) {
+	checks := 0
+	err := WaitForReady(gocontext.Background(), time.Millisecond, ReadinessFunc(func() (bool, error) {
+		checks++
+		return checks == 3, nil
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, checks)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	err = WaitForReady(ctx, time.Hour, ReadinessFunc(func() (bool, error) { return false, nil }))
+	assert.Equal(t, gocontext.Canceled, err)
+}
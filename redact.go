@@ -0,0 +1,317 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// RedactedValue replaces the values of sensitive fields
+	RedactedValue = "<redacted>"
+
+	// SensitiveTag marks a field of a Go type as sensitive with the tag `operatorkit:"sensitive"`
+	SensitiveTag = "operatorkit"
+
+	// SensitiveExtension marks a property of a CRD schema as sensitive with "x-operatorkit-sensitive": true
+	SensitiveExtension = "x-operatorkit-sensitive"
+)
+
+// Redactor masks the sensitive fields of objects, such as credentials embedded in the spec of a custom resource, so
+// they do not leak into logs, events, or diagnostics. Fields are given as JSON paths such as "spec.password".
+// Arrays are traversed without a path segment, and "*" matches every key of a map.
+type Redactor struct {
+	paths [][]string
+}
+
+// NewRedactor creates a redactor of the fields at the dotted paths
+func NewRedactor(paths ...string) *Redactor {
+	r := &Redactor{}
+	for _, path := range paths {
+		r.paths = append(r.paths, strings.Split(path, "."))
+	}
+	return r
+}
+
+// RedactorForType creates a redactor of the fields of the Go type of obj tagged with SensitiveTag
+func RedactorForType(obj interface{}) *Redactor {
+	r := &Redactor{}
+	typePaths(reflect.TypeOf(obj), nil, map[reflect.Type]bool{}, &r.paths)
+	return r
+}
+
+// RedactorForSchema creates a redactor of the properties of an OpenAPI v3 schema marked with SensitiveExtension
+func RedactorForSchema(schema map[string]interface{}) *Redactor {
+	r := &Redactor{}
+	schemaPaths(schema, nil, &r.paths)
+	return r
+}
+
+// RedactorForResource creates a redactor from the schema of the storage version in the CRD manifest of the
+// resource. Resources without a manifest have nothing to redact.
+func RedactorForResource(resource CustomResource) (*Redactor, error) {
+	if len(resource.Manifest) == 0 {
+		return &Redactor{}, nil
+	}
+	crd := map[string]interface{}{}
+	if err := json.Unmarshal(resource.Manifest, &crd); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest of %s. %+v", resource.Name, err)
+	}
+
+	spec, _ := crd["spec"].(map[string]interface{})
+	validation, _ := spec["validation"].(map[string]interface{})
+	versions, _ := spec["versions"].([]interface{})
+	for _, v := range versions {
+		version, _ := v.(map[string]interface{})
+		if version["name"] == resource.Version && version["schema"] != nil {
+			validation, _ = version["schema"].(map[string]interface{})
+		}
+	}
+	schema, _ := validation["openAPIV3Schema"].(map[string]interface{})
+	return RedactorForSchema(schema), nil
+}
+
+// Paths returns the sorted dotted paths of the redacted fields
+func (r *Redactor) Paths() []string {
+	paths := make([]string, 0, len(r.paths))
+	for _, path := range r.paths {
+		paths = append(paths, strings.Join(path, "."))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Redact returns a copy of the object as a JSON map with the sensitive fields replaced by RedactedValue. The object
+// itself is not changed. Objects that cannot be converted are returned as RedactedValue as a whole.
+func (r *Redactor) Redact(obj interface{}) interface{} {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return RedactedValue
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return RedactedValue
+	}
+	for _, path := range r.paths {
+		redactPath(value, path, func(interface{}) interface{} { return RedactedValue })
+	}
+	return value
+}
+
+// RedactJSON returns the JSON with the sensitive fields replaced by RedactedValue
+func (r *Redactor) RedactJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse the object to redact. %+v", err)
+	}
+	return json.Marshal(r.Redact(value))
+}
+
+// RedactText replaces the values of the sensitive fields of the object wherever they appear in free text, such as
+// an error message or an event embedding the spec
+func (r *Redactor) RedactText(text string, obj interface{}) string {
+	for _, secret := range r.secrets(obj) {
+		text = strings.Replace(text, secret, RedactedValue, -1)
+	}
+	return text
+}
+
+// secrets returns the non-empty string values of the sensitive fields of the object, longest first so that a
+// secret containing another one is replaced whole
+func (r *Redactor) secrets(obj interface{}) []string {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	var secrets []string
+	for _, path := range r.paths {
+		redactPath(value, path, func(v interface{}) interface{} {
+			collectStrings(v, &secrets)
+			return v
+		})
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return secrets
+}
+
+var (
+	redactorsLock sync.RWMutex
+	redactors     = map[reflect.Type]*Redactor{}
+)
+
+// RegisterRedactor registers the redactor of the Go type of obj. The operator logger and the events of controllers
+// redact the objects of registered types.
+func RegisterRedactor(obj interface{}, r *Redactor) {
+	redactorsLock.Lock()
+	defer redactorsLock.Unlock()
+	redactors[reflect.TypeOf(obj)] = r
+}
+
+// Redact redacts the object with the redactor registered for its type. Objects of other types are returned as is.
+func Redact(obj interface{}) interface{} {
+	if r := redactorOf(obj); r != nil {
+		return r.Redact(obj)
+	}
+	return obj
+}
+
+// RedactText redacts the sensitive values of the object in the text with the redactor registered for its type
+func RedactText(text string, obj interface{}) string {
+	if r := redactorOf(obj); r != nil {
+		return r.RedactText(text, obj)
+	}
+	return text
+}
+
+func redactorOf(obj interface{}) *Redactor {
+	if obj == nil {
+		return nil
+	}
+	redactorsLock.RLock()
+	defer redactorsLock.RUnlock()
+	return redactors[reflect.TypeOf(obj)]
+}
+
+// redactPath replaces the values at the path with the result of f
+func redactPath(value interface{}, path []string, f func(interface{}) interface{}) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			redactPath(item, path, f)
+		}
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return
+		}
+		for key, child := range v {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				v[key] = f(child)
+				continue
+			}
+			redactPath(child, path[1:], f)
+		}
+	}
+}
+
+func collectStrings(value interface{}, secrets *[]string) {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			*secrets = append(*secrets, v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectStrings(item, secrets)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			collectStrings(item, secrets)
+		}
+	}
+}
+
+// typePaths adds the JSON paths of the fields tagged with SensitiveTag. Types already on the current path are
+// skipped so that recursive types terminate.
+func typePaths(t reflect.Type, prefix []string, visiting map[reflect.Type]bool, paths *[][]string) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		typePaths(t.Elem(), prefix, visiting, paths)
+	case reflect.Map:
+		typePaths(t.Elem(), appendPath(prefix, "*"), visiting, paths)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, inline := jsonFieldName(field)
+			if name == "-" || (field.PkgPath != "" && !field.Anonymous) {
+				continue
+			}
+			path := prefix
+			if !inline {
+				path = appendPath(prefix, name)
+			}
+			if field.Tag.Get(SensitiveTag) == "sensitive" && !inline {
+				*paths = append(*paths, path)
+				continue
+			}
+			typePaths(field.Type, path, visiting, paths)
+		}
+	}
+}
+
+// jsonFieldName returns the JSON name of the field, and whether the field is embedded in its parent
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		if field.Anonymous {
+			return "", true
+		}
+		name = field.Name
+	}
+	return name, false
+}
+
+// schemaPaths adds the paths of the properties marked with SensitiveExtension
+func schemaPaths(schema map[string]interface{}, prefix []string, paths *[][]string) {
+	if schema == nil {
+		return
+	}
+	if sensitive, _ := schema[SensitiveExtension].(bool); sensitive && len(prefix) > 0 {
+		*paths = append(*paths, prefix)
+		return
+	}
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		for name, property := range properties {
+			child, _ := property.(map[string]interface{})
+			schemaPaths(child, appendPath(prefix, name), paths)
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		schemaPaths(items, prefix, paths)
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		schemaPaths(additional, appendPath(prefix, "*"), paths)
+	}
+}
+
+// appendPath returns a new path so that sibling paths do not share a backing array
+func appendPath(prefix []string, name string) []string {
+	path := make([]string, len(prefix), len(prefix)+1)
+	copy(path, prefix)
+	return append(path, name)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type redactedCredentials struct {
+	User     string `json:"user"`
+	Password string `json:"password" operatorkit:"sensitive"`
+}
+
+type redactedSpec struct {
+	Admin   redactedCredentials            `json:"admin"`
+	Users   []redactedCredentials          `json:"users,omitempty"`
+	Tokens  map[string]string              `json:"tokens" operatorkit:"sensitive"`
+	Backups map[string]redactedCredentials `json:"backups,omitempty"`
+	Next    *redactedSpec                  `json:"next,omitempty"`
+}
+
+type redactedSample struct {
+	Kind string       `json:"kind"`
+	Spec redactedSpec `json:"spec"`
+}
+
+func TestRedactorForType(t *testing.T) {
+	r := RedactorForType(&redactedSample{})
+	assert.Equal(t, []string{"spec.admin.password", "spec.backups.*.password", "spec.tokens", "spec.users.password"}, r.Paths())
+
+	sample := &redactedSample{Kind: "Sample", Spec: redactedSpec{
+		Admin:   redactedCredentials{User: "admin", Password: "s3cret"},
+		Users:   []redactedCredentials{{User: "a", Password: "pa"}, {User: "b", Password: "pb"}},
+		Tokens:  map[string]string{"api": "t0ken"},
+		Backups: map[string]redactedCredentials{"s3": {User: "backup", Password: "pbackup"}},
+	}}
+	assert.Equal(t, map[string]interface{}{
+		"kind": "Sample",
+		"spec": map[string]interface{}{
+			"admin":   map[string]interface{}{"user": "admin", "password": RedactedValue},
+			"users":   []interface{}{map[string]interface{}{"user": "a", "password": RedactedValue}, map[string]interface{}{"user": "b", "password": RedactedValue}},
+			"tokens":  RedactedValue,
+			"backups": map[string]interface{}{"s3": map[string]interface{}{"user": "backup", "password": RedactedValue}},
+		},
+	}, r.Redact(sample))
+	assert.Equal(t, "s3cret", sample.Spec.Admin.Password)
+
+	assert.Equal(t, "login admin:<redacted> failed with token <redacted>", r.RedactText("login admin:s3cret failed with token t0ken", sample))
+
+	data, err := r.RedactJSON([]byte(`{"spec":{"admin":{"user":"admin","password":"x"}}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"admin":{"user":"admin","password":"<redacted>"}}}`, string(data))
+}
+
+func TestRedactorForResource(t *testing.T) {
+	resource := CustomResource{Name: "sample", Version: "v1", Manifest: []byte(`{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind": "CustomResourceDefinition",
+		"spec": {"versions": [{"name": "v1", "schema": {"openAPIV3Schema": {"type": "object", "properties": {
+			"spec": {"type": "object", "properties": {
+				"password": {"type": "string", "x-operatorkit-sensitive": true},
+				"users": {"type": "array", "items": {"type": "object", "properties": {"key": {"type": "string", "x-operatorkit-sensitive": true}}}},
+				"env": {"type": "object", "additionalProperties": {"type": "string", "x-operatorkit-sensitive": true}},
+				"size": {"type": "integer"}
+			}}
+		}}}}]}
+	}`)}
+	r, err := RedactorForResource(resource)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"spec.env.*", "spec.password", "spec.users.key"}, r.Paths())
+
+	r, err = RedactorForResource(CustomResource{Name: "empty"})
+	assert.NoError(t, err)
+	assert.Empty(t, r.Paths())
+}
+
+func TestRegisteredRedactor(t *testing.T) {
+	RegisterRedactor(&redactedSample{}, NewRedactor("spec.admin.password"))
+	sample := &redactedSample{Spec: redactedSpec{Admin: redactedCredentials{Password: "s3cret"}}}
+
+	redacted := Redact(sample).(map[string]interface{})
+	assert.Equal(t, RedactedValue, redacted["spec"].(map[string]interface{})["admin"].(map[string]interface{})["password"])
+	assert.Equal(t, "value", Redact("value"))
+	assert.Equal(t, "bad <redacted>", RedactText("bad s3cret", sample))
+	assert.Equal(t, "bad s3cret", RedactText("bad s3cret", "other"))
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Reference is an object a custom resource depends on, such as the Secret with its credentials
+type Reference struct {
+	// Kind of the object, such as "Secret" or "ConfigMap"
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// ReferenceTracker records the objects each custom resource depends on, so that the custom resources are
+// reconciled again when the objects change. Owners are the namespace/name keys of the custom resources.
+type ReferenceTracker struct {
+	lock   sync.Mutex
+	owners map[Reference]map[string]bool
+	refs   map[string][]Reference
+}
+
+// NewReferenceTracker creates a tracker without references
+func NewReferenceTracker() *ReferenceTracker {
+	return &ReferenceTracker{owners: map[Reference]map[string]bool{}, refs: map[string][]Reference{}}
+}
+
+// Track replaces the references of the owner. Reconciles declare all the references of the custom resource each
+// time, so references that were removed from the resource are no longer tracked.
+func (t *ReferenceTracker) Track(owner string, refs ...Reference) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.forget(owner)
+	for _, ref := range refs {
+		if t.owners[ref] == nil {
+			t.owners[ref] = map[string]bool{}
+		}
+		t.owners[ref][owner] = true
+	}
+	if len(refs) > 0 {
+		t.refs[owner] = append([]Reference{}, refs...)
+	}
+}
+
+// Forget drops the references of the owner, such as after the owner was deleted
+func (t *ReferenceTracker) Forget(owner string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.forget(owner)
+}
+
+func (t *ReferenceTracker) forget(owner string) {
+	for _, ref := range t.refs[owner] {
+		delete(t.owners[ref], owner)
+		if len(t.owners[ref]) == 0 {
+			delete(t.owners, ref)
+		}
+	}
+	delete(t.refs, owner)
+}
+
+// Owners returns the sorted keys of the owners depending on the object
+func (t *ReferenceTracker) Owners(ref Reference) []string {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	owners := make([]string, 0, len(t.owners[ref]))
+	for owner := range t.owners[ref] {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// ReferenceHandlers returns the handlers of a watcher of the objects of the kind, such as a watcher of Secrets
+// created with NewBuiltinWatcher, queueing the custom resources that depend on a changed object according to the
+// References tracker of the controller
+func (c *Controller) ReferenceHandlers(kind string) cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return
+		}
+		for _, owner := range c.options.References.Owners(Reference{Kind: kind, Namespace: accessor.GetNamespace(), Name: accessor.GetName()}) {
+			c.queue.Add(owner)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			enqueue(newObj)
+		},
+		DeleteFunc: enqueue,
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestReferenceTracker(t *testing.T) {
+	tracker := NewReferenceTracker()
+	tls := Reference{Kind: "Secret", Namespace: "ns", Name: "tls"}
+	config := Reference{Kind: "ConfigMap", Namespace: "ns", Name: "config"}
+
+	tracker.Track("ns/b", tls)
+	tracker.Track("ns/a", tls, config)
+	assert.Equal(t, []string{"ns/a", "ns/b"}, tracker.Owners(tls))
+	assert.Equal(t, []string{"ns/a"}, tracker.Owners(config))
+
+	// the references are replaced on each reconcile
+	tracker.Track("ns/a", config)
+	assert.Equal(t, []string{"ns/b"}, tracker.Owners(tls))
+
+	tracker.Forget("ns/b")
+	assert.Equal(t, []string{}, tracker.Owners(tls))
+	assert.Equal(t, 1, len(tracker.owners))
+
+	var nilTracker *ReferenceTracker
+	assert.Nil(t, nilTracker.Owners(tls))
+	nilTracker.Forget("ns/a")
+}
+
+func TestReferenceHandlers(t *testing.T) {
+	c := &Controller{
+		resource: exampleResource,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		options:  ControllerOptions{References: NewReferenceTracker()},
+	}
+	defer c.queue.ShutDown()
+	c.options.References.Track("ns/a", Reference{Kind: "Secret", Namespace: "ns", Name: "tls"})
+
+	handlers := c.ReferenceHandlers("Secret")
+	handlers.AddFunc(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "other"}})
+	assert.Equal(t, 0, c.queue.Len())
+
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "tls"}}
+	handlers.UpdateFunc(secret, secret)
+	assert.Equal(t, 1, c.queue.Len())
+	key, _ := c.queue.Get()
+	assert.Equal(t, "ns/a", key)
+	c.queue.Done(key)
+
+	handlers.DeleteFunc(cache.DeletedFinalStateUnknown{Key: "ns/tls", Obj: secret})
+	assert.Equal(t, 1, c.queue.Len())
+
+	// a ConfigMap with the same name is another object
+	c.ReferenceHandlers("ConfigMap").AddFunc(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "tls"}})
+	assert.Equal(t, 1, c.queue.Len())
+}
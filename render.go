@@ -0,0 +1,191 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// RenderFunc returns the child objects the operator creates for the custom resource, without calling the cluster.
+// A reconciler can share the func to build the children it applies.
+type RenderFunc func(obj runtime.Object) ([]runtime.Object, error)
+
+type operatorRenderer struct {
+	resource CustomResource
+	render   RenderFunc
+}
+
+// AddRenderer registers the func rendering the children of the custom resource for RunRender
+func (o *Operator) AddRenderer(resource CustomResource, render RenderFunc) {
+	o.renderers = append(o.renderers, operatorRenderer{resource: resource, render: render})
+}
+
+// RunRender reads the custom resources from the YAML or JSON manifests at the path and writes the child objects
+// rendered for them to out as a YAML stream, without a cluster. The path is a file, a directory whose .yaml, .yml,
+// and .json files are read in lexical order, or "-" for stdin. Objects of kinds without a renderer are skipped.
+func (o *Operator) RunRender(path string, out io.Writer) error {
+	if path == "-" {
+		return o.Render(os.Stdin, out)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read manifests. %+v", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to read manifests in %s. %+v", path, err)
+		}
+		files = nil
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		sort.Strings(files)
+	}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read manifests in %s. %+v", file, err)
+		}
+		if err := o.Render(bytes.NewReader(data), out); err != nil {
+			return fmt.Errorf("failed to render %s. %+v", file, err)
+		}
+	}
+	return nil
+}
+
+// Render reads the custom resources from a YAML or JSON stream and writes their rendered children to out. Custom
+// resources whose kind is registered in the scheme of the operator are passed to the renderer as typed objects,
+// others as *unstructured.Unstructured.
+func (o *Operator) Render(in io.Reader, out io.Writer) error {
+	return decodeManifests(in, func(doc map[string]interface{}) error {
+		obj := &unstructured.Unstructured{Object: doc}
+		renderer := o.rendererOf(obj)
+		if renderer == nil {
+			o.context.logger().Debug("skipping manifest without a renderer", "kind", obj.GetKind(), "name", obj.GetName())
+			return nil
+		}
+		typed, err := o.typedObject(obj)
+		if err != nil {
+			return err
+		}
+		children, err := renderer.render(typed)
+		if err != nil {
+			return fmt.Errorf("failed to render %s %s. %+v", renderer.resource.Name, obj.GetName(), err)
+		}
+		for _, child := range children {
+			if err := o.writeManifest(child, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// decodeManifests calls f with each document of a YAML or JSON stream. Empty documents are skipped.
+func decodeManifests(in io.Reader, f func(doc map[string]interface{}) error) error {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(in, 4096)
+	for {
+		doc := map[string]interface{}{}
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to parse manifest. %+v", err)
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		if err := f(doc); err != nil {
+			return err
+		}
+	}
+}
+
+func (o *Operator) rendererOf(obj *unstructured.Unstructured) *operatorRenderer {
+	for i, renderer := range o.renderers {
+		if obj.GroupVersionKind() == renderer.resource.GroupVersionKind() {
+			return &o.renderers[i]
+		}
+	}
+	return nil
+}
+
+// typedObject decodes the custom resource into its type if the scheme knows it
+func (o *Operator) typedObject(obj *unstructured.Unstructured) (runtime.Object, error) {
+	if o.scheme == nil || !o.scheme.Recognizes(obj.GroupVersionKind()) {
+		return obj, nil
+	}
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	typed, _, err := serializer.NewCodecFactory(o.scheme).UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s %s. %+v", obj.GetKind(), obj.GetName(), err)
+	}
+	return typed, nil
+}
+
+// writeManifest writes the object as a YAML document. Typed objects usually have no apiVersion and kind set, so
+// they are looked up in the scheme of the operator and the Kubernetes scheme.
+func (o *Operator) writeManifest(obj runtime.Object, out io.Writer) error {
+	if obj.GetObjectKind().GroupVersionKind().Empty() {
+		for _, scheme := range []*runtime.Scheme{o.scheme, kubescheme.Scheme} {
+			if scheme == nil {
+				continue
+			}
+			if kinds, _, err := scheme.ObjectKinds(obj); err == nil && len(kinds) > 0 {
+				obj.GetObjectKind().SetGroupVersionKind(kinds[0])
+				break
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to serialize rendered object. %+v", err)
+	}
+	if _, err := fmt.Fprintf(out, "---\n%s", data); err != nil {
+		return fmt.Errorf("failed to write rendered object. %+v", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRunRender(t *testing.T) {
+	resource := CustomResource{Name: "sample", Plural: "samples", Group: "example.com", Version: "v1", Kind: "Sample"}
+	operator := NewOperator(Context{}, nil, OperatorOptions{})
+	operator.AddRenderer(resource, func(obj runtime.Object) ([]runtime.Object, error) {
+		sample := obj.(*unstructured.Unstructured)
+		greeting, _, _ := unstructured.NestedString(sample.Object, "spec", "greeting")
+		return []runtime.Object{&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: sample.GetName() + "-config", Namespace: sample.GetNamespace()},
+			Data:       map[string]string{"greeting": greeting},
+		}}, nil
+	})
+
+	dir, err := ioutil.TempDir("", "render")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	manifests := map[string]string{
+		"b.yaml": "apiVersion: example.com/v1\nkind: Sample\nmetadata:\n  name: second\n  namespace: ns\nspec:\n  greeting: hi\n",
+		"a.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns\n---\napiVersion: example.com/v1\nkind: Sample\nmetadata:\n  name: first\n  namespace: ns\nspec:\n  greeting: hello\n",
+		"README": "not a manifest",
+	}
+	for name, content := range manifests {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, operator.RunRender(dir, out))
+	assert.Equal(t, `---
+apiVersion: v1
+data:
+  greeting: hello
+kind: ConfigMap
+metadata:
+  creationTimestamp: null
+  name: first-config
+  namespace: ns
+---
+apiVersion: v1
+data:
+  greeting: hi
+kind: ConfigMap
+metadata:
+  creationTimestamp: null
+  name: second-config
+  namespace: ns
+`, out.String())
+
+	assert.Error(t, operator.RunRender(filepath.Join(dir, "missing"), out))
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"time"
+
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// InstallOutcome is the result of installing a single custom resource
+type InstallOutcome string
+
+const (
+	// OutcomeCreated means the CRD/TPR was created by the operator
+	OutcomeCreated InstallOutcome = "Created"
+	// OutcomeAlreadyExisted means the CRD/TPR was found in the cluster and left as it was
+	OutcomeAlreadyExisted InstallOutcome = "AlreadyExisted"
+	// OutcomeVerified means the CRD of a resource with the secondary role was created by the primary operator and
+	// serves the kind and version of the resource
+	OutcomeVerified InstallOutcome = "Verified"
+	// OutcomeUpdated means the existing CRD/TPR was updated to match the custom resource definition
+	OutcomeUpdated InstallOutcome = "Updated"
+	// OutcomeFailed means the CRD/TPR could not be created or did not initialize
+	OutcomeFailed InstallOutcome = "Failed"
+)
+
+// ResourceReport is the outcome of installing a single custom resource
+type ResourceReport struct {
+	Resource CustomResource
+	Outcome  InstallOutcome
+
+	// Duration spent creating and waiting for the resource to initialize
+	Duration time.Duration
+
+	// Err is set when the outcome is OutcomeFailed
+	Err error
+}
+
+// InstallReport holds the outcome of each custom resource passed to CreateCustomResourcesWithReport
+type InstallReport struct {
+	Resources []ResourceReport
+}
+
+// Failed returns the reports of the resources that failed to install
+func (r *InstallReport) Failed() []ResourceReport {
+	var failed []ResourceReport
+	for _, resource := range r.Resources {
+		if resource.Outcome == OutcomeFailed {
+			failed = append(failed, resource)
+		}
+	}
+	return failed
+}
+
+// Err returns an aggregate of the errors of all failed resources, or nil if all resources were installed
+func (r *InstallReport) Err() error {
+	var errs []error
+	for _, resource := range r.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %+v", resource.Resource.Name, resource.Err))
+	}
+	return errorsUtil.NewAggregate(errs)
+}
+
+// String summarizes the outcome of each resource on a separate line
+func (r *InstallReport) String() string {
+	var summary string
+	for _, resource := range r.Resources {
+		summary += fmt.Sprintf("%s.%s: %s in %s\n", resource.Resource.Plural, resource.Resource.Group, resource.Outcome, resource.Duration)
+		if resource.Err != nil {
+			summary += fmt.Sprintf("  error: %+v\n", resource.Err)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// ErrorClass groups the errors returned by reconcilers for choosing a requeue policy
+type ErrorClass string
+
+const (
+	// ErrorClassNotFound is an API NotFound error, such as for a resource deleted during the reconcile
+	ErrorClassNotFound ErrorClass = "NotFound"
+	// ErrorClassConflict is an API Conflict error from updating a stale version of a resource
+	ErrorClassConflict ErrorClass = "Conflict"
+	// ErrorClassDependencyNotReady is a DependencyNotReadyError returned while waiting for another resource
+	ErrorClassDependencyNotReady ErrorClass = "DependencyNotReady"
+	// ErrorClassExternal5xx is an ExternalError or API error with a 5xx status code
+	ErrorClassExternal5xx ErrorClass = "External5xx"
+	// ErrorClassTerminal is a TerminalError, such as for an invalid spec that fails until the resource is changed.
+	// Keys failing with a terminal error are dropped unless the class has a requeue policy.
+	ErrorClassTerminal ErrorClass = "Terminal"
+	// ErrorClassOther is any other error
+	ErrorClassOther ErrorClass = "Other"
+)
+
+// Result is returned by a ResultReconciler after a successful reconcile
+type Result struct {
+	// RequeueAfter reconciles the key again after the delay, such as while waiting for a resource outside of the
+	// cluster. The key is not requeued if it is zero.
+	RequeueAfter time.Duration
+}
+
+// ResultReconciler is implemented by reconcilers that requeue keys after successful reconciles. The controller calls
+// ReconcileResult instead of Reconcile for them.
+type ResultReconciler interface {
+	Reconciler
+	ReconcileResult(key string) (Result, error)
+}
+
+// ResultReconcilerFunc adapts a function to the ResultReconciler interface
+type ResultReconcilerFunc func(key string) (Result, error)
+
+// Reconcile calls f(key) and drops the result
+func (f ResultReconcilerFunc) Reconcile(key string) error {
+	_, err := f(key)
+	return err
+}
+
+// ReconcileResult calls f(key)
+func (f ResultReconcilerFunc) ReconcileResult(key string) (Result, error) {
+	return f(key)
+}
+
+// RequeueAction is what the controller does with the key of a failed reconcile
+type RequeueAction int
+
+const (
+	// RequeueWithBackoff requeues the key after a delay that grows with each failure. This is the default.
+	RequeueWithBackoff RequeueAction = iota
+	// RequeueImmediately requeues the key without delay
+	RequeueImmediately
+	// RequeueAfterDelay requeues the key after the fixed delay of the policy
+	RequeueAfterDelay
+	// Drop forgets the key until the resource changes again
+	Drop
+)
+
+// RequeuePolicy decides how the key of a failed reconcile is requeued
+type RequeuePolicy struct {
+	Action RequeueAction
+
+	// Delay before the key is reconciled again with RequeueAfterDelay
+	Delay time.Duration
+
+	// RateLimiter is optional and computes the delays of RequeueWithBackoff. Defaults to the rate limiter of the
+	// controller queue.
+	RateLimiter workqueue.RateLimiter
+}
+
+// DependencyNotReadyError is returned by reconcilers when a resource the custom resource depends on is not ready yet
+type DependencyNotReadyError struct {
+	Dependency string
+}
+
+func (e *DependencyNotReadyError) Error() string {
+	return fmt.Sprintf("dependency %s is not ready", e.Dependency)
+}
+
+// ExternalError is returned by reconcilers when a service outside of the cluster failed with the status code
+type ExternalError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *ExternalError) Error() string {
+	return fmt.Sprintf("external service failed with status %d. %+v", e.StatusCode, e.Err)
+}
+
+// TerminalError wraps an error that retrying the reconcile does not resolve, such as an invalid spec. The key is
+// reconciled again only when the resource changes.
+type TerminalError struct {
+	Err error
+}
+
+// NewTerminalError wraps the error as a TerminalError
+func NewTerminalError(err error) error {
+	return &TerminalError{Err: err}
+}
+
+func (e *TerminalError) Error() string {
+	return e.Err.Error()
+}
+
+// IsTerminalError returns whether the error is a TerminalError
+func IsTerminalError(err error) bool {
+	_, ok := err.(*TerminalError)
+	return ok
+}
+
+// ClassifyError returns the class of an error returned by a reconciler
+func ClassifyError(err error) ErrorClass {
+	switch e := err.(type) {
+	case *TerminalError:
+		return ErrorClassTerminal
+	case *DependencyNotReadyError:
+		return ErrorClassDependencyNotReady
+	case *ExternalError:
+		if e.StatusCode >= 500 {
+			return ErrorClassExternal5xx
+		}
+		return ErrorClassOther
+	}
+
+	switch {
+	case errors.IsNotFound(err):
+		return ErrorClassNotFound
+	case errors.IsConflict(err):
+		return ErrorClassConflict
+	}
+	if status, ok := err.(errors.APIStatus); ok && status.Status().Code >= 500 {
+		return ErrorClassExternal5xx
+	}
+	return ErrorClassOther
+}
+
+// requeue adds the key of a failed reconcile back to the queue according to the policy of the error class
+func (c *Controller) requeue(key interface{}, err error) {
+	classify := c.options.ErrorClassifier
+	if classify == nil {
+		classify = ClassifyError
+	}
+	class := classify(err)
+
+	if c.options.MaxRetries > 0 && c.countFailure(key) > c.options.MaxRetries {
+		c.deadLetter(key, err)
+		return
+	}
+
+	policy, ok := c.options.RequeuePolicies[class]
+	if !ok && class == ErrorClassTerminal {
+		policy = RequeuePolicy{Action: Drop}
+	}
+	switch policy.Action {
+	case RequeueImmediately:
+		c.queue.Add(key)
+	case RequeueAfterDelay:
+		c.queue.AddAfter(key, policy.Delay)
+	case Drop:
+		c.context.logger().Info("dropping the key after the reconcile failed", "resource", c.resource.Name, "key", key, "class", class)
+		c.forget(key)
+	default:
+		if policy.RateLimiter != nil {
+			c.queue.AddAfter(key, policy.RateLimiter.When(key))
+			return
+		}
+		c.queue.AddRateLimited(key)
+	}
+}
+
+// requeueResult adds the key of a successful reconcile back to the queue if the result asks for it
+func (c *Controller) requeueResult(key interface{}, result Result) {
+	if result.RequeueAfter > 0 {
+		c.queue.AddAfter(key, result.RequeueAfter)
+	}
+}
+
+// countFailure counts a failed reconcile of the key and returns the number of consecutive failures
+func (c *Controller) countFailure(key interface{}) int {
+	c.failuresLock.Lock()
+	defer c.failuresLock.Unlock()
+	if c.failures == nil {
+		c.failures = map[interface{}]int{}
+	}
+	c.failures[key]++
+	return c.failures[key]
+}
+
+// deadLetter drops the key of a resource that failed more times than the controller retries
+func (c *Controller) deadLetter(key interface{}, err error) {
+	c.context.logger().Error(err, "dropping the key after the retries were exhausted", "resource", c.resource.Name, "key", key,
+		"retries", c.options.MaxRetries)
+	c.recordEvent(key.(string), v1.EventTypeWarning, EventReasonRetriesExhausted,
+		fmt.Sprintf("gave up after %d retries. %v", c.options.MaxRetries, err))
+	if c.options.DeadLetter != nil {
+		c.options.DeadLetter(key.(string), err)
+	}
+	c.forget(key)
+}
+
+// forget resets the failures and the backoff of the key in the queue and in the rate limiters of the requeue policies
+func (c *Controller) forget(key interface{}) {
+	c.failuresLock.Lock()
+	delete(c.failures, key)
+	c.failuresLock.Unlock()
+	c.queue.Forget(key)
+	for _, policy := range c.options.RequeuePolicies {
+		if policy.RateLimiter != nil {
+			policy.RateLimiter.Forget(key)
+		}
+	}
+}
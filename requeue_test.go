@@ -0,0 +1,132 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestClassifyError(t *testing.T) {
+	resource := schema.GroupResource{Group: "example.com", Resource: "samples"}
+	assert.Equal(t, ErrorClassNotFound, ClassifyError(errors.NewNotFound(resource, "a")))
+	assert.Equal(t, ErrorClassConflict, ClassifyError(errors.NewConflict(resource, "a", fmt.Errorf("stale"))))
+	assert.Equal(t, ErrorClassExternal5xx, ClassifyError(errors.NewInternalError(fmt.Errorf("failed"))))
+	assert.Equal(t, ErrorClassDependencyNotReady, ClassifyError(&DependencyNotReadyError{Dependency: "database"}))
+	assert.Equal(t, ErrorClassExternal5xx, ClassifyError(&ExternalError{StatusCode: 503, Err: fmt.Errorf("unavailable")}))
+	assert.Equal(t, ErrorClassOther, ClassifyError(&ExternalError{StatusCode: 400, Err: fmt.Errorf("bad request")}))
+	assert.Equal(t, ErrorClassOther, ClassifyError(fmt.Errorf("failed")))
+	assert.Equal(t, ErrorClassTerminal, ClassifyError(NewTerminalError(fmt.Errorf("invalid spec"))))
+}
+
+func TestRequeueResult(t *testing.T) {
+	c := &Controller{
+		context:  Context{},
+		resource: exampleResource,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		reconciler: ResultReconcilerFunc(func(key string) (Result, error) {
+			return Result{RequeueAfter: 10 * time.Millisecond}, nil
+		}),
+	}
+	defer c.queue.ShutDown()
+
+	result, err := c.reconcile(NewReconcileTrace("default/a", c.context.logger()))
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Millisecond, result.RequeueAfter)
+
+	c.requeueResult("default/a", result)
+	assert.Equal(t, 0, c.queue.Len())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, c.queue.Len())
+
+	// no delay, no requeue
+	c.requeueResult("default/b", Result{})
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, c.queue.Len())
+}
+
+func TestRequeueTerminalError(t *testing.T) {
+	c := &Controller{
+		context:  Context{},
+		resource: exampleResource,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+	defer c.queue.ShutDown()
+
+	err := NewTerminalError(fmt.Errorf("invalid spec"))
+	assert.True(t, IsTerminalError(err))
+	assert.False(t, IsTerminalError(fmt.Errorf("invalid spec")))
+	assert.EqualError(t, err, "invalid spec")
+
+	// terminal errors are dropped by default
+	c.requeue("default/a", err)
+	assert.Equal(t, 0, c.queue.Len())
+	assert.Equal(t, 0, c.queue.NumRequeues("default/a"))
+
+	// unless the class has a policy
+	c.options.RequeuePolicies = map[ErrorClass]RequeuePolicy{ErrorClassTerminal: {Action: RequeueImmediately}}
+	c.requeue("default/a", err)
+	assert.Equal(t, 1, c.queue.Len())
+}
+
+func TestRequeueMaxRetries(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	var deadLetters []string
+	c := &Controller{
+		context:  Context{Recorder: recorder},
+		resource: exampleResource,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		store:    cache.NewStore(cache.MetaNamespaceKeyFunc),
+		options: ControllerOptions{
+			MaxRetries:      2,
+			RequeuePolicies: map[ErrorClass]RequeuePolicy{ErrorClassOther: {Action: RequeueImmediately}},
+			DeadLetter: func(key string, err error) {
+				deadLetters = append(deadLetters, fmt.Sprintf("%s: %v", key, err))
+			},
+		},
+	}
+	defer c.queue.ShutDown()
+	assert.NoError(t, c.store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}}))
+
+	for i := 0; i < 2; i++ {
+		c.requeue("default/a", fmt.Errorf("failed"))
+		key, _ := c.queue.Get()
+		c.queue.Done(key)
+	}
+	assert.Empty(t, deadLetters)
+
+	// the third failure exhausts the retries
+	c.requeue("default/a", fmt.Errorf("failed"))
+	assert.Equal(t, 0, c.queue.Len())
+	assert.Equal(t, []string{"default/a: failed"}, deadLetters)
+	assert.Equal(t, "Warning RetriesExhausted gave up after 2 retries. failed", <-recorder.Events)
+
+	// the failures start over once the key is dropped or reconciled
+	c.requeue("default/a", fmt.Errorf("failed"))
+	assert.Equal(t, 1, c.queue.Len())
+	c.forget("default/a")
+	assert.Equal(t, 0, len(c.failures))
+}
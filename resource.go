@@ -21,18 +21,28 @@ which also has the apache 2.0 license.
 package operatorkit
 
 import (
+	gocontext "context"
 	"fmt"
+	"sync"
 	"time"
 
+	"k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/kubernetes/pkg/util/version"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 )
 
 // CustomResource is for creating a Kubernetes TPR/CRD
@@ -54,6 +64,27 @@ type CustomResource struct {
 
 	// Kind is the serialized interface of the resource.
 	Kind string
+
+	// Role of the operator for the CRD. Defaults to the primary role, which creates the CRD.
+	Role CRDRole
+
+	// Manifest is optional and set by ParseCRDs to the JSON of the CRD manifest the resource was loaded from. The
+	// CRD is created from the manifest with the apiextensions version of the manifest instead of being generated
+	// from the fields. The install fails if the cluster does not serve that version.
+	Manifest []byte
+
+	// DefaultInstances are optional and created by CreateCustomResources once the resource is established, unless
+	// instances of the same names already exist. The context must have a RESTConfig.
+	DefaultInstances []runtime.Object
+
+	// ValidationRules are optional CEL rules on the custom resource. CreateAdmissionPolicies enforces them with a
+	// ValidatingAdmissionPolicy on clusters that support it, without an admission webhook.
+	ValidationRules []ValidationRule
+}
+
+// GroupVersionKind returns the group, version, and kind of the custom resource
+func (r CustomResource) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
 }
 
 // Context hold the clientsets used for creating and watching custom resources
@@ -62,57 +93,327 @@ type Context struct {
 	APIExtensionClientset apiextensionsclient.Interface
 	Interval              time.Duration
 	Timeout               time.Duration
+
+	// WaitStrategy used when waiting for custom resources to initialize. Defaults to an ExponentialWaitStrategy
+	// configured by the Backoff.
+	WaitStrategy WaitStrategy
+
+	// Backoff configures the default wait strategy. The Duration replaces the Interval if set, and the Cap limits the
+	// interval between two attempts, 10s if not set. Defaults to DefaultWaitBackoff.
+	Backoff *wait.Backoff
+
+	// Progress is optional and called as each custom resource is created, established, or fails. It is called
+	// concurrently for the resources installed in parallel.
+	Progress ProgressFunc
+
+	// CRDConcurrency is the number of custom resources created and waited for at once. Defaults to 5. Set it to 1
+	// to install the resources one at a time.
+	CRDConcurrency int
+
+	// Metrics is optional and records the custom resource setup and controller activity
+	Metrics *Metrics
+
+	// APIFlavor selects the API used to register custom resources. Defaults to detecting it from the server version.
+	APIFlavor APIFlavor
+
+	// Recorder is optional and emits events about the custom resources and their reconciles
+	Recorder record.EventRecorder
+
+	// TracerProvider is optional and traces the installation of the custom resources and the reconciles
+	TracerProvider TracerProvider
+
+	// TracePropagator is optional and sends the spans of the kit to the apiserver in the headers of its requests
+	TracePropagator TracePropagator
+
+	// Logger receives the structured logs of the kit. Defaults to info level logs on stderr.
+	Logger Logger
+
+	// DynamicClientPool is optional and provides clients for arbitrary kinds, including the registered custom resources
+	DynamicClientPool dynamic.ClientPool
+
+	// RESTMapper is optional and maps kinds to resources. It is reset after custom resources are registered.
+	RESTMapper *discovery.DeferredDiscoveryRESTMapper
+
+	// RESTConfig is optional and used to create the clients of the custom resources
+	RESTConfig *rest.Config
+
+	// Capabilities of the server, set by DetectCapabilities
+	Capabilities *Capabilities
+
+	// TPRTimeoutPolicy decides what happens to a TPR that did not initialize before the timeout. Defaults to
+	// keeping it.
+	TPRTimeoutPolicy TPRTimeoutPolicy
+
+	// CRDMismatchPolicy decides what happens when the CRD of a resource already exists with a different group,
+	// scope, kind, or versions. Defaults to failing the install.
+	CRDMismatchPolicy CRDMismatchPolicy
+
+	// OperatorName is optional and labels the CRDs of the resources with the primary role, so that another operator
+	// declaring itself primary for the same CRD fails to install instead of fighting over it
+	OperatorName string
+}
+
+// APIFlavor is the API used to register custom resources with the cluster
+type APIFlavor int
+
+const (
+	// AutoDetect selects the API based on the version of the Kubernetes server
+	AutoDetect APIFlavor = iota
+	// ForceCRDv1 registers apiextensions.k8s.io/v1 CRDs, available on Kubernetes 1.16 and above
+	ForceCRDv1
+	// ForceCRDv1beta1 registers apiextensions.k8s.io/v1beta1 CRDs, available on Kubernetes 1.7 to 1.21
+	ForceCRDv1beta1
+	// ForceTPR registers ThirdPartyResources, available on Kubernetes below 1.8
+	ForceTPR
+)
+
+// String returns the API of the flavor, such as "apiextensions.k8s.io/v1"
+func (f APIFlavor) String() string {
+	switch f {
+	case AutoDetect:
+		return "auto-detect"
+	case ForceCRDv1:
+		return apiextensionsGroup + "/v1"
+	case ForceCRDv1beta1:
+		return apiextensionsGroup + "/v1beta1"
+	case ForceTPR:
+		return "extensions/v1beta1 ThirdPartyResources"
+	default:
+		return fmt.Sprintf("APIFlavor(%d)", int(f))
+	}
+}
+
+const defaultCRDConcurrency = 5
+
+type createFunc func(context Context, resource CustomResource) (InstallOutcome, error)
+type waitForInitFunc func(context Context, resource CustomResource) error
+
+// InstallPhase is a step in the installation of a custom resource
+type InstallPhase string
+
+const (
+	// PhaseCreated is reported when the CRD/TPR was created or already existed
+	PhaseCreated InstallPhase = "Created"
+	// PhaseEstablished is reported when the custom resource is ready to be used
+	PhaseEstablished InstallPhase = "Established"
+	// PhaseFailed is reported when creating or waiting for the custom resource failed
+	PhaseFailed InstallPhase = "Failed"
+)
+
+// ProgressFunc is called as a custom resource passes through the install phases. The error is only set for PhaseFailed.
+type ProgressFunc func(resource CustomResource, phase InstallPhase, err error)
+
+func (c Context) reportProgress(resource CustomResource, phase InstallPhase, err error) {
+	if c.Progress != nil {
+		c.Progress(resource, phase, err)
+	}
+}
+
+func (c Context) waitStrategy() WaitStrategy {
+	if c.WaitStrategy != nil {
+		return c.WaitStrategy
+	}
+	backoff := DefaultWaitBackoff
+	if c.Backoff != nil {
+		backoff = *c.Backoff
+	}
+	maxInterval := backoff.Cap
+	if maxInterval <= 0 {
+		maxInterval = maxWaitInterval
+	}
+	return ExponentialWaitStrategy{Factor: backoff.Factor, Jitter: backoff.Jitter, Steps: backoff.Steps, MaxInterval: maxInterval}
+}
+
+// waitInterval is the first interval of the wait strategy
+func (c Context) waitInterval() time.Duration {
+	if c.Backoff != nil && c.Backoff.Duration > 0 {
+		return c.Backoff.Duration
+	}
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultInterval
 }
 
 // CreateCustomResources creates the given custom resources and waits for them to initialize
-// The resource is of kind CRD if the Kubernetes server is 1.7.0 and above.
+// Unless an APIFlavor is forced in the context, the kind of the resource depends on the Kubernetes server version.
+// The resource is a v1 CRD if the Kubernetes server is 1.16.0 and above.
+// The resource is a v1beta1 CRD if the Kubernetes server is 1.7.0 and above.
 // The resource is of kind TPR if the Kubernetes server is below 1.7.0.
 func CreateCustomResources(context Context, resources []CustomResource) error {
+	_, err := CreateCustomResourcesWithReport(context, resources)
+	return err
+}
 
-	// CRD is available on v1.7.0 and above. TPR became deprecated on v1.7.0
-	serverVersion, err := context.Clientset.Discovery().ServerVersion()
+// CreateCustomResourcesWithReport creates the given custom resources and waits for them to initialize like
+// CreateCustomResources. The returned report holds the outcome of each resource, even when an error is returned,
+// unless the server version could not be determined.
+func CreateCustomResourcesWithReport(context Context, resources []CustomResource) (*InstallReport, error) {
+	ctx, span := context.startSpan(gocontext.Background(), SpanCreateCustomResources, "resources", len(resources))
+	create, verify, waitForInit, err := installFuncs(context)
 	if err != nil {
-		return fmt.Errorf("Error getting server version: %v", err)
+		endSpan(span, err)
+		return nil, err
 	}
-	kubeVersion := version.MustParseSemantic(serverVersion.GitVersion)
 
-	var lastErr error
-	if kubeVersion.AtLeast(version.MustParseSemantic(serverVersionV170)) {
-		for _, resource := range resources {
-			err = createCRD(context, resource)
-			if err != nil {
-				lastErr = err
-			}
-		}
+	concurrency := context.CRDConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultCRDConcurrency
+	}
 
-		for _, resource := range resources {
-			if err := waitForCRDInit(context, resource); err != nil {
-				lastErr = err
-			}
+	// each resource is created and waited for independently, with up to concurrency resources in flight, so that a
+	// slow apiserver does not multiply the startup time by the number of resources
+	report := &InstallReport{Resources: make([]ResourceReport, len(resources))}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, resource := range resources {
+		install := create
+		if resource.Role == SecondaryCRDRole {
+			install = verify
 		}
-	} else {
-		// Create and wait for TPR resources
-		for _, resource := range resources {
-			err = createTPR(context, resource)
-			if err != nil {
-				lastErr = err
-			}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, resource CustomResource) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			report.Resources[i] = installResource(ctx, context, resource, install, waitForInit)
+		}(i, resource)
+	}
+	wg.Wait()
+	context.resetRESTMapper()
+
+	var lastErr error
+	for _, result := range report.Resources {
+		if result.Err != nil {
+			lastErr = result.Err
 		}
+	}
+	endSpan(span, lastErr)
+	return report, lastErr
+}
 
-		for _, resource := range resources {
-			if err := waitForTPRInit(context, resource); err != nil {
-				lastErr = err
-			}
+// installResource creates or verifies the resource and waits for it to initialize
+func installResource(ctx gocontext.Context, context Context, resource CustomResource, install createFunc, waitForInit waitForInitFunc) (result ResourceReport) {
+	logger := context.logger()
+	ctx, span := context.startSpan(ctx, SpanInstallCustomResource, "resource", resource.Name)
+	defer func() {
+		span.SetAttributes("outcome", string(result.Outcome))
+		endSpan(span, result.Err)
+	}()
+	start := time.Now()
+	outcome, err := install(context.withSpan(ctx), resource)
+	context.Metrics.observeCRDCreation(resource.Name, outcome)
+	result = ResourceReport{Resource: resource, Outcome: outcome, Duration: time.Since(start), Err: err}
+	if err != nil {
+		logger.Error(err, "failed to create custom resource", "resource", resource.Name)
+		context.reportProgress(resource, PhaseFailed, err)
+		context.recordCRDEvent(resource, v1.EventTypeWarning, EventReasonCRDFailed, err.Error())
+		return result
+	}
+	logger.Info("created custom resource", "resource", resource.Name, "outcome", outcome)
+	context.reportProgress(resource, PhaseCreated, nil)
+
+	logger.Debug("waiting for custom resource to initialize", "resource", resource.Name)
+	start = time.Now()
+	waitCtx, waitSpan := context.startSpan(ctx, SpanWaitForCustomResource, "resource", resource.Name)
+	err = waitForInit(context.withSpan(waitCtx), resource)
+	endSpan(waitSpan, err)
+	context.Metrics.observeEstablishment(resource.Name, time.Since(start), err)
+	result.Duration += time.Since(start)
+	if err != nil {
+		result.Outcome = OutcomeFailed
+		result.Err = err
+		logger.Error(err, "custom resource did not initialize", "resource", resource.Name)
+		context.reportProgress(resource, PhaseFailed, err)
+		context.recordCRDEvent(resource, v1.EventTypeWarning, EventReasonCRDFailed, err.Error())
+		return result
+	}
+	logger.Info("custom resource is established", "resource", resource.Name, "duration", result.Duration)
+	context.reportProgress(resource, PhaseEstablished, nil)
+	context.recordCRDEvent(resource, v1.EventTypeNormal, EventReasonCRDEstablished, fmt.Sprintf("%s is established", resource.Name))
+
+	if err := createDefaultInstances(context, resource); err != nil {
+		result.Outcome = OutcomeFailed
+		result.Err = err
+		logger.Error(err, "failed to create default instances", "resource", resource.Name)
+		context.reportProgress(resource, PhaseFailed, err)
+	}
+	return result
+}
+
+// installFuncs returns the functions that create, verify, and wait for custom resources of the API flavor of the
+// cluster. Resources with the secondary role are verified instead of created.
+func installFuncs(context Context) (createFunc, createFunc, waitForInitFunc, error) {
+	flavor, err := detectAPIFlavor(context)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	context.logger().Info("installing the custom resources", "api", flavor.String(), "detected", context.APIFlavor == AutoDetect)
+	create, verify, waitForInit := flavorInstallFuncs(flavor)
+	return create, verify, waitForInit, nil
+}
+
+// flavorInstallFuncs returns the functions that create, verify, and wait for custom resources of the API flavor
+func flavorInstallFuncs(flavor APIFlavor) (createFunc, createFunc, waitForInitFunc) {
+	switch flavor {
+	case ForceCRDv1:
+		return createCRDv1, verifyCRDv1, waitForCRDv1Init
+	case ForceCRDv1beta1:
+		return createCRD, verifyCRDv1beta1, waitForCRDInit
+	default:
+		return createTPR, verifyTPR, waitForTPRInit
+	}
+}
+
+// detectAPIFlavor returns the flavor forced in the context, or the flavor supported by the cluster
+func detectAPIFlavor(context Context) (APIFlavor, error) {
+	if context.APIFlavor != AutoDetect {
+		return context.APIFlavor, nil
+	}
+	if capabilities := context.Capabilities; capabilities != nil {
+		switch {
+		case capabilities.CRDv1:
+			return ForceCRDv1, nil
+		case capabilities.CRDv1beta1:
+			return ForceCRDv1beta1, nil
+		default:
+			return ForceTPR, nil
 		}
 	}
-	return lastErr
+
+	// CRD is available on v1.7.0 and above. TPR became deprecated on v1.7.0
+	serverVersion, err := context.Clientset.Discovery().ServerVersion()
+	if err != nil {
+		return AutoDetect, fmt.Errorf("Error getting server version: %v", err)
+	}
+	kubeVersion, err := parseServerVersion(serverVersion)
+	if err != nil {
+		context.logger().Info("selecting the custom resource API from discovery", "reason", err.Error())
+		return apiFlavorFromDiscovery(context, serverVersion.GitVersion)
+	}
+
+	if kubeVersion.AtLeast(serverVersionV1160) {
+		return ForceCRDv1, nil
+	}
+	if kubeVersion.AtLeast(serverVersionV170) {
+		return ForceCRDv1beta1, nil
+	}
+	if !kubeVersion.AtLeast(serverVersionV120) {
+		return AutoDetect, &ErrUnsupportedServerVersion{Version: serverVersion.GitVersion}
+	}
+	return ForceTPR, nil
 }
 
-func createCRD(context Context, resource CustomResource) error {
-	crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
-	crd := &apiextensionsv1beta1.CustomResourceDefinition{
+func newCRDv1beta1(resource CustomResource) *apiextensionsv1beta1.CustomResourceDefinition {
+	return &apiextensionsv1beta1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apiextensions.k8s.io/v1beta1",
+			Kind:       "CustomResourceDefinition",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: crdName,
+			Name: fmt.Sprintf("%s.%s", resource.Plural, resource.Group),
 		},
 		Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
 			Group:   resource.Group,
@@ -125,40 +426,60 @@ func createCRD(context Context, resource CustomResource) error {
 			},
 		},
 	}
+}
+
+func createCRD(context Context, resource CustomResource) (InstallOutcome, error) {
+	if len(resource.Manifest) > 0 {
+		return createCRDFromManifest(context, resource)
+	}
+	crd := newCRDv1beta1(resource)
+	crd.Labels = context.crdLabels()
 
-	_, err := context.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions().Create(crd)
+	crdClient := context.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions()
+	_, err := crdClient.Create(crd)
 	if err != nil {
 		if !errors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create %s CRD. %+v", resource.Name, err)
+			return OutcomeFailed, fmt.Errorf("failed to create %s CRD. %+v", resource.Name, err)
 		}
+		existing, err := crdClient.Get(crd.Name, metav1.GetOptions{})
+		if err != nil {
+			return OutcomeFailed, fmt.Errorf("failed to get %s CRD. %+v", resource.Name, err)
+		}
+		if err := checkCRDOwner(context, resource, existing.Labels); err != nil {
+			return OutcomeFailed, err
+		}
+		update, err := checkCRDMismatch(context, resource, crdV1beta1Definition(crd), crdV1beta1Definition(existing))
+		if err != nil {
+			return OutcomeFailed, err
+		}
+		if !update {
+			return OutcomeAlreadyExisted, nil
+		}
+		existing.Spec = crd.Spec
+		if _, err := crdClient.Update(existing); err != nil {
+			return OutcomeFailed, fmt.Errorf("failed to update %s CRD. %+v", resource.Name, err)
+		}
+		return OutcomeUpdated, nil
 	}
-	return nil
+	return OutcomeCreated, nil
 }
 
 func waitForCRDInit(context Context, resource CustomResource) error {
 	crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
-	return wait.Poll(context.Interval, context.Timeout, func() (bool, error) {
-		crd, err := context.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions().Get(crdName, metav1.GetOptions{})
+	crdClient := context.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions()
+	watchFunc := func() (watch.Interface, error) {
+		return crdClient.Watch(metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", crdName).String()})
+	}
+	return waitForCRDEstablished(context, resource, watchFunc, func() ([]CRDCondition, error) {
+		crd, err := crdClient.Get(crdName, metav1.GetOptions{})
 		if err != nil {
-			return false, err
-		}
-		for _, cond := range crd.Status.Conditions {
-			switch cond.Type {
-			case apiextensionsv1beta1.Established:
-				if cond.Status == apiextensionsv1beta1.ConditionTrue {
-					return true, nil
-				}
-			case apiextensionsv1beta1.NamesAccepted:
-				if cond.Status == apiextensionsv1beta1.ConditionFalse {
-					return false, fmt.Errorf("Name conflict: %v\n", cond.Reason)
-				}
-			}
+			return nil, err
 		}
-		return false, nil
+		return crdV1beta1Conditions(crd), nil
 	})
 }
 
-func createTPR(context Context, resource CustomResource) error {
+func createTPR(context Context, resource CustomResource) (InstallOutcome, error) {
 	tprName := fmt.Sprintf("%s.%s", resource.Name, resource.Group)
 	tpr := &v1beta1.ThirdPartyResource{
 		ObjectMeta: metav1.ObjectMeta{
@@ -172,35 +493,78 @@ func createTPR(context Context, resource CustomResource) error {
 	_, err := context.Clientset.ExtensionsV1beta1().ThirdPartyResources().Create(tpr)
 	if err != nil {
 		if !errors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create %s TPR. %+v", resource.Name, err)
+			return OutcomeFailed, fmt.Errorf("failed to create %s TPR. %+v", resource.Name, err)
 		}
+		return OutcomeAlreadyExisted, nil
 	}
-	return nil
+	return OutcomeCreated, nil
 }
 
+// TPRTimeoutPolicy decides what happens to a TPR that did not initialize before the timeout
+type TPRTimeoutPolicy int
+
+const (
+	// KeepTPROnTimeout leaves the TPR in place and reports why it did not initialize. This is the default.
+	KeepTPROnTimeout TPRTimeoutPolicy = iota
+
+	// DeleteTPROnTimeout deletes the TPR so that the next attempt creates it again. Deprecated: the deletion races
+	// with other components registering the same TPR.
+	DeleteTPROnTimeout
+)
+
 func waitForTPRInit(context Context, resource CustomResource) error {
 	// wait for TPR being established
 	restcli := context.Clientset.CoreV1().RESTClient()
 	uri := fmt.Sprintf("apis/%s/%s/%s", resource.Group, resource.Version, resource.Plural)
 	tprName := fmt.Sprintf("%s.%s", resource.Name, resource.Group)
 
-	err := wait.Poll(context.Interval, context.Timeout, func() (bool, error) {
+	var lastErr error
+	err := context.waitStrategy().Wait(context.waitInterval(), context.Timeout, nil, func() (bool, error) {
 		_, err := restcli.Get().RequestURI(uri).DoRaw()
 		if err != nil {
 			if errors.IsNotFound(err) {
+				lastErr = err
 				return false, nil
 			}
 			return false, err
 		}
 		return true, nil
-
 	})
-	if err != nil {
+	if err == wait.ErrWaitTimeout {
+		err = &ErrCRDNotEstablished{Resource: resource.Name, Diagnostic: diagnoseTPR(context, resource, tprName, lastErr)}
+	}
+	if err != nil && context.TPRTimeoutPolicy == DeleteTPROnTimeout {
+		context.logger().Info("deleting the TPR that did not initialize. the delete-on-timeout policy is deprecated", "resource", resource.Name)
 		deleteErr := context.Clientset.ExtensionsV1beta1().ThirdPartyResources().Delete(tprName, nil)
 		if deleteErr != nil {
 			return errorsUtil.NewAggregate([]error{err, deleteErr})
 		}
 		return err
 	}
+	if err != nil {
+		return err
+	}
 	return nil
 }
+
+// diagnoseTPR describes why the TPR did not initialize, from the TPR and the last response of the apiserver
+func diagnoseTPR(context Context, resource CustomResource, tprName string, lastErr error) string {
+	tpr, err := context.Clientset.ExtensionsV1beta1().ThirdPartyResources().Get(tprName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return fmt.Sprintf("the TPR %s does not exist, another component may have deleted it", tprName)
+	}
+	if err != nil {
+		return fmt.Sprintf("failed to get the TPR %s. %+v", tprName, err)
+	}
+	declared := false
+	for _, version := range tpr.Versions {
+		if version.Name == resource.Version {
+			declared = true
+		}
+	}
+	if !declared {
+		return fmt.Sprintf("the TPR %s does not declare version %s", tprName, resource.Version)
+	}
+	return fmt.Sprintf("the TPR %s exists but the apiserver does not serve %s/%s yet. last response: %v", tprName,
+		resource.Group, resource.Version, lastErr)
+}
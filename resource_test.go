@@ -17,14 +17,22 @@ package operatorkit
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apiextensionsclientfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 )
 
 var exampleResource = CustomResource{
@@ -42,8 +50,9 @@ func TestCreateCRDCustomResource(t *testing.T) {
 		Timeout:               1 * time.Second,
 	}
 
-	err := createCRD(ctx, exampleResource)
+	outcome, err := createCRD(ctx, exampleResource)
 	assert.NoError(t, err)
+	assert.Equal(t, OutcomeCreated, outcome)
 
 	crdName := fmt.Sprintf("%s.%s", exampleResource.Plural, exampleResource.Group)
 	crd, err := ctx.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions().Get(crdName, metav1.GetOptions{})
@@ -57,6 +66,11 @@ func TestCreateCRDCustomResource(t *testing.T) {
 	assert.Equal(t, "example.com", crd.Spec.Group)
 	assert.Equal(t, "v1alpha", crd.Spec.Version)
 	assert.Equal(t, apiextensionsv1beta1.NamespaceScoped, crd.Spec.Scope)
+
+	// creating the CRD again is not an error
+	outcome, err = createCRD(ctx, exampleResource)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeAlreadyExisted, outcome)
 }
 
 func TestCreateTPRCustomResource(t *testing.T) {
@@ -66,8 +80,9 @@ func TestCreateTPRCustomResource(t *testing.T) {
 		Timeout:   1 * time.Second,
 	}
 
-	err := createTPR(ctx, exampleResource)
+	outcome, err := createTPR(ctx, exampleResource)
 	assert.NoError(t, err)
+	assert.Equal(t, OutcomeCreated, outcome)
 
 	tprName := fmt.Sprintf("%s.%s", exampleResource.Name, exampleResource.Group)
 	tpr, err := ctx.Clientset.ExtensionsV1beta1().ThirdPartyResources().Get(tprName, metav1.GetOptions{})
@@ -77,3 +92,137 @@ func TestCreateTPRCustomResource(t *testing.T) {
 	assert.Equal(t, "v1alpha", tpr.Versions[0].Name)
 	assert.Equal(t, "ThirdPartyResource for example", tpr.Description)
 }
+
+func TestCreateCRDMismatch(t *testing.T) {
+	existing := newCRDv1beta1(exampleResource)
+	existing.Spec.Scope = apiextensionsv1beta1.ClusterScoped
+	existing.Spec.Names.Kind = "Other"
+	ctx := Context{APIExtensionClientset: apiextensionsclientfake.NewSimpleClientset(existing)}
+	resource := exampleResource
+	resource.Kind = "Example"
+
+	outcome, err := createCRD(ctx, resource)
+	assert.Equal(t, OutcomeFailed, outcome)
+	assert.Equal(t, &ErrCRDMismatch{Resource: "example", Mismatches: []string{
+		"scope is Cluster instead of Namespaced", "kind is Other instead of Example"}}, err)
+	assert.True(t, IsMisconfiguration(err))
+
+	// the scope cannot be updated
+	ctx.CRDMismatchPolicy = UpdateOnCRDMismatch
+	_, err = createCRD(ctx, resource)
+	assert.Error(t, err)
+
+	ctx.CRDMismatchPolicy = WarnOnCRDMismatch
+	outcome, err = createCRD(ctx, resource)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeAlreadyExisted, outcome)
+
+	existing.Spec.Scope = apiextensionsv1beta1.NamespaceScoped
+	ctx.APIExtensionClientset = apiextensionsclientfake.NewSimpleClientset(existing)
+	ctx.CRDMismatchPolicy = UpdateOnCRDMismatch
+	outcome, err = createCRD(ctx, resource)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeUpdated, outcome)
+	crd, err := ctx.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions().Get(existing.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Example", crd.Spec.Names.Kind)
+}
+
+// newInstallClients serves the server version and v1beta1 CRDs that are established once created, except that the
+// CRD of the resource named "broken" cannot be created and the names of the CRD of the resource named "conflicting"
+// are not accepted
+func newInstallClients(t *testing.T) (kubernetes.Interface, apiextensionsclient.Interface) {
+	clientset, err := kubernetes.NewForConfig(&rest.Config{
+		Host: "http://install",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			recorder.WriteString(`{"major":"1","minor":"8","gitVersion":"v1.8.0"}`)
+			return recorder.Result(), nil
+		}),
+	})
+	assert.NoError(t, err)
+
+	apiExtClientset, err := apiextensionsclient.NewForConfig(&rest.Config{
+		Host: "http://install",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			switch {
+			case req.Method == http.MethodPost:
+				body, _ := ioutil.ReadAll(req.Body)
+				if strings.Contains(string(body), `"name":"brokens.example.com"`) {
+					recorder.WriteHeader(http.StatusInternalServerError)
+					recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"InternalError","code":500}`)
+					break
+				}
+				recorder.WriteHeader(http.StatusCreated)
+				recorder.Write(body)
+			case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/conflictings.example.com"):
+				recorder.WriteString(`{"apiVersion":"apiextensions.k8s.io/v1beta1","kind":"CustomResourceDefinition",` +
+					`"metadata":{"name":"conflictings.example.com"},` +
+					`"status":{"conditions":[{"type":"NamesAccepted","status":"False","reason":"KindConflict","message":"taken"}]}}`)
+			case req.Method == http.MethodGet:
+				recorder.WriteString(`{"apiVersion":"apiextensions.k8s.io/v1beta1","kind":"CustomResourceDefinition",` +
+					`"status":{"conditions":[{"type":"Established","status":"True"}]}}`)
+			default:
+				t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+			}
+			return recorder.Result(), nil
+		}),
+	})
+	assert.NoError(t, err)
+	return clientset, apiExtClientset
+}
+
+func installResources(names ...string) []CustomResource {
+	var resources []CustomResource
+	for _, name := range names {
+		resources = append(resources, CustomResource{Name: name, Plural: name + "s", Group: "example.com", Version: "v1",
+			Kind: name, Scope: apiextensionsv1beta1.NamespaceScoped})
+	}
+	return resources
+}
+
+func TestCreateCustomResourcesProgress(t *testing.T) {
+	var lock sync.Mutex
+	phases := map[string][]InstallPhase{}
+	failures := map[string]error{}
+	clientset, apiExtClientset := newInstallClients(t)
+	ctx := Context{
+		Clientset:             clientset,
+		APIExtensionClientset: apiExtClientset,
+		Interval:              time.Millisecond,
+		Timeout:               time.Second,
+		CRDConcurrency:        2,
+		Progress: func(resource CustomResource, phase InstallPhase, err error) {
+			// the resources are installed concurrently
+			lock.Lock()
+			defer lock.Unlock()
+			phases[resource.Name] = append(phases[resource.Name], phase)
+			if phase == PhaseFailed {
+				failures[resource.Name] = err
+			} else {
+				assert.NoError(t, err)
+			}
+		},
+	}
+
+	report, err := CreateCustomResourcesWithReport(ctx, installResources("first", "broken", "second", "conflicting", "third"))
+	assert.Error(t, err)
+	assert.Equal(t, map[string][]InstallPhase{
+		"first":       {PhaseCreated, PhaseEstablished},
+		"broken":      {PhaseFailed},
+		"second":      {PhaseCreated, PhaseEstablished},
+		"conflicting": {PhaseCreated, PhaseFailed},
+		"third":       {PhaseCreated, PhaseEstablished},
+	}, phases)
+
+	// the failures reported to the callback are the errors of the report
+	assert.Len(t, failures, 2)
+	assert.Equal(t, report.Resources[1].Err, failures["broken"])
+	assert.Equal(t, report.Resources[3].Err, failures["conflicting"])
+	assert.Equal(t, OutcomeFailed, report.Resources[1].Outcome)
+	assert.Equal(t, OutcomeFailed, report.Resources[3].Outcome)
+	assert.Equal(t, OutcomeCreated, report.Resources[4].Outcome)
+}
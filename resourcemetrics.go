@@ -0,0 +1,249 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// FieldMetric exports a field of the custom resources as a gauge per resource, labeled with the namespace and name
+// of the resource, like kube-state-metrics does for built-in resources. Numbers are exported as is, booleans as 1 or
+// 0, and RFC 3339 timestamps as Unix seconds. Resources without the field have no series.
+type FieldMetric struct {
+	// Name of the metric, prefixed with the namespace of the metrics and the name of the resource, such as
+	// "ready_replicas" for "operator_cluster_ready_replicas"
+	Name string
+	Help string
+
+	// Path of the field, such as "status.readyReplicas"
+	Path string
+
+	// Values of a string field, such as the phases of the resource. Each value has a series labeled by the last
+	// element of the path, which is 1 for the value of the field and 0 for the other values.
+	Values []string
+
+	// Labels adds labels from other fields by label name, such as "version": "spec.version"
+	Labels map[string]string
+}
+
+// ResourceMetricsOptions configure the metrics exported for the custom resources of a controller
+type ResourceMetricsOptions struct {
+	Fields []FieldMetric
+
+	// Conditions records the status conditions of the resources with Metrics.SetConditions whenever the controller
+	// observes them, so that the alert rules of the condition catalog are evaluated against them. The controller
+	// deletes the series of deleted resources.
+	Conditions bool
+}
+
+// ExportResources registers a collector deriving gauges from the custom resources in the store of the controller
+// each time the metrics are gathered, so that platform teams alert on the state of the resources, such as the
+// number of clusters in the Failed phase, without deploying another exporter. Deleted resources leave the store, so
+// their series disappear with them.
+func (m *Metrics) ExportResources(c *Controller, opts ResourceMetricsOptions) error {
+	if m == nil {
+		return nil
+	}
+	collector := &resourceCollector{controller: c, options: opts, fields: map[string]*prometheus.Desc{}}
+	prefix := strings.ToLower(c.resource.Name)
+	for _, field := range opts.Fields {
+		if field.Path == "" {
+			return fmt.Errorf("field metric %s has no path", field.Name)
+		}
+		if _, ok := collector.fields[field.Name]; ok {
+			return fmt.Errorf("field metric %s is declared more than once", field.Name)
+		}
+		labels := []string{"namespace", "name"}
+		if len(field.Values) > 0 {
+			labels = append(labels, lastPathElement(field.Path))
+		}
+		labels = append(labels, sortedLabelNames(field.Labels)...)
+		if err := checkUniqueLabels(labels); err != nil {
+			return fmt.Errorf("field metric %s has conflicting labels. %+v", field.Name, err)
+		}
+		collector.fields[field.Name] = prometheus.NewDesc(prometheus.BuildFQName(m.namespace, prefix, field.Name),
+			field.Help, labels, nil)
+	}
+
+	if opts.Conditions {
+		m.conditionControllersLock.Lock()
+		exported := m.conditionControllers[c]
+		m.conditionControllersLock.Unlock()
+		if exported {
+			return fmt.Errorf("the conditions of %s are already exported", c.resource.Name)
+		}
+	}
+	if len(collector.fields) > 0 {
+		if err := m.registerer.Register(collector); err != nil {
+			return fmt.Errorf("failed to register the metrics of %s. %+v", c.resource.Name, err)
+		}
+	}
+	if opts.Conditions {
+		m.conditionControllersLock.Lock()
+		m.conditionControllers[c] = true
+		m.conditionControllersLock.Unlock()
+
+		// the resources already in the store are recorded now, the others when the controller observes them
+		for _, obj := range c.store.List() {
+			m.observeConditions(c, obj)
+		}
+	}
+	return nil
+}
+
+// checkUniqueLabels returns an error naming the first label that appears more than once
+func checkUniqueLabels(labels []string) error {
+	seen := map[string]bool{}
+	for _, label := range labels {
+		if seen[label] {
+			return fmt.Errorf("label %q is used more than once", label)
+		}
+		seen[label] = true
+	}
+	return nil
+}
+
+// observeConditions records the conditions of the custom resource if its controller exports them
+func (m *Metrics) observeConditions(c *Controller, obj interface{}) {
+	if m == nil {
+		return
+	}
+	m.conditionControllersLock.Lock()
+	exported := m.conditionControllers[c]
+	m.conditionControllersLock.Unlock()
+	if !exported {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	conditions, err := objectConditions(obj)
+	if err != nil {
+		return
+	}
+	m.SetConditions(c.resource.Name, key, conditions)
+}
+
+type resourceCollector struct {
+	controller *Controller
+	options    ResourceMetricsOptions
+	fields     map[string]*prometheus.Desc
+}
+
+func (r *resourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range r.fields {
+		ch <- desc
+	}
+}
+
+func (r *resourceCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, obj := range r.controller.store.List() {
+		object, err := jsonMap(obj)
+		if err != nil {
+			continue
+		}
+		metadata, _ := object["metadata"].(map[string]interface{})
+		namespace, _ := metadata["namespace"].(string)
+		name, _ := metadata["name"].(string)
+
+		for _, field := range r.options.Fields {
+			value, ok := fieldValue(object, field.Path)
+			if !ok {
+				continue
+			}
+			labels := []string{namespace, name}
+			extra := sortedLabelNames(field.Labels)
+			extraValues := make([]string, len(extra))
+			for i, label := range extra {
+				if v, ok := fieldValue(object, field.Labels[label]); ok {
+					extraValues[i] = fmt.Sprint(v)
+				}
+			}
+			desc := r.fields[field.Name]
+			if len(field.Values) > 0 {
+				for _, v := range field.Values {
+					gauge := 0.0
+					if value == v {
+						gauge = 1
+					}
+					values := append(append(append([]string{}, labels...), v), extraValues...)
+					ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, gauge, values...)
+				}
+				continue
+			}
+			if gauge, ok := gaugeValue(value); ok {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, gauge, append(labels, extraValues...)...)
+			}
+		}
+
+	}
+}
+
+// fieldValue returns the value at the dotted path of the object
+func fieldValue(object map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = object
+	for _, element := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[element]; !ok {
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+// gaugeValue converts a number, boolean, or RFC 3339 timestamp to the value of a gauge
+func gaugeValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return float64(t.Unix()), true
+		}
+	}
+	return 0, false
+}
+
+func lastPathElement(path string) string {
+	elements := strings.Split(path, ".")
+	return elements[len(elements)-1]
+}
+
+func sortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestExportResources(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	a := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "ns", "name": "a"},
+		"spec":     map[string]interface{}{"version": "1.2"},
+		"status": map[string]interface{}{"phase": "Failed", "readyReplicas": 2, "healthy": false,
+			"lastBackup": "2020-01-02T03:04:05Z", "conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "False"}}},
+	}}
+	store.Add(a)
+	store.Add(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "ns", "name": "b"},
+	}})
+	m := NewMetrics("test")
+	c := newController(Context{Metrics: m}, exampleResource, nil, nil, ControllerOptions{})
+	defer c.queue.ShutDown()
+	c.store = store
+	err := m.ExportResources(c, ResourceMetricsOptions{
+		Fields: []FieldMetric{
+			{Name: "ready_replicas", Help: "Ready replicas.", Path: "status.readyReplicas", Labels: map[string]string{"version": "spec.version"}},
+			{Name: "healthy", Help: "Healthy.", Path: "status.healthy"},
+			{Name: "last_backup_timestamp_seconds", Help: "Last backup.", Path: "status.lastBackup"},
+			{Name: "status_phase", Help: "Phase.", Path: "status.phase", Values: []string{"Running", "Failed"}},
+		},
+		Conditions: true,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{"name=a,namespace=ns,version=1.2": 2},
+		gatherLabeled(t, m.Registry(), "test_example_ready_replicas"))
+	assert.Equal(t, map[string]float64{"name=a,namespace=ns": 0}, gatherLabeled(t, m.Registry(), "test_example_healthy"))
+	assert.Equal(t, map[string]float64{"name=a,namespace=ns": 1577934245},
+		gatherLabeled(t, m.Registry(), "test_example_last_backup_timestamp_seconds"))
+	assert.Equal(t, map[string]float64{"name=a,namespace=ns,phase=Running": 0, "name=a,namespace=ns,phase=Failed": 1},
+		gatherLabeled(t, m.Registry(), "test_example_status_phase"))
+	assert.Equal(t, map[string]float64{"name=a,namespace=ns,resource=example,status=False,type=Ready": 1},
+		gatherLabeled(t, m.Registry(), "test_resource_condition"))
+
+	// the conditions are recorded again when the controller observes an update
+	updated := a.DeepCopy()
+	updated.Object["status"].(map[string]interface{})["conditions"] = []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}}
+	assert.NoError(t, store.Update(updated))
+	c.eventHandlers().UpdateFunc(a, updated)
+	assert.Equal(t, map[string]float64{"name=a,namespace=ns,resource=example,status=True,type=Ready": 1},
+		gatherLabeled(t, m.Registry(), "test_resource_condition"))
+
+	// the series of deleted resources disappear with them
+	assert.NoError(t, store.Delete(updated))
+	c.eventHandlers().DeleteFunc(updated)
+	assert.Empty(t, gatherLabeled(t, m.Registry(), "test_resource_condition"))
+	assert.Empty(t, gatherLabeled(t, m.Registry(), "test_example_status_phase"))
+
+	assert.Error(t, m.ExportResources(c, ResourceMetricsOptions{Fields: []FieldMetric{{Name: "missing_path"}}}))
+	assert.EqualError(t, m.ExportResources(c, ResourceMetricsOptions{Conditions: true}),
+		"the conditions of example are already exported")
+}
+
+func TestExportResourcesLabelCollisions(t *testing.T) {
+	c := &Controller{resource: exampleResource, store: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	m := NewMetrics("test")
+
+	// the label of the values is the last element of the path
+	err := m.ExportResources(c, ResourceMetricsOptions{Fields: []FieldMetric{
+		{Name: "owner", Path: "spec.owner.name", Values: []string{"a", "b"}},
+	}})
+	assert.EqualError(t, err, `field metric owner has conflicting labels. label "name" is used more than once`)
+
+	err = m.ExportResources(c, ResourceMetricsOptions{Fields: []FieldMetric{
+		{Name: "status_phase", Path: "status.phase", Values: []string{"Running"}, Labels: map[string]string{"phase": "spec.phase"}},
+	}})
+	assert.EqualError(t, err, `field metric status_phase has conflicting labels. label "phase" is used more than once`)
+
+	err = m.ExportResources(c, ResourceMetricsOptions{Fields: []FieldMetric{
+		{Name: "replicas", Path: "spec.replicas"},
+		{Name: "replicas", Path: "status.replicas"},
+	}})
+	assert.EqualError(t, err, "field metric replicas is declared more than once")
+
+	// nothing was registered by the rejected declarations
+	assert.NoError(t, m.ExportResources(c, ResourceMetricsOptions{Fields: []FieldMetric{
+		{Name: "replicas", Path: "spec.replicas", Labels: map[string]string{"phase": "status.phase"}},
+	}}))
+}
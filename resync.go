@@ -0,0 +1,204 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResyncSchedule enqueues all the custom resources of a controller periodically, regardless of Kubernetes events, for
+// operators that poll systems outside of the cluster. Set either the interval or the cron expression.
+type ResyncSchedule struct {
+	// Interval between two resyncs
+	Interval time.Duration
+
+	// Cron expression of the resyncs in the local time zone, with the minute, hour, day of month, month, and day of
+	// week fields such as "*/15 * * * *", or one of @hourly, @daily, @weekly, and @monthly
+	Cron string
+}
+
+// next returns the func computing the time of the resync following a time
+func (s ResyncSchedule) next() (func(time.Time) time.Time, error) {
+	switch {
+	case s.Interval > 0 && s.Cron != "":
+		return nil, fmt.Errorf("resync schedule has both an interval and a cron expression")
+	case s.Interval > 0:
+		return func(t time.Time) time.Time { return t.Add(s.Interval) }, nil
+	case s.Cron != "":
+		schedule, err := ParseCron(s.Cron)
+		if err != nil {
+			return nil, err
+		}
+		return schedule.Next, nil
+	default:
+		return nil, fmt.Errorf("resync schedule has neither an interval nor a cron expression")
+	}
+}
+
+// CronSchedule is a parsed cron expression
+type CronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+
+	// anyDay and anyWeekday are set when the field is "*". A day matches either restricted field, as in cron.
+	anyDay, anyWeekday bool
+}
+
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseCron parses a cron expression with five fields. Each field is "*", a value, a range such as "1-5", or a
+// list of them, with an optional step such as "*/10".
+func ParseCron(expr string) (*CronSchedule, error) {
+	if descriptor, ok := cronDescriptors[strings.TrimSpace(expr)]; ok {
+		expr = descriptor
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	s := &CronSchedule{anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	var err error
+	for _, f := range []struct {
+		bits     *uint64
+		field    string
+		min, max int
+	}{
+		{&s.minutes, fields[0], 0, 59},
+		{&s.hours, fields[1], 0, 23},
+		{&s.days, fields[2], 1, 31},
+		{&s.months, fields[3], 1, 12},
+		{&s.weekdays, fields[4], 0, 7},
+	} {
+		if *f.bits, err = parseCronField(f.field, f.min, f.max); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q. %+v", expr, err)
+		}
+	}
+	// 7 is another name for Sunday
+	if s.weekdays&(1<<7) != 0 {
+		s.weekdays |= 1
+	}
+	return s, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if step > 1 {
+				// a value with a step, such as "5/15", runs from the value to the maximum
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time after t matching the schedule, or the zero time if none matches within five years,
+// such as for February 30th
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// runResync enqueues all the custom resources in the store at the times of the schedule until the stop channel is
+// closed
+func (c *Controller) runResync(next func(time.Time) time.Time, stopCh <-chan struct{}) {
+	for {
+		at := next(time.Now())
+		if at.IsZero() {
+			c.context.logger().Info("the resync schedule has no next time", "resource", c.resource.Name)
+			return
+		}
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+			c.resyncAll()
+		}
+	}
+}
+
+// resyncAll enqueues the keys of all the custom resources in the store
+func (c *Controller) resyncAll() {
+	keys := c.store.ListKeys()
+	for _, key := range keys {
+		c.queue.Add(key)
+	}
+	c.context.Metrics.SetWorkqueueDepth(c.resource.Name, c.queue.Len())
+	c.context.logger().Debug("enqueued all resources for the periodic resync", "resource", c.resource.Name, "count", len(keys))
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestCronSchedule(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC)
+	for expr, expected := range map[string]time.Time{
+		"*/15 * * * *":   time.Date(2024, time.January, 31, 10, 15, 0, 0, time.UTC),
+		"0 * * * *":      time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC),
+		"@daily":         time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"30 2 * * 1-5":   time.Date(2024, time.February, 1, 2, 30, 0, 0, time.UTC),
+		"0 0 29 2 *":     time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * 7":      time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"5,10 10 31 * *": time.Date(2024, time.January, 31, 10, 10, 0, 0, time.UTC),
+		"0 0 30 2 *":     {},
+	} {
+		schedule, err := ParseCron(expr)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, schedule.Next(start), expr)
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestResyncSchedule(t *testing.T) {
+	next, err := ResyncSchedule{Interval: time.Minute}.next()
+	assert.NoError(t, err)
+	now := time.Now()
+	assert.Equal(t, now.Add(time.Minute), next(now))
+
+	_, err = ResyncSchedule{}.next()
+	assert.Error(t, err)
+	_, err = ResyncSchedule{Interval: time.Minute, Cron: "@hourly"}.next()
+	assert.Error(t, err)
+	_, err = ResyncSchedule{Cron: "@never"}.next()
+	assert.Error(t, err)
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}})
+	store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "b"}})
+	c := &Controller{store: store, queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())}
+
+	stopCh := make(chan struct{})
+	go c.runResync(func(t time.Time) time.Time { return t.Add(10 * time.Millisecond) }, stopCh)
+	time.Sleep(50 * time.Millisecond)
+	close(stopCh)
+	assert.Equal(t, 2, c.queue.Len())
+}
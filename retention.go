@@ -0,0 +1,217 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const defaultRetentionInterval = time.Hour
+
+// RetentionKind is a kind of object pruned by the retention controller
+type RetentionKind string
+
+const (
+	// RetainJobs prunes finished Jobs, such as the helper jobs of an operator. Running jobs are never pruned, and the
+	// pods of pruned jobs are deleted with them.
+	RetainJobs RetentionKind = "Jobs"
+	// RetainEvents prunes Events by the time they were last seen
+	RetainEvents RetentionKind = "Events"
+	// RetainConfigMaps prunes ConfigMaps, such as the diagnostics collected by an operator
+	RetainConfigMaps RetentionKind = "ConfigMaps"
+)
+
+// RetentionRule bounds how long the objects of a kind matching the selector are kept. Objects older than the MaxAge
+// are deleted, and only the newest MaxCount objects of each namespace are kept. A zero MaxAge or MaxCount does not
+// limit the age or the count.
+type RetentionRule struct {
+	Kind RetentionKind
+
+	// Namespace of the objects. Defaults to all namespaces.
+	Namespace string
+
+	// LabelSelector of the objects, such as the labels the operator sets on its helper jobs. Without a selector all
+	// the objects of the kind are subject to the rule.
+	LabelSelector string
+
+	MaxAge   time.Duration
+	MaxCount int
+}
+
+// RetentionController prunes the jobs, events, and diagnostics created by an operator, so long-lived clusters stay
+// tidy without external cron jobs
+type RetentionController struct {
+	context  Context
+	interval time.Duration
+	rules    []RetentionRule
+	now      func() time.Time
+}
+
+// retentionCandidate is an object subject to a rule, with the time its age is measured from
+type retentionCandidate struct {
+	namespace string
+	name      string
+	time      time.Time
+}
+
+// NewRetentionController creates a controller pruning by the rules at the interval. The interval defaults to an hour.
+func NewRetentionController(context Context, interval time.Duration, rules ...RetentionRule) *RetentionController {
+	return &RetentionController{
+		context:  context,
+		interval: durationOrDefault(interval, defaultRetentionInterval),
+		rules:    rules,
+		now:      time.Now,
+	}
+}
+
+// Run prunes at the interval until the stop channel is closed
+func (c *RetentionController) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if _, err := c.Prune(); err != nil {
+			c.context.logger().Error(err, "failed to prune objects")
+		}
+	}, c.interval, stopCh)
+}
+
+// Prune deletes the objects that are past the retention of their rule. It returns the number of deleted objects
+// and an aggregate of the errors of all rules.
+func (c *RetentionController) Prune() (int, error) {
+	var errs []error
+	deleted := 0
+	for _, rule := range c.rules {
+		candidates, err := c.candidates(rule)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, candidate := range expiredCandidates(candidates, rule, c.now()) {
+			if err := c.delete(rule.Kind, candidate); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			deleted++
+			c.context.logger().Debug("pruned object", "kind", rule.Kind, "namespace", candidate.namespace, "name", candidate.name)
+		}
+	}
+	if deleted > 0 {
+		c.context.logger().Info("pruned objects past their retention", "count", deleted)
+	}
+	return deleted, errorsUtil.NewAggregate(errs)
+}
+
+// candidates lists the objects subject to the rule
+func (c *RetentionController) candidates(rule RetentionRule) ([]retentionCandidate, error) {
+	opts := metav1.ListOptions{LabelSelector: rule.LabelSelector}
+	var candidates []retentionCandidate
+	switch rule.Kind {
+	case RetainJobs:
+		jobs, err := c.context.Clientset.BatchV1().Jobs(rule.Namespace).List(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs. %+v", err)
+		}
+		for _, job := range jobs.Items {
+			if finished, at := jobFinished(&job); finished {
+				candidates = append(candidates, retentionCandidate{namespace: job.Namespace, name: job.Name, time: at})
+			}
+		}
+	case RetainEvents:
+		events, err := c.context.Clientset.CoreV1().Events(rule.Namespace).List(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events. %+v", err)
+		}
+		for _, event := range events.Items {
+			at := event.LastTimestamp.Time
+			if at.IsZero() {
+				at = event.CreationTimestamp.Time
+			}
+			candidates = append(candidates, retentionCandidate{namespace: event.Namespace, name: event.Name, time: at})
+		}
+	case RetainConfigMaps:
+		configMaps, err := c.context.Clientset.CoreV1().ConfigMaps(rule.Namespace).List(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list configmaps. %+v", err)
+		}
+		for _, configMap := range configMaps.Items {
+			candidates = append(candidates, retentionCandidate{namespace: configMap.Namespace, name: configMap.Name, time: configMap.CreationTimestamp.Time})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported retention kind %s", rule.Kind)
+	}
+	return candidates, nil
+}
+
+// delete deletes the object, ignoring objects that are already gone
+func (c *RetentionController) delete(kind RetentionKind, candidate retentionCandidate) error {
+	var err error
+	switch kind {
+	case RetainJobs:
+		propagation := metav1.DeletePropagationBackground
+		err = c.context.Clientset.BatchV1().Jobs(candidate.namespace).Delete(candidate.name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+	case RetainEvents:
+		err = c.context.Clientset.CoreV1().Events(candidate.namespace).Delete(candidate.name, &metav1.DeleteOptions{})
+	case RetainConfigMaps:
+		err = c.context.Clientset.CoreV1().ConfigMaps(candidate.namespace).Delete(candidate.name, &metav1.DeleteOptions{})
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to prune %s %s/%s. %+v", kind, candidate.namespace, candidate.name, err)
+	}
+	return nil
+}
+
+// jobFinished returns whether the job completed or failed, and when
+func jobFinished(job *batchv1.Job) (bool, time.Time) {
+	for _, cond := range job.Status.Conditions {
+		if (cond.Type == batchv1.JobComplete || cond.Type == batchv1.JobFailed) && cond.Status == v1.ConditionTrue {
+			if job.Status.CompletionTime != nil {
+				return true, job.Status.CompletionTime.Time
+			}
+			return true, cond.LastTransitionTime.Time
+		}
+	}
+	return false, time.Time{}
+}
+
+// expiredCandidates returns the candidates older than the max age of the rule, and those beyond the max count of
+// the newest candidates of their namespace
+func expiredCandidates(candidates []retentionCandidate, rule RetentionRule, now time.Time) []retentionCandidate {
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].time.After(candidates[j].time) })
+
+	var expired []retentionCandidate
+	kept := map[string]int{}
+	for _, candidate := range candidates {
+		if rule.MaxAge > 0 && now.Sub(candidate.time) > rule.MaxAge {
+			expired = append(expired, candidate)
+			continue
+		}
+		if rule.MaxCount > 0 && kept[candidate.namespace] >= rule.MaxCount {
+			expired = append(expired, candidate)
+			continue
+		}
+		kept[candidate.namespace]++
+	}
+	return expired
+}
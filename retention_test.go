@@ -0,0 +1,90 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func finishedJob(name string, finished time.Time) *batchv1.Job {
+	completion := metav1.NewTime(finished)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{"app": "helper"}},
+		Status: batchv1.JobStatus{
+			CompletionTime: &completion,
+			Conditions:     []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func TestRetentionController(t *testing.T) {
+	now := time.Now()
+	event := func(name string, lastSeen time.Time) *v1.Event {
+		return &v1.Event{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}, LastTimestamp: metav1.NewTime(lastSeen)}
+	}
+	clientset := fake.NewSimpleClientset(
+		finishedJob("old", now.Add(-2*time.Hour)),
+		finishedJob("recent", now.Add(-10*time.Minute)),
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "ns", Labels: map[string]string{"app": "helper"},
+			CreationTimestamp: metav1.NewTime(now.Add(-3 * time.Hour))}},
+		event("first", now.Add(-3*time.Minute)),
+		event("second", now.Add(-2*time.Minute)),
+		event("third", now.Add(-time.Minute)),
+	)
+	c := NewRetentionController(Context{Clientset: clientset}, 0,
+		RetentionRule{Kind: RetainJobs, LabelSelector: "app=helper", MaxAge: time.Hour},
+		RetentionRule{Kind: RetainEvents, Namespace: "ns", MaxCount: 2},
+	)
+	assert.Equal(t, defaultRetentionInterval, c.interval)
+
+	deleted, err := c.Prune()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	jobs, err := clientset.BatchV1().Jobs("ns").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	var names []string
+	for _, job := range jobs.Items {
+		names = append(names, job.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"recent", "running"}, names)
+
+	events, err := clientset.CoreV1().Events("ns").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	names = nil
+	for _, event := range events.Items {
+		names = append(names, event.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"second", "third"}, names)
+
+	// nothing is left to prune
+	deleted, err = c.Prune()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+
+	_, err = NewRetentionController(Context{Clientset: clientset}, 0, RetentionRule{Kind: "Pods"}).Prune()
+	assert.Error(t, err)
+}
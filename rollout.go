@@ -0,0 +1,225 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	gocontext "context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const defaultRolloutStepTimeout = 10 * time.Minute
+
+// RolloutOptions configure how RollOut updates a set of workloads
+type RolloutOptions struct {
+	// MaxUnavailable is the number of workloads updated at the same time. Defaults to one.
+	MaxUnavailable int
+
+	// StepTimeout bounds the wait for the workloads of a step to be ready. Defaults to ten minutes.
+	StepTimeout time.Duration
+
+	// Interval between two readiness checks. Defaults to DefaultReadinessInterval.
+	Interval time.Duration
+
+	// Rollback restores the previous spec of the updated workloads when a step fails or the rollout is cancelled
+	Rollback bool
+}
+
+// RolloutResult lists the workloads updated and rolled back by a rollout, as namespace/name
+type RolloutResult struct {
+	Updated    []string
+	RolledBack []string
+}
+
+// RolloutError is returned when a step of a rollout failed. The workloads after the failed step are not updated.
+type RolloutError struct {
+	// Workload that failed, as namespace/name
+	Workload string
+	Err      error
+}
+
+func (e *RolloutError) Error() string {
+	return fmt.Sprintf("rollout stopped at %s. %+v", e.Workload, e.Err)
+}
+
+// rolloutWorkload updates one deployment or stateful set, and remembers its spec before the update for the rollback
+type rolloutWorkload struct {
+	name     string
+	update   func() error
+	ready    func() (bool, error)
+	rollback func() error
+}
+
+// RollOut updates the spec of the deployments and stateful sets to the desired objects in order, MaxUnavailable at a
+// time, and waits for the workloads of each step to be ready before starting the next, so that a storage cluster
+// keeps enough daemons serving during an upgrade. The objects must be *appsv1beta2.Deployment or
+// *appsv1beta2.StatefulSet with their namespace and name set, and the workloads must exist. The rollout stops at
+// the first step that fails, and the updated workloads are rolled back if the options say so.
+func RollOut(ctx gocontext.Context, context Context, desired []runtime.Object, opts RolloutOptions) (RolloutResult, error) {
+	result := RolloutResult{}
+	if context.Clientset == nil {
+		return result, fmt.Errorf("the context has no clientset")
+	}
+	var workloads []*rolloutWorkload
+	for _, obj := range desired {
+		workload, err := newRolloutWorkload(context, obj)
+		if err != nil {
+			return result, err
+		}
+		workloads = append(workloads, workload)
+	}
+	batch := opts.MaxUnavailable
+	if batch <= 0 {
+		batch = 1
+	}
+
+	var updated []*rolloutWorkload
+	for start := 0; start < len(workloads); start += batch {
+		end := start + batch
+		if end > len(workloads) {
+			end = len(workloads)
+		}
+		step := workloads[start:end]
+		err := rolloutStep(ctx, step, &updated, opts)
+		for _, workload := range updated[len(result.Updated):] {
+			result.Updated = append(result.Updated, workload.name)
+		}
+		if err == nil {
+			continue
+		}
+		if !opts.Rollback {
+			return result, err
+		}
+		var errs []error
+		for i := len(updated) - 1; i >= 0; i-- {
+			if rollbackErr := updated[i].rollback(); rollbackErr != nil {
+				errs = append(errs, fmt.Errorf("failed to roll back %s. %+v", updated[i].name, rollbackErr))
+				continue
+			}
+			result.RolledBack = append(result.RolledBack, updated[i].name)
+		}
+		context.logger().Info("rolled back", "workloads", strings.Join(result.RolledBack, ","), "error", err.Error())
+		if len(errs) > 0 {
+			return result, errorsUtil.NewAggregate(append([]error{err}, errs...))
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+// rolloutStep updates the workloads of the step and waits for them to be ready within the step timeout
+func rolloutStep(ctx gocontext.Context, step []*rolloutWorkload, updated *[]*rolloutWorkload, opts RolloutOptions) error {
+	for _, workload := range step {
+		if err := workload.update(); err != nil {
+			return &RolloutError{Workload: workload.name, Err: err}
+		}
+		*updated = append(*updated, workload)
+	}
+
+	stepCtx, cancel := gocontext.WithTimeout(ctx, durationOrDefault(opts.StepTimeout, defaultRolloutStepTimeout))
+	defer cancel()
+	for _, workload := range step {
+		if err := WaitForReady(stepCtx, opts.Interval, ReadinessFunc(workload.ready)); err != nil {
+			return &RolloutError{Workload: workload.name, Err: err}
+		}
+	}
+	return nil
+}
+
+func newRolloutWorkload(context Context, obj runtime.Object) (*rolloutWorkload, error) {
+	switch desired := obj.(type) {
+	case *appsv1beta2.Deployment:
+		client := context.Clientset.AppsV1beta2().Deployments(desired.Namespace)
+		var previous *appsv1beta2.DeploymentSpec
+		return &rolloutWorkload{
+			name: desired.Namespace + "/" + desired.Name,
+			update: func() error {
+				live, err := client.Get(desired.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				spec := live.Spec
+				live.Spec = desired.Spec
+				if _, err := client.Update(live); err != nil {
+					return err
+				}
+				previous = &spec
+				return nil
+			},
+			ready: func() (bool, error) {
+				live, err := client.Get(desired.Name, metav1.GetOptions{})
+				if err != nil {
+					return false, err
+				}
+				return DeploymentReady(live)
+			},
+			rollback: func() error {
+				live, err := client.Get(desired.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				live.Spec = *previous
+				_, err = client.Update(live)
+				return err
+			},
+		}, nil
+	case *appsv1beta2.StatefulSet:
+		client := context.Clientset.AppsV1beta2().StatefulSets(desired.Namespace)
+		var previous *appsv1beta2.StatefulSetSpec
+		return &rolloutWorkload{
+			name: desired.Namespace + "/" + desired.Name,
+			update: func() error {
+				live, err := client.Get(desired.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				spec := live.Spec
+				live.Spec = desired.Spec
+				if _, err := client.Update(live); err != nil {
+					return err
+				}
+				previous = &spec
+				return nil
+			},
+			ready: func() (bool, error) {
+				live, err := client.Get(desired.Name, metav1.GetOptions{})
+				if err != nil {
+					return false, err
+				}
+				return StatefulSetReady(live), nil
+			},
+			rollback: func() error {
+				live, err := client.Get(desired.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				live.Spec = *previous
+				_, err = client.Update(live)
+				return err
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("cannot roll out %T, only deployments and stateful sets", obj)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	gocontext "context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func rolloutDeployment(name, image string, ready bool) *appsv1beta2.Deployment {
+	deployment := &appsv1beta2.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Generation: 1},
+		Spec: appsv1beta2.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "daemon", Image: image}}}}},
+		Status: appsv1beta2.DeploymentStatus{ObservedGeneration: 1},
+	}
+	if ready {
+		deployment.Status.Replicas, deployment.Status.UpdatedReplicas, deployment.Status.AvailableReplicas = 1, 1, 1
+	}
+	return deployment
+}
+
+func rolloutImage(t *testing.T, clientset *fake.Clientset, name string) string {
+	deployment, err := clientset.AppsV1beta2().Deployments("ns").Get(name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	return deployment.Spec.Template.Spec.Containers[0].Image
+}
+
+func TestRollOut(t *testing.T) {
+	clientset := fake.NewSimpleClientset(rolloutDeployment("a", "v1", true), rolloutDeployment("b", "v1", false),
+		rolloutDeployment("c", "v1", true))
+	context := Context{Clientset: clientset}
+	opts := RolloutOptions{StepTimeout: 20 * time.Millisecond, Interval: time.Millisecond}
+
+	result, err := RollOut(gocontext.Background(), context, []runtime.Object{rolloutDeployment("a", "v2", false)}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ns/a"}, result.Updated)
+	assert.Equal(t, "v2", rolloutImage(t, clientset, "a"))
+
+	// b never becomes ready, so c is not updated and a and b are rolled back
+	opts.Rollback = true
+	desired := []runtime.Object{rolloutDeployment("a", "v3", false), rolloutDeployment("b", "v3", false),
+		rolloutDeployment("c", "v3", false)}
+	result, err = RollOut(gocontext.Background(), context, desired, opts)
+	rolloutErr, ok := err.(*RolloutError)
+	assert.True(t, ok)
+	assert.Equal(t, "ns/b", rolloutErr.Workload)
+	assert.Equal(t, []string{"ns/a", "ns/b"}, result.Updated)
+	assert.Equal(t, []string{"ns/b", "ns/a"}, result.RolledBack)
+	assert.Equal(t, "v2", rolloutImage(t, clientset, "a"))
+	assert.Equal(t, "v1", rolloutImage(t, clientset, "b"))
+	assert.Equal(t, "v1", rolloutImage(t, clientset, "c"))
+
+	// without rollback the updated workloads keep their new spec
+	opts.Rollback = false
+	opts.MaxUnavailable = 2
+	result, err = RollOut(gocontext.Background(), context, desired, opts)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"ns/a", "ns/b"}, result.Updated)
+	assert.Equal(t, "v3", rolloutImage(t, clientset, "b"))
+
+	_, err = RollOut(gocontext.Background(), context, []runtime.Object{&v1.Pod{}}, opts)
+	assert.Error(t, err)
+}
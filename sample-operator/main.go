@@ -22,18 +22,26 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	opkit "github.com/rook/operator-kit"
 	sample "github.com/rook/operator-kit/sample-operator/pkg/apis/myproject/v1alpha1"
 	sampleclient "github.com/rook/operator-kit/sample-operator/pkg/client/clientset/versioned/typed/myproject/v1alpha1"
 	"k8s.io/api/core/v1"
-	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
 func main() {
+	resources := []opkit.CustomResource{sample.SampleResource}
+
+	// "sample-operator export-crds" writes the CRDs for clusters where the operator may not create them
+	if len(os.Args) > 1 && os.Args[1] == "export-crds" {
+		if err := opkit.RunExportCommand(os.Args[2:], resources, os.Stdout); err != nil {
+			fmt.Printf("failed to export CRDs. %+v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Println("Getting kubernetes context")
 	context, sampleClientset, err := createContext()
 	if err != nil {
@@ -43,7 +51,6 @@ func main() {
 
 	// Create and wait for CRD resources
 	fmt.Println("Registering the sample resource")
-	resources := []opkit.CustomResource{sample.SampleResource}
 	err = opkit.CreateCustomResources(*context, resources)
 	if err != nil {
 		fmt.Printf("failed to create custom resource. %+v\n", err)
@@ -76,14 +83,9 @@ func createContext() (*opkit.Context, sampleclient.MyprojectV1alpha1Interface, e
 		return nil, nil, fmt.Errorf("failed to get k8s config. %+v", err)
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	context, err := opkit.NewContext(config)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get k8s client. %+v", err)
-	}
-
-	apiExtClientset, err := apiextensionsclient.NewForConfig(config)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create k8s API extension clientset. %+v", err)
+		return nil, nil, err
 	}
 
 	sampleClientset, err := sampleclient.NewForConfig(config)
@@ -91,12 +93,6 @@ func createContext() (*opkit.Context, sampleclient.MyprojectV1alpha1Interface, e
 		return nil, nil, fmt.Errorf("failed to create sample clientset. %+v", err)
 	}
 
-	context := &opkit.Context{
-		Clientset:             clientset,
-		APIExtensionClientset: apiExtClientset,
-		Interval:              500 * time.Millisecond,
-		Timeout:               60 * time.Second,
-	}
 	return context, sampleClientset, nil
 
 }
@@ -0,0 +1,193 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// ReconcileScheduler divides a fixed number of concurrent reconciles between the controllers of an operator, and
+// optionally between the tenants of each controller, with weighted fair queuing. Each class of reconciles is
+// charged the time its reconciles take divided by its weight, and a free slot goes to the waiting class that was
+// charged the least, so one expensive controller cannot monopolize the workers of the others.
+type ReconcileScheduler struct {
+	lock    sync.Mutex
+	slots   int
+	running int
+	weights map[string]float64
+	charged map[string]float64
+	waiting map[string][]chan struct{}
+}
+
+// NewReconcileScheduler creates a scheduler running up to slots reconciles at once across all its controllers
+func NewReconcileScheduler(slots int) *ReconcileScheduler {
+	if slots < 1 {
+		slots = 1
+	}
+	return &ReconcileScheduler{
+		slots:   slots,
+		weights: map[string]float64{},
+		charged: map[string]float64{},
+		waiting: map[string][]chan struct{}{},
+	}
+}
+
+// SetWeight sets the share of the reconcile time of a class relative to the others. Classes default to a weight
+// of 1. The class of a controller is the name of its resource, or "resource/tenant" with a tenant func.
+func (s *ReconcileScheduler) SetWeight(class string, weight float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if weight > 0 {
+		s.weights[class] = weight
+	}
+}
+
+// Charged returns the reconcile time charged to the class, divided by its weight
+func (s *ReconcileScheduler) Charged(class string) time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return time.Duration(s.charged[class] * float64(time.Second))
+}
+
+// Acquire blocks until a slot is granted to the class, and returns the func to call with the slot when the
+// reconcile is done. It returns false if the stop channel was closed first.
+func (s *ReconcileScheduler) Acquire(class string, stopCh <-chan struct{}) (func(), bool) {
+	s.lock.Lock()
+	if s.running < s.slots && len(s.waiting) == 0 {
+		s.running++
+		s.lock.Unlock()
+		return s.releaser(class), true
+	}
+
+	if len(s.waiting[class]) == 0 {
+		// a class becoming active starts from the least charged active class rather than from its old charge,
+		// so idle classes do not save up time to monopolize the slots later
+		s.charged[class] = maxFloat(s.charged[class], s.minWaitingCharge())
+	}
+	granted := make(chan struct{})
+	s.waiting[class] = append(s.waiting[class], granted)
+	s.lock.Unlock()
+
+	select {
+	case <-granted:
+		return s.releaser(class), true
+	case <-stopCh:
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if !s.dequeue(class, granted) {
+			// the slot was granted concurrently, pass it on
+			s.grant()
+		}
+		return nil, false
+	}
+}
+
+// releaser returns the func charging the class for the time since the slot was acquired and freeing the slot
+func (s *ReconcileScheduler) releaser(class string) func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			weight := s.weights[class]
+			if weight == 0 {
+				weight = 1
+			}
+			s.charged[class] += time.Since(start).Seconds() / weight
+			s.grant()
+		})
+	}
+}
+
+// grant hands the freed slot to the first waiter of the least charged waiting class, or frees it
+func (s *ReconcileScheduler) grant() {
+	next := ""
+	for class := range s.waiting {
+		if next == "" || s.charged[class] < s.charged[next] || (s.charged[class] == s.charged[next] && class < next) {
+			next = class
+		}
+	}
+	if next == "" {
+		s.running--
+		return
+	}
+
+	granted := s.waiting[next][0]
+	s.waiting[next] = s.waiting[next][1:]
+	if len(s.waiting[next]) == 0 {
+		delete(s.waiting, next)
+	}
+	close(granted)
+}
+
+// dequeue removes a waiter that gave up, and returns false if it was not waiting anymore
+func (s *ReconcileScheduler) dequeue(class string, granted chan struct{}) bool {
+	waiters := s.waiting[class]
+	for i, waiter := range waiters {
+		if waiter != granted {
+			continue
+		}
+		s.waiting[class] = append(waiters[:i:i], waiters[i+1:]...)
+		if len(s.waiting[class]) == 0 {
+			delete(s.waiting, class)
+		}
+		return true
+	}
+	return false
+}
+
+func (s *ReconcileScheduler) minWaitingCharge() float64 {
+	first := true
+	var min float64
+	for class := range s.waiting {
+		if first || s.charged[class] < min {
+			min = s.charged[class]
+			first = false
+		}
+	}
+	return min
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// NamespaceTenant is a tenant func treating each namespace as a tenant
+func NamespaceTenant(key string) string {
+	namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+	return namespace
+}
+
+// acquireSlot waits for a slot of the scheduler of the controller, if any, for the reconcile of the key
+func (c *Controller) acquireSlot(key string) (func(), bool) {
+	if c.options.Scheduler == nil {
+		return func() {}, true
+	}
+	class := c.resource.Name
+	if c.options.Tenant != nil {
+		class += "/" + c.options.Tenant(key)
+	}
+	return c.options.Scheduler.Acquire(class, c.stopCh)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcileSchedulerFairness(t *testing.T) {
+	s := NewReconcileScheduler(1)
+	s.SetWeight("cheap", 2)
+	s.charged["expensive"] = 10
+
+	release, ok := s.Acquire("expensive", nil)
+	assert.True(t, ok)
+
+	order := make(chan string, 2)
+	acquire := func(class string) {
+		release, ok := s.Acquire(class, nil)
+		assert.True(t, ok)
+		order <- class
+		release()
+	}
+	go acquire("expensive")
+	waitForWaiters(t, s, 1)
+	go acquire("cheap")
+	waitForWaiters(t, s, 2)
+
+	// the slot goes to the least charged class even though it queued last
+	release()
+	assert.Equal(t, "cheap", <-order)
+	assert.Equal(t, "expensive", <-order)
+	assert.True(t, s.Charged("expensive") >= 10*time.Second)
+	assert.Equal(t, 0, s.running)
+}
+
+func TestReconcileSchedulerStop(t *testing.T) {
+	s := NewReconcileScheduler(1)
+	release, ok := s.Acquire("a", nil)
+	assert.True(t, ok)
+
+	stopCh := make(chan struct{})
+	done := make(chan bool)
+	go func() {
+		_, ok := s.Acquire("b", stopCh)
+		done <- ok
+	}()
+	waitForWaiters(t, s, 1)
+	close(stopCh)
+	assert.False(t, <-done)
+
+	release()
+	release()
+	assert.Equal(t, 0, s.running)
+	assert.Empty(t, s.waiting)
+}
+
+func TestNamespaceTenant(t *testing.T) {
+	assert.Equal(t, "ns", NamespaceTenant("ns/name"))
+	assert.Equal(t, "", NamespaceTenant("name"))
+}
+
+func waitForWaiters(t *testing.T, s *ReconcileScheduler, count int) {
+	for i := 0; i < 100; i++ {
+		s.lock.Lock()
+		waiters := 0
+		for _, w := range s.waiting {
+			waiters += len(w)
+		}
+		s.lock.Unlock()
+		if waiters == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d waiters", count)
+}
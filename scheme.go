@@ -0,0 +1,44 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AddKnownTypesFunc returns a func registering the object and list types of the custom resource with a scheme, for
+// use with runtime.NewSchemeBuilder. The list is registered as the kind suffixed with "List".
+func (r CustomResource) AddKnownTypesFunc(obj, list runtime.Object) func(*runtime.Scheme) error {
+	return func(scheme *runtime.Scheme) error {
+		gv := r.GroupVersionKind().GroupVersion()
+		scheme.AddKnownTypeWithName(gv.WithKind(r.Kind), obj)
+		scheme.AddKnownTypeWithName(gv.WithKind(r.Kind+"List"), list)
+		metav1.AddToGroupVersion(scheme, gv)
+		return nil
+	}
+}
+
+// AddToScheme registers the object and list types of the custom resource with the scheme, together with the
+// options and status types of its group version. It returns the codec for the query parameters of requests.
+func AddToScheme(scheme *runtime.Scheme, resource CustomResource, obj, list runtime.Object) (runtime.ParameterCodec, error) {
+	if err := resource.AddKnownTypesFunc(obj, list)(scheme); err != nil {
+		return nil, err
+	}
+	return runtime.NewParameterCodec(scheme), nil
+}
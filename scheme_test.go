@@ -0,0 +1,42 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAddToScheme(t *testing.T) {
+	resource := CustomResource{Name: "sample", Plural: "samples", Group: "example.com", Version: "v1", Kind: "Sample"}
+	scheme := runtime.NewScheme()
+	codec, err := AddToScheme(scheme, resource, &metav1.Status{}, &metav1.List{})
+	assert.NoError(t, err)
+	assert.NotNil(t, codec)
+
+	gv := schema.GroupVersion{Group: "example.com", Version: "v1"}
+	assert.True(t, scheme.Recognizes(gv.WithKind("Sample")))
+	assert.True(t, scheme.Recognizes(gv.WithKind("SampleList")))
+	assert.True(t, scheme.Recognizes(gv.WithKind("ListOptions")))
+
+	values, err := codec.EncodeParameters(&metav1.ListOptions{LabelSelector: "app=sample"}, gv)
+	assert.NoError(t, err)
+	assert.Equal(t, "app=sample", values.Get("labelSelector"))
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// SharedInformers shares the informer caches and clients of custom resources between controllers. Controllers of
+// the same resource and namespace, such as separate status and finalizer controllers, then list and watch the
+// resource once and keep one copy of it in memory.
+type SharedInformers struct {
+	context   Context
+	scheme    *runtime.Scheme
+	lock      sync.Mutex
+	clients   map[schema.GroupVersion]rest.Interface
+	informers map[string]*sharedInformer
+	transform TransformFunc
+}
+
+// sharedInformer is run once, by the first controller started with it
+type sharedInformer struct {
+	informer cache.SharedIndexInformer
+	once     sync.Once
+}
+
+func (s *sharedInformer) run(stopCh <-chan struct{}) {
+	s.once.Do(func() { s.informer.Run(stopCh) })
+}
+
+// NewSharedInformers creates the shared informers. The scheme must have the types of the custom resources
+// registered. The context must have a RESTConfig unless all clients are added with SetClient.
+func NewSharedInformers(context Context, scheme *runtime.Scheme) *SharedInformers {
+	return &SharedInformers{
+		context:   context,
+		scheme:    scheme,
+		clients:   map[schema.GroupVersion]rest.Interface{},
+		informers: map[string]*sharedInformer{},
+	}
+}
+
+// SetClient sets the client of the group and version of the resource, for example a fake client in tests
+func (s *SharedInformers) SetClient(resource CustomResource, client rest.Interface) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.clients[resource.GroupVersionKind().GroupVersion()] = client
+}
+
+// SetTransform sets the transform of the objects of the informers created afterwards, for example DefaultTransform
+func (s *SharedInformers) SetTransform(transform TransformFunc) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.transform = transform
+}
+
+// Client returns the client of the group and version of the resource, shared by all its resources
+func (s *SharedInformers) Client(resource CustomResource) (rest.Interface, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.client(resource)
+}
+
+func (s *SharedInformers) client(resource CustomResource) (rest.Interface, error) {
+	gv := resource.GroupVersionKind().GroupVersion()
+	if client, ok := s.clients[gv]; ok {
+		return client, nil
+	}
+	if s.context.RESTConfig == nil {
+		return nil, fmt.Errorf("the context has no RESTConfig")
+	}
+
+	config := *s.context.RESTConfig
+	client, err := newRESTClient(&config, resource.Group, resource.Version, s.scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for %s. %+v", gv.String(), err)
+	}
+	s.clients[gv] = client
+	return client, nil
+}
+
+// Informer returns the informer of the resource in the namespace, creating it on first use
+func (s *SharedInformers) Informer(resource CustomResource, namespace string, objType runtime.Object) (cache.SharedIndexInformer, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	shared, err := s.informer(resource, namespace, objType)
+	if err != nil {
+		return nil, err
+	}
+	return shared.informer, nil
+}
+
+func (s *SharedInformers) informer(resource CustomResource, namespace string, objType runtime.Object) (*sharedInformer, error) {
+	key := fmt.Sprintf("%s/%s", resource.Name, namespace)
+	if shared, ok := s.informers[key]; ok {
+		return shared, nil
+	}
+
+	client, err := s.client(resource)
+	if err != nil {
+		return nil, err
+	}
+	source := cache.NewListWatchFromClient(client, resource.Plural, namespace, fields.Everything())
+	pageListWatch(source, DefaultPageSize)
+	instrumentWatch(source, s.context, resource.Name)
+	transformListWatch(source, s.context, resource.Name, s.transform)
+
+	shared := &sharedInformer{
+		informer: cache.NewSharedIndexInformer(source, objType, 0, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+	}
+	s.informers[key] = shared
+	return shared, nil
+}
+
+// NewController creates a controller like NewControllerWithOptions, sharing the client and the informer of the
+// resource and namespace with the other controllers created by the shared informers. The indexers of the options
+// are added to the shared informer, before it is started, unless an index of the same name was added already. The informer is run by the
+// first controller started, until its stop channel is closed, so all controllers sharing it must be stopped
+// together, as the controllers of a Manager are.
+func (s *SharedInformers) NewController(resource CustomResource, namespace string, objType runtime.Object, reconciler Reconciler,
+	options ControllerOptions) (*Controller, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	shared, err := s.informer(resource, namespace, objType)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.client(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	// controllers sharing the informer may declare the same indexes
+	indexers := cache.Indexers{}
+	existing := shared.informer.GetIndexer().GetIndexers()
+	for name, indexFunc := range options.Indexers {
+		if _, ok := existing[name]; !ok {
+			indexers[name] = indexFunc
+		}
+	}
+	if len(indexers) > 0 {
+		if err := shared.informer.AddIndexers(indexers); err != nil {
+			return nil, fmt.Errorf("failed to add the indexers of %s. %+v", resource.Name, err)
+		}
+	}
+
+	c := newController(s.context, resource, client, reconciler, options)
+	c.indexer = shared.informer.GetIndexer()
+	c.store = c.indexer
+	c.hasSynced = shared.informer.HasSynced
+	c.runInformer = shared.run
+	c.skipReplayedAdds(shared.informer.GetStore().ListKeys())
+	shared.informer.AddEventHandler(c.eventHandlers())
+	return c, nil
+}
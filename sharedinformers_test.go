@@ -0,0 +1,65 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSharedInformers(t *testing.T) {
+	informers := NewSharedInformers(Context{}, scheme.Scheme)
+	_, err := informers.Client(exampleResource)
+	assert.Error(t, err)
+
+	client := &rest.RESTClient{}
+	informers.SetClient(exampleResource, client)
+	shared, err := informers.Client(exampleResource)
+	assert.NoError(t, err)
+	assert.Equal(t, client, shared)
+
+	reconciler := ReconcilerFunc(func(key string) error { return nil })
+	status, err := informers.NewController(exampleResource, "ns", &v1.ConfigMap{}, reconciler, ControllerOptions{})
+	assert.NoError(t, err)
+	finalizer, err := informers.NewController(exampleResource, "ns", &v1.ConfigMap{}, reconciler, ControllerOptions{})
+	assert.NoError(t, err)
+	assert.True(t, status.Store() == finalizer.Store())
+	assert.False(t, status.queue == finalizer.queue)
+
+	other, err := informers.NewController(exampleResource, "other", &v1.ConfigMap{}, reconciler, ControllerOptions{})
+	assert.NoError(t, err)
+	assert.False(t, status.Store() == other.Store())
+
+	informer, err := informers.Informer(exampleResource, "ns", &v1.ConfigMap{})
+	assert.NoError(t, err)
+	assert.True(t, informer.GetStore() == status.Store())
+
+	// controllers sharing the informer may declare the same index
+	byName := func(obj interface{}) ([]string, error) { return nil, nil }
+	options := ControllerOptions{Indexers: cache.Indexers{"name": byName}}
+	indexed, err := informers.NewController(exampleResource, "ns", &v1.ConfigMap{}, reconciler, options)
+	assert.NoError(t, err)
+	_, err = informers.NewController(exampleResource, "ns", &v1.ConfigMap{}, reconciler, options)
+	assert.NoError(t, err)
+	indexers := indexed.Indexer().GetIndexers()
+	assert.NotNil(t, indexers["name"])
+	assert.NotNil(t, indexers[cache.NamespaceIndex])
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"time"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// SignalTopicLabel labels each Signal with its topic so subscribers only watch the signals of their topic
+const SignalTopicLabel = "operatorkit.io/topic"
+
+// SignalResource is the custom resource carrying signals between operators, such as "maintenance starting". Create
+// it with CreateCustomResources before publishing or subscribing.
+var SignalResource = CustomResource{
+	Name:    "signal",
+	Plural:  "signals",
+	Group:   "operatorkit.io",
+	Version: "v1",
+	Scope:   apiextensionsv1beta1.NamespaceScoped,
+	Kind:    "Signal",
+}
+
+// Signal is a message published by an operator to the operators subscribed to its topic
+type Signal struct {
+	// Topic of the signal, such as "maintenance". It must be a valid label value.
+	Topic string
+
+	// Source is the operator publishing the signal
+	Source string
+
+	// Data is optional and describes the signal
+	Data map[string]string
+
+	// Namespace and Name of the Signal resource, set by the server
+	Namespace string
+	Name      string
+
+	// Published is the time the signal was published, set by the server
+	Published time.Time
+}
+
+// PublishSignal creates a Signal resource in the namespace. The context must have a DynamicClientPool.
+func PublishSignal(context Context, namespace string, signal Signal) (*Signal, error) {
+	client, err := signalClient(context, namespace)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{}
+	for key, value := range signal.Data {
+		data[key] = value
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": SignalResource.Group + "/" + SignalResource.Version,
+		"kind":       SignalResource.Kind,
+		"metadata": map[string]interface{}{
+			"generateName": signal.Topic + "-",
+			"namespace":    namespace,
+			"labels":       map[string]interface{}{SignalTopicLabel: signal.Topic},
+		},
+		"spec": map[string]interface{}{"topic": signal.Topic, "source": signal.Source, "data": data},
+	}}
+	created, err := client.Create(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish signal %s. %+v", signal.Topic, err)
+	}
+	return signalFromObject(created), nil
+}
+
+// SubscribeSignals creates a watcher passing the signals of the topic published in the namespace to the handler.
+// Signals published before the watch started are passed too, so that subscribers see the signals published while
+// they were down; compare Published to ignore old signals. Watch must be called with an
+// *unstructured.Unstructured object type. The context must have a DynamicClientPool.
+func SubscribeSignals(context Context, namespace, topic string, handler func(signal *Signal)) (*ResourceWatcher, error) {
+	gvr := schema.GroupVersionResource{Group: SignalResource.Group, Version: SignalResource.Version, Resource: SignalResource.Plural}
+	w, err := NewDynamicWatcher(context, gvr, namespace, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				handler(signalFromObject(u))
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	selector := fmt.Sprintf("%s=%s", SignalTopicLabel, topic)
+	list, watchFunc := w.source.ListFunc, w.source.WatchFunc
+	w.source.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		options.LabelSelector = selector
+		return list(options)
+	}
+	w.source.WatchFunc = func(options metav1.ListOptions) (watch.Interface, error) {
+		options.LabelSelector = selector
+		return watchFunc(options)
+	}
+	return w, nil
+}
+
+// PruneSignals deletes the signals in the namespace published more than maxAge ago and returns how many were
+// deleted. The context must have a DynamicClientPool.
+func PruneSignals(context Context, namespace string, maxAge time.Duration) (int, error) {
+	client, err := signalClient(context, namespace)
+	if err != nil {
+		return 0, err
+	}
+	obj, err := client.List(metav1.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list signals. %+v", err)
+	}
+	list, ok := obj.(*unstructured.UnstructuredList)
+	if !ok {
+		return 0, fmt.Errorf("unexpected list type %T of signals", obj)
+	}
+
+	deleted := 0
+	for _, item := range list.Items {
+		if time.Since(item.GetCreationTimestamp().Time) <= maxAge {
+			continue
+		}
+		if err := client.Delete(item.GetName(), &metav1.DeleteOptions{}); err != nil {
+			return deleted, fmt.Errorf("failed to delete signal %s. %+v", item.GetName(), err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func signalClient(context Context, namespace string) (dynamic.ResourceInterface, error) {
+	if context.DynamicClientPool == nil {
+		return nil, fmt.Errorf("the context has no dynamic client pool")
+	}
+	gvr := schema.GroupVersionResource{Group: SignalResource.Group, Version: SignalResource.Version, Resource: SignalResource.Plural}
+	return dynamicResourceClient(context.DynamicClientPool, gvr, namespace)
+}
+
+func signalFromObject(obj *unstructured.Unstructured) *Signal {
+	topic, _, _ := unstructured.NestedString(obj.Object, "spec", "topic")
+	source, _, _ := unstructured.NestedString(obj.Object, "spec", "source")
+	data, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "data")
+	return &Signal{
+		Topic:     topic,
+		Source:    source,
+		Data:      data,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Published: obj.GetCreationTimestamp().Time,
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+func TestSignals(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().UTC().Format(time.RFC3339)
+	var requests []string
+	var published string
+	pool := dynamic.NewDynamicClientPool(&rest.Config{
+		Host: "http://signals",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req.Method+" "+req.URL.Path+"?"+req.URL.RawQuery)
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			switch req.Method {
+			case http.MethodPost:
+				body, _ := ioutil.ReadAll(req.Body)
+				published = string(body)
+				recorder.WriteHeader(http.StatusCreated)
+				recorder.WriteString(`{"apiVersion":"operatorkit.io/v1","kind":"Signal","metadata":{"name":"maintenance-x7k2p","namespace":"ns","creationTimestamp":"` + recent + `"},
+					"spec":{"topic":"maintenance","source":"storage-operator","data":{"node":"node-1"}}}`)
+			case http.MethodGet:
+				recorder.WriteString(`{"apiVersion":"operatorkit.io/v1","kind":"SignalList","metadata":{},"items":[
+					{"apiVersion":"operatorkit.io/v1","kind":"Signal","metadata":{"name":"old","namespace":"ns","creationTimestamp":"` + old + `"}},
+					{"apiVersion":"operatorkit.io/v1","kind":"Signal","metadata":{"name":"recent","namespace":"ns","creationTimestamp":"` + recent + `"}}]}`)
+			default:
+				recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Success"}`)
+			}
+			return recorder.Result(), nil
+		}),
+	})
+	context := Context{DynamicClientPool: pool}
+
+	_, err := PublishSignal(Context{}, "ns", Signal{Topic: "maintenance"})
+	assert.Error(t, err)
+
+	signal, err := PublishSignal(context, "ns", Signal{Topic: "maintenance", Source: "storage-operator", Data: map[string]string{"node": "node-1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "maintenance-x7k2p", signal.Name)
+	assert.Equal(t, "storage-operator", signal.Source)
+	assert.Equal(t, map[string]string{"node": "node-1"}, signal.Data)
+	assert.Contains(t, published, `"generateName":"maintenance-"`)
+	assert.Contains(t, published, `"labels":{"operatorkit.io/topic":"maintenance"}`)
+
+	var received []*Signal
+	w, err := SubscribeSignals(context, "ns", "maintenance", func(signal *Signal) {
+		received = append(received, signal)
+	})
+	assert.NoError(t, err)
+	obj, err := w.source.ListFunc(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(obj.(*unstructured.UnstructuredList).Items))
+	assert.Equal(t, "GET /apis/operatorkit.io/v1/namespaces/ns/signals?labelSelector=operatorkit.io%2Ftopic%3Dmaintenance", requests[len(requests)-1])
+	w.resourceEventHandlers.AddFunc(&obj.(*unstructured.UnstructuredList).Items[1])
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, "recent", received[0].Name)
+
+	requests = nil
+	deleted, err := PruneSignals(context, "ns", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, "DELETE /apis/operatorkit.io/v1/namespaces/ns/signals/old?", requests[1])
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	snapshotObjectsDir = "objects"
+	snapshotStateFile  = "state.json"
+)
+
+// SnapshotOptions selects the objects of an operator in a snapshot
+type SnapshotOptions struct {
+	// Resources are the custom resources of the operator. All their instances are kept.
+	Resources []CustomResource
+
+	// Children are the resources of the children. Only the objects owned by an object kept before them are kept,
+	// so list the children after the resources of their owners.
+	Children []schema.GroupVersionResource
+
+	// Namespace of the objects. Defaults to all namespaces.
+	Namespace string
+
+	// State is optional and its state is kept with the objects
+	State StateStore
+}
+
+// SnapshotObject is an object of a snapshot with the resource it is created with
+type SnapshotObject struct {
+	Resource schema.GroupVersionResource `json:"resource"`
+	Object   *unstructured.Unstructured  `json:"object"`
+}
+
+// Snapshot holds the objects and the state of an operator, for disaster recovery drills that restore the operator
+// into a fresh cluster
+type Snapshot struct {
+	Objects []SnapshotObject
+	State   map[string]string
+}
+
+// TakeSnapshot lists the custom resources and the children owned by them. The context must have a
+// DynamicClientPool.
+func TakeSnapshot(context Context, options SnapshotOptions) (*Snapshot, error) {
+	if context.DynamicClientPool == nil {
+		return nil, fmt.Errorf("the context has no dynamic client pool")
+	}
+	snapshot := &Snapshot{}
+	kept := map[types.UID]bool{}
+	add := func(gvr schema.GroupVersionResource, ownedOnly bool) error {
+		client, err := dynamicResourceClient(context.DynamicClientPool, gvr, options.Namespace)
+		if err != nil {
+			return err
+		}
+		obj, err := client.List(metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to list %s. %+v", gvr.String(), err)
+		}
+		list, ok := obj.(*unstructured.UnstructuredList)
+		if !ok {
+			return fmt.Errorf("unexpected list type %T of %s", obj, gvr.String())
+		}
+		for i := range list.Items {
+			item := &list.Items[i]
+			if ownedOnly && !ownedByAny(item, kept) {
+				continue
+			}
+			kept[item.GetUID()] = true
+			snapshot.Objects = append(snapshot.Objects, SnapshotObject{Resource: gvr, Object: item})
+		}
+		return nil
+	}
+
+	for _, resource := range options.Resources {
+		gvr := schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Plural}
+		if err := add(gvr, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, gvr := range options.Children {
+		if err := add(gvr, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.State != nil {
+		state, _, err := options.State.Get()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the operator state. %+v", err)
+		}
+		snapshot.State = state
+	}
+	return snapshot, nil
+}
+
+// RestoreSnapshot creates the objects of the snapshot in the cluster of the context and writes the state to the
+// store, which is optional. Owners are created before the objects they own and the owner references are remapped to
+// the UIDs of the new owners. References to owners missing from the snapshot are dropped, since the garbage
+// collector would otherwise delete the restored objects right away. The cluster must not have the objects yet.
+func RestoreSnapshot(context Context, snapshot *Snapshot, state StateStore) error {
+	if context.DynamicClientPool == nil {
+		return fmt.Errorf("the context has no dynamic client pool")
+	}
+	inSnapshot := map[types.UID]bool{}
+	for _, o := range snapshot.Objects {
+		inSnapshot[o.Object.GetUID()] = true
+	}
+
+	uids := map[types.UID]types.UID{}
+	pending := snapshot.Objects
+	for len(pending) > 0 {
+		var waiting []SnapshotObject
+		for _, o := range pending {
+			if !ownersRestored(o.Object, inSnapshot, uids) {
+				waiting = append(waiting, o)
+				continue
+			}
+			created, err := restoreObject(context.DynamicClientPool, o, inSnapshot, uids)
+			if err != nil {
+				return err
+			}
+			uids[o.Object.GetUID()] = created.GetUID()
+		}
+		if len(waiting) == len(pending) {
+			return fmt.Errorf("the owner references of %d objects form a cycle", len(waiting))
+		}
+		pending = waiting
+	}
+
+	if state != nil && len(snapshot.State) > 0 {
+		if err := UpdateState(state, func(data map[string]string) error {
+			for key, value := range snapshot.State {
+				data[key] = value
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to restore the operator state. %+v", err)
+		}
+	}
+	return nil
+}
+
+// restoreObject creates a copy of the object without its server-set metadata and with the remapped owners
+func restoreObject(pool dynamic.ClientPool, o SnapshotObject, inSnapshot map[types.UID]bool, uids map[types.UID]types.UID) (*unstructured.Unstructured, error) {
+	obj := o.Object.DeepCopy()
+	for _, field := range []string{"uid", "resourceVersion", "selfLink", "creationTimestamp", "generation", "deletionTimestamp", "managedFields"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	var refs []metav1.OwnerReference
+	for _, ref := range obj.GetOwnerReferences() {
+		if inSnapshot[ref.UID] {
+			ref.UID = uids[ref.UID]
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+
+	client, err := dynamicResourceClient(pool, o.Resource, obj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	created, err := client.Create(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore %s %s/%s. %+v", o.Resource.Resource, obj.GetNamespace(), obj.GetName(), err)
+	}
+	return created, nil
+}
+
+// dynamicResourceClient returns the dynamic client of the resource in the namespace, or of all namespaces if it is empty
+func dynamicResourceClient(pool dynamic.ClientPool, gvr schema.GroupVersionResource, namespace string) (dynamic.ResourceInterface, error) {
+	client, err := pool.ClientForGroupVersionResource(gvr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dynamic client for %s. %+v", gvr.String(), err)
+	}
+	return client.Resource(&metav1.APIResource{Name: gvr.Resource, Namespaced: namespace != ""}, namespace), nil
+}
+
+func ownedByAny(obj *unstructured.Unstructured, owners map[types.UID]bool) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if owners[ref.UID] {
+			return true
+		}
+	}
+	return false
+}
+
+// ownersRestored returns whether all owners of the object in the snapshot were restored
+func ownersRestored(obj *unstructured.Unstructured, inSnapshot map[types.UID]bool, uids map[types.UID]types.UID) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if _, restored := uids[ref.UID]; inSnapshot[ref.UID] && !restored {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteSnapshot writes the snapshot to out as a gzipped tar archive with a file per object and a file of the state
+func WriteSnapshot(snapshot *Snapshot, out io.Writer) error {
+	gz := gzip.NewWriter(out)
+	archive := tar.NewWriter(gz)
+	write := func(name string, value interface{}) error {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize %s. %+v", name, err)
+		}
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}); err != nil {
+			return err
+		}
+		_, err = archive.Write(data)
+		return err
+	}
+
+	for i, o := range snapshot.Objects {
+		if err := write(path.Join(snapshotObjectsDir, fmt.Sprintf("%05d.json", i)), o); err != nil {
+			return err
+		}
+	}
+	if err := write(snapshotStateFile, snapshot.State); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot
+func ReadSnapshot(in io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the snapshot archive. %+v", err)
+	}
+	archive := tar.NewReader(gz)
+	snapshot := &Snapshot{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return snapshot, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the snapshot archive. %+v", err)
+		}
+		data, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s. %+v", header.Name, err)
+		}
+
+		switch {
+		case header.Name == snapshotStateFile:
+			err = json.Unmarshal(data, &snapshot.State)
+		case strings.HasPrefix(header.Name, snapshotObjectsDir+"/"):
+			o := SnapshotObject{}
+			err = json.Unmarshal(data, &o)
+			snapshot.Objects = append(snapshot.Objects, o)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s. %+v", header.Name, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// newSnapshotPool serves the lists of the paths and records the objects created
+func newSnapshotPool(lists map[string]string, created *[]*unstructured.Unstructured) dynamic.ClientPool {
+	return dynamic.NewDynamicClientPool(&rest.Config{
+		Host: "http://snapshot",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			if req.Method == http.MethodPost {
+				body, _ := ioutil.ReadAll(req.Body)
+				obj := &unstructured.Unstructured{}
+				if err := obj.UnmarshalJSON(body); err != nil {
+					return nil, err
+				}
+				*created = append(*created, obj)
+				result := obj.DeepCopy()
+				result.SetUID(types.UID("new-" + obj.GetKind() + "-" + obj.GetName()))
+				data, _ := result.MarshalJSON()
+				recorder.WriteHeader(http.StatusCreated)
+				recorder.Write(data)
+				return recorder.Result(), nil
+			}
+			recorder.WriteString(lists[req.URL.Path])
+			return recorder.Result(), nil
+		}),
+	})
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	lists := map[string]string{
+		"/apis/example.com/v1alpha/namespaces/ns/examples": `{"apiVersion":"example.com/v1alpha","kind":"ExampleList","metadata":{},"items":[
+			{"apiVersion":"example.com/v1alpha","kind":"Example","metadata":{"name":"a","namespace":"ns","uid":"uid-a","resourceVersion":"5"},"spec":{"size":3}}]}`,
+		"/apis/apps/v1beta2/namespaces/ns/statefulsets": `{"apiVersion":"apps/v1beta2","kind":"StatefulSetList","metadata":{},"items":[
+			{"apiVersion":"apps/v1beta2","kind":"StatefulSet","metadata":{"name":"a","namespace":"ns","uid":"uid-sts","ownerReferences":[
+				{"apiVersion":"example.com/v1alpha","kind":"Example","name":"a","uid":"uid-a"}]}},
+			{"apiVersion":"apps/v1beta2","kind":"StatefulSet","metadata":{"name":"other","namespace":"ns","uid":"uid-other"}}]}`,
+		"/api/v1/namespaces/ns/configmaps": `{"apiVersion":"v1","kind":"ConfigMapList","metadata":{},"items":[
+			{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a-config","namespace":"ns","uid":"uid-cm","ownerReferences":[
+				{"apiVersion":"apps/v1beta2","kind":"StatefulSet","name":"a","uid":"uid-sts"},
+				{"apiVersion":"v1","kind":"Node","name":"node","uid":"uid-node"}]}}]}`,
+	}
+	state := NewMemoryStateStore()
+	_, err := state.Update(map[string]string{"allocated": "a"}, "")
+	assert.NoError(t, err)
+
+	var created []*unstructured.Unstructured
+	context := Context{DynamicClientPool: newSnapshotPool(lists, &created)}
+	_, err = TakeSnapshot(Context{}, SnapshotOptions{})
+	assert.Error(t, err)
+
+	// the children are listed before their owners in the snapshot to check the restore order
+	snapshot, err := TakeSnapshot(context, SnapshotOptions{
+		Resources: []CustomResource{exampleResource},
+		Children: []schema.GroupVersionResource{
+			{Group: "apps", Version: "v1beta2", Resource: "statefulsets"},
+			{Version: "v1", Resource: "configmaps"},
+		},
+		Namespace: "ns",
+		State:     state,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(snapshot.Objects))
+	snapshot.Objects[0], snapshot.Objects[2] = snapshot.Objects[2], snapshot.Objects[0]
+
+	var archive bytes.Buffer
+	assert.NoError(t, WriteSnapshot(snapshot, &archive))
+	restored, err := ReadSnapshot(&archive)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, restored)
+
+	fresh := NewMemoryStateStore()
+	assert.NoError(t, RestoreSnapshot(context, restored, fresh))
+	assert.Equal(t, 3, len(created))
+	names := []string{}
+	for _, obj := range created {
+		names = append(names, obj.GetKind()+"/"+obj.GetName())
+		assert.Equal(t, "", string(obj.GetUID()))
+		assert.Equal(t, "", obj.GetResourceVersion())
+	}
+	assert.Equal(t, []string{"Example/a", "StatefulSet/a", "ConfigMap/a-config"}, names)
+
+	// owners are remapped to the new UIDs and references outside of the snapshot are dropped
+	refs := created[2].GetOwnerReferences()
+	assert.Equal(t, 1, len(refs))
+	assert.Equal(t, "new-StatefulSet-a", string(refs[0].UID))
+	assert.Equal(t, "StatefulSet", refs[0].Kind)
+
+	data, _, err := fresh.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"allocated": "a"}, data)
+}
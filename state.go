@@ -0,0 +1,369 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/api/core/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+// ErrStateConflict is returned by a state store when the state changed since the version passed to Update was read
+var ErrStateConflict = errors.New("the state was modified concurrently")
+
+// leaseAnnotationPrefix prefixes the keys of the state stored in the annotations of a Lease
+const leaseAnnotationPrefix = "state.operatorkit.io/"
+
+// StateResource is the custom resource storing state for NewCustomResourceStateStore. Create it with
+// CreateCustomResources before using the store.
+var StateResource = CustomResource{
+	Name:    "operatorstate",
+	Plural:  "operatorstates",
+	Group:   "operatorkit.io",
+	Version: "v1",
+	Scope:   apiextensionsv1beta1.NamespaceScoped,
+	Kind:    "OperatorState",
+}
+
+// StateStore persists internal state of the operator, such as allocation maps, that does not belong in the status
+// of a custom resource. Writes use optimistic concurrency so that replicas of the operator cannot overwrite each
+// other's changes.
+type StateStore interface {
+	// Get returns the state and its version. A state that was never written is empty with the version "".
+	Get() (map[string]string, string, error)
+
+	// Update replaces the state if it is still at the version, and returns the new version. It returns
+	// ErrStateConflict when the state was changed since.
+	Update(data map[string]string, version string) (string, error)
+}
+
+// UpdateState reads the state, applies the mutate func, and writes it back, starting over when the state was
+// modified concurrently
+func UpdateState(store StateStore, mutate func(data map[string]string) error) error {
+	return retryStateConflicts(func() error {
+		data, version, err := store.Get()
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			data = map[string]string{}
+		}
+		if err := mutate(data); err != nil {
+			return err
+		}
+		_, err = store.Update(data, version)
+		return err
+	})
+}
+
+func retryStateConflicts(f func() error) error {
+	var lastErr error
+	err := wait.ExponentialBackoff(retry.DefaultRetry, func() (bool, error) {
+		lastErr = f()
+		if lastErr == ErrStateConflict {
+			return false, nil
+		}
+		return true, lastErr
+	})
+	if err == wait.ErrWaitTimeout {
+		return lastErr
+	}
+	return err
+}
+
+// memoryStateStore keeps the state in memory, for tests
+type memoryStateStore struct {
+	lock    sync.Mutex
+	data    map[string]string
+	version int
+}
+
+// NewMemoryStateStore creates a state store keeping the state in memory, for unit tests
+func NewMemoryStateStore() StateStore {
+	return &memoryStateStore{}
+}
+
+func (s *memoryStateStore) Get() (map[string]string, string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.version == 0 {
+		return map[string]string{}, "", nil
+	}
+	return copyState(s.data), strconv.Itoa(s.version), nil
+}
+
+func (s *memoryStateStore) Update(data map[string]string, version string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	current := ""
+	if s.version > 0 {
+		current = strconv.Itoa(s.version)
+	}
+	if version != current {
+		return "", ErrStateConflict
+	}
+	s.data = copyState(data)
+	s.version++
+	return strconv.Itoa(s.version), nil
+}
+
+// configMapStateStore keeps the state in the data of a ConfigMap
+type configMapStateStore struct {
+	context   Context
+	namespace string
+	name      string
+}
+
+// NewConfigMapStateStore creates a state store keeping the state in the data of the named ConfigMap
+func NewConfigMapStateStore(context Context, namespace, name string) StateStore {
+	return &configMapStateStore{context: context, namespace: namespace, name: name}
+}
+
+func (s *configMapStateStore) Get() (map[string]string, string, error) {
+	cm, err := s.context.Clientset.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return map[string]string{}, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to get state configmap %s. %+v", s.name, err)
+	}
+	return copyState(cm.Data), cm.ResourceVersion, nil
+}
+
+func (s *configMapStateStore) Update(data map[string]string, version string) (string, error) {
+	configMaps := s.context.Clientset.CoreV1().ConfigMaps(s.namespace)
+	var cm *v1.ConfigMap
+	var err error
+	if version == "" {
+		cm, err = configMaps.Create(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+			Data:       data,
+		})
+	} else {
+		// the whole configmap is replaced, so start from the latest one to keep the labels, annotations, and owners
+		if cm, err = configMaps.Get(s.name, metav1.GetOptions{}); err != nil {
+			if kerrors.IsNotFound(err) {
+				return "", ErrStateConflict
+			}
+			return "", fmt.Errorf("failed to get state configmap %s. %+v", s.name, err)
+		}
+		if cm.ResourceVersion != version {
+			return "", ErrStateConflict
+		}
+		cm.Data = data
+		cm, err = configMaps.Update(cm)
+	}
+	if kerrors.IsConflict(err) || kerrors.IsAlreadyExists(err) {
+		return "", ErrStateConflict
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write state configmap %s. %+v", s.name, err)
+	}
+	return cm.ResourceVersion, nil
+}
+
+// rawStateStore keeps the state in an object of an API the typed clientsets do not support, read and written as JSON
+type rawStateStore struct {
+	client    rest.Interface
+	path      string
+	name      string
+	newObject func() map[string]interface{}
+	readData  func(obj map[string]interface{}) map[string]string
+	writeData func(obj map[string]interface{}, data map[string]string)
+}
+
+// NewCustomResourceStateStore creates a state store keeping the state in the data of the named OperatorState
+// custom resource. The client must be configured for the group and version of the StateResource.
+func NewCustomResourceStateStore(client rest.Interface, namespace, name string) StateStore {
+	return &rawStateStore{
+		client: client,
+		path:   fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", StateResource.Group, StateResource.Version, namespace, StateResource.Plural),
+		name:   name,
+		newObject: func() map[string]interface{} {
+			return map[string]interface{}{
+				"apiVersion": StateResource.Group + "/" + StateResource.Version,
+				"kind":       StateResource.Kind,
+				"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			}
+		},
+		readData: func(obj map[string]interface{}) map[string]string {
+			return stringMap(obj["data"])
+		},
+		writeData: func(obj map[string]interface{}, data map[string]string) {
+			obj["data"] = data
+		},
+	}
+}
+
+// NewLeaseStateStore creates a state store keeping small state in the annotations of the named
+// coordination.k8s.io/v1 Lease, available on Kubernetes 1.14 and above. The clientsets of the context do not support
+// Leases, so the store requires the RESTConfig of the context.
+func NewLeaseStateStore(context Context, namespace, name string) (StateStore, error) {
+	client, err := newCoordinationClient(context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the client of the lease state store %s. %+v", name, err)
+	}
+	return &rawStateStore{
+		client: client,
+		path:   fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", namespace),
+		name:   name,
+		newObject: func() map[string]interface{} {
+			return map[string]interface{}{
+				"apiVersion": "coordination.k8s.io/v1",
+				"kind":       "Lease",
+				"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			}
+		},
+		readData: func(obj map[string]interface{}) map[string]string {
+			data := map[string]string{}
+			for key, value := range stringMap(metadataOf(obj)["annotations"]) {
+				if strings.HasPrefix(key, leaseAnnotationPrefix) {
+					data[strings.TrimPrefix(key, leaseAnnotationPrefix)] = value
+				}
+			}
+			return data
+		},
+		writeData: func(obj map[string]interface{}, data map[string]string) {
+			annotations := map[string]string{}
+			for key, value := range stringMap(metadataOf(obj)["annotations"]) {
+				if !strings.HasPrefix(key, leaseAnnotationPrefix) {
+					annotations[key] = value
+				}
+			}
+			for key, value := range data {
+				annotations[leaseAnnotationPrefix+key] = value
+			}
+			metadataOf(obj)["annotations"] = annotations
+		},
+	}, nil
+}
+
+func (s *rawStateStore) Get() (map[string]string, string, error) {
+	obj, err := s.get()
+	if err != nil {
+		return nil, "", err
+	}
+	if obj == nil {
+		return map[string]string{}, "", nil
+	}
+	version, _ := metadataOf(obj)["resourceVersion"].(string)
+	return s.readData(obj), version, nil
+}
+
+func (s *rawStateStore) Update(data map[string]string, version string) (string, error) {
+	var obj map[string]interface{}
+	if version == "" {
+		obj = s.newObject()
+	} else {
+		// the whole object is replaced, so start from the latest one to keep the fields the store does not own
+		var err error
+		if obj, err = s.get(); err != nil {
+			return "", err
+		}
+		if obj == nil {
+			return "", ErrStateConflict
+		}
+		metadataOf(obj)["resourceVersion"] = version
+	}
+	s.writeData(obj, data)
+
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize state %s. %+v", s.name, err)
+	}
+	var raw []byte
+	if version == "" {
+		raw, err = s.client.Post().AbsPath(s.path).SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	} else {
+		raw, err = s.client.Put().AbsPath(s.path, s.name).SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	}
+	if kerrors.IsConflict(err) || kerrors.IsAlreadyExists(err) {
+		return "", ErrStateConflict
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write state %s. %+v", s.name, err)
+	}
+
+	written := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &written); err != nil {
+		return "", fmt.Errorf("failed to parse state %s. %+v", s.name, err)
+	}
+	newVersion, _ := metadataOf(written)["resourceVersion"].(string)
+	return newVersion, nil
+}
+
+// get returns the object of the state, or nil if it does not exist
+func (s *rawStateStore) get() (map[string]interface{}, error) {
+	raw, err := s.client.Get().AbsPath(s.path, s.name).DoRaw()
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get state %s. %+v", s.name, err)
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s. %+v", s.name, err)
+	}
+	return obj, nil
+}
+
+func metadataOf(obj map[string]interface{}) map[string]interface{} {
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	return metadata
+}
+
+func stringMap(value interface{}) map[string]string {
+	result := map[string]string{}
+	switch m := value.(type) {
+	case map[string]interface{}:
+		for key, v := range m {
+			if s, ok := v.(string); ok {
+				result[key] = s
+			}
+		}
+	case map[string]string:
+		for key, v := range m {
+			result[key] = v
+		}
+	}
+	return result
+}
+
+func copyState(data map[string]string) map[string]string {
+	result := make(map[string]string, len(data))
+	for key, value := range data {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestMemoryStateStore(t *testing.T) {
+	store := NewMemoryStateStore()
+	data, version, err := store.Get()
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	assert.Equal(t, "", version)
+
+	version, err = store.Update(map[string]string{"a": "1"}, "")
+	assert.NoError(t, err)
+	_, err = store.Update(map[string]string{"a": "2"}, "")
+	assert.Equal(t, ErrStateConflict, err)
+
+	data, current, err := store.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, version, current)
+	assert.Equal(t, map[string]string{"a": "1"}, data)
+}
+
+func TestUpdateState(t *testing.T) {
+	store := NewMemoryStateStore()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := UpdateState(store, func(data map[string]string) error {
+				count, _ := strconv.Atoi(data["count"])
+				data["count"] = strconv.Itoa(count + 1)
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	data, _, err := store.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "3", data["count"])
+}
+
+func TestConfigMapStateStore(t *testing.T) {
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "Pod", Name: "operator", UID: "uid"}
+	clientset := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "state", Namespace: "ns", ResourceVersion: "1",
+			Labels: map[string]string{"app": "operator"}, OwnerReferences: []metav1.OwnerReference{owner}},
+		Data: map[string]string{"a": "1"},
+	})
+	store := NewConfigMapStateStore(Context{Clientset: clientset}, "ns", "state")
+
+	data, version, err := store.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1"}, data)
+	assert.Equal(t, "1", version)
+
+	// the data is replaced, the rest of the configmap is kept
+	_, err = store.Update(map[string]string{"a": "2"}, version)
+	assert.NoError(t, err)
+	cm, err := clientset.CoreV1().ConfigMaps("ns").Get("state", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "2"}, cm.Data)
+	assert.Equal(t, map[string]string{"app": "operator"}, cm.Labels)
+	assert.Equal(t, []metav1.OwnerReference{owner}, cm.OwnerReferences)
+
+	_, err = store.Update(map[string]string{"a": "3"}, "0")
+	assert.Equal(t, ErrStateConflict, err)
+	_, err = store.Update(map[string]string{"a": "3"}, "")
+	assert.Equal(t, ErrStateConflict, err)
+
+	missing := NewConfigMapStateStore(Context{Clientset: clientset}, "ns", "missing")
+	data, version, err = missing.Get()
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	assert.Equal(t, "", version)
+	_, err = missing.Update(map[string]string{"a": "1"}, "1")
+	assert.Equal(t, ErrStateConflict, err)
+}
+
+// newLeaseServer serves the named Lease in the namespace "ns", enforcing the resourceVersion of updates
+func newLeaseServer(t *testing.T, name string) *httptest.Server {
+	var lease map[string]interface{}
+	version := 0
+	respond := func(w http.ResponseWriter, code int, obj interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(obj)
+	}
+	status := func(w http.ResponseWriter, code int, reason metav1.StatusReason) {
+		respond(w, code, metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status: metav1.StatusFailure, Code: int32(code), Reason: reason})
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const path = "/apis/coordination.k8s.io/v1/namespaces/ns/leases"
+		var body map[string]interface{}
+		if r.Method != http.MethodGet {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == path+"/"+name:
+			if lease == nil {
+				status(w, http.StatusNotFound, metav1.StatusReasonNotFound)
+				return
+			}
+			respond(w, http.StatusOK, lease)
+		case r.Method == http.MethodPost && r.URL.Path == path:
+			if lease != nil {
+				status(w, http.StatusConflict, metav1.StatusReasonAlreadyExists)
+				return
+			}
+			version++
+			metadataOf(body)["resourceVersion"] = strconv.Itoa(version)
+			lease = body
+			respond(w, http.StatusCreated, lease)
+		case r.Method == http.MethodPut && r.URL.Path == path+"/"+name:
+			if lease == nil || metadataOf(body)["resourceVersion"] != strconv.Itoa(version) {
+				status(w, http.StatusConflict, metav1.StatusReasonConflict)
+				return
+			}
+			version++
+			metadataOf(body)["resourceVersion"] = strconv.Itoa(version)
+			lease = body
+			respond(w, http.StatusOK, lease)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+}
+
+func TestLeaseStateStore(t *testing.T) {
+	_, err := NewLeaseStateStore(Context{Clientset: fake.NewSimpleClientset()}, "ns", "state")
+	assert.Error(t, err)
+
+	server := newLeaseServer(t, "state")
+	defer server.Close()
+	store, err := NewLeaseStateStore(Context{RESTConfig: &rest.Config{Host: server.URL}}, "ns", "state")
+	assert.NoError(t, err)
+
+	data, version, err := store.Get()
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	assert.Equal(t, "", version)
+
+	version, err = store.Update(map[string]string{"a": "1"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", version)
+	_, err = store.Update(map[string]string{"a": "1"}, "")
+	assert.Equal(t, ErrStateConflict, err)
+
+	version, err = store.Update(map[string]string{"b": "2"}, version)
+	assert.NoError(t, err)
+	_, err = store.Update(map[string]string{"c": "3"}, "1")
+	assert.Equal(t, ErrStateConflict, err)
+
+	data, current, err := store.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, version, current)
+	assert.Equal(t, map[string]string{"b": "2"}, data)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+// StatusMutateFunc sets the desired status on the latest version of a custom resource
+type StatusMutateFunc func(obj runtime.Object) error
+
+// StatusUpdateOptions configures how UpdateStatus writes the status of a custom resource
+type StatusUpdateOptions struct {
+	// UseSubresource sends the update to the /status subresource. The CRD must have the subresource enabled.
+	UseSubresource bool
+
+	// Backoff between retries after a conflict. Defaults to retry.DefaultRetry.
+	Backoff *wait.Backoff
+}
+
+// UpdateStatus fetches the latest version of the named custom resource into obj, applies the mutate func and
+// writes the result back. When the update fails with a 409 Conflict, the object is fetched again and the mutate
+// func is reapplied until the backoff is exhausted.
+func UpdateStatus(client rest.Interface, resource CustomResource, namespace, name string, obj runtime.Object,
+	mutate StatusMutateFunc, opts StatusUpdateOptions) error {
+
+	backoff := retry.DefaultRetry
+	if opts.Backoff != nil {
+		backoff = *opts.Backoff
+	}
+
+	return retry.RetryOnConflict(backoff, func() error {
+		err := client.Get().Namespace(namespace).Resource(resource.Plural).Name(name).Do().Into(obj)
+		if err != nil {
+			return err
+		}
+
+		if err := mutate(obj); err != nil {
+			return err
+		}
+
+		request := client.Put().Namespace(namespace).Resource(resource.Plural).Name(name)
+		if opts.UseSubresource {
+			request = request.SubResource("status")
+		}
+		return request.Body(obj).Do().Into(obj)
+	})
+}
+
+// PatchStatus sends the JSON patch (RFC 6902) to the named custom resource and decodes the result into obj. Unlike
+// UpdateStatus the request carries no resourceVersion, so it never fails with a conflict and needs no retries. This
+// suits hot paths such as counters, but concurrent writers to the same path overwrite each other: a replace computed
+// from a stale read loses increments made in between. Start the patch with a JSONPatchTest of the read value to make
+// the server reject the patch when the value changed. Set UseSubresource in the options when the CRD has the status
+// subresource enabled. The backoff of the options is not used.
+func PatchStatus(client rest.Interface, resource CustomResource, namespace, name string, patch []JSONPatchOperation,
+	obj runtime.Object, opts StatusUpdateOptions) error {
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the status patch. %+v", err)
+	}
+
+	request := client.Patch(types.JSONPatchType).Namespace(namespace).Resource(resource.Plural).Name(name)
+	if opts.UseSubresource {
+		request = request.SubResource("status")
+	}
+	return request.Body(data).Do().Into(obj)
+}
+
+// JSONPatchReplace returns the operation replacing the value at the path, such as "/status/processed"
+func JSONPatchReplace(path string, value interface{}) JSONPatchOperation {
+	return JSONPatchOperation{Op: "replace", Path: path, Value: value}
+}
+
+// JSONPatchAdd returns the operation setting the value at the path, whether or not the member exists yet
+func JSONPatchAdd(path string, value interface{}) JSONPatchOperation {
+	return JSONPatchOperation{Op: "add", Path: path, Value: value}
+}
+
+// JSONPatchAppend returns the operation appending the value to the array at the path, such as "/status/conditions"
+func JSONPatchAppend(path string, value interface{}) JSONPatchOperation {
+	return JSONPatchOperation{Op: "add", Path: path + "/-", Value: value}
+}
+
+// JSONPatchTest returns the operation failing the whole patch unless the value at the path equals the value
+func JSONPatchTest(path string, value interface{}) JSONPatchOperation {
+	return JSONPatchOperation{Op: "test", Path: path, Value: value}
+}
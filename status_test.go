@@ -0,0 +1,167 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+const conflictStatus = `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Conflict","code":409}`
+
+var configMapResource = CustomResource{Name: "configmap", Plural: "configmaps", Version: "v1"}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newConfigMapClient creates a client of the core v1 API that serves the requests with the handler
+func newConfigMapClient(t *testing.T, handler func(recorder *httptest.ResponseRecorder, req *http.Request)) *rest.RESTClient {
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host: "http://configmaps",
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			recorder := httptest.NewRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			handler(recorder, req)
+			return recorder.Result(), nil
+		}),
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &schema.GroupVersion{Version: "v1"},
+			NegotiatedSerializer: scheme.Codecs,
+		},
+		APIPath: "/api",
+	})
+	assert.NoError(t, err)
+	return client
+}
+
+func TestUpdateStatusRetryOnConflict(t *testing.T) {
+	resourceVersion := 1
+	conflicts := 1
+	var puts []string
+	client := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			assert.Equal(t, "/api/v1/namespaces/ns/configmaps/a", req.URL.Path)
+			fmt.Fprintf(recorder, `{"metadata":{"name":"a","namespace":"ns","resourceVersion":"%d"},"data":{"count":"%d"}}`,
+				resourceVersion, resourceVersion)
+		case http.MethodPut:
+			body, _ := ioutil.ReadAll(req.Body)
+			puts = append(puts, req.URL.Path)
+			if conflicts > 0 {
+				// another writer updated the resource since it was read
+				conflicts--
+				resourceVersion++
+				recorder.WriteHeader(http.StatusConflict)
+				recorder.WriteString(conflictStatus)
+				return
+			}
+			recorder.Write(body)
+		default:
+			t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+		}
+	})
+	backoff := &wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+
+	// the mutate func is reapplied to the latest version after the conflict
+	var seen []string
+	cm := &v1.ConfigMap{}
+	err := UpdateStatus(client, configMapResource, "ns", "a", cm, func(obj runtime.Object) error {
+		configMap := obj.(*v1.ConfigMap)
+		seen = append(seen, configMap.ResourceVersion)
+		configMap.Data["state"] = "ready"
+		return nil
+	}, StatusUpdateOptions{UseSubresource: true, Backoff: backoff})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, seen)
+	assert.Equal(t, []string{"/api/v1/namespaces/ns/configmaps/a/status", "/api/v1/namespaces/ns/configmaps/a/status"}, puts)
+	assert.Equal(t, "2", cm.ResourceVersion)
+	assert.Equal(t, map[string]string{"count": "2", "state": "ready"}, cm.Data)
+
+	// the conflict is returned once the backoff is exhausted
+	conflicts = 5
+	puts = nil
+	err = UpdateStatus(client, configMapResource, "ns", "a", &v1.ConfigMap{}, func(obj runtime.Object) error {
+		return nil
+	}, StatusUpdateOptions{Backoff: backoff})
+	assert.True(t, errors.IsConflict(err), fmt.Sprintf("%+v", err))
+	assert.Equal(t, []string{"/api/v1/namespaces/ns/configmaps/a", "/api/v1/namespaces/ns/configmaps/a",
+		"/api/v1/namespaces/ns/configmaps/a"}, puts)
+
+	// errors of the mutate func are not retried
+	puts = nil
+	err = UpdateStatus(client, configMapResource, "ns", "a", &v1.ConfigMap{}, func(obj runtime.Object) error {
+		return fmt.Errorf("invalid status")
+	}, StatusUpdateOptions{Backoff: backoff})
+	assert.EqualError(t, err, "invalid status")
+	assert.Empty(t, puts)
+}
+
+func TestPatchStatus(t *testing.T) {
+	var patch *http.Request
+	var body string
+	client := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+		if req.Method != http.MethodPatch {
+			t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+			return
+		}
+		patch = req
+		data, _ := ioutil.ReadAll(req.Body)
+		body = string(data)
+		recorder.WriteString(`{"metadata":{"name":"a","namespace":"ns","resourceVersion":"3"},"data":{"processed":"4"}}`)
+	})
+
+	cm := &v1.ConfigMap{}
+	err := PatchStatus(client, configMapResource, "ns", "a", []JSONPatchOperation{
+		JSONPatchTest("/data/processed", "3"),
+		JSONPatchReplace("/data/processed", "4"),
+		JSONPatchAppend("/data/list", "x"),
+	}, cm, StatusUpdateOptions{UseSubresource: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/v1/namespaces/ns/configmaps/a/status", patch.URL.Path)
+	assert.Equal(t, "application/json-patch+json", patch.Header.Get("Content-Type"))
+	// the patch carries no resourceVersion, so it cannot fail with a conflict
+	assert.JSONEq(t, `[{"op":"test","path":"/data/processed","value":"3"},{"op":"replace","path":"/data/processed","value":"4"},`+
+		`{"op":"add","path":"/data/list/-","value":"x"}]`, body)
+	assert.Equal(t, "3", cm.ResourceVersion)
+	assert.Equal(t, "4", cm.Data["processed"])
+
+	// the server rejects the whole patch when a test operation fails
+	rejecting := newConfigMapClient(t, func(recorder *httptest.ResponseRecorder, req *http.Request) {
+		patch = req
+		recorder.WriteHeader(http.StatusUnprocessableEntity)
+		recorder.WriteString(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Invalid","code":422}`)
+	})
+	err = PatchStatus(rejecting, configMapResource, "ns", "a", []JSONPatchOperation{JSONPatchTest("/data/processed", "2")},
+		&v1.ConfigMap{}, StatusUpdateOptions{})
+	assert.True(t, errors.IsInvalid(err), fmt.Sprintf("%+v", err))
+	assert.Equal(t, "/api/v1/namespaces/ns/configmaps/a", patch.URL.Path)
+}
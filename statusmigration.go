@@ -0,0 +1,157 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeprecatedStatusField is a status field replaced by a field of a new status schema. Paths are JSON pointers
+// (RFC 6901) to members of objects, not to array items.
+type DeprecatedStatusField struct {
+	// Path of the deprecated field, such as "/status/state"
+	Path string
+
+	// Replacement is the path of the new field, such as "/status/phase"
+	Replacement string
+
+	// RemovedIn is the release that stops writing the deprecated field, reported to the clients still writing it
+	RemovedIn string
+}
+
+// StatusDualWrite writes the deprecated status fields along with their replacements while the consumers of the
+// status migrate to the new schema, usually for a few releases. Clients still writing the deprecated fields are
+// found by serving Mutate as a mutating webhook of the resource, which counts their writes in the metrics.
+type StatusDualWrite struct {
+	context  Context
+	resource string
+	fields   []DeprecatedStatusField
+}
+
+// NewStatusDualWrite creates the dual write of the deprecated status fields of the custom resource
+func NewStatusDualWrite(context Context, resource CustomResource, fields ...DeprecatedStatusField) *StatusDualWrite {
+	return &StatusDualWrite{context: context, resource: resource.Name, fields: fields}
+}
+
+// Patch returns the status patch, such as for PatchStatus, with each operation on a replacement or a field under it
+// repeated on the deprecated field. Test operations are not repeated.
+func (d *StatusDualWrite) Patch(patch []JSONPatchOperation) []JSONPatchOperation {
+	result := append([]JSONPatchOperation{}, patch...)
+	for _, op := range patch {
+		if op.Op == "test" {
+			continue
+		}
+		for _, field := range d.fields {
+			if op.Path == field.Replacement || strings.HasPrefix(op.Path, field.Replacement+"/") {
+				deprecated := op
+				deprecated.Path = field.Path + strings.TrimPrefix(op.Path, field.Replacement)
+				result = append(result, deprecated)
+			}
+		}
+	}
+	return result
+}
+
+// Apply copies the replacements of the object to its deprecated fields, or clears the deprecated fields of unset
+// replacements, for the mutate func of UpdateStatus. The type of the object must still have the deprecated fields.
+func (d *StatusDualWrite) Apply(obj runtime.Object) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to serialize the object. %+v", err)
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse the object. %+v", err)
+	}
+
+	for _, field := range d.fields {
+		value, found, err := unstructured.NestedFieldNoCopy(doc, jsonPointerFields(field.Replacement)...)
+		if err != nil {
+			return fmt.Errorf("failed to read status field %s. %+v", field.Replacement, err)
+		}
+		if !found {
+			unstructured.RemoveNestedField(doc, jsonPointerFields(field.Path)...)
+			continue
+		}
+		if err := unstructured.SetNestedField(doc, value, jsonPointerFields(field.Path)...); err != nil {
+			return fmt.Errorf("failed to write status field %s. %+v", field.Path, err)
+		}
+	}
+
+	if data, err = json.Marshal(doc); err != nil {
+		return fmt.Errorf("failed to serialize the object. %+v", err)
+	}
+	// the object is reset first since decoding leaves the fields missing from the data untouched
+	value := reflect.ValueOf(obj).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	return json.Unmarshal(data, obj)
+}
+
+// Mutate is the MutateFunc of a mutating webhook of the resource. When a client other than the operator changes a
+// deprecated field without its replacement, the write is counted as a usage of the deprecated field and the
+// replacement is patched to the same value, so the operator only ever reads the replacements.
+func (d *StatusDualWrite) Mutate(request *AdmissionRequest) ([]JSONPatchOperation, error) {
+	if len(request.Object) == 0 {
+		return nil, nil
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(request.Object, &obj); err != nil {
+		return nil, fmt.Errorf("failed to parse the object. %+v", err)
+	}
+	old := map[string]interface{}{}
+	if len(request.OldObject) > 0 {
+		if err := json.Unmarshal(request.OldObject, &old); err != nil {
+			return nil, fmt.Errorf("failed to parse the old object. %+v", err)
+		}
+	}
+
+	var patch []JSONPatchOperation
+	for _, field := range d.fields {
+		value, found, _ := unstructured.NestedFieldNoCopy(obj, jsonPointerFields(field.Path)...)
+		oldValue, oldFound, _ := unstructured.NestedFieldNoCopy(old, jsonPointerFields(field.Path)...)
+		if !found || (oldFound && reflect.DeepEqual(value, oldValue)) {
+			continue
+		}
+		replacement, _, _ := unstructured.NestedFieldNoCopy(obj, jsonPointerFields(field.Replacement)...)
+		if reflect.DeepEqual(value, replacement) {
+			continue
+		}
+
+		d.context.Metrics.observeDeprecatedStatusWrite(d.resource, field.Path, request.UserInfo)
+		d.context.logger().Info("deprecated status field written", "resource", d.resource, "namespace", request.Namespace,
+			"name", request.Name, "field", field.Path, "replacement", field.Replacement, "removedIn", field.RemovedIn,
+			"user", request.UserInfo.Username)
+		patch = append(patch, JSONPatchAdd(field.Replacement, value))
+	}
+	return patch, nil
+}
+
+// jsonPointerFields returns the unescaped member names of a JSON pointer
+func jsonPointerFields(path string) []string {
+	fields := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, field := range fields {
+		fields[i] = strings.Replace(strings.Replace(field, "~1", "/", -1), "~0", "~", -1)
+	}
+	return fields
+}
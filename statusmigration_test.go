@@ -0,0 +1,116 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+)
+
+func newTestStatusDualWrite(metrics *Metrics) *StatusDualWrite {
+	return NewStatusDualWrite(Context{Metrics: metrics}, exampleResource,
+		DeprecatedStatusField{Path: "/status/state", Replacement: "/status/phase", RemovedIn: "v2.0"},
+		DeprecatedStatusField{Path: "/data/old", Replacement: "/data/new"})
+}
+
+func TestStatusDualWritePatch(t *testing.T) {
+	d := newTestStatusDualWrite(nil)
+	patch := d.Patch([]JSONPatchOperation{
+		JSONPatchTest("/status/phase", "Pending"),
+		JSONPatchReplace("/status/phase", "Running"),
+		JSONPatchAdd("/status/phaseReason", "started"),
+		JSONPatchAdd("/status/healthScore", 100),
+	})
+	assert.Equal(t, []JSONPatchOperation{
+		JSONPatchTest("/status/phase", "Pending"),
+		JSONPatchReplace("/status/phase", "Running"),
+		JSONPatchAdd("/status/phaseReason", "started"),
+		JSONPatchAdd("/status/healthScore", 100),
+		JSONPatchReplace("/status/state", "Running"),
+	}, patch)
+}
+
+func TestStatusDualWriteApply(t *testing.T) {
+	d := newTestStatusDualWrite(nil)
+	cm := &v1.ConfigMap{Data: map[string]string{"new": "value"}}
+	cm.Name = "a"
+	assert.NoError(t, d.Apply(cm))
+	assert.Equal(t, "a", cm.Name)
+	assert.Equal(t, map[string]string{"new": "value", "old": "value"}, cm.Data)
+
+	// the deprecated field is cleared with its replacement
+	cm.Data = map[string]string{"old": "value", "other": "kept"}
+	assert.NoError(t, d.Apply(cm))
+	assert.Equal(t, map[string]string{"other": "kept"}, cm.Data)
+}
+
+func TestStatusDualWriteMutate(t *testing.T) {
+	m := NewMetrics("test")
+	d := newTestStatusDualWrite(m)
+	user := AdmissionUserInfo{Username: "system:serviceaccount:monitoring:dashboard"}
+
+	// the operator writes both fields
+	patch, err := d.Mutate(&AdmissionRequest{
+		Operation: "UPDATE",
+		UserInfo:  AdmissionUserInfo{Username: "system:serviceaccount:default:operator"},
+		Object:    []byte(`{"status":{"state":"Running","phase":"Running"}}`),
+		OldObject: []byte(`{"status":{"state":"Pending","phase":"Pending"}}`),
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, patch)
+
+	// unchanged deprecated fields are not writes
+	patch, err = d.Mutate(&AdmissionRequest{
+		Operation: "UPDATE",
+		UserInfo:  user,
+		Object:    []byte(`{"status":{"state":"Pending","phase":"Running"}}`),
+		OldObject: []byte(`{"status":{"state":"Pending","phase":"Pending"}}`),
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, patch)
+
+	// another client writes the deprecated field only
+	patch, err = d.Mutate(&AdmissionRequest{
+		Operation: "UPDATE",
+		UserInfo:  user,
+		Object:    []byte(`{"status":{"state":"Failed","phase":"Running"}}`),
+		OldObject: []byte(`{"status":{"state":"Running","phase":"Running"}}`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{JSONPatchAdd("/status/phase", "Failed")}, patch)
+
+	families, err := m.Registry().Gather()
+	assert.NoError(t, err)
+	writes := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "test_deprecated_status_field_writes_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			writes[labels["field"]+"/"+labels["user"]] = metric.GetCounter().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{"/status/state/system:serviceaccount:monitoring:dashboard": 1}, writes)
+
+	_, err = d.Mutate(&AdmissionRequest{Operation: "CREATE", Object: []byte(`not json`)})
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ghodss/yaml"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TemplateData is passed to the templates of a TemplateRenderer
+type TemplateData struct {
+	// Name and Namespace of the custom resource
+	Name      string
+	Namespace string
+
+	// Object is the custom resource as the map of its JSON, so fields are read like {{ .Object.spec.replicas }}
+	Object map[string]interface{}
+
+	// Spec is the spec of the custom resource, for short
+	Spec map[string]interface{}
+
+	// Params are the parameters of the renderer, such as the images of the operands
+	Params map[string]interface{}
+}
+
+// TemplateRenderer renders the children of a custom resource from Go templates of YAML manifests, so that operators
+// declare their children instead of building every object in Go. Each template may render several objects separated
+// by "---". The rendered children get the namespace of a namespaced custom resource unless they set one, and the
+// custom resource as their controller owner. Owner references across namespaces, or from a namespaced owner to a
+// cluster scoped child, are invalid and make the garbage collector delete the child, so such children are rendered
+// without an owner and must be deleted by the reconciler, usually with a finalizer.
+type TemplateRenderer struct {
+	resource  CustomResource
+	templates *template.Template
+	params    map[string]interface{}
+}
+
+// clusterScopedKinds are the built-in cluster scoped kinds, which never get a namespace or a namespaced owner
+var clusterScopedKinds = map[schema.GroupKind]bool{
+	{Kind: "Namespace"}:        true,
+	{Kind: "Node"}:             true,
+	{Kind: "PersistentVolume"}: true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                       true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                true,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                 true,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                             true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:               true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:   true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}: true,
+}
+
+// TemplateFuncs are the functions available in the templates besides the built-in functions of text/template
+var TemplateFuncs = template.FuncMap{
+	"default": func(def, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return def
+		}
+		return value
+	},
+	"quote": func(value interface{}) string {
+		return fmt.Sprintf("%q", fmt.Sprint(value))
+	},
+	"toYaml": func(value interface{}) (string, error) {
+		data, err := yaml.Marshal(value)
+		return strings.TrimSuffix(string(data), "\n"), err
+	},
+	"indent": func(spaces int, text string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.Replace(text, "\n", "\n"+pad, -1)
+	},
+}
+
+// NewTemplateRenderer parses the templates by name for the children of the resource. The templates are executed in
+// the order of their names. Missing keys in the data fail the rendering, so typos are caught in tests.
+func NewTemplateRenderer(resource CustomResource, templates map[string]string, params map[string]interface{}) (*TemplateRenderer, error) {
+	root := template.New(resource.Name).Funcs(TemplateFuncs).Option("missingkey=error")
+	for name, text := range templates {
+		if _, err := root.New(name).Parse(text); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s. %+v", name, err)
+		}
+	}
+	return &TemplateRenderer{resource: resource, templates: root, params: params}, nil
+}
+
+// LoadTemplateRenderer parses the .yaml, .yml, and .tmpl files of the directory as the templates of the children
+// of the resource
+func LoadTemplateRenderer(resource CustomResource, dir string, params map[string]interface{}) (*TemplateRenderer, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates in %s. %+v", dir, err)
+	}
+	templates := map[string]string{}
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".tmpl":
+			if entry.IsDir() {
+				continue
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template %s. %+v", entry.Name(), err)
+			}
+			templates[entry.Name()] = string(data)
+		}
+	}
+	return NewTemplateRenderer(resource, templates, params)
+}
+
+// Render executes the templates for the custom resource and returns the children as *unstructured.Unstructured.
+// It is a RenderFunc, so it can be registered with AddRenderer or used for drift detection.
+func (r *TemplateRenderer) Render(obj runtime.Object) ([]runtime.Object, error) {
+	owner, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access metadata of %s. %+v", r.resource.Name, err)
+	}
+	object, err := jsonMap(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize %s %s. %+v", r.resource.Name, owner.GetName(), err)
+	}
+	spec, _ := object["spec"].(map[string]interface{})
+	data := TemplateData{Name: owner.GetName(), Namespace: owner.GetNamespace(), Object: object, Spec: spec, Params: r.params}
+
+	var names []string
+	for _, t := range r.templates.Templates() {
+		if t.Name() != r.resource.Name {
+			names = append(names, t.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var children []runtime.Object
+	for _, name := range names {
+		var out bytes.Buffer
+		if err := r.templates.ExecuteTemplate(&out, name, data); err != nil {
+			return nil, fmt.Errorf("failed to render template %s for %s %s. %+v", name, r.resource.Name, owner.GetName(), err)
+		}
+		err := decodeManifests(&out, func(doc map[string]interface{}) error {
+			child := &unstructured.Unstructured{Object: doc}
+			if child.GetKind() == "" || child.GetName() == "" {
+				return fmt.Errorf("template %s rendered an object without a kind or name", name)
+			}
+			clusterScoped := clusterScopedKinds[child.GroupVersionKind().GroupKind()]
+			if child.GetNamespace() == "" && r.resource.Scope != apiextensionsv1beta1.ClusterScoped && !clusterScoped {
+				child.SetNamespace(owner.GetNamespace())
+			}
+			if owner.GetNamespace() == "" || (!clusterScoped && child.GetNamespace() == owner.GetNamespace()) {
+				child.SetOwnerReferences(append(child.GetOwnerReferences(), *metav1.NewControllerRef(owner, r.resource.GroupVersionKind())))
+			}
+			children = append(children, child)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode template %s for %s %s. %+v", name, r.resource.Name, owner.GetName(), err)
+		}
+	}
+	return children, nil
+}
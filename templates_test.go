@@ -0,0 +1,105 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTemplateRenderer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a-configmap.yaml"), []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}-config
+data:
+  replicas: {{ .Spec.replicas | quote }}
+  labels: |
+{{ toYaml .Object.metadata.labels | indent 4 }}
+`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b-services.yaml"), []byte(`
+{{- range $i, $port := .Spec.ports }}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ $.Name }}-{{ $i }}
+  namespace: other
+spec:
+  ports:
+  - port: {{ $port }}
+  selector:
+    image: {{ $.Params.image }}
+{{- end }}
+`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c-clusterrole.yaml"), []byte(`
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: {{ .Namespace }}-{{ .Name }}
+`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("{{ .Missing }}"), 0644))
+
+	renderer, err := LoadTemplateRenderer(exampleResource, dir, map[string]interface{}{"image": "db"})
+	assert.NoError(t, err)
+	owner := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1alpha",
+		"kind":       "Example",
+		"metadata":   map[string]interface{}{"namespace": "ns", "name": "db", "uid": "uid", "labels": map[string]interface{}{"app": "db"}},
+		"spec":       map[string]interface{}{"replicas": 3, "ports": []interface{}{80, 443}},
+	}}
+	children, err := renderer.Render(owner)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(children))
+
+	config := children[0].(*unstructured.Unstructured)
+	assert.Equal(t, "db-config", config.GetName())
+	assert.Equal(t, "ns", config.GetNamespace())
+	assert.Equal(t, map[string]interface{}{"replicas": "3", "labels": "app: db\n"}, config.Object["data"])
+	assert.Equal(t, "db", config.GetOwnerReferences()[0].Name)
+	assert.True(t, *config.GetOwnerReferences()[0].Controller)
+
+	service := children[1].(*unstructured.Unstructured)
+	assert.Equal(t, "db-0", service.GetName())
+	assert.Equal(t, "other", service.GetNamespace())
+	assert.Equal(t, "db", service.Object["spec"].(map[string]interface{})["selector"].(map[string]interface{})["image"])
+	assert.Equal(t, "db-1", children[2].(*unstructured.Unstructured).GetName())
+
+	// children in another namespace or cluster scoped children cannot be owned by a namespaced resource
+	assert.Empty(t, service.GetOwnerReferences())
+	role := children[3].(*unstructured.Unstructured)
+	assert.Equal(t, "ns-db", role.GetName())
+	assert.Equal(t, "", role.GetNamespace())
+	assert.Empty(t, role.GetOwnerReferences())
+
+	// missing keys fail the rendering
+	renderer, err = NewTemplateRenderer(exampleResource, map[string]string{"a": "{{ .Spec.size }}"}, nil)
+	assert.NoError(t, err)
+	_, err = renderer.Render(owner)
+	assert.Error(t, err)
+
+	_, err = NewTemplateRenderer(exampleResource, map[string]string{"a": "{{ .Name "}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	gocontext "context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"k8s.io/client-go/rest"
+)
+
+// ReconcileIDKey is the log key of the ID of the reconcile a message was logged in
+const ReconcileIDKey = "reconcileID"
+
+// ReconcileTrace identifies a single reconcile of a key, so that its logs, API calls, and events can be correlated
+// with each other and with the audit logs of the apiserver
+type ReconcileTrace struct {
+	// ID is unique to the reconcile
+	ID  string
+	Key string
+
+	// Logger adds the ID and key to every message
+	Logger Logger
+
+	// Ctx carries the span of the reconcile when the context has a TracerProvider, so that reconcilers start their
+	// spans as children of it and create clients sending it with Context.WithSpan. Defaults to the background context.
+	Ctx gocontext.Context
+}
+
+// TracedReconciler is implemented by reconcilers that correlate their work with the ID of each reconcile. The
+// controller calls ReconcileTraced instead of Reconcile for them.
+type TracedReconciler interface {
+	Reconciler
+	ReconcileTraced(trace ReconcileTrace) error
+}
+
+// NewReconcileTrace generates the ID of a reconcile of the key
+func NewReconcileTrace(key string, logger Logger) ReconcileTrace {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		logger.Error(err, "failed to generate a reconcile ID")
+	}
+	trace := ReconcileTrace{ID: hex.EncodeToString(id), Key: key, Ctx: gocontext.Background()}
+	trace.Logger = logger.WithValues(ReconcileIDKey, trace.ID, "key", key)
+	return trace
+}
+
+// UserAgent returns the user agent with the ID as a comment, such as "operator/v1.0 (reconcile 1a2b3c4d5e6f7a8b)"
+func (t ReconcileTrace) UserAgent(userAgent string) string {
+	if userAgent == "" {
+		userAgent = rest.DefaultKubernetesUserAgent()
+	}
+	return fmt.Sprintf("%s (reconcile %s)", userAgent, t.ID)
+}
+
+// Request sets the user agent of the request to the default user agent with the ID, so the request can be found
+// in the audit logs of the apiserver
+func (t ReconcileTrace) Request(request *rest.Request) *rest.Request {
+	return request.SetHeader("User-Agent", t.UserAgent(""))
+}
+
+// Config returns a copy of the config whose clients send the ID in their user agent, for clientsets created for
+// the reconcile
+func (t ReconcileTrace) Config(config *rest.Config) *rest.Config {
+	traced := *config
+	traced.UserAgent = t.UserAgent(config.UserAgent)
+	return &traced
+}
+
+// EventMessage adds the ID to the message of an event
+func (t ReconcileTrace) EventMessage(message string) string {
+	return fmt.Sprintf("%s (reconcile %s)", message, t.ID)
+}
+
+// StatusPatch returns the patch setting status.lastReconcileID of the custom resource to the ID, for PatchStatus
+func (t ReconcileTrace) StatusPatch() []JSONPatchOperation {
+	return []JSONPatchOperation{JSONPatchAdd("/status/lastReconcileID", t.ID)}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+)
+
+type tracedReconciler struct {
+	traces []ReconcileTrace
+}
+
+func (r *tracedReconciler) Reconcile(key string) error {
+	return fmt.Errorf("not traced")
+}
+
+func (r *tracedReconciler) ReconcileTraced(trace ReconcileTrace) error {
+	r.traces = append(r.traces, trace)
+	trace.Logger.Info("reconciling")
+	return nil
+}
+
+func TestReconcileTrace(t *testing.T) {
+	var out bytes.Buffer
+	reconciler := &tracedReconciler{}
+	c := &Controller{
+		context:    Context{Logger: NewStdLogger(&out, LogLevelInfo)},
+		resource:   exampleResource,
+		reconciler: reconciler,
+		options:    ControllerOptions{Locks: NewKeyLocks()},
+	}
+
+	first := NewReconcileTrace("default/a", c.context.logger())
+	second := NewReconcileTrace("default/a", c.context.logger())
+	assert.Equal(t, 16, len(first.ID))
+	assert.NotEqual(t, first.ID, second.ID)
+
+	_, err := c.reconcile(first)
+	assert.NoError(t, err)
+	assert.Equal(t, []ReconcileTrace{first}, reconciler.traces)
+	assert.Contains(t, out.String(), fmt.Sprintf(`msg="reconciling" reconcileID="%s" key="default/a"`, first.ID))
+
+	assert.Equal(t, fmt.Sprintf("sample/v1 (reconcile %s)", first.ID), first.UserAgent("sample/v1"))
+	config := first.Config(&rest.Config{Host: "https://cluster", UserAgent: "sample/v1"})
+	assert.Equal(t, "https://cluster", config.Host)
+	assert.Equal(t, first.UserAgent("sample/v1"), config.UserAgent)
+	assert.Equal(t, fmt.Sprintf("boom (reconcile %s)", first.ID), first.EventMessage("boom"))
+	assert.Equal(t, []JSONPatchOperation{{Op: "add", Path: "/status/lastReconcileID", Value: first.ID}}, first.StatusPatch())
+
+	// plain reconcilers are called with the key
+	c.reconciler = ReconcilerFunc(func(key string) error {
+		return fmt.Errorf("failed %s", key)
+	})
+	_, err = c.reconcile(second)
+	assert.EqualError(t, err, "failed default/a")
+}
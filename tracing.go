@@ -0,0 +1,141 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+)
+
+// TracerName is the name of the tracer the kit gets from the TracerProvider, the import path of the kit
+const TracerName = "github.com/rook/operator-kit"
+
+// TracerProvider provides the tracer starting the spans of the kit. It is the subset of an OpenTelemetry
+// TracerProvider the kit needs, so that operators adapt their OpenTelemetry TracerProvider in a few lines and the kit
+// does not depend on a tracing library.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer starts the spans of the kit. The keysAndValues are alternating attribute keys and values, like for the
+// Logger.
+type Tracer interface {
+	Start(ctx gocontext.Context, name string, keysAndValues ...interface{}) (gocontext.Context, Span)
+}
+
+// TracePropagator injects the span of a context into the headers of a request, like an OpenTelemetry
+// TextMapPropagator with a HeaderCarrier, so that the spans of the apiserver are children of the spans of the kit
+type TracePropagator interface {
+	Inject(ctx gocontext.Context, header http.Header)
+}
+
+// Span is a unit of work of the kit, such as a reconcile, ended once the work is done
+type Span interface {
+	SetAttributes(keysAndValues ...interface{})
+
+	// RecordError records the error and marks the span as failed
+	RecordError(err error)
+
+	End()
+}
+
+// Names of the spans started by the kit
+const (
+	SpanCreateCustomResources = "operatorkit.CreateCustomResources"
+	SpanInstallCustomResource = "operatorkit.InstallCustomResource"
+	SpanWaitForCustomResource = "operatorkit.WaitForCustomResource"
+	SpanReconcile             = "operatorkit.Reconcile"
+)
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(keysAndValues ...interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+// startSpan starts a span with the tracer provider of the context, or returns a span doing nothing without one
+func (c Context) startSpan(ctx gocontext.Context, name string, keysAndValues ...interface{}) (gocontext.Context, Span) {
+	if c.TracerProvider == nil {
+		return ctx, noopSpan{}
+	}
+	return c.TracerProvider.Tracer(TracerName).Start(ctx, name, keysAndValues...)
+}
+
+// WithSpan returns a copy of the context whose clients send the span of ctx in the headers of their requests, so
+// the API calls made during a reconcile, such as with the Ctx of its ReconcileTrace, are traced as part of it. The
+// clients are created again from the RESTConfig of the context. Without a TracePropagator the context is returned
+// unchanged.
+func (c Context) WithSpan(ctx gocontext.Context) (Context, error) {
+	if c.TracePropagator == nil {
+		return c, nil
+	}
+	if c.RESTConfig == nil {
+		return c, fmt.Errorf("the context has no RESTConfig to trace")
+	}
+	config := *c.RESTConfig
+	wrap := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return &tracingTransport{next: rt, ctx: ctx, propagator: c.TracePropagator}
+	}
+	return c.withClients(&config)
+}
+
+// withSpan is WithSpan for the API calls of the kit, which are still made untraced when the clients cannot be
+// created again
+func (c Context) withSpan(ctx gocontext.Context) Context {
+	if c.TracePropagator == nil || c.RESTConfig == nil {
+		return c
+	}
+	traced, err := c.WithSpan(ctx)
+	if err != nil {
+		c.logger().Error(err, "failed to create the traced clients")
+		return c
+	}
+	return traced
+}
+
+// tracingTransport injects the span of its context into the headers of each request
+type tracingTransport struct {
+	next       http.RoundTripper
+	ctx        gocontext.Context
+	propagator TracePropagator
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a round tripper must not modify the request it was given
+	traced := new(http.Request)
+	*traced = *req
+	traced.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		traced.Header[name] = values
+	}
+	t.propagator.Inject(t.ctx, traced.Header)
+	return t.next.RoundTrip(traced)
+}
+
+// endSpan records the error, if any, and ends the span
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+type spanKey struct{}
+
+// recordedSpan is a span recorded by the recordingTracer with the name of its parent span
+type recordedSpan struct {
+	name       string
+	parent     string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttributes(keysAndValues ...interface{}) {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		s.attributes[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type recordingTracer struct {
+	name  string
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Tracer(name string) Tracer {
+	t.name = name
+	return t
+}
+
+func (t *recordingTracer) Start(ctx gocontext.Context, name string, keysAndValues ...interface{}) (gocontext.Context, Span) {
+	span := &recordedSpan{name: name, attributes: map[string]interface{}{}}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+	span.SetAttributes(keysAndValues...)
+	t.spans = append(t.spans, span)
+	return gocontext.WithValue(ctx, spanKey{}, span), span
+}
+
+func TestInstallResourceSpans(t *testing.T) {
+	tracer := &recordingTracer{}
+	context := Context{TracerProvider: tracer}
+	install := func(context Context, resource CustomResource) (InstallOutcome, error) {
+		return OutcomeCreated, nil
+	}
+	waitForInit := func(context Context, resource CustomResource) error {
+		return fmt.Errorf("not established")
+	}
+
+	result := installResource(gocontext.Background(), context, exampleResource, install, waitForInit)
+	assert.Error(t, result.Err)
+	assert.Equal(t, TracerName, tracer.name)
+	assert.Equal(t, 2, len(tracer.spans))
+	installSpan, waitSpan := tracer.spans[0], tracer.spans[1]
+	assert.Equal(t, SpanInstallCustomResource, installSpan.name)
+	assert.Equal(t, map[string]interface{}{"resource": "example", "outcome": string(OutcomeFailed)}, installSpan.attributes)
+	assert.EqualError(t, installSpan.err, "not established")
+	assert.True(t, installSpan.ended)
+	assert.Equal(t, SpanWaitForCustomResource, waitSpan.name)
+	assert.Equal(t, SpanInstallCustomResource, waitSpan.parent)
+	assert.EqualError(t, waitSpan.err, "not established")
+	assert.True(t, waitSpan.ended)
+
+	// without a tracer provider the spans do nothing
+	ctx, span := Context{}.startSpan(gocontext.Background(), SpanReconcile)
+	assert.Equal(t, gocontext.Background(), ctx)
+	endSpan(span, fmt.Errorf("ignored"))
+}
+
+// spanPropagator sends the name of the span in a header
+type spanPropagator struct{}
+
+func (spanPropagator) Inject(ctx gocontext.Context, header http.Header) {
+	if span, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		header.Set("X-Span", span.name)
+	}
+}
+
+func TestWithSpan(t *testing.T) {
+	spans := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spans <- r.Header.Get("X-Span")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"config","namespace":"ns"}}`))
+	}))
+	defer server.Close()
+	context, err := NewContext(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	tracer := &recordingTracer{}
+	context.TracerProvider = tracer
+
+	// without a propagator the context is unchanged
+	ctx, span := context.startSpan(gocontext.Background(), SpanReconcile)
+	traced, err := context.WithSpan(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, context.Clientset, traced.Clientset)
+
+	context.TracePropagator = spanPropagator{}
+	traced, err = context.WithSpan(ctx)
+	assert.NoError(t, err)
+	_, err = traced.Clientset.CoreV1().ConfigMaps("ns").Get("config", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, SpanReconcile, <-spans)
+	span.End()
+
+	// the clients of the context itself are not traced
+	_, err = context.Clientset.CoreV1().ConfigMaps("ns").Get("config", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", <-spans)
+
+	_, err = Context{TracePropagator: spanPropagator{}}.WithSpan(ctx)
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// LastAppliedAnnotation is set by kubectl apply to the whole applied manifest
+const LastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// TransformFunc modifies an object in place before it enters the informer store, usually to drop bulky fields the
+// controller never reads. On clusters with many custom resources this reduces the memory of the operator a lot.
+type TransformFunc func(obj runtime.Object) error
+
+// DefaultTransform strips the managed fields and the last applied configuration, which often take more space than
+// the rest of the object
+var DefaultTransform = ChainTransforms(StripManagedFields, StripAnnotations(LastAppliedAnnotation))
+
+// ChainTransforms returns a transform running the transforms in order
+func ChainTransforms(transforms ...TransformFunc) TransformFunc {
+	return func(obj runtime.Object) error {
+		for _, transform := range transforms {
+			if err := transform(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// StripManagedFields removes metadata.managedFields from unstructured objects. Typed objects drop the field when
+// they are decoded since the types of the kit predate server-side apply.
+func StripManagedFields(obj runtime.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		unstructured.RemoveNestedField(u.Object, "metadata", "managedFields")
+	}
+	return nil
+}
+
+// StripAnnotations returns a transform removing the annotations with the keys
+func StripAnnotations(keys ...string) TransformFunc {
+	return func(obj runtime.Object) error {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		annotations := accessor.GetAnnotations()
+		if len(annotations) == 0 {
+			return nil
+		}
+		for _, key := range keys {
+			delete(annotations, key)
+		}
+		accessor.SetAnnotations(annotations)
+		return nil
+	}
+}
+
+// transformListWatch applies the transform to the objects listed and watched by the source. Objects failing the
+// transform are stored as is.
+func transformListWatch(source *cache.ListWatch, context Context, resource string, transform TransformFunc) {
+	if transform == nil {
+		return
+	}
+	apply := func(obj runtime.Object) {
+		if err := transform(obj); err != nil {
+			context.logger().Error(err, "failed to transform object", "resource", resource)
+		}
+	}
+
+	listFunc := source.ListFunc
+	source.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		list, err := listFunc(options)
+		if err != nil {
+			return list, err
+		}
+		// the items are pointers into the list, so they are transformed in place
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return list, err
+		}
+		for _, item := range items {
+			apply(item)
+		}
+		return list, nil
+	}
+
+	watchFunc := source.WatchFunc
+	source.WatchFunc = func(options metav1.ListOptions) (watch.Interface, error) {
+		w, err := watchFunc(options)
+		if err != nil {
+			return w, err
+		}
+		return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+			if event.Type != watch.Error && event.Object != nil {
+				apply(event.Object)
+			}
+			return event, true
+		}), nil
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDefaultTransform(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":          "a",
+			"managedFields": []interface{}{map[string]interface{}{"manager": "kubectl"}},
+			"annotations":   map[string]interface{}{LastAppliedAnnotation: "{}", "keep": "yes"},
+		},
+	}}
+	assert.NoError(t, DefaultTransform(u))
+	_, found, _ := unstructured.NestedFieldNoCopy(u.Object, "metadata", "managedFields")
+	assert.False(t, found)
+	assert.Equal(t, map[string]string{"keep": "yes"}, u.GetAnnotations())
+
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{LastAppliedAnnotation: "{}"}}}
+	assert.NoError(t, DefaultTransform(cm))
+	assert.Empty(t, cm.Annotations)
+}
+
+func TestTransformListWatch(t *testing.T) {
+	annotated := metav1.ObjectMeta{Name: "a", Annotations: map[string]string{"big": "data"}}
+	fakeWatch := watch.NewFake()
+	source := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &v1.ConfigMapList{Items: []v1.ConfigMap{{ObjectMeta: annotated}}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return fakeWatch, nil
+		},
+	}
+	transformListWatch(source, Context{}, "configmap", StripAnnotations("big"))
+
+	list, err := source.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, list.(*v1.ConfigMapList).Items[0].Annotations)
+
+	w, err := source.Watch(metav1.ListOptions{})
+	assert.NoError(t, err)
+	go fakeWatch.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "b", Annotations: map[string]string{"big": "data"}}})
+	event := <-w.ResultChan()
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Empty(t, event.Object.(*v1.ConfigMap).Annotations)
+	w.Stop()
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// TTLAnnotation sets the seconds a finished custom resource is kept before the controller deletes it, like the
+	// ttlSecondsAfterFinished of Jobs. It overrides the default TTL of the controller.
+	TTLAnnotation = "operatorkit.io/ttl-seconds-after-finished"
+
+	// EventReasonTTLExpired is the reason of the event emitted when a finished custom resource is deleted
+	EventReasonTTLExpired = "TTLExpired"
+)
+
+// FinishedFunc returns whether the custom resource reached a terminal phase and the time it finished. The time is
+// zero if the resource does not record when it finished, in which case the TTL starts when the controller first
+// sees the resource finished. That time is not persisted, so the TTL starts over when the operator restarts.
+type FinishedFunc func(obj runtime.Object) (bool, time.Time)
+
+// TTLOptions configure the deletion of finished custom resources by their controller
+type TTLOptions struct {
+	// Finished tells the finished resources, such as TerminalPhases
+	Finished FinishedFunc
+
+	// DefaultTTL is optional and applies to the resources without the TTLAnnotation. Without it, only the resources
+	// with the annotation are deleted.
+	DefaultTTL *time.Duration
+}
+
+// TerminalPhases returns a finished func for resources whose status.phase is one of the phases. The finish time is
+// status.completionTime, or else the last transition of the conditions in status.conditions. Without either, the
+// finish time is unknown and the TTL starts when the controller first sees the terminal phase.
+func TerminalPhases(phases ...string) FinishedFunc {
+	terminal := map[string]bool{}
+	for _, phase := range phases {
+		terminal[phase] = true
+	}
+	return func(obj runtime.Object) (bool, time.Time) {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return false, time.Time{}
+		}
+		status := struct {
+			Status struct {
+				Phase          string       `json:"phase"`
+				CompletionTime *metav1.Time `json:"completionTime"`
+				Conditions     []Condition  `json:"conditions"`
+			} `json:"status"`
+		}{}
+		if err := json.Unmarshal(data, &status); err != nil || !terminal[status.Status.Phase] {
+			return false, time.Time{}
+		}
+		if status.Status.CompletionTime != nil {
+			return true, status.Status.CompletionTime.Time
+		}
+		var finished time.Time
+		for _, condition := range status.Status.Conditions {
+			if condition.LastTransitionTime.Time.After(finished) {
+				finished = condition.LastTransitionTime.Time
+			}
+		}
+		return true, finished
+	}
+}
+
+// resourceTTL returns the TTL of the resource from its annotation or the default of the options
+func (o *TTLOptions) resourceTTL(accessor metav1.Object) (time.Duration, bool, error) {
+	value, ok := accessor.GetAnnotations()[TTLAnnotation]
+	if !ok {
+		if o.DefaultTTL == nil {
+			return 0, false, nil
+		}
+		return *o.DefaultTTL, true, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false, fmt.Errorf("invalid %s annotation %q", TTLAnnotation, value)
+	}
+	return time.Duration(seconds) * time.Second, true, nil
+}
+
+// expireTTL deletes the custom resource with the key when it finished more than its TTL ago, and otherwise queues
+// the key again when the TTL expires. It returns true when the resource was deleted and must not be reconciled.
+func (c *Controller) expireTTL(key string, now time.Time) (bool, error) {
+	if c.options.TTL == nil || c.options.TTL.Finished == nil {
+		return false, nil
+	}
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil || !exists {
+		c.forgetFinished(key)
+		return false, err
+	}
+	object, ok := obj.(runtime.Object)
+	if !ok {
+		return false, nil
+	}
+	accessor, err := meta.Accessor(object)
+	if err != nil || accessor.GetDeletionTimestamp() != nil {
+		return false, err
+	}
+	ttl, ok, err := c.options.TTL.resourceTTL(accessor)
+	if err != nil {
+		// the resource is reconciled as usual until the annotation is fixed
+		c.context.logger().Error(err, "ignoring the TTL", "resource", c.resource.Name, "key", key)
+		return false, nil
+	}
+	if !ok {
+		return false, nil
+	}
+	finished, at := c.options.TTL.Finished(object)
+	if !finished {
+		c.forgetFinished(key)
+		return false, nil
+	}
+	if at.IsZero() {
+		at = c.firstSeenFinished(key, now)
+	}
+
+	if remaining := at.Add(ttl).Sub(now); remaining > 0 {
+		c.queue.AddAfter(key, remaining)
+		return false, nil
+	}
+	propagation := metav1.DeletePropagationBackground
+	err = c.client.Delete().Namespace(accessor.GetNamespace()).Resource(c.resource.Plural).Name(accessor.GetName()).
+		Body(&metav1.DeleteOptions{PropagationPolicy: &propagation}).Do().Error()
+	if err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to delete the expired %s %s. %+v", c.resource.Name, key, err)
+	}
+	c.forgetFinished(key)
+	c.context.logger().Info("deleted the finished resource after its TTL", "resource", c.resource.Name, "key", key, "ttl", ttl)
+	c.recordEvent(key, v1.EventTypeNormal, EventReasonTTLExpired,
+		fmt.Sprintf("deleted %s %s after it finished", c.resource.Name, now.Sub(at).Round(time.Second)))
+	return true, nil
+}
+
+// firstSeenFinished returns the time the controller first saw the resource with the key finished without a finish
+// time, recording now if it is the first time
+func (c *Controller) firstSeenFinished(key string, now time.Time) time.Time {
+	c.finishedLock.Lock()
+	defer c.finishedLock.Unlock()
+	if seen, ok := c.finishedSeen[key]; ok {
+		return seen
+	}
+	c.finishedSeen[key] = now
+	return now
+}
+
+// forgetFinished drops the time the resource with the key was first seen finished, once it is deleted or running again
+func (c *Controller) forgetFinished(key string) {
+	c.finishedLock.Lock()
+	defer c.finishedLock.Unlock()
+	delete(c.finishedSeen, key)
+}
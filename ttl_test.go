@@ -0,0 +1,144 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+)
+
+func finishedObject(name, phase string, annotations map[string]interface{}, completion time.Time) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"namespace": "ns", "name": name}
+	if annotations != nil {
+		metadata["annotations"] = annotations
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata,
+		"status":     map[string]interface{}{"phase": phase, "completionTime": completion.UTC().Format(time.RFC3339)},
+	}}
+}
+
+func TestTerminalPhases(t *testing.T) {
+	finished := TerminalPhases("Succeeded", "Failed")
+	completion := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	done, at := finished(finishedObject("a", "Failed", nil, completion))
+	assert.True(t, done)
+	assert.True(t, completion.Equal(at))
+
+	done, _ = finished(finishedObject("a", "Running", nil, completion))
+	assert.False(t, done)
+
+	// without a completion time, the last condition transition is the finish time
+	obj := finishedObject("a", "Succeeded", nil, completion)
+	obj.Object["status"] = map[string]interface{}{"phase": "Succeeded", "conditions": []interface{}{
+		map[string]interface{}{"type": "Complete", "status": "True", "lastTransitionTime": "2024-01-02T00:00:00Z"},
+	}}
+	done, at = finished(obj)
+	assert.True(t, done)
+	assert.True(t, time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC).Equal(at))
+
+	// without either, the finish time is unknown rather than the creation time
+	obj.Object["metadata"].(map[string]interface{})["creationTimestamp"] = "2020-01-01T00:00:00Z"
+	obj.Object["status"] = map[string]interface{}{"phase": "Succeeded"}
+	done, at = finished(obj)
+	assert.True(t, done)
+	assert.True(t, at.IsZero())
+}
+
+func TestExpireTTL(t *testing.T) {
+	requests := map[string]string{}
+	c := newBulkController(t, requests)
+	recorder := record.NewFakeRecorder(10)
+	c.context.Recorder = recorder
+	ttl := time.Hour
+	c.options.TTL = &TTLOptions{Finished: TerminalPhases("Succeeded"), DefaultTTL: &ttl}
+
+	now := time.Now().Truncate(time.Second)
+	c.store.Add(finishedObject("expired", "Succeeded", nil, now.Add(-2*time.Hour)))
+	c.store.Add(finishedObject("recent", "Succeeded", nil, now.Add(-time.Minute)))
+	c.store.Add(finishedObject("short", "Succeeded", map[string]interface{}{TTLAnnotation: "30"}, now.Add(-time.Minute)))
+	c.store.Add(finishedObject("invalid", "Succeeded", map[string]interface{}{TTLAnnotation: "soon"}, now.Add(-2*time.Hour)))
+	c.store.Add(finishedObject("running", "Running", nil, now.Add(-2*time.Hour)))
+
+	deleted, err := c.expireTTL("ns/expired", now)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	_, ok := requests["/api/v1/namespaces/ns/configmaps/expired"]
+	assert.True(t, ok)
+	assert.Equal(t, "Normal TTLExpired deleted configmap 2h0m0s after it finished", <-recorder.Events)
+
+	deleted, err = c.expireTTL("ns/short", now)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	for _, key := range []string{"ns/recent", "ns/invalid", "ns/running", "ns/missing"} {
+		deleted, err = c.expireTTL(key, now)
+		assert.NoError(t, err)
+		assert.False(t, deleted, key)
+	}
+	_, ok = requests["/api/v1/namespaces/ns/configmaps/recent"]
+	assert.False(t, ok)
+
+	// without a default TTL, only annotated resources expire
+	c.options.TTL.DefaultTTL = nil
+	deleted, _ = c.expireTTL("ns/expired", now)
+	assert.False(t, deleted)
+}
+
+func TestExpireTTLWithoutFinishTime(t *testing.T) {
+	requests := map[string]string{}
+	c := newBulkController(t, requests)
+	ttl := time.Hour
+	c.options.TTL = &TTLOptions{Finished: TerminalPhases("Succeeded"), DefaultTTL: &ttl}
+	obj := finishedObject("old", "Succeeded", nil, time.Time{})
+	obj.Object["metadata"].(map[string]interface{})["creationTimestamp"] = "2020-01-01T00:00:00Z"
+	obj.Object["status"] = map[string]interface{}{"phase": "Succeeded"}
+	c.store.Add(obj)
+
+	// the TTL of a resource created long ago starts when the controller first sees it finished
+	now := time.Now()
+	deleted, err := c.expireTTL("ns/old", now)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = c.expireTTL("ns/old", now.Add(30*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	_, ok := requests["/api/v1/namespaces/ns/configmaps/old"]
+	assert.False(t, ok)
+
+	deleted, err = c.expireTTL("ns/old", now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	_, ok = requests["/api/v1/namespaces/ns/configmaps/old"]
+	assert.True(t, ok)
+	assert.Empty(t, c.finishedSeen)
+
+	// the TTL starts over when the resource runs again before it expired
+	c.expireTTL("ns/old", now)
+	obj.Object["status"] = map[string]interface{}{"phase": "Running"}
+	c.expireTTL("ns/old", now.Add(30*time.Minute))
+	obj.Object["status"] = map[string]interface{}{"phase": "Succeeded"}
+	deleted, _ = c.expireTTL("ns/old", now.Add(time.Hour))
+	assert.False(t, deleted)
+	assert.Equal(t, now.Add(time.Hour), c.finishedSeen["ns/old"])
+}
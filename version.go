@@ -0,0 +1,129 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+)
+
+const apiextensionsGroup = "apiextensions.k8s.io"
+
+var (
+	serverVersionV120  = serverVersion{major: 1, minor: 2}
+	serverVersionV170  = serverVersion{major: 1, minor: 7}
+	serverVersionV1110 = serverVersion{major: 1, minor: 11}
+	serverVersionV1160 = serverVersion{major: 1, minor: 16}
+)
+
+// serverVersion is the release of a Kubernetes server. Pre-release and build metadata are not kept since the kit
+// only compares releases.
+type serverVersion struct {
+	major, minor, patch uint64
+}
+
+func (v serverVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+// AtLeast returns whether the version is the same as or newer than the min version
+func (v serverVersion) AtLeast(min serverVersion) bool {
+	if v.major != min.major {
+		return v.major > min.major
+	}
+	if v.minor != min.minor {
+		return v.minor > min.minor
+	}
+	return v.patch >= min.patch
+}
+
+// newServerVersion parses the numeric parts of a version. It fails rather than panics on parts out of range.
+func newServerVersion(major, minor, patch string) (serverVersion, error) {
+	var v serverVersion
+	var err error
+	if v.major, err = strconv.ParseUint(major, 10, 32); err != nil {
+		return v, fmt.Errorf("invalid major version %q", major)
+	}
+	if v.minor, err = strconv.ParseUint(minor, 10, 32); err != nil {
+		return v, fmt.Errorf("invalid minor version %q", minor)
+	}
+	if patch == "" {
+		return v, nil
+	}
+	if v.patch, err = strconv.ParseUint(patch, 10, 32); err != nil {
+		return v, fmt.Errorf("invalid patch version %q", patch)
+	}
+	return v, nil
+}
+
+// versionRE matches the major, minor, and optional patch version at the start of a GitVersion
+// such as "v1.10.5-gke.3" or "v1.21.4+rke2r1"
+var versionRE = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)(?:\.([0-9]+))?`)
+
+// parseServerVersion parses the version reported by the server. Vendor suffixes of the GitVersion are dropped.
+// If the GitVersion is not recognized, the Major and Minor fields are used instead.
+func parseServerVersion(info *apimachineryversion.Info) (serverVersion, error) {
+	if parts := versionRE.FindStringSubmatch(strings.TrimSpace(info.GitVersion)); parts != nil {
+		return newServerVersion(parts[1], parts[2], parts[3])
+	}
+
+	// some distributions report a minor version like "9+"
+	major := strings.TrimRight(info.Major, "+")
+	minor := strings.TrimRight(info.Minor, "+")
+	if major == "" || minor == "" {
+		return serverVersion{}, fmt.Errorf("unrecognized server version %q", info.GitVersion)
+	}
+	return newServerVersion(major, minor, "")
+}
+
+// apiFlavorFromDiscovery selects the API from the apiextensions versions served by the cluster,
+// for clusters where the server version cannot be parsed. TPRs are selected if the cluster serves the extensions group.
+func apiFlavorFromDiscovery(context Context, gitVersion string) (APIFlavor, error) {
+	groups, err := context.Clientset.Discovery().ServerGroups()
+	if err != nil {
+		return AutoDetect, fmt.Errorf("failed to discover the server API groups. %+v", err)
+	}
+
+	extensions := false
+	for _, group := range groups.Groups {
+		if group.Name == "extensions" {
+			extensions = true
+		}
+		if group.Name != apiextensionsGroup {
+			continue
+		}
+		served := map[string]bool{}
+		for _, v := range group.Versions {
+			served[v.Version] = true
+		}
+		if served["v1"] {
+			return ForceCRDv1, nil
+		}
+		if served["v1beta1"] {
+			return ForceCRDv1beta1, nil
+		}
+	}
+	if !extensions {
+		return AutoDetect, &ErrUnsupportedServerVersion{Version: gitVersion}
+	}
+	return ForceTPR, nil
+}
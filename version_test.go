@@ -0,0 +1,60 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		info     apimachineryversion.Info
+		expected string
+	}{
+		{apimachineryversion.Info{GitVersion: "v1.8.2"}, "1.8.2"},
+		{apimachineryversion.Info{GitVersion: "v1.10.5-gke.3"}, "1.10.5"},
+		{apimachineryversion.Info{GitVersion: "v1.21.4+rke2r1"}, "1.21.4"},
+		{apimachineryversion.Info{GitVersion: "v1.11"}, "1.11.0"},
+		{apimachineryversion.Info{GitVersion: "custom-build", Major: "1", Minor: "9+"}, "1.9.0"},
+	}
+	for _, test := range tests {
+		v, err := parseServerVersion(&test.info)
+		assert.NoError(t, err, test.info.GitVersion)
+		assert.Equal(t, test.expected, v.String(), test.info.GitVersion)
+	}
+
+	for _, info := range []apimachineryversion.Info{
+		{GitVersion: "custom-build"},
+		{GitVersion: "v1.99999999999.0"},
+		{GitVersion: "custom-build", Major: "one", Minor: "9"},
+	} {
+		_, err := parseServerVersion(&info)
+		assert.Error(t, err, info.GitVersion)
+	}
+}
+
+func TestServerVersionAtLeast(t *testing.T) {
+	v, err := parseServerVersion(&apimachineryversion.Info{GitVersion: "v1.16.0-eks-2b3a4c"})
+	assert.NoError(t, err)
+	assert.True(t, v.AtLeast(serverVersionV1160))
+	assert.True(t, v.AtLeast(serverVersionV170))
+	assert.True(t, v.AtLeast(serverVersion{major: 1, minor: 15, patch: 9}))
+	assert.False(t, v.AtLeast(serverVersion{major: 1, minor: 16, patch: 1}))
+	assert.False(t, v.AtLeast(serverVersion{major: 2}))
+}
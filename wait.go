@@ -0,0 +1,187 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// WatchFunc opens a watch that emits an event whenever the object being waited on changes
+type WatchFunc func() (watch.Interface, error)
+
+// WaitStrategy decides how the kit waits for a condition on the cluster to be met
+type WaitStrategy interface {
+	// Wait blocks until the condition returns true, the condition returns an error, or the timeout expires.
+	// The watchFunc is optional. Strategies that react to changes use it to be notified instead of polling.
+	Wait(interval, timeout time.Duration, watchFunc WatchFunc, condition wait.ConditionFunc) error
+}
+
+// LinearWaitStrategy polls the condition at a fixed interval
+type LinearWaitStrategy struct{}
+
+// Wait polls the condition every interval until the timeout
+func (LinearWaitStrategy) Wait(interval, timeout time.Duration, watchFunc WatchFunc, condition wait.ConditionFunc) error {
+	return wait.Poll(interval, timeout, condition)
+}
+
+// ExponentialWaitStrategy polls the condition with an interval that grows after each attempt. Adding jitter keeps
+// many operators starting at the same time, such as after a cluster outage, from polling the API server in lockstep.
+type ExponentialWaitStrategy struct {
+	// Factor the interval is multiplied by after each attempt. Defaults to 2 if zero. A factor of 1 keeps the
+	// interval constant.
+	Factor float64
+
+	// MaxInterval caps the interval between two attempts, before jitter is added. Zero means no cap.
+	MaxInterval time.Duration
+
+	// Jitter adds a random duration of up to Jitter times the interval to each wait. Zero means no jitter.
+	Jitter float64
+
+	// Steps limits the number of attempts. Zero means the attempts are only limited by the timeout.
+	Steps int
+}
+
+// Wait polls the condition starting at the given interval until the timeout
+func (s ExponentialWaitStrategy) Wait(interval, timeout time.Duration, watchFunc WatchFunc, condition wait.ConditionFunc) error {
+	factor := s.Factor
+	if factor == 0 {
+		factor = 2
+	} else if factor < 1 {
+		factor = 1
+	}
+
+	deadline := time.Now().Add(timeout)
+	delay := interval
+	for attempt := 0; s.Steps <= 0 || attempt < s.Steps; attempt++ {
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return wait.ErrWaitTimeout
+		}
+		sleep := delay
+		if s.Jitter > 0 {
+			sleep = wait.Jitter(delay, s.Jitter)
+		}
+		if sleep > remaining {
+			sleep = remaining
+		}
+		time.Sleep(sleep)
+
+		done, err := condition()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		delay = time.Duration(float64(delay) * factor)
+		if s.MaxInterval > 0 && delay > s.MaxInterval {
+			delay = s.MaxInterval
+		}
+	}
+	return wait.ErrWaitTimeout
+}
+
+// WatchWaitStrategy evaluates the condition each time the watched object changes instead of polling.
+// It falls back to polling at the given interval for the rest of the timeout when no watchFunc is available, the
+// watch cannot be opened, or the watch reports an error.
+type WatchWaitStrategy struct{}
+
+// errWatchFailed is returned by waitForWatchEvent when the watch reports an error event
+var errWatchFailed = fmt.Errorf("the watch reported an error")
+
+// Wait evaluates the condition on every watch event until the timeout
+func (WatchWaitStrategy) Wait(interval, timeout time.Duration, watchFunc WatchFunc, condition wait.ConditionFunc) error {
+	if watchFunc == nil {
+		return wait.Poll(interval, timeout, condition)
+	}
+
+	end := time.Now().Add(timeout)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		w, err := watchFunc()
+		if err != nil {
+			// a watch that fails after the server closed the previous one only has the rest of the timeout
+			return pollUntil(end, interval, condition)
+		}
+
+		// evaluate once the watch is open so that a change made before the watch started is not missed
+		done, err := condition()
+		if err != nil || done {
+			w.Stop()
+			return err
+		}
+
+		done, err = waitForWatchEvent(w, deadline.C, condition)
+		w.Stop()
+		if err == errWatchFailed {
+			// an error such as an expired resource version would fail the next watch as well
+			return pollUntil(end, interval, condition)
+		}
+		if err != nil || done {
+			return err
+		}
+
+		// the watch was closed by the server. Wait an interval before opening a new one so that a server closing
+		// every watch right away is not hit in a hot loop.
+		retry := time.NewTimer(interval)
+		select {
+		case <-retry.C:
+		case <-deadline.C:
+			retry.Stop()
+			return wait.ErrWaitTimeout
+		}
+	}
+}
+
+// pollUntil polls the condition at the interval until the end of the timeout
+func pollUntil(end time.Time, interval time.Duration, condition wait.ConditionFunc) error {
+	remaining := end.Sub(time.Now())
+	if remaining <= 0 {
+		return wait.ErrWaitTimeout
+	}
+	return wait.Poll(interval, remaining, condition)
+}
+
+// waitForWatchEvent evaluates the condition on each event until it is met, the deadline passes, or the watch closes.
+// An error event returns errWatchFailed.
+func waitForWatchEvent(w watch.Interface, deadline <-chan time.Time, condition wait.ConditionFunc) (bool, error) {
+	for {
+		select {
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			if event.Type == watch.Error {
+				return false, errWatchFailed
+			}
+			done, err := condition()
+			if err != nil || done {
+				return done, err
+			}
+		case <-deadline:
+			return false, wait.ErrWaitTimeout
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestExponentialWaitStrategy(t *testing.T) {
+	attempts := 0
+	strategy := ExponentialWaitStrategy{Factor: 2, MaxInterval: 20 * time.Millisecond}
+	err := strategy.Wait(time.Millisecond, time.Second, nil, func() (bool, error) {
+		attempts++
+		return attempts == 5, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, attempts)
+
+	// the condition error is returned right away
+	attempts = 0
+	err = strategy.Wait(time.Millisecond, time.Second, nil, func() (bool, error) {
+		attempts++
+		return false, fmt.Errorf("failed")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// the timeout is honored
+	err = strategy.Wait(time.Millisecond, 10*time.Millisecond, nil, func() (bool, error) {
+		return false, nil
+	})
+	assert.Equal(t, wait.ErrWaitTimeout, err)
+}
+
+func TestExponentialWaitStrategyJitterAndSteps(t *testing.T) {
+	attempts := 0
+	strategy := ExponentialWaitStrategy{Factor: 1, Jitter: 0.5, Steps: 3}
+	start := time.Now()
+	err := strategy.Wait(2*time.Millisecond, time.Second, nil, func() (bool, error) {
+		attempts++
+		return false, nil
+	})
+	assert.Equal(t, wait.ErrWaitTimeout, err)
+	assert.Equal(t, 3, attempts)
+	assert.True(t, time.Since(start) >= 6*time.Millisecond)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestWatchWaitStrategy(t *testing.T) {
+	fakeWatch := watch.NewFake()
+	watchFunc := func() (watch.Interface, error) {
+		return fakeWatch, nil
+	}
+
+	// the condition is met on the second event after the watch was opened
+	attempts := 0
+	condition := func() (bool, error) {
+		attempts++
+		return attempts == 3, nil
+	}
+
+	go func() {
+		fakeWatch.Add(&v1.ConfigMap{})
+		fakeWatch.Modify(&v1.ConfigMap{})
+	}()
+
+	err := WatchWaitStrategy{}.Wait(time.Hour, time.Second, watchFunc, condition)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestWatchWaitStrategyTimeout(t *testing.T) {
+	watchFunc := func() (watch.Interface, error) {
+		return watch.NewFake(), nil
+	}
+	err := WatchWaitStrategy{}.Wait(time.Hour, 10*time.Millisecond, watchFunc, func() (bool, error) {
+		return false, nil
+	})
+	assert.Equal(t, wait.ErrWaitTimeout, err)
+}
+
+func TestWatchWaitStrategyFallbackRemainingTime(t *testing.T) {
+	fakeWatch := watch.NewFake()
+	opened := false
+	watchFunc := func() (watch.Interface, error) {
+		if opened {
+			return nil, fmt.Errorf("watch failed")
+		}
+		opened = true
+		return fakeWatch, nil
+	}
+	go func() {
+		// the server closes the watch after most of the timeout, and the next watch cannot be opened
+		time.Sleep(150 * time.Millisecond)
+		fakeWatch.Stop()
+	}()
+
+	start := time.Now()
+	err := WatchWaitStrategy{}.Wait(10*time.Millisecond, 200*time.Millisecond, watchFunc, func() (bool, error) {
+		return false, nil
+	})
+	assert.Equal(t, wait.ErrWaitTimeout, err)
+	// the fallback only polls for the rest of the timeout
+	assert.True(t, time.Since(start) < 300*time.Millisecond, time.Since(start).String())
+}
+
+func TestWatchWaitStrategyReopenDelay(t *testing.T) {
+	// the server closes every watch right away
+	opened := 0
+	watchFunc := func() (watch.Interface, error) {
+		opened++
+		fakeWatch := watch.NewFake()
+		fakeWatch.Stop()
+		return fakeWatch, nil
+	}
+	err := WatchWaitStrategy{}.Wait(50*time.Millisecond, 120*time.Millisecond, watchFunc, func() (bool, error) {
+		return false, nil
+	})
+	assert.Equal(t, wait.ErrWaitTimeout, err)
+	// a new watch is opened only once per interval
+	assert.True(t, opened <= 3, "opened %d watches", opened)
+}
+
+func TestWatchWaitStrategyErrorEvent(t *testing.T) {
+	fakeWatch := watch.NewFake()
+	opened := 0
+	watchFunc := func() (watch.Interface, error) {
+		opened++
+		return fakeWatch, nil
+	}
+	go fakeWatch.Error(&v1.ConfigMap{})
+
+	// the condition is polled once the watch reported an error, and not evaluated as if the object changed
+	attempts := 0
+	err := WatchWaitStrategy{}.Wait(10*time.Millisecond, time.Second, watchFunc, func() (bool, error) {
+		attempts++
+		return attempts == 3, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, opened)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestDefaultWaitStrategyCap(t *testing.T) {
+	strategy := Context{}.waitStrategy().(ExponentialWaitStrategy)
+	assert.Equal(t, maxWaitInterval, strategy.MaxInterval)
+	assert.Equal(t, DefaultWaitBackoff.Factor, strategy.Factor)
+
+	context := Context{Backoff: &wait.Backoff{Duration: time.Millisecond, Factor: 2, Cap: 5 * time.Millisecond}}
+	strategy = context.waitStrategy().(ExponentialWaitStrategy)
+	assert.Equal(t, 5*time.Millisecond, strategy.MaxInterval)
+	assert.Equal(t, time.Millisecond, context.waitInterval())
+}
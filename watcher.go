@@ -20,7 +20,9 @@ package operatorkit
 import (
 	"errors"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -31,12 +33,32 @@ var (
 	ErrVersionOutdated = errors.New("requested version is outdated in apiserver")
 )
 
+// Predicate decides whether the events of an object are passed to the handlers of a watcher
+type Predicate func(obj interface{}) bool
+
+// LabelSelectorPredicate passes the events of objects whose labels match the selector. The deletes missed by the
+// watch are matched against the last known state of the object.
+func LabelSelectorPredicate(selector labels.Selector) Predicate {
+	return func(obj interface{}) bool {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return false
+		}
+		return selector.Matches(labels.Set(accessor.GetLabels()))
+	}
+}
+
 // ResourceWatcher watches a custom resource for desired state
 type ResourceWatcher struct {
 	resource              CustomResource
 	namespace             string
 	resourceEventHandlers cache.ResourceEventHandlerFuncs
+	predicates            []Predicate
 	client                rest.Interface
+	source                *cache.ListWatch
 	scheme                *runtime.Scheme
 }
 
@@ -50,15 +72,48 @@ func NewWatcher(resource CustomResource, namespace string, handlers cache.Resour
 	}
 }
 
+// WithPredicates filters the events passed to the handlers to the objects that pass all predicates. An update of an
+// object that stops passing is handled as a delete, and one that starts passing as an add.
+func (w *ResourceWatcher) WithPredicates(predicates ...Predicate) *ResourceWatcher {
+	w.predicates = append(w.predicates, predicates...)
+	return w
+}
+
+// handlers returns the event handlers filtered by the predicates
+func (w *ResourceWatcher) handlers() cache.ResourceEventHandler {
+	if len(w.predicates) == 0 {
+		return w.resourceEventHandlers
+	}
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			for _, predicate := range w.predicates {
+				if !predicate(obj) {
+					return false
+				}
+			}
+			return true
+		},
+		Handler: w.resourceEventHandlers,
+	}
+}
+
 // Watch begins watching the custom resource (TPR/CRD). The call will block until a Done signal is raised during in the context.
 // When the watch has detected a create, update, or delete event, it will handled by the functions in the resourceEventHandlers. After the callback returns, the watch loop will continue for the next event.
 // If the callback returns an error, the error will be logged.
 func (w *ResourceWatcher) Watch(objType runtime.Object, done <-chan struct{}) error {
-	source := cache.NewListWatchFromClient(
-		w.client,
-		w.resource.Plural,
-		w.namespace,
-		fields.Everything())
+	var source *cache.ListWatch
+	if w.source != nil {
+		// copied since paging wraps the funcs of the source
+		lw := *w.source
+		source = &lw
+	} else {
+		source = cache.NewListWatchFromClient(
+			w.client,
+			w.resource.Plural,
+			w.namespace,
+			fields.Everything())
+	}
+	pageListWatch(source, DefaultPageSize)
 	_, controller := cache.NewInformer(
 		source,
 
@@ -71,7 +126,7 @@ func (w *ResourceWatcher) Watch(objType runtime.Object, done <-chan struct{}) er
 		0,
 
 		// Your custom resource event handlers.
-		w.resourceEventHandlers)
+		w.handlers())
 
 	go controller.Run(done)
 	<-done
@@ -0,0 +1,74 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNewBuiltinWatcher(t *testing.T) {
+	context := Context{Clientset: fake.NewSimpleClientset()}
+
+	w, err := NewBuiltinWatcher(context, NodeResource, "ns", cache.ResourceEventHandlerFuncs{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", w.namespace)
+
+	w, err = NewBuiltinWatcher(context, DeploymentResource, "ns", cache.ResourceEventHandlerFuncs{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ns", w.namespace)
+
+	_, err = NewBuiltinWatcher(context, exampleResource, "ns", cache.ResourceEventHandlerFuncs{})
+	assert.EqualError(t, err, "example.com/v1alpha is not a built-in resource")
+	_, err = NewBuiltinWatcher(Context{}, PodResource, "ns", cache.ResourceEventHandlerFuncs{})
+	assert.Error(t, err)
+}
+
+func TestWatcherPredicates(t *testing.T) {
+	var added, deleted []string
+	handlers := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { added = append(added, obj.(*v1.Pod).Name) },
+		UpdateFunc: func(oldObj, newObj interface{}) { added = append(added, newObj.(*v1.Pod).Name) },
+		DeleteFunc: func(obj interface{}) { deleted = append(deleted, obj.(*v1.Pod).Name) },
+	}
+	w := NewWatcher(PodResource, "ns", handlers, nil).
+		WithPredicates(LabelSelectorPredicate(labels.SelectorFromSet(labels.Set{"app": "sample"})))
+
+	sample := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "sample", Labels: map[string]string{"app": "sample"}}}
+	other := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+	w.handlers().OnAdd(sample)
+	w.handlers().OnAdd(other)
+	assert.Equal(t, []string{"sample"}, added)
+
+	// the pod stops matching when its label is removed
+	w.handlers().OnUpdate(sample, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "sample"}})
+	assert.Equal(t, []string{"sample"}, deleted)
+
+	// the tombstones of missed deletes are matched against the last known state
+	var tombstones []string
+	w = NewWatcher(PodResource, "ns", cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) { tombstones = append(tombstones, obj.(cache.DeletedFinalStateUnknown).Key) },
+	}, nil).WithPredicates(LabelSelectorPredicate(labels.SelectorFromSet(labels.Set{"app": "sample"})))
+	w.handlers().OnDelete(cache.DeletedFinalStateUnknown{Key: "ns/sample", Obj: sample})
+	w.handlers().OnDelete(cache.DeletedFinalStateUnknown{Key: "ns/other", Obj: other})
+	assert.Equal(t, []string{"ns/sample"}, tombstones)
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The admission types are defined here rather than imported so the webhooks work with both the
+// admission.k8s.io/v1beta1 and v1 AdmissionReview, which share the same wire format.
+
+// AdmissionReview is the request sent to a webhook by the apiserver and the response sent back
+type AdmissionReview struct {
+	APIVersion string             `json:"apiVersion,omitempty"`
+	Kind       string             `json:"kind,omitempty"`
+	Request    *AdmissionRequest  `json:"request,omitempty"`
+	Response   *AdmissionResponse `json:"response,omitempty"`
+}
+
+// AdmissionRequest describes the operation on an object that is admitted
+type AdmissionRequest struct {
+	UID       string                 `json:"uid"`
+	Kind      AdmissionKind          `json:"kind"`
+	Resource  AdmissionResource      `json:"resource"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Operation string                 `json:"operation"`
+	UserInfo  AdmissionUserInfo      `json:"userInfo"`
+	Object    json.RawMessage        `json:"object,omitempty"`
+	OldObject json.RawMessage        `json:"oldObject,omitempty"`
+	DryRun    *bool                  `json:"dryRun,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+// AdmissionKind is the group, version, and kind of the admitted object
+type AdmissionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// AdmissionResource is the group, version, and resource of the admitted object
+type AdmissionResource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+// AdmissionUserInfo identifies the user requesting the operation
+type AdmissionUserInfo struct {
+	Username string   `json:"username,omitempty"`
+	UID      string   `json:"uid,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// AdmissionResponse is the decision of a webhook
+type AdmissionResponse struct {
+	UID       string         `json:"uid"`
+	Allowed   bool           `json:"allowed"`
+	Result    *metav1.Status `json:"status,omitempty"`
+	Patch     []byte         `json:"patch,omitempty"`
+	PatchType *string        `json:"patchType,omitempty"`
+}
+
+// JSONPatchOperation is a single operation of a JSON patch (RFC 6902) returned by a mutating webhook
+type JSONPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MutateFunc returns the patch operations to apply to the admitted object. Returning an error rejects the request.
+type MutateFunc func(request *AdmissionRequest) ([]JSONPatchOperation, error)
+
+// NewMutatingWebhookHandler creates an http.Handler serving AdmissionReview requests of a mutating webhook.
+// The handler must be served over TLS with a certificate trusted by the webhook configuration.
+func NewMutatingWebhookHandler(mutate MutateFunc) http.Handler {
+	return admissionHandler(func(request *AdmissionRequest) *AdmissionResponse {
+		patch, err := mutate(request)
+		if err != nil {
+			return deniedResponse(err)
+		}
+		response := &AdmissionResponse{Allowed: true}
+		if len(patch) > 0 {
+			data, err := json.Marshal(patch)
+			if err != nil {
+				return deniedResponse(fmt.Errorf("failed to serialize the patch. %+v", err))
+			}
+			patchType := "JSONPatch"
+			response.Patch = data
+			response.PatchType = &patchType
+		}
+		return response
+	})
+}
+
+// ValidateFunc returns an error to reject the admitted request
+type ValidateFunc func(request *AdmissionRequest) error
+
+// NewValidatingWebhookHandler creates an http.Handler serving AdmissionReview requests of a validating webhook.
+// The handler must be served over TLS with a certificate trusted by the webhook configuration.
+func NewValidatingWebhookHandler(validate ValidateFunc) http.Handler {
+	return admissionHandler(func(request *AdmissionRequest) *AdmissionResponse {
+		if err := validate(request); err != nil {
+			return deniedResponse(err)
+		}
+		return &AdmissionResponse{Allowed: true}
+	})
+}
+
+// admissionHandler decodes the AdmissionReview, passes the request to admit, and writes back the response
+func admissionHandler(admit func(request *AdmissionRequest) *AdmissionResponse) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		if contentType := r.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+			http.Error(w, fmt.Sprintf("unsupported content type %q", contentType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read the request. %+v", err), http.StatusBadRequest)
+			return
+		}
+		review := &AdmissionReview{}
+		if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+			http.Error(w, "the request is not an AdmissionReview", http.StatusBadRequest)
+			return
+		}
+
+		response := admit(review.Request)
+		response.UID = review.Request.UID
+		review.Request = nil
+		review.Response = response
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			http.Error(w, fmt.Sprintf("failed to write the response. %+v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+func deniedResponse(err error) *AdmissionResponse {
+	return &AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: err.Error(),
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		},
+	}
+}
+
+// annotationPatch returns the operations adding the annotations to the raw object
+func annotationPatch(raw json.RawMessage, annotations map[string]string) ([]JSONPatchOperation, error) {
+	obj := struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("failed to parse the object. %+v", err)
+	}
+
+	if obj.Metadata.Annotations == nil {
+		return []JSONPatchOperation{{Op: "add", Path: "/metadata/annotations", Value: annotations}}, nil
+	}
+	var patch []JSONPatchOperation
+	for key, value := range annotations {
+		patch = append(patch, JSONPatchOperation{Op: "add", Path: "/metadata/annotations/" + escapeJSONPointer(key), Value: value})
+	}
+	return patch, nil
+}
+
+// escapeJSONPointer escapes a key for use in a JSON pointer path (RFC 6901)
+func escapeJSONPointer(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
+
+// unescapeJSONPointer returns the key of an escaped token of a JSON pointer path
+func unescapeJSONPointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+}
+
+// applyJSONPatch returns the raw object with the add, replace, and remove operations of the patch applied, so that
+// a mutate func chained after another one sees the object as patched by it. Test operations are skipped.
+func applyJSONPatch(raw json.RawMessage, patch []JSONPatchOperation) (json.RawMessage, error) {
+	if len(patch) == 0 {
+		return raw, nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse the object. %+v", err)
+	}
+	for _, operation := range patch {
+		if operation.Op == "test" {
+			continue
+		}
+		if operation.Op != "add" && operation.Op != "replace" && operation.Op != "remove" {
+			return nil, fmt.Errorf("unsupported patch operation %s", operation.Op)
+		}
+		// the value is converted to its JSON types, so that later operations can patch inside it
+		var value interface{}
+		if operation.Value != nil {
+			data, err := json.Marshal(operation.Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize the value of %s. %+v", operation.Path, err)
+			}
+			if err := json.Unmarshal(data, &value); err != nil {
+				return nil, fmt.Errorf("failed to parse the value of %s. %+v", operation.Path, err)
+			}
+		}
+		if operation.Path == "" {
+			doc = value
+			continue
+		}
+		if !strings.HasPrefix(operation.Path, "/") {
+			return nil, fmt.Errorf("invalid patch path %s", operation.Path)
+		}
+		var tokens []string
+		for _, token := range strings.Split(operation.Path[1:], "/") {
+			tokens = append(tokens, unescapeJSONPointer(token))
+		}
+		var err error
+		if doc, err = patchJSONValue(doc, tokens, operation.Op, value); err != nil {
+			return nil, fmt.Errorf("failed to apply the patch of %s. %+v", operation.Path, err)
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// patchJSONValue applies the operation at the path of the tokens below the value and returns the patched value
+func patchJSONValue(doc interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	token, last := tokens[0], len(tokens) == 1
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		if last {
+			if op == "remove" {
+				delete(d, token)
+			} else {
+				d[token] = value
+			}
+			return d, nil
+		}
+		child, ok := d[token]
+		if !ok {
+			return nil, fmt.Errorf("%s does not exist", token)
+		}
+		patched, err := patchJSONValue(child, tokens[1:], op, value)
+		d[token] = patched
+		return d, err
+	case []interface{}:
+		if last && op == "add" && token == "-" {
+			return append(d, value), nil
+		}
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index > len(d) || (index == len(d) && !(last && op == "add")) {
+			return nil, fmt.Errorf("invalid index %s", token)
+		}
+		if !last {
+			patched, err := patchJSONValue(d[index], tokens[1:], op, value)
+			d[index] = patched
+			return d, err
+		}
+		switch op {
+		case "add":
+			d = append(d, nil)
+			copy(d[index+1:], d[index:])
+			d[index] = value
+		case "remove":
+			d = append(d[:index], d[index+1:]...)
+		default:
+			d[index] = value
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("%s is not an object or list", token)
+	}
+}
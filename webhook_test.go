@@ -0,0 +1,76 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotationPatch(t *testing.T) {
+	patch, err := annotationPatch([]byte(`{"metadata":{"name":"a"}}`), map[string]string{"example.com/queued": "5"})
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{{Op: "add", Path: "/metadata/annotations", Value: map[string]string{"example.com/queued": "5"}}}, patch)
+
+	patch, err = annotationPatch([]byte(`{"metadata":{"annotations":{"b":"c"}}}`), map[string]string{"example.com/queued": "5"})
+	assert.NoError(t, err)
+	assert.Equal(t, []JSONPatchOperation{{Op: "add", Path: "/metadata/annotations/example.com~1queued", Value: "5"}}, patch)
+
+	_, err = annotationPatch([]byte(`not json`), nil)
+	assert.Error(t, err)
+}
+
+func TestMutatingWebhookHandler(t *testing.T) {
+	handler := NewMutatingWebhookHandler(func(request *AdmissionRequest) ([]JSONPatchOperation, error) {
+		if request.Name == "denied" {
+			return nil, errors.New("not allowed")
+		}
+		return annotationPatch(request.Object, map[string]string{"a": "b"})
+	})
+
+	review := func(name string) *AdmissionResponse {
+		body := []byte(`{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1","name":"` + name + `","operation":"CREATE","object":{"metadata":{}}}}`)
+		r := httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		result := &AdmissionReview{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+		assert.Equal(t, "1", result.Response.UID)
+		return result.Response
+	}
+
+	response := review("allowed")
+	assert.True(t, response.Allowed)
+	assert.Equal(t, "JSONPatch", *response.PatchType)
+	assert.JSONEq(t, `[{"op":"add","path":"/metadata/annotations","value":{"a":"b"}}]`, string(response.Patch))
+
+	response = review("denied")
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "not allowed", response.Result.Message)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/mutate", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsUtil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// The typed clientset predates the webhook configurations. They are sent as raw JSON, which has the same shape in
+// admissionregistration.k8s.io/v1 and v1beta1.
+const admissionRegistrationGroup = "admissionregistration.k8s.io"
+
+// WebhookType is the kind of admission webhook
+type WebhookType string
+
+const (
+	// MutatingWebhook may change the admitted objects
+	MutatingWebhook WebhookType = "Mutating"
+	// ValidatingWebhook only accepts or rejects the admitted objects
+	ValidatingWebhook WebhookType = "Validating"
+)
+
+// FailurePolicy decides what happens to a request when the webhook cannot be called
+type FailurePolicy string
+
+const (
+	// FailurePolicyFail rejects the request
+	FailurePolicyFail FailurePolicy = "Fail"
+	// FailurePolicyIgnore admits the request
+	FailurePolicyIgnore FailurePolicy = "Ignore"
+)
+
+// WebhookConfiguration describes a webhook admitting custom resources
+type WebhookConfiguration struct {
+	// Name of the configuration and its webhook. Must be a fully qualified name such as "samples.example.com".
+	Name string
+
+	// Type of the webhook
+	Type WebhookType
+
+	// Resources admitted by the webhook
+	Resources []CustomResource
+
+	// Operations admitted by the webhook. Defaults to CREATE and UPDATE.
+	Operations []string
+
+	// Service serving the webhook in the cluster
+	Service WebhookService
+
+	// CABundle is the PEM encoded CA that signed the serving certificate of the webhook
+	CABundle []byte
+
+	// FailurePolicy of the webhook. Defaults to FailurePolicyFail.
+	FailurePolicy FailurePolicy
+
+	// NamespaceSelector is optional and limits the webhook to the objects in matching namespaces
+	NamespaceSelector *metav1.LabelSelector
+}
+
+// WebhookService is the service serving a webhook
+type WebhookService struct {
+	Namespace string
+	Name      string
+	Path      string
+	Port      int32
+}
+
+// webhookConfigurationJSON is the subset of a Mutating/ValidatingWebhookConfiguration the kit writes
+type webhookConfigurationJSON struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   metav1.ObjectMeta `json:"metadata"`
+	Webhooks   []webhookJSON     `json:"webhooks"`
+}
+
+type webhookJSON struct {
+	Name                    string                `json:"name"`
+	ClientConfig            webhookClientConfig   `json:"clientConfig"`
+	Rules                   []webhookRule         `json:"rules"`
+	FailurePolicy           FailurePolicy         `json:"failurePolicy"`
+	NamespaceSelector       *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	SideEffects             string                `json:"sideEffects"`
+	AdmissionReviewVersions []string              `json:"admissionReviewVersions"`
+}
+
+type webhookClientConfig struct {
+	Service  webhookServiceReference `json:"service"`
+	CABundle []byte                  `json:"caBundle,omitempty"`
+}
+
+type webhookServiceReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Path      string `json:"path,omitempty"`
+	Port      int32  `json:"port,omitempty"`
+}
+
+type webhookRule struct {
+	Operations  []string `json:"operations"`
+	APIGroups   []string `json:"apiGroups"`
+	APIVersions []string `json:"apiVersions"`
+	Resources   []string `json:"resources"`
+}
+
+// CreateWebhookConfigurations registers the webhook configurations and waits until the API server serves them.
+// Existing configurations are updated, for example to a new CA bundle. The admissionregistration.k8s.io/v1 API is
+// used when the cluster supports v1 CRDs and v1beta1 otherwise, which requires Kubernetes 1.9 or newer.
+func CreateWebhookConfigurations(context Context, configs []WebhookConfiguration) error {
+	flavor, err := detectAPIFlavor(context)
+	if err != nil {
+		return err
+	}
+	version := "v1beta1"
+	if flavor == ForceCRDv1 {
+		version = "v1"
+	}
+
+	var errs []error
+	for _, config := range configs {
+		outcome, err := createWebhookConfiguration(context, version, config)
+		if err != nil {
+			context.logger().Error(err, "failed to register webhook configuration", "name", config.Name)
+			errs = append(errs, err)
+			continue
+		}
+		context.logger().Info("registered webhook configuration", "name", config.Name, "outcome", outcome)
+	}
+	for _, config := range configs {
+		if err := waitForWebhookConfiguration(context, version, config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errorsUtil.NewAggregate(errs)
+}
+
+func createWebhookConfiguration(context Context, version string, config WebhookConfiguration) (InstallOutcome, error) {
+	obj := newWebhookConfiguration(version, config)
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to serialize webhook configuration %s. %+v", config.Name, err)
+	}
+
+	restcli := context.Clientset.Discovery().RESTClient()
+	_, err = restcli.Post().AbsPath(webhookConfigurationPath(version, config.Type)).
+		SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	if err == nil {
+		return OutcomeCreated, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return OutcomeFailed, fmt.Errorf("failed to create webhook configuration %s. %+v", config.Name, err)
+	}
+
+	// replace the existing configuration, which requires its resourceVersion
+	raw, err := restcli.Get().AbsPath(webhookConfigurationPath(version, config.Type), config.Name).DoRaw()
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to get webhook configuration %s. %+v", config.Name, err)
+	}
+	existing := &webhookConfigurationJSON{}
+	if err := json.Unmarshal(raw, existing); err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to parse webhook configuration %s. %+v", config.Name, err)
+	}
+	obj.Metadata.ResourceVersion = existing.Metadata.ResourceVersion
+	if body, err = json.Marshal(obj); err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to serialize webhook configuration %s. %+v", config.Name, err)
+	}
+	_, err = restcli.Put().AbsPath(webhookConfigurationPath(version, config.Type), config.Name).
+		SetHeader("Content-Type", "application/json").Body(body).DoRaw()
+	if err != nil {
+		return OutcomeFailed, fmt.Errorf("failed to update webhook configuration %s. %+v", config.Name, err)
+	}
+	return OutcomeUpdated, nil
+}
+
+func waitForWebhookConfiguration(context Context, version string, config WebhookConfiguration) error {
+	restcli := context.Clientset.Discovery().RESTClient()
+	err := context.waitStrategy().Wait(context.waitInterval(), context.Timeout, nil, func() (bool, error) {
+		_, err := restcli.Get().AbsPath(webhookConfigurationPath(version, config.Type), config.Name).DoRaw()
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("webhook configuration %s was not admitted. %+v", config.Name, err)
+	}
+	return nil
+}
+
+func newWebhookConfiguration(version string, config WebhookConfiguration) *webhookConfigurationJSON {
+	operations := config.Operations
+	if len(operations) == 0 {
+		operations = []string{"CREATE", "UPDATE"}
+	}
+	failurePolicy := config.FailurePolicy
+	if failurePolicy == "" {
+		failurePolicy = FailurePolicyFail
+	}
+
+	var rules []webhookRule
+	for _, resource := range config.Resources {
+		rules = append(rules, webhookRule{
+			Operations:  operations,
+			APIGroups:   []string{resource.Group},
+			APIVersions: []string{resource.Version},
+			Resources:   []string{resource.Plural},
+		})
+	}
+
+	return &webhookConfigurationJSON{
+		APIVersion: fmt.Sprintf("%s/%s", admissionRegistrationGroup, version),
+		Kind:       string(config.Type) + "WebhookConfiguration",
+		Metadata:   metav1.ObjectMeta{Name: config.Name},
+		Webhooks: []webhookJSON{
+			{
+				Name: config.Name,
+				ClientConfig: webhookClientConfig{
+					Service: webhookServiceReference{
+						Namespace: config.Service.Namespace,
+						Name:      config.Service.Name,
+						Path:      config.Service.Path,
+						Port:      config.Service.Port,
+					},
+					CABundle: config.CABundle,
+				},
+				Rules:                   rules,
+				FailurePolicy:           failurePolicy,
+				NamespaceSelector:       config.NamespaceSelector,
+				SideEffects:             "None",
+				AdmissionReviewVersions: []string{"v1", "v1beta1"},
+			},
+		},
+	}
+}
+
+func webhookConfigurationPath(version string, webhookType WebhookType) string {
+	return fmt.Sprintf("/apis/%s/%s/%swebhookconfigurations", admissionRegistrationGroup, version, strings.ToLower(string(webhookType)))
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWebhookConfiguration(t *testing.T) {
+	resource := CustomResource{Name: "sample", Plural: "samples", Group: "example.com", Version: "v1alpha1", Kind: "Sample"}
+	config := WebhookConfiguration{
+		Name:      "samples.example.com",
+		Type:      MutatingWebhook,
+		Resources: []CustomResource{resource},
+		Service:   WebhookService{Namespace: "operators", Name: "sample-operator", Path: "/mutate"},
+		CABundle:  []byte("ca"),
+	}
+
+	obj := newWebhookConfiguration("v1", config)
+	assert.Equal(t, "admissionregistration.k8s.io/v1", obj.APIVersion)
+	assert.Equal(t, "MutatingWebhookConfiguration", obj.Kind)
+	assert.Equal(t, FailurePolicyFail, obj.Webhooks[0].FailurePolicy)
+	assert.Equal(t, []webhookRule{{
+		Operations:  []string{"CREATE", "UPDATE"},
+		APIGroups:   []string{"example.com"},
+		APIVersions: []string{"v1alpha1"},
+		Resources:   []string{"samples"},
+	}}, obj.Webhooks[0].Rules)
+
+	raw, err := json.Marshal(obj)
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), `"caBundle":"Y2E="`)
+
+	assert.Equal(t, "/apis/admissionregistration.k8s.io/v1beta1/validatingwebhookconfigurations",
+		webhookConfigurationPath("v1beta1", ValidatingWebhook))
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kit for Kubernetes operators
+package operatorkit
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ErrQuotaExceeded is returned when a write would grow a work directory beyond its quota
+var ErrQuotaExceeded = errors.New("the work directory quota is exceeded")
+
+// clusterScopedDir holds the work directories of cluster scoped resources, whose keys have no namespace. Namespace
+// names cannot start with an underscore, so it never collides with a namespace.
+const clusterScopedDir = "_cluster"
+
+// WorkDirOptions configures the work directories
+type WorkDirOptions struct {
+	// Quota is the maximum size in bytes of the files in each work directory. Zero means no quota.
+	Quota int64
+
+	// ClearOnStart removes all work directories left by a previous run of the operator
+	ClearOnStart bool
+}
+
+// WorkDirs manages a local work directory per custom resource, for operators that stage files such as rendered
+// configs or backups. The directories are removed when their custom resource is deleted, see RemoveOnDeletion,
+// and directories of resources deleted while the operator was not running are removed by Prune.
+type WorkDirs struct {
+	root    string
+	options WorkDirOptions
+	lock    sync.Mutex
+}
+
+// NewWorkDirs creates the root of the work directories
+func NewWorkDirs(root string, options WorkDirOptions) (*WorkDirs, error) {
+	if options.ClearOnStart {
+		if err := os.RemoveAll(root); err != nil {
+			return nil, fmt.Errorf("failed to clear work directories in %s. %+v", root, err)
+		}
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create work directories in %s. %+v", root, err)
+	}
+	return &WorkDirs{root: root, options: options}, nil
+}
+
+// Dir creates the work directory of the custom resource with the namespace/name key if it is missing and returns
+// its path
+func (w *WorkDirs) Dir(key string) (string, error) {
+	dir, err := w.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create work directory of %s. %+v", key, err)
+	}
+	return dir, nil
+}
+
+// Usage returns the size in bytes of the files in the work directory of the custom resource
+func (w *WorkDirs) Usage(key string) (int64, error) {
+	dir, err := w.path(key)
+	if err != nil {
+		return 0, err
+	}
+	return dirSize(dir)
+}
+
+// WriteFile writes the file at the relative path in the work directory of the custom resource. It fails with
+// ErrQuotaExceeded instead if the directory would grow beyond the quota. Files written to the directory by other
+// means count toward the quota of later writes.
+func (w *WorkDirs) WriteFile(key, name string, data []byte) error {
+	dir, err := w.Dir(key)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, filepath.Clean("/"+name))
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.options.Quota > 0 {
+		usage, err := dirSize(dir)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(path); err == nil {
+			usage -= info.Size()
+		}
+		if usage+int64(len(data)) > w.options.Quota {
+			return ErrQuotaExceeded
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory of %s. %+v", name, err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s of %s. %+v", name, key, err)
+	}
+	return nil
+}
+
+// Remove removes the work directory of the custom resource
+func (w *WorkDirs) Remove(key string) error {
+	dir, err := w.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove work directory of %s. %+v", key, err)
+	}
+	namespaceDir := filepath.Dir(dir)
+	if namespaceDir != w.root {
+		// only succeeds once the namespace has no work directories left
+		os.Remove(namespaceDir)
+	}
+	return nil
+}
+
+// RemoveOnDeletion returns a finalize func for HandleDeletion that removes the work directory of the deleted
+// custom resource
+func (w *WorkDirs) RemoveOnDeletion() FinalizeFunc {
+	return func(obj runtime.Object, namespaceTerminating bool) error {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return err
+		}
+		return w.Remove(key)
+	}
+}
+
+// Prune removes the work directories of custom resources that are not in the store, such as resources deleted
+// while the operator was not running. Call it once the store of the controller has synced.
+func (w *WorkDirs) Prune(store cache.Store) error {
+	namespaces, err := ioutil.ReadDir(w.root)
+	if err != nil {
+		return fmt.Errorf("failed to read work directories. %+v", err)
+	}
+	for _, namespace := range namespaces {
+		if !namespace.IsDir() {
+			continue
+		}
+		dirs, err := ioutil.ReadDir(filepath.Join(w.root, namespace.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read work directories. %+v", err)
+		}
+		for _, dir := range dirs {
+			key := namespace.Name() + "/" + dir.Name()
+			if namespace.Name() == clusterScopedDir {
+				key = dir.Name()
+			}
+			if _, exists, err := store.GetByKey(key); err != nil || exists {
+				continue
+			}
+			if err := w.Remove(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// path returns the work directory of the key, rejecting keys that would escape the root
+func (w *WorkDirs) path(key string) (string, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return "", err
+	}
+	if namespace == "" {
+		namespace = clusterScopedDir
+	}
+	for _, part := range []string{namespace, name} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return filepath.Join(w.root, namespace, name), nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s. %+v", dir, err)
+	}
+	return size, nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorkit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWorkDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "workdirs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	dirs, err := NewWorkDirs(root, WorkDirOptions{Quota: 10})
+	assert.NoError(t, err)
+
+	assert.NoError(t, dirs.WriteFile("ns/a", "config/app.conf", []byte("123456")))
+	usage, err := dirs.Usage("ns/a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), usage)
+
+	// replacing a file only counts the new size
+	assert.NoError(t, dirs.WriteFile("ns/a", "config/app.conf", []byte("1234567890")))
+	assert.Equal(t, ErrQuotaExceeded, dirs.WriteFile("ns/a", "backup", []byte("1")))
+	assert.NoError(t, dirs.WriteFile("ns/b", "backup", []byte("1")))
+	assert.NoError(t, dirs.WriteFile("cluster-wide", "backup", []byte("1")))
+
+	// paths cannot escape the work directory
+	assert.NoError(t, dirs.WriteFile("ns/b", "../../escape", []byte("1")))
+	_, err = os.Stat(filepath.Join(root, "ns", "b", "escape"))
+	assert.NoError(t, err)
+	_, err = dirs.Dir("ns/..")
+	assert.Error(t, err)
+
+	// the directory of a deleted resource is removed
+	finalize := dirs.RemoveOnDeletion()
+	assert.NoError(t, finalize(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "a"}}, false))
+	_, err = os.Stat(filepath.Join(root, "ns", "a"))
+	assert.True(t, os.IsNotExist(err))
+
+	// directories of resources missing from the store are pruned after a restart
+	dirs, err = NewWorkDirs(root, WorkDirOptions{})
+	assert.NoError(t, err)
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cluster-wide"}})
+	assert.NoError(t, dirs.Prune(store))
+	_, err = os.Stat(filepath.Join(root, "ns"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(root, clusterScopedDir, "cluster-wide", "backup"))
+	assert.NoError(t, err)
+
+	dirs, err = NewWorkDirs(root, WorkDirOptions{ClearOnStart: true})
+	assert.NoError(t, err)
+	entries, err := ioutil.ReadDir(root)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}